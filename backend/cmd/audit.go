@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"daybook-backend/config"
+	"daybook-backend/database"
+	"daybook-backend/models"
+	"daybook-backend/services"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm/logger"
+)
+
+// Audit runs `daybook audit [-user <id|username|email>] [-repair] [-json]`.
+// It exits with 1 when mismatches were found and not repaired.
+func Audit(cfg *config.Config, args []string) int {
+	flags := flag.NewFlagSet("audit", flag.ContinueOnError)
+	user := flags.String("user", "", "Only audit this user (ID, username or email)")
+	repair := flags.Bool("repair", false, "Correct every mismatch in a single database transaction")
+	asJSON := flags.Bool("json", false, "Print the report as JSON")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	database.LogLevel = logger.Silent
+	if err := database.InitDatabase(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize database: %v\n", err)
+		return 2
+	}
+	defer database.CloseDatabase()
+
+	userID := uuid.Nil
+	if *user != "" {
+		var found models.User
+		query := database.DB.Where("username = ? OR email = ?", *user, *user)
+		if parsedID, err := uuid.Parse(*user); err == nil {
+			query = database.DB.Where("id = ?", parsedID)
+		}
+		if err := query.First(&found).Error; err != nil {
+			fmt.Fprintf(os.Stderr, "User %q not found\n", *user)
+			return 2
+		}
+		userID = found.ID
+	}
+
+	report, err := services.AuditBalances(database.DB, userID, *repair)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Balance audit failed: %v\n", err)
+		return 2
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return 2
+		}
+	} else {
+		printAuditReport(os.Stdout, report)
+	}
+
+	if !report.Consistent() && !report.Repaired {
+		return 1
+	}
+	return 0
+}
+
+func printAuditReport(w io.Writer, report *services.AuditReport) {
+	fmt.Fprintf(w, "Audited %d accounts, %d credit cards and %d goals: %d mismatches\n",
+		report.Accounts, report.CreditCards, report.Goals, len(report.Mismatches))
+
+	for _, m := range report.Mismatches {
+		fmt.Fprintf(w, "\n%s %q (%s) of user %s\n", m.Kind, m.Name, m.ID, m.UserID)
+		fmt.Fprintf(w, "  stored %s, expected %s", m.Stored, m.Expected)
+		if m.Journal != nil {
+			fmt.Fprintf(w, ", journal %s", *m.Journal)
+		}
+		fmt.Fprintf(w, ", difference %s %s\n", m.Difference, m.Currency)
+
+		for _, t := range m.Transactions {
+			deleted := ""
+			if t.Deleted {
+				deleted = " (deleted)"
+			}
+			fmt.Fprintf(w, "  transaction %s %s %s %s %q%s: expected %s, journal %s\n",
+				t.ID, t.Date.Format("2006-01-02"), t.Type, t.Amount, t.Description, deleted, t.Expected, t.Journal)
+		}
+		for _, a := range m.Adjustments {
+			fmt.Fprintf(w, "  %s entry %s %s %s %q\n", a.Kind, a.EntryID, a.Date.Format("2006-01-02"), a.Amount, a.Description)
+		}
+	}
+
+	if report.Repaired {
+		fmt.Fprintln(w, "\nAll mismatches were repaired")
+	} else if !report.Consistent() {
+		fmt.Fprintln(w, "\nRun with -repair to correct them")
+	}
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"daybook-backend/config"
+)
+
+// Command is a maintenance task run from the command line instead of the
+// server, e.g. `daybook audit`
+type Command struct {
+	Name  string
+	Usage string
+	Run   func(cfg *config.Config, args []string) int // Returns the exit code
+}
+
+// Commands lists the available subcommands
+var Commands = []Command{
+	{Name: "audit", Usage: "Recompute balances from history and optionally repair them", Run: Audit},
+	{Name: "migrate", Usage: "Apply, roll back or list database migrations", Run: Migrate},
+}
+
+// Run executes the named subcommand and returns its exit code
+func Run(cfg *config.Config, name string, args []string) int {
+	for _, command := range Commands {
+		if command.Name == name {
+			return command.Run(cfg, args)
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Unknown command %q\n\nCommands:\n", name)
+	for _, command := range Commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", command.Name, command.Usage)
+	}
+	return 2
+}
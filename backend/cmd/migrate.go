@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"daybook-backend/config"
+	"daybook-backend/database"
+
+	"gorm.io/gorm/logger"
+)
+
+const migrateUsage = `Usage: daybook-backend migrate <command>
+
+Commands:
+  up                Apply every pending migration
+  down [-steps N]   Roll back the last N applied migrations (default 1)
+  status            List migrations and when they were applied
+  schema            Print the SQL that creates the tables of the current models
+`
+
+// Migrate runs `daybook migrate up|down|status|schema`
+func Migrate(cfg *config.Config, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	if args[0] == "schema" {
+		statements, err := database.ModelSchema()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to generate schema: %v\n", err)
+			return 2
+		}
+		for _, statement := range statements {
+			fmt.Println(statement + ";")
+		}
+		return 0
+	}
+
+	flags := flag.NewFlagSet("migrate "+args[0], flag.ContinueOnError)
+	steps := flags.Int("steps", 1, "Number of migrations to roll back")
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	database.LogLevel = logger.Warn
+	if err := database.Connect(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 2
+	}
+	defer database.CloseDatabase()
+
+	switch args[0] {
+	case "up":
+		applied, err := database.MigrateUp(database.DB)
+		for _, migration := range applied {
+			fmt.Printf("Applied %d %s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed: %v\n", err)
+			return 1
+		}
+		if len(applied) == 0 {
+			fmt.Println("Database is up to date")
+		}
+
+	case "down":
+		if *steps < 1 {
+			fmt.Fprintln(os.Stderr, "-steps must be at least 1")
+			return 2
+		}
+		rolledBack, err := database.MigrateDown(database.DB, *steps)
+		for _, migration := range rolledBack {
+			fmt.Printf("Rolled back %d %s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed: %v\n", err)
+			return 1
+		}
+		if len(rolledBack) == 0 {
+			fmt.Println("No migrations to roll back")
+		}
+
+	case "status":
+		states, err := database.MigrationStatus(database.DB)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed: %v\n", err)
+			return 1
+		}
+		for _, state := range states {
+			applied := "pending"
+			if state.AppliedAt != nil {
+				applied = "applied " + state.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%4d  %-30s %s\n", state.Version, state.Name, applied)
+		}
+
+	default:
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return 2
+	}
+	return 0
+}
@@ -11,11 +11,13 @@ import (
 )
 
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	Database DatabaseConfig `mapstructure:"database"`
-	Redis    RedisConfig    `mapstructure:"redis"`
-	JWT      JWTConfig      `mapstructure:"jwt"`
-	CORS     CORSConfig     `mapstructure:"cors"`
+	Server    ServerConfig    `mapstructure:"server"`
+	Database  DatabaseConfig  `mapstructure:"database"`
+	Redis     RedisConfig     `mapstructure:"redis"`
+	JWT       JWTConfig       `mapstructure:"jwt"`
+	CORS      CORSConfig      `mapstructure:"cors"`
+	Scheduler SchedulerConfig `mapstructure:"scheduler"`
+	Notify    NotifyConfig    `mapstructure:"notify"`
 }
 
 type ServerConfig struct {
@@ -31,6 +33,8 @@ type DatabaseConfig struct {
 	DBName   string `mapstructure:"dbname"`
 	SSLMode  string `mapstructure:"sslmode"`
 	TimeZone string `mapstructure:"timezone"`
+	// Apply pending migrations on start instead of `migrate up`
+	MigrateOnStart bool `mapstructure:"migrate_on_start"`
 }
 
 type RedisConfig struct {
@@ -54,6 +58,25 @@ type CORSConfig struct {
 	MaxAge           int      `mapstructure:"max_age"`
 }
 
+type SchedulerConfig struct {
+	Enabled         bool `mapstructure:"enabled"`
+	IntervalMinutes int  `mapstructure:"interval_minutes"`
+}
+
+// NotifyConfig configures how notifications leave the server. Email is
+// delivered only when an SMTP host is set.
+type NotifyConfig struct {
+	SMTPHost       string `mapstructure:"smtp_host"`
+	SMTPPort       string `mapstructure:"smtp_port"`
+	SMTPUsername   string `mapstructure:"smtp_username"`
+	SMTPPassword   string `mapstructure:"smtp_password"`
+	SMTPFrom       string `mapstructure:"smtp_from"`
+	WebhookTimeout int    `mapstructure:"webhook_timeout"` // Seconds
+	// Let webhooks reach loopback, private and link-local addresses, for
+	// testing against local receivers. Off by default.
+	WebhookAllowPrivate bool `mapstructure:"webhook_allow_private"`
+}
+
 var AppConfig *Config
 
 func LoadConfig() (*Config, error) {
@@ -84,13 +107,14 @@ func LoadConfig() (*Config, error) {
 			Mode: getEnv("SERVER_MODE", "debug"),
 		},
 		Database: DatabaseConfig{
-			Host:     getEnv("DB_HOST", "localhost"),
-			Port:     getEnv("DB_PORT", "5432"),
-			User:     getEnv("DB_USER", "postgres"),
-			Password: getEnv("DB_PASSWORD", ""),
-			DBName:   getEnv("DB_NAME", "daybook"),
-			SSLMode:  getEnv("DB_SSLMODE", "disable"),
-			TimeZone: getEnv("DB_TIMEZONE", "UTC"),
+			Host:           getEnv("DB_HOST", "localhost"),
+			Port:           getEnv("DB_PORT", "5432"),
+			User:           getEnv("DB_USER", "postgres"),
+			Password:       getEnv("DB_PASSWORD", ""),
+			DBName:         getEnv("DB_NAME", "daybook"),
+			SSLMode:        getEnv("DB_SSLMODE", "disable"),
+			TimeZone:       getEnv("DB_TIMEZONE", "UTC"),
+			MigrateOnStart: getEnv("DB_MIGRATE_ON_START", "true") == "true",
 		},
 		Redis: RedisConfig{
 			Host:     getEnv("REDIS_HOST", "localhost"),
@@ -110,6 +134,19 @@ func LoadConfig() (*Config, error) {
 			AllowCredentials: getEnv("CORS_ALLOW_CREDENTIALS", "true") == "true",
 			MaxAge:           parseIntWithDefault(getEnv("CORS_MAX_AGE", "12"), 12),
 		},
+		Scheduler: SchedulerConfig{
+			Enabled:         getEnv("SCHEDULER_ENABLED", "true") == "true",
+			IntervalMinutes: parseIntWithDefault(getEnv("SCHEDULER_INTERVAL_MINUTES", "60"), 60),
+		},
+		Notify: NotifyConfig{
+			SMTPHost:            getEnv("SMTP_HOST", ""),
+			SMTPPort:            getEnv("SMTP_PORT", "587"),
+			SMTPUsername:        getEnv("SMTP_USERNAME", ""),
+			SMTPPassword:        getEnv("SMTP_PASSWORD", ""),
+			SMTPFrom:            getEnv("SMTP_FROM", "daybook@localhost"),
+			WebhookTimeout:      parseIntWithDefault(getEnv("NOTIFY_WEBHOOK_TIMEOUT_SECONDS", "10"), 10),
+			WebhookAllowPrivate: getEnv("NOTIFY_WEBHOOK_ALLOW_PRIVATE", "false") == "true",
+		},
 	}
 
 	AppConfig = config
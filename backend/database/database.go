@@ -18,53 +18,95 @@ var (
 	DB          *gorm.DB
 	RedisClient *redis.Client
 	ctx         = context.Background()
+
+	// LogLevel controls SQL logging. Commands that print to stdout lower it
+	// before calling InitDatabase.
+	LogLevel = logger.Info
 )
 
+// migratedModels lists every model with a table. The baseline migration
+// was generated from them with ModelSchema.
+var migratedModels = []interface{}{
+	&models.User{},
+	&models.Account{},
+	&models.AccountType{},
+	&models.Transaction{},
+	&models.TransactionSplit{},
+	&models.TransactionRule{},
+	&models.SavedSearch{},
+	&models.NetWorthSnapshot{},
+	&models.JournalEntry{},
+	&models.Posting{},
+	&models.RecurringTransaction{},
+	&models.Tag{},
+	&models.Category{},
+	&models.ExchangeRate{},
+	&models.ImportMapping{},
+	&models.ImportBatch{},
+	&models.ImportRow{},
+	&models.CreditCard{},
+	&models.CreditCardTransaction{},
+	&models.CreditCardPayment{},
+	&models.Statement{},
+	&models.Reward{},
+	&models.RewardRule{},
+	&models.RewardRedemption{},
+	&models.Bill{},
+	&models.BillPayment{},
+	&models.Budget{},
+	&models.EnvelopeAssignment{},
+	&models.EnvelopeMove{},
+	&models.Reconciliation{},
+	&models.ReconciliationTransaction{},
+	&models.Goal{},
+	&models.GoalHolding{},
+	&models.GoalContribution{},
+	&models.Settings{},
+	&models.Notification{},
+	&models.NotificationDelivery{},
+}
+
+// InitDatabase connects to PostgreSQL and brings the schema up to date.
+// With migrations on start disabled it refuses to run against a database
+// with pending migrations instead.
 func InitDatabase(cfg *config.Config) error {
+	if err := Connect(cfg); err != nil {
+		return err
+	}
+
+	if !cfg.Database.MigrateOnStart {
+		pending, err := PendingMigrations(DB)
+		if err != nil {
+			return fmt.Errorf("failed to check migrations: %w", err)
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("database has %d pending migrations, run `daybook-backend migrate up` first", len(pending))
+		}
+		return nil
+	}
+
+	applied, err := MigrateUp(DB)
+	if err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+	log.Printf("Database migrated successfully (%d migrations applied)\n", len(applied))
+
+	return nil
+}
+
+// Connect opens the PostgreSQL connection without touching the schema
+func Connect(cfg *config.Config) error {
 	var err error
 
-	// Initialize PostgreSQL
 	dsn := cfg.Database.GetDSN()
 	log.Printf("Connecting to database: %s\n", dsn)
 	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
+		Logger: logger.Default.LogMode(LogLevel),
 	})
 	if err != nil {
 		return fmt.Errorf("failed to connect to database: %w", err)
 	}
 
-	// Enable UUID extension
-	DB.Exec("CREATE EXTENSION IF NOT EXISTS \"uuid-ossp\"")
-
-	// Auto-migrate all models
-	err = DB.AutoMigrate(
-		&models.User{},
-		&models.Account{},
-		&models.AccountType{},
-		&models.Transaction{},
-		&models.RecurringTransaction{},
-		&models.Tag{},
-		&models.CreditCard{},
-		&models.CreditCardTransaction{},
-		&models.CreditCardPayment{},
-		&models.Statement{},
-		&models.Reward{},
-		&models.Bill{},
-		&models.BillPayment{},
-		&models.Budget{},
-		&models.Reconciliation{},
-		&models.ReconciliationTransaction{},
-		&models.Goal{},
-		&models.GoalHolding{},
-		&models.GoalContribution{},
-		&models.Settings{},
-	)
-	if err != nil {
-		return fmt.Errorf("failed to migrate database: %w", err)
-	}
-
-	log.Println("Database migrated successfully")
-
 	return nil
 }
 
@@ -0,0 +1,275 @@
+package database
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"daybook-backend/models"
+
+	"gorm.io/gorm"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migration is one versioned change to the schema or data. Up and Down run
+// in a transaction together with the update of schema_migrations.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error // nil when the migration cannot be undone
+}
+
+// Migrations lists every migration in the order it is applied. Versions are
+// never reused or reordered once released; a change to the models needs a
+// new migration at the end.
+var Migrations = []Migration{
+	{Version: 1, Name: "baseline", Up: baselineUp, Down: sqlMigration("0001_baseline.down.sql")},
+	{Version: 2, Name: "backfill_journal", Up: backfillJournal, Down: noop},
+	{Version: 3, Name: "credit_card_billing_cycle", Up: sqlMigration("0003_credit_card_billing_cycle.up.sql"), Down: sqlMigration("0003_credit_card_billing_cycle.down.sql")},
+	{Version: 4, Name: "credit_card_interest", Up: sqlMigration("0004_credit_card_interest.up.sql"), Down: sqlMigration("0004_credit_card_interest.down.sql")},
+	{Version: 5, Name: "credit_card_rewards", Up: sqlMigration("0005_credit_card_rewards.up.sql"), Down: sqlMigration("0005_credit_card_rewards.down.sql")},
+	{Version: 6, Name: "bill_autopay", Up: sqlMigration("0006_bill_autopay.up.sql"), Down: sqlMigration("0006_bill_autopay.down.sql")},
+	{Version: 7, Name: "notifications", Up: sqlMigration("0007_notifications.up.sql"), Down: sqlMigration("0007_notifications.down.sql")},
+	{Version: 8, Name: "settings_time_zone", Up: sqlMigration("0008_settings_time_zone.up.sql"), Down: sqlMigration("0008_settings_time_zone.down.sql")},
+	{Version: 9, Name: "envelopes", Up: sqlMigration("0009_envelopes.up.sql"), Down: sqlMigration("0009_envelopes.down.sql")},
+	{Version: 10, Name: "transaction_splits", Up: sqlMigration("0010_transaction_splits.up.sql"), Down: sqlMigration("0010_transaction_splits.down.sql")},
+	{Version: 11, Name: "transaction_rules", Up: sqlMigration("0011_transaction_rules.up.sql"), Down: sqlMigration("0011_transaction_rules.down.sql")},
+	{Version: 12, Name: "transaction_search", Up: sqlMigration("0012_transaction_search.up.sql"), Down: sqlMigration("0012_transaction_search.down.sql")},
+	{Version: 13, Name: "keyset_pagination", Up: sqlMigration("0013_keyset_pagination.up.sql"), Down: sqlMigration("0013_keyset_pagination.down.sql")},
+	{Version: 14, Name: "net_worth_snapshots", Up: sqlMigration("0014_net_worth_snapshots.up.sql"), Down: sqlMigration("0014_net_worth_snapshots.down.sql")},
+	{Version: 15, Name: "bill_payment_due_date", Up: sqlMigration("0015_bill_payment_due_date.up.sql"), Down: sqlMigration("0015_bill_payment_due_date.down.sql")},
+}
+
+// SchemaMigration records an applied migration
+type SchemaMigration struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false" json:"version"`
+	Name      string    `gorm:"not null" json:"name"`
+	AppliedAt time.Time `gorm:"not null" json:"appliedAt"`
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// MigrationState is a migration and whether it has been applied
+type MigrationState struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"appliedAt"` // Nil while pending
+}
+
+// ErrIrreversibleMigration is returned when rolling back a migration that
+// has no down step
+var ErrIrreversibleMigration = errors.New("migration cannot be rolled back")
+
+// migrationLockID identifies the advisory lock that keeps two processes from
+// migrating at the same time
+const migrationLockID = 727384011
+
+const createSchemaMigrationsSQL = `CREATE TABLE IF NOT EXISTS "schema_migrations" (
+	"version" bigint PRIMARY KEY,
+	"name" text NOT NULL,
+	"applied_at" timestamptz NOT NULL
+)`
+
+// MigrateUp applies every pending migration in order and returns the ones
+// it applied. Each migration commits on its own, so a failure leaves the
+// earlier ones applied.
+func MigrateUp(db *gorm.DB) ([]Migration, error) {
+	if err := db.Exec(createSchemaMigrationsSQL).Error; err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	var applied []Migration
+	for _, migration := range Migrations {
+		ran := false
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", migrationLockID).Error; err != nil {
+				return err
+			}
+
+			// Another process may have applied it while we waited
+			var count int64
+			if err := tx.Model(&SchemaMigration{}).Where("version = ?", migration.Version).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return nil
+			}
+
+			log.Printf("Applying migration %d %s\n", migration.Version, migration.Name)
+			if err := migration.Up(tx); err != nil {
+				return err
+			}
+			ran = true
+			return tx.Create(&SchemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return applied, fmt.Errorf("migration %d %s: %w", migration.Version, migration.Name, err)
+		}
+		if ran {
+			applied = append(applied, migration)
+		}
+	}
+	return applied, nil
+}
+
+// MigrateDown rolls back the most recently applied migrations, at most
+// steps of them, newest first, and returns the ones it rolled back
+func MigrateDown(db *gorm.DB, steps int) ([]Migration, error) {
+	if err := db.Exec(createSchemaMigrationsSQL).Error; err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	var rolledBack []Migration
+	for i := len(Migrations) - 1; i >= 0 && len(rolledBack) < steps; i-- {
+		migration := Migrations[i]
+		ran := false
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", migrationLockID).Error; err != nil {
+				return err
+			}
+
+			var count int64
+			if err := tx.Model(&SchemaMigration{}).Where("version = ?", migration.Version).Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 {
+				return nil
+			}
+			if migration.Down == nil {
+				return ErrIrreversibleMigration
+			}
+
+			log.Printf("Rolling back migration %d %s\n", migration.Version, migration.Name)
+			if err := migration.Down(tx); err != nil {
+				return err
+			}
+			ran = true
+			return tx.Where("version = ?", migration.Version).Delete(&SchemaMigration{}).Error
+		})
+		if err != nil {
+			return rolledBack, fmt.Errorf("migration %d %s: %w", migration.Version, migration.Name, err)
+		}
+		if ran {
+			rolledBack = append(rolledBack, migration)
+		}
+	}
+	return rolledBack, nil
+}
+
+// MigrationStatus lists every known migration with the time it was applied
+func MigrationStatus(db *gorm.DB) ([]MigrationState, error) {
+	if err := db.Exec(createSchemaMigrationsSQL).Error; err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	var records []SchemaMigration
+	if err := db.Find(&records).Error; err != nil {
+		return nil, err
+	}
+	appliedAt := make(map[int]time.Time, len(records))
+	for _, record := range records {
+		appliedAt[record.Version] = record.AppliedAt
+	}
+
+	states := make([]MigrationState, len(Migrations))
+	for i, migration := range Migrations {
+		states[i] = MigrationState{Version: migration.Version, Name: migration.Name}
+		if at, ok := appliedAt[migration.Version]; ok {
+			states[i].AppliedAt = &at
+		}
+	}
+	return states, nil
+}
+
+// PendingMigrations returns the migrations not applied yet
+func PendingMigrations(db *gorm.DB) ([]Migration, error) {
+	states, err := MigrationStatus(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for i, state := range states {
+		if state.AppliedAt == nil {
+			pending = append(pending, Migrations[i])
+		}
+	}
+	return pending, nil
+}
+
+// sqlMigration runs the statements of an embedded SQL file one at a time
+func sqlMigration(name string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		content, err := migrationFiles.ReadFile("migrations/" + name)
+		if err != nil {
+			return err
+		}
+
+		for _, statement := range splitStatements(string(content)) {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// splitStatements splits a SQL file on the semicolons that end a line and
+// drops comment lines
+func splitStatements(content string) []string {
+	var statements []string
+	var current strings.Builder
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSuffix(strings.TrimSpace(current.String()), ";"))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+	return statements
+}
+
+// baselineUp creates the schema. Databases created by AutoMigrate already
+// have most of it; their float money columns are converted first, the
+// columns added since are created and every other statement of the
+// baseline is a no-op for them.
+func baselineUp(tx *gorm.DB) error {
+	if err := ConvertMoneyColumns(tx, migratedModels); err != nil {
+		return err
+	}
+	return sqlMigration("0001_baseline.up.sql")(tx)
+}
+
+// backfillJournal records the transactions that predate the journal
+func backfillJournal(tx *gorm.DB) error {
+	recorded, err := models.BackfillJournal(tx)
+	if err != nil {
+		return err
+	}
+	if recorded > 0 {
+		log.Printf("Backfilled %d journal entries\n", recorded)
+	}
+	return nil
+}
+
+// noop is the down step of data migrations whose changes are removed along
+// with the tables by an earlier migration's down step
+func noop(*gorm.DB) error {
+	return nil
+}
@@ -0,0 +1,299 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"daybook-backend/models"
+
+	"gorm.io/gorm/schema"
+)
+
+// schemaState is the tables and columns of a simulated database
+type schemaState map[string]map[string]bool
+
+var (
+	createTablePattern = regexp.MustCompile(`(?s)^CREATE TABLE IF NOT EXISTS "(\w+)" \((.*)\)$`)
+	tableColumnPattern = regexp.MustCompile(`(?:^|,)\s*"(\w+)" `)
+	addColumnPattern   = regexp.MustCompile(`^ALTER TABLE "(\w+)" ADD COLUMN (IF NOT EXISTS )?"(\w+)"`)
+	dropColumnPattern  = regexp.MustCompile(`^ALTER TABLE "(\w+)" DROP COLUMN (IF EXISTS )?"(\w+)"`)
+	createIndexPattern = regexp.MustCompile(`(?s)^CREATE (?:UNIQUE )?INDEX IF NOT EXISTS "\w+" ON "(\w+)"(?: USING \w+)? \((.*)\)$`)
+	dropTablePattern   = regexp.MustCompile(`^DROP TABLE IF EXISTS "(\w+)"`)
+	updatePattern      = regexp.MustCompile(`(?s)^UPDATE "(\w+)" SET (.*)$`)
+	writePattern       = regexp.MustCompile(`^(?:INSERT INTO|DELETE FROM) "(\w+)"`)
+	identifierPattern  = regexp.MustCompile(`"(\w+)"`)
+)
+
+// apply simulates a migration statement, failing where Postgres would: on a
+// missing table or column, or on adding a column that already exists
+func (s schemaState) apply(statement string) error {
+	table := func(name string) (map[string]bool, error) {
+		if columns, ok := s[name]; ok {
+			return columns, nil
+		}
+		return nil, fmt.Errorf("table %s does not exist", name)
+	}
+	requireColumns := func(name, expression string) error {
+		columns, err := table(name)
+		if err != nil {
+			return err
+		}
+		for _, match := range identifierPattern.FindAllStringSubmatch(expression, -1) {
+			if !columns[match[1]] {
+				return fmt.Errorf("column %s.%s does not exist", name, match[1])
+			}
+		}
+		return nil
+	}
+
+	switch {
+	case strings.HasPrefix(statement, "CREATE EXTENSION"), strings.HasPrefix(statement, "DROP INDEX"):
+		return nil
+
+	case createTablePattern.MatchString(statement):
+		match := createTablePattern.FindStringSubmatch(statement)
+		if _, ok := s[match[1]]; ok {
+			return nil
+		}
+		columns := map[string]bool{}
+		for _, column := range tableColumnPattern.FindAllStringSubmatch(match[2], -1) {
+			columns[column[1]] = true
+		}
+		s[match[1]] = columns
+
+	case addColumnPattern.MatchString(statement):
+		match := addColumnPattern.FindStringSubmatch(statement)
+		columns, err := table(match[1])
+		if err != nil {
+			return err
+		}
+		if columns[match[3]] && match[2] == "" {
+			return fmt.Errorf("column %s.%s already exists", match[1], match[3])
+		}
+		columns[match[3]] = true
+
+	case dropColumnPattern.MatchString(statement):
+		match := dropColumnPattern.FindStringSubmatch(statement)
+		columns, err := table(match[1])
+		if err != nil {
+			return err
+		}
+		if !columns[match[3]] && match[2] == "" {
+			return fmt.Errorf("column %s.%s does not exist", match[1], match[3])
+		}
+		delete(columns, match[3])
+
+	case createIndexPattern.MatchString(statement):
+		match := createIndexPattern.FindStringSubmatch(statement)
+		return requireColumns(match[1], match[2])
+
+	case dropTablePattern.MatchString(statement):
+		delete(s, dropTablePattern.FindStringSubmatch(statement)[1])
+
+	case updatePattern.MatchString(statement):
+		match := updatePattern.FindStringSubmatch(statement)
+		return requireColumns(match[1], match[2])
+
+	case writePattern.MatchString(statement):
+		_, err := table(writePattern.FindStringSubmatch(statement)[1])
+		return err
+
+	default:
+		return fmt.Errorf("statement not understood by the simulation")
+	}
+	return nil
+}
+
+// applyFile simulates every statement of an embedded migration file
+func (s schemaState) applyFile(t *testing.T, name string) {
+	t.Helper()
+	content, err := migrationFiles.ReadFile("migrations/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, statement := range splitStatements(string(content)) {
+		if err := s.apply(statement); err != nil {
+			t.Fatalf("%s: %v in\n%s", name, err, statement)
+		}
+	}
+}
+
+// requireModels fails unless every column of the models exists
+func (s schemaState) requireModels(t *testing.T, when string, models ...interface{}) {
+	t.Helper()
+	for _, model := range models {
+		table, columns := modelColumns(t, model)
+		for _, column := range columns {
+			if !s[table][column] {
+				t.Errorf("%s: column %s.%s does not exist", when, table, column)
+			}
+		}
+	}
+}
+
+// modelColumns returns the table of a model and the columns AutoMigrate
+// would create for it
+func modelColumns(t *testing.T, model interface{}) (string, []string) {
+	t.Helper()
+	parsed, err := schema.Parse(model, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var columns []string
+	for _, field := range parsed.Fields {
+		if field.DBName != "" && !field.IgnoreMigration {
+			columns = append(columns, field.DBName)
+		}
+	}
+	return parsed.Table, columns
+}
+
+// sqlFileName returns the name of the file of a migration step
+func sqlFileName(migration Migration, direction string) string {
+	return fmt.Sprintf("%04d_%s.%s.sql", migration.Version, migration.Name, direction)
+}
+
+// migrateUp simulates every migration on the schema. The baseline's money
+// conversion only changes column types and is left out. The journal
+// backfill is Go code, so in its place the columns it reads and writes are
+// checked to exist.
+func (s schemaState) migrateUp(t *testing.T) {
+	t.Helper()
+	for _, migration := range Migrations {
+		if migration.Version != 2 {
+			s.applyFile(t, sqlFileName(migration, "up"))
+			continue
+		}
+
+		when := "before the journal backfill"
+		s.requireModels(t, when, &models.Transaction{}, &models.JournalEntry{}, &models.Posting{})
+		for table, columns := range map[string][]string{
+			"accounts":     {"id", "user_id", "name", "currency", "balance", "deleted_at"},
+			"credit_cards": {"id", "user_id", "name", "currency", "current_balance", "deleted_at"},
+		} {
+			for _, column := range columns {
+				if !s[table][column] {
+					t.Errorf("%s: column %s.%s does not exist", when, table, column)
+				}
+			}
+		}
+	}
+}
+
+func TestMigrationVersions(t *testing.T) {
+	names := make(map[string]bool)
+	for i, migration := range Migrations {
+		if migration.Version != i+1 {
+			t.Errorf("migration %s has version %d, want %d", migration.Name, migration.Version, i+1)
+		}
+		if names[migration.Name] {
+			t.Errorf("migration name %s is used twice", migration.Name)
+		}
+		names[migration.Name] = true
+		if migration.Up == nil {
+			t.Errorf("migration %d %s has no up step", migration.Version, migration.Name)
+		}
+	}
+
+	// Every migration but the journal backfill has both files, and every
+	// file belongs to a migration
+	files := make(map[string]bool)
+	for _, migration := range Migrations {
+		if migration.Version == 2 {
+			continue
+		}
+		for _, direction := range []string{"up", "down"} {
+			files[sqlFileName(migration, direction)] = true
+		}
+	}
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := make(map[string]bool)
+	for _, entry := range entries {
+		found[entry.Name()] = true
+		if !files[entry.Name()] {
+			t.Errorf("migrations/%s belongs to no migration", entry.Name())
+		}
+	}
+	for name := range files {
+		if !found[name] {
+			t.Errorf("migrations/%s is missing", name)
+		}
+	}
+}
+
+// TestMigrationsOnNewDatabase checks that migrating an empty database builds
+// exactly the schema of the models and that rolling back removes it again
+func TestMigrationsOnNewDatabase(t *testing.T) {
+	state := schemaState{}
+	state.migrateUp(t)
+
+	want := schemaState{}
+	for _, model := range migratedModels {
+		table, columns := modelColumns(t, model)
+		want[table] = map[string]bool{}
+		for _, column := range columns {
+			want[table][column] = true
+		}
+	}
+	for table, columns := range state {
+		if want[table] == nil {
+			t.Errorf("table %s has no model", table)
+			continue
+		}
+		for column := range columns {
+			if !want[table][column] {
+				t.Errorf("column %s.%s has no model field", table, column)
+			}
+		}
+	}
+	state.requireModels(t, "after migrating", migratedModels...)
+
+	for i := len(Migrations) - 1; i >= 0; i-- {
+		if Migrations[i].Version != 2 {
+			state.applyFile(t, sqlFileName(Migrations[i], "down"))
+		}
+	}
+	if len(state) > 0 {
+		tables := make([]string, 0, len(state))
+		for table := range state {
+			tables = append(tables, table)
+		}
+		sort.Strings(tables)
+		t.Errorf("tables left after rolling back every migration: %v", tables)
+	}
+}
+
+// TestMigrationsOnPreSeriesSchema checks that a database AutoMigrate created
+// before versioned migrations, as recorded in testdata, reaches the schema
+// of the models. Its existing tables are left alone by CREATE TABLE IF NOT
+// EXISTS, so columns added since must be added explicitly.
+func TestMigrationsOnPreSeriesSchema(t *testing.T) {
+	content, err := os.ReadFile("testdata/pre_series_schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tables map[string][]string
+	if err := json.Unmarshal(content, &tables); err != nil {
+		t.Fatal(err)
+	}
+	state := schemaState{}
+	for table, columns := range tables {
+		state[table] = map[string]bool{}
+		for _, column := range columns {
+			state[table][column] = true
+		}
+	}
+
+	state.migrateUp(t)
+	state.requireModels(t, "after migrating", migratedModels...)
+}
@@ -0,0 +1,27 @@
+DROP TABLE IF EXISTS "settings";
+DROP TABLE IF EXISTS "goal_contributions";
+DROP TABLE IF EXISTS "goal_holdings";
+DROP TABLE IF EXISTS "goals";
+DROP TABLE IF EXISTS "reconciliation_transactions";
+DROP TABLE IF EXISTS "reconciliations";
+DROP TABLE IF EXISTS "budgets";
+DROP TABLE IF EXISTS "bill_payments";
+DROP TABLE IF EXISTS "bills";
+DROP TABLE IF EXISTS "rewards";
+DROP TABLE IF EXISTS "statements";
+DROP TABLE IF EXISTS "credit_card_payments";
+DROP TABLE IF EXISTS "credit_card_transactions";
+DROP TABLE IF EXISTS "credit_cards";
+DROP TABLE IF EXISTS "import_rows";
+DROP TABLE IF EXISTS "import_batches";
+DROP TABLE IF EXISTS "import_mappings";
+DROP TABLE IF EXISTS "exchange_rates";
+DROP TABLE IF EXISTS "categories";
+DROP TABLE IF EXISTS "tags";
+DROP TABLE IF EXISTS "recurring_transactions";
+DROP TABLE IF EXISTS "postings";
+DROP TABLE IF EXISTS "journal_entries";
+DROP TABLE IF EXISTS "transactions";
+DROP TABLE IF EXISTS "account_types";
+DROP TABLE IF EXISTS "accounts";
+DROP TABLE IF EXISTS "users";
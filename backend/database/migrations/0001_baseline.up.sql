@@ -0,0 +1,164 @@
+-- Baseline: the schema AutoMigrate maintained before versioned migrations.
+-- Every statement is idempotent so that databases created by AutoMigrate
+-- adopt the baseline unchanged. Columns added to tables that AutoMigrate
+-- already created are added explicitly, since CREATE TABLE IF NOT EXISTS
+-- leaves existing tables alone.
+
+CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+
+CREATE TABLE IF NOT EXISTS "users" ("id" uuid DEFAULT uuid_generate_v4(),"username" text NOT NULL,"email" text NOT NULL,"password" text NOT NULL,"full_name" text,"role" text DEFAULT 'user',"last_login" timestamptz,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_users_deleted_at" ON "users" ("deleted_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_email" ON "users" ("email");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_username" ON "users" ("username");
+
+CREATE TABLE IF NOT EXISTS "accounts" ("id" uuid DEFAULT uuid_generate_v4(),"user_id" uuid NOT NULL,"name" text NOT NULL,"type" text NOT NULL,"initial_balance" numeric(19,4) DEFAULT 0,"balance" numeric(19,4) DEFAULT 0,"currency" text DEFAULT 'BDT',"description" text,"institution" text,"account_number" text,"last_reconciled" timestamptz,"reconciliation_difference" numeric(19,4) DEFAULT 0,"active" boolean DEFAULT true,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_accounts_deleted_at" ON "accounts" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_accounts_user_id" ON "accounts" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "account_types" ("id" uuid DEFAULT uuid_generate_v4(),"user_id" uuid NOT NULL,"name" text NOT NULL,"icon" text,"description" text,"active" boolean DEFAULT true,"sort_order" bigint DEFAULT 0,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_account_types_deleted_at" ON "account_types" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_account_types_user_id" ON "account_types" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "transactions" ("id" uuid DEFAULT uuid_generate_v4(),"user_id" uuid NOT NULL,"account_id" uuid NOT NULL,"to_account_id" uuid,"type" text NOT NULL,"amount" numeric(19,4) NOT NULL,"to_amount" numeric(19,4),"exchange_rate" decimal,"category_id" text NOT NULL,"date" timestamptz NOT NULL,"description" text,"tags" jsonb,"savings_goal_id" uuid,"fixed_deposit_id" uuid,"investment_id" uuid,"recurring_id" uuid,"credit_card_id" uuid,"attachments" jsonb,"reconciled" boolean DEFAULT false,"reconciliation_id" uuid,"external_id" text,"import_batch_id" uuid,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,PRIMARY KEY ("id"));
+ALTER TABLE "transactions" ADD COLUMN IF NOT EXISTS "to_amount" numeric(19,4);
+ALTER TABLE "transactions" ADD COLUMN IF NOT EXISTS "exchange_rate" decimal;
+ALTER TABLE "transactions" ADD COLUMN IF NOT EXISTS "external_id" text;
+ALTER TABLE "transactions" ADD COLUMN IF NOT EXISTS "import_batch_id" uuid;
+CREATE INDEX IF NOT EXISTS "idx_transactions_deleted_at" ON "transactions" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_transactions_import_batch_id" ON "transactions" ("import_batch_id");
+CREATE INDEX IF NOT EXISTS "idx_transactions_external_id" ON "transactions" ("external_id");
+CREATE INDEX IF NOT EXISTS "idx_transactions_reconciled" ON "transactions" ("reconciled");
+CREATE INDEX IF NOT EXISTS "idx_transactions_investment_id" ON "transactions" ("investment_id");
+CREATE INDEX IF NOT EXISTS "idx_transactions_fixed_deposit_id" ON "transactions" ("fixed_deposit_id");
+CREATE INDEX IF NOT EXISTS "idx_transactions_savings_goal_id" ON "transactions" ("savings_goal_id");
+CREATE INDEX IF NOT EXISTS "idx_transactions_date" ON "transactions" ("date");
+CREATE INDEX IF NOT EXISTS "idx_transactions_category_id" ON "transactions" ("category_id");
+CREATE INDEX IF NOT EXISTS "idx_transactions_to_account_id" ON "transactions" ("to_account_id");
+CREATE INDEX IF NOT EXISTS "idx_transactions_account_id" ON "transactions" ("account_id");
+CREATE INDEX IF NOT EXISTS "idx_transactions_user_id" ON "transactions" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "journal_entries" ("id" uuid DEFAULT uuid_generate_v4(),"user_id" uuid NOT NULL,"transaction_id" uuid,"kind" text NOT NULL,"date" timestamptz NOT NULL,"description" text,"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_journal_entries_date" ON "journal_entries" ("date");
+CREATE INDEX IF NOT EXISTS "idx_journal_entries_transaction_id" ON "journal_entries" ("transaction_id");
+CREATE INDEX IF NOT EXISTS "idx_journal_entries_user_id" ON "journal_entries" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "postings" ("id" uuid DEFAULT uuid_generate_v4(),"entry_id" uuid NOT NULL,"user_id" uuid NOT NULL,"ledger_type" text NOT NULL,"ledger_id" uuid,"ledger_key" text,"amount" numeric(19,4) NOT NULL,"currency" text NOT NULL,"created_at" timestamptz,PRIMARY KEY ("id"),CONSTRAINT "fk_journal_entries_postings" FOREIGN KEY ("entry_id") REFERENCES "journal_entries"("id"));
+CREATE INDEX IF NOT EXISTS "idx_posting_ledger" ON "postings" ("ledger_type","ledger_id");
+CREATE INDEX IF NOT EXISTS "idx_postings_user_id" ON "postings" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_postings_entry_id" ON "postings" ("entry_id");
+
+CREATE TABLE IF NOT EXISTS "recurring_transactions" ("id" uuid DEFAULT uuid_generate_v4(),"user_id" uuid NOT NULL,"template_id" uuid DEFAULT uuid_generate_v4(),"template_user_id" uuid NOT NULL,"template_account_id" uuid NOT NULL,"template_to_account_id" uuid,"template_type" text NOT NULL,"template_amount" numeric(19,4) NOT NULL,"template_to_amount" numeric(19,4),"template_exchange_rate" decimal,"template_category_id" text NOT NULL,"template_date" timestamptz NOT NULL,"template_description" text,"template_tags" jsonb,"template_savings_goal_id" uuid,"template_fixed_deposit_id" uuid,"template_investment_id" uuid,"template_recurring_id" uuid,"template_credit_card_id" uuid,"template_attachments" jsonb,"template_reconciled" boolean DEFAULT false,"template_reconciliation_id" uuid,"template_external_id" text,"template_import_batch_id" uuid,"template_created_at" timestamptz,"template_updated_at" timestamptz,"template_deleted_at" timestamptz,"frequency" text NOT NULL,"start_date" timestamptz NOT NULL,"end_date" timestamptz,"last_processed" timestamptz,"enabled" boolean DEFAULT true,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,PRIMARY KEY ("id","template_id"));
+ALTER TABLE "recurring_transactions" ADD COLUMN IF NOT EXISTS "template_to_amount" numeric(19,4);
+ALTER TABLE "recurring_transactions" ADD COLUMN IF NOT EXISTS "template_exchange_rate" decimal;
+ALTER TABLE "recurring_transactions" ADD COLUMN IF NOT EXISTS "template_external_id" text;
+ALTER TABLE "recurring_transactions" ADD COLUMN IF NOT EXISTS "template_import_batch_id" uuid;
+CREATE INDEX IF NOT EXISTS "idx_recurring_transactions_deleted_at" ON "recurring_transactions" ("template_deleted_at","deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_recurring_transactions_import_batch_id" ON "recurring_transactions" ("template_import_batch_id");
+CREATE INDEX IF NOT EXISTS "idx_recurring_transactions_external_id" ON "recurring_transactions" ("template_external_id");
+CREATE INDEX IF NOT EXISTS "idx_recurring_transactions_reconciled" ON "recurring_transactions" ("template_reconciled");
+CREATE INDEX IF NOT EXISTS "idx_recurring_transactions_investment_id" ON "recurring_transactions" ("template_investment_id");
+CREATE INDEX IF NOT EXISTS "idx_recurring_transactions_fixed_deposit_id" ON "recurring_transactions" ("template_fixed_deposit_id");
+CREATE INDEX IF NOT EXISTS "idx_recurring_transactions_savings_goal_id" ON "recurring_transactions" ("template_savings_goal_id");
+CREATE INDEX IF NOT EXISTS "idx_recurring_transactions_date" ON "recurring_transactions" ("template_date");
+CREATE INDEX IF NOT EXISTS "idx_recurring_transactions_category_id" ON "recurring_transactions" ("template_category_id");
+CREATE INDEX IF NOT EXISTS "idx_recurring_transactions_to_account_id" ON "recurring_transactions" ("template_to_account_id");
+CREATE INDEX IF NOT EXISTS "idx_recurring_transactions_account_id" ON "recurring_transactions" ("template_account_id");
+CREATE INDEX IF NOT EXISTS "idx_recurring_transactions_user_id" ON "recurring_transactions" ("user_id","template_user_id");
+
+CREATE TABLE IF NOT EXISTS "tags" ("id" uuid DEFAULT uuid_generate_v4(),"user_id" uuid NOT NULL,"name" text NOT NULL,"color" text,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_tags_deleted_at" ON "tags" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_tags_user_id" ON "tags" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "categories" ("id" uuid DEFAULT uuid_generate_v4(),"user_id" uuid NOT NULL,"parent_id" uuid,"key" text NOT NULL,"name" text NOT NULL,"kind" text NOT NULL,"icon" text,"color" text,"is_system" boolean DEFAULT false,"active" boolean DEFAULT true,"sort_order" bigint DEFAULT 0,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_categories_deleted_at" ON "categories" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_categories_key" ON "categories" ("key");
+CREATE INDEX IF NOT EXISTS "idx_categories_parent_id" ON "categories" ("parent_id");
+CREATE INDEX IF NOT EXISTS "idx_categories_user_id" ON "categories" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "exchange_rates" ("id" uuid DEFAULT uuid_generate_v4(),"user_id" uuid NOT NULL,"base_currency" varchar(3) NOT NULL,"quote_currency" varchar(3) NOT NULL,"rate" numeric(24,10) NOT NULL,"date" date NOT NULL,"source" text DEFAULT 'manual',"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_exchange_rate_pair_date" ON "exchange_rates" ("user_id","base_currency","quote_currency","date");
+
+CREATE TABLE IF NOT EXISTS "import_mappings" ("id" uuid DEFAULT uuid_generate_v4(),"user_id" uuid NOT NULL,"account_id" uuid NOT NULL,"date_column" text,"amount_column" text,"debit_column" text,"credit_column" text,"description_column" text,"category_column" text,"reference_column" text,"date_format" text,"decimal_separator" text DEFAULT '.',"delimiter" text DEFAULT ',',"has_header" boolean,"skip_rows" bigint DEFAULT 0,"negate_amounts" boolean DEFAULT false,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_import_mappings_account_id" ON "import_mappings" ("account_id");
+CREATE INDEX IF NOT EXISTS "idx_import_mappings_user_id" ON "import_mappings" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "import_batches" ("id" uuid DEFAULT uuid_generate_v4(),"user_id" uuid NOT NULL,"account_id" uuid NOT NULL,"file_name" text NOT NULL,"format" text NOT NULL,"status" text DEFAULT 'preview',"total_rows" bigint,"error_rows" bigint,"duplicate_rows" bigint,"imported_rows" bigint,"committed_at" timestamptz,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_import_batches_deleted_at" ON "import_batches" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_import_batches_status" ON "import_batches" ("status");
+CREATE INDEX IF NOT EXISTS "idx_import_batches_account_id" ON "import_batches" ("account_id");
+CREATE INDEX IF NOT EXISTS "idx_import_batches_user_id" ON "import_batches" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "import_rows" ("id" uuid DEFAULT uuid_generate_v4(),"batch_id" uuid NOT NULL,"row_number" bigint NOT NULL,"date" timestamptz,"type" text,"amount" numeric(19,4),"description" text,"category_id" text,"external_id" text,"errors" jsonb,"duplicate" text,"duplicate_of_id" uuid,"skip" boolean DEFAULT false,"transaction_id" uuid,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"),CONSTRAINT "fk_import_batches_rows" FOREIGN KEY ("batch_id") REFERENCES "import_batches"("id"));
+CREATE INDEX IF NOT EXISTS "idx_import_rows_batch_id" ON "import_rows" ("batch_id");
+
+CREATE TABLE IF NOT EXISTS "credit_cards" ("id" uuid DEFAULT uuid_generate_v4(),"user_id" uuid NOT NULL,"name" text NOT NULL,"last_four_digits" text,"card_network" text,"credit_limit" numeric(19,4) NOT NULL,"current_balance" numeric(19,4) DEFAULT 0,"currency" text DEFAULT 'BDT',"apr" decimal,"due_date" timestamptz,"statement_date" timestamptz,"minimum_payment" numeric(19,4) DEFAULT 0,"last_payment_date" timestamptz,"last_payment_amount" numeric(19,4) DEFAULT 0,"rewards_program" text,"active" boolean DEFAULT true,"notes" text,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,PRIMARY KEY ("id"));
+ALTER TABLE "credit_cards" ADD COLUMN IF NOT EXISTS "currency" text DEFAULT 'BDT';
+CREATE INDEX IF NOT EXISTS "idx_credit_cards_deleted_at" ON "credit_cards" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_credit_cards_user_id" ON "credit_cards" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "credit_card_transactions" ("id" uuid DEFAULT uuid_generate_v4(),"user_id" uuid NOT NULL,"card_id" uuid NOT NULL,"transaction_id" uuid,"category_id" text,"amount" numeric(19,4) NOT NULL,"description" text,"merchant" text,"date" timestamptz NOT NULL,"type" text NOT NULL,"tags" jsonb,"attachments" jsonb,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_credit_card_transactions_deleted_at" ON "credit_card_transactions" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_credit_card_transactions_transaction_id" ON "credit_card_transactions" ("transaction_id");
+CREATE INDEX IF NOT EXISTS "idx_credit_card_transactions_card_id" ON "credit_card_transactions" ("card_id");
+CREATE INDEX IF NOT EXISTS "idx_credit_card_transactions_user_id" ON "credit_card_transactions" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "credit_card_payments" ("id" uuid DEFAULT uuid_generate_v4(),"user_id" uuid NOT NULL,"card_id" uuid NOT NULL,"account_id" uuid NOT NULL,"amount" numeric(19,4) NOT NULL,"payment_date" timestamptz NOT NULL,"description" text,"transaction_id" uuid,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_credit_card_payments_deleted_at" ON "credit_card_payments" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_credit_card_payments_transaction_id" ON "credit_card_payments" ("transaction_id");
+CREATE INDEX IF NOT EXISTS "idx_credit_card_payments_account_id" ON "credit_card_payments" ("account_id");
+CREATE INDEX IF NOT EXISTS "idx_credit_card_payments_card_id" ON "credit_card_payments" ("card_id");
+CREATE INDEX IF NOT EXISTS "idx_credit_card_payments_user_id" ON "credit_card_payments" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "statements" ("id" uuid DEFAULT uuid_generate_v4(),"user_id" uuid NOT NULL,"card_id" uuid NOT NULL,"statement_date" timestamptz NOT NULL,"due_date" timestamptz NOT NULL,"opening_balance" numeric(19,4),"closing_balance" numeric(19,4),"minimum_payment" numeric(19,4),"total_charges" numeric(19,4),"total_payments" numeric(19,4),"interest_charged" numeric(19,4),"paid" boolean DEFAULT false,"paid_date" timestamptz,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_statements_deleted_at" ON "statements" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_statements_card_id" ON "statements" ("card_id");
+CREATE INDEX IF NOT EXISTS "idx_statements_user_id" ON "statements" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "rewards" ("id" uuid DEFAULT uuid_generate_v4(),"user_id" uuid NOT NULL,"card_id" uuid NOT NULL,"type" text,"amount" numeric(19,4),"description" text,"earned_date" timestamptz NOT NULL,"redeemed" boolean DEFAULT false,"redeemed_at" timestamptz,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_rewards_deleted_at" ON "rewards" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_rewards_card_id" ON "rewards" ("card_id");
+CREATE INDEX IF NOT EXISTS "idx_rewards_user_id" ON "rewards" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "bills" ("id" uuid DEFAULT uuid_generate_v4(),"user_id" uuid NOT NULL,"name" text NOT NULL,"category" text NOT NULL,"amount" numeric(19,4) NOT NULL,"frequency" text NOT NULL,"start_date" timestamptz NOT NULL,"due_day" bigint,"last_paid_date" timestamptz,"last_paid_amount" numeric(19,4) DEFAULT 0,"auto_pay" boolean DEFAULT false,"reminder_days" bigint DEFAULT 3,"active" boolean DEFAULT true,"notes" text,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_bills_deleted_at" ON "bills" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_bills_user_id" ON "bills" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "bill_payments" ("id" uuid DEFAULT uuid_generate_v4(),"user_id" uuid NOT NULL,"bill_id" uuid NOT NULL,"amount" numeric(19,4) NOT NULL,"payment_date" timestamptz NOT NULL,"account_id" uuid,"notes" text,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_bill_payments_deleted_at" ON "bill_payments" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_bill_payments_payment_date" ON "bill_payments" ("payment_date");
+CREATE INDEX IF NOT EXISTS "idx_bill_payments_bill_id" ON "bill_payments" ("bill_id");
+CREATE INDEX IF NOT EXISTS "idx_bill_payments_user_id" ON "bill_payments" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "budgets" ("id" uuid DEFAULT uuid_generate_v4(),"user_id" uuid NOT NULL,"category_id" text NOT NULL,"amount" numeric(19,4) NOT NULL,"period" text NOT NULL,"custom_start_date" timestamptz,"custom_end_date" timestamptz,"rollover" boolean DEFAULT false,"alert_threshold" decimal DEFAULT 80,"enabled" boolean DEFAULT true,"notes" text,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_budgets_deleted_at" ON "budgets" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_budgets_category_id" ON "budgets" ("category_id");
+CREATE INDEX IF NOT EXISTS "idx_budgets_user_id" ON "budgets" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "reconciliations" ("id" uuid DEFAULT uuid_generate_v4(),"user_id" uuid NOT NULL,"account_id" uuid NOT NULL,"reconciliation_date" timestamptz NOT NULL,"statement_balance" numeric(19,4) NOT NULL,"book_balance" numeric(19,4) NOT NULL,"difference" numeric(19,4) NOT NULL,"notes" text,"status" varchar(20) DEFAULT 'pending',"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,PRIMARY KEY ("id"),CONSTRAINT "fk_reconciliations_account" FOREIGN KEY ("account_id") REFERENCES "accounts"("id"));
+CREATE INDEX IF NOT EXISTS "idx_reconciliations_deleted_at" ON "reconciliations" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_reconciliations_account_id" ON "reconciliations" ("account_id");
+CREATE INDEX IF NOT EXISTS "idx_reconciliations_user_id" ON "reconciliations" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "reconciliation_transactions" ("id" uuid DEFAULT uuid_generate_v4(),"reconciliation_id" uuid NOT NULL,"transaction_id" uuid NOT NULL,"created_at" timestamptz,PRIMARY KEY ("id"),CONSTRAINT "fk_reconciliation_transactions_transaction" FOREIGN KEY ("transaction_id") REFERENCES "transactions"("id"),CONSTRAINT "fk_reconciliations_transactions" FOREIGN KEY ("reconciliation_id") REFERENCES "reconciliations"("id"));
+CREATE INDEX IF NOT EXISTS "idx_reconciliation_transactions_transaction_id" ON "reconciliation_transactions" ("transaction_id");
+CREATE INDEX IF NOT EXISTS "idx_reconciliation_transactions_reconciliation_id" ON "reconciliation_transactions" ("reconciliation_id");
+
+CREATE TABLE IF NOT EXISTS "goals" ("id" uuid DEFAULT uuid_generate_v4(),"user_id" uuid NOT NULL,"name" text NOT NULL,"description" text,"icon" text,"color" text,"category" text,"priority" text,"target_amount" numeric(19,4) NOT NULL,"current_amount" numeric(19,4) DEFAULT 0,"target_date" timestamptz,"monthly_contribution" numeric(19,4),"status" text DEFAULT 'active',"achieved" boolean DEFAULT false,"achieved_date" timestamptz,"last_contribution" numeric(19,4),"last_contribution_date" timestamptz,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_goals_deleted_at" ON "goals" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_goals_user_id" ON "goals" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "goal_holdings" ("id" uuid DEFAULT uuid_generate_v4(),"user_id" uuid NOT NULL,"goal_id" uuid NOT NULL,"name" text NOT NULL,"type" text NOT NULL,"status" text DEFAULT 'active',"purchase_date" timestamptz NOT NULL,"amount" numeric(19,4) NOT NULL,"current_value" numeric(19,4),"institution" text,"account_number" text,"interest_rate" decimal,"maturity_date" timestamptz,"maturity_amount" numeric(19,4),"tenure_months" bigint,"symbol" text,"quantity" decimal,"cost_basis" numeric(19,4),"current_price" numeric(19,4),"monthly_deposit" numeric(19,4),"details" jsonb,"transaction_id" uuid,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,PRIMARY KEY ("id"),CONSTRAINT "fk_goals_holdings" FOREIGN KEY ("goal_id") REFERENCES "goals"("id"));
+CREATE INDEX IF NOT EXISTS "idx_goal_holdings_deleted_at" ON "goal_holdings" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_goal_holdings_type" ON "goal_holdings" ("type");
+CREATE INDEX IF NOT EXISTS "idx_goal_holdings_goal_id" ON "goal_holdings" ("goal_id");
+CREATE INDEX IF NOT EXISTS "idx_goal_holdings_user_id" ON "goal_holdings" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "goal_contributions" ("id" uuid DEFAULT uuid_generate_v4(),"user_id" uuid NOT NULL,"goal_id" uuid NOT NULL,"holding_id" uuid,"type" text NOT NULL,"amount" numeric(19,4) NOT NULL,"date" timestamptz NOT NULL,"notes" text,"transaction_id" uuid NOT NULL,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,PRIMARY KEY ("id"),CONSTRAINT "fk_goals_contributions" FOREIGN KEY ("goal_id") REFERENCES "goals"("id"));
+CREATE INDEX IF NOT EXISTS "idx_goal_contributions_deleted_at" ON "goal_contributions" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_goal_contributions_date" ON "goal_contributions" ("date");
+CREATE INDEX IF NOT EXISTS "idx_goal_contributions_goal_id" ON "goal_contributions" ("goal_id");
+CREATE INDEX IF NOT EXISTS "idx_goal_contributions_user_id" ON "goal_contributions" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "settings" ("id" uuid DEFAULT uuid_generate_v4(),"user_id" uuid NOT NULL,"currency" text DEFAULT 'BDT',"dark_mode" boolean DEFAULT false,"date_format" text DEFAULT 'MM/DD/YYYY',"first_day_of_week" bigint DEFAULT 0,"language" text DEFAULT 'en',"notif_push" boolean,"notif_email" boolean,"notif_budget_alerts" boolean,"notif_bill_reminders" boolean,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_settings_deleted_at" ON "settings" ("deleted_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_settings_user_id" ON "settings" ("user_id");
@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS "idx_statement_card_date";
+ALTER TABLE "statements" DROP COLUMN IF EXISTS "generated";
+ALTER TABLE "statements" DROP COLUMN IF EXISTS "total_refunds";
+ALTER TABLE "statements" DROP COLUMN IF EXISTS "total_fees";
+ALTER TABLE "statements" DROP COLUMN IF EXISTS "period_start";
+
+ALTER TABLE "credit_cards" DROP COLUMN IF EXISTS "min_payment_floor";
+ALTER TABLE "credit_cards" DROP COLUMN IF EXISTS "min_payment_percent";
+ALTER TABLE "credit_cards" DROP COLUMN IF EXISTS "grace_period_days";
+ALTER TABLE "credit_cards" DROP COLUMN IF EXISTS "statement_day";
@@ -0,0 +1,14 @@
+-- Billing cycle settings on credit cards and the totals of generated statements
+
+ALTER TABLE "credit_cards" ADD COLUMN "statement_day" bigint;
+ALTER TABLE "credit_cards" ADD COLUMN "grace_period_days" bigint;
+ALTER TABLE "credit_cards" ADD COLUMN "min_payment_percent" decimal;
+ALTER TABLE "credit_cards" ADD COLUMN "min_payment_floor" numeric(19,4);
+UPDATE "credit_cards" SET "statement_day" = 0, "grace_period_days" = 0, "min_payment_percent" = 0, "min_payment_floor" = 0;
+
+ALTER TABLE "statements" ADD COLUMN "period_start" timestamptz;
+ALTER TABLE "statements" ADD COLUMN "total_fees" numeric(19,4);
+ALTER TABLE "statements" ADD COLUMN "total_refunds" numeric(19,4);
+ALTER TABLE "statements" ADD COLUMN "generated" boolean;
+UPDATE "statements" SET "total_fees" = 0, "total_refunds" = 0, "generated" = false;
+CREATE INDEX IF NOT EXISTS "idx_statement_card_date" ON "statements" ("card_id","statement_date");
@@ -0,0 +1,6 @@
+DELETE FROM "categories" WHERE "is_system" = true AND "key" IN ('credit_card_interest', 'credit_card_fee');
+
+ALTER TABLE "statements" DROP COLUMN IF EXISTS "late_fee_assessed";
+ALTER TABLE "statements" DROP COLUMN IF EXISTS "late_fee_charged";
+
+ALTER TABLE "credit_cards" DROP COLUMN IF EXISTS "late_fee";
@@ -0,0 +1,19 @@
+-- Late fee setting on credit cards, late fee tracking on statements and the
+-- system categories for interest and fee charges
+
+ALTER TABLE "credit_cards" ADD COLUMN "late_fee" numeric(19,4);
+UPDATE "credit_cards" SET "late_fee" = 0;
+
+ALTER TABLE "statements" ADD COLUMN "late_fee_charged" numeric(19,4);
+ALTER TABLE "statements" ADD COLUMN "late_fee_assessed" boolean;
+-- Statements already past their due date are not charged retroactively
+UPDATE "statements" SET "late_fee_charged" = 0, "late_fee_assessed" = "due_date" < NOW();
+
+-- Users without any categories yet are seeded with the full default set later
+INSERT INTO "categories" ("id", "user_id", "key", "name", "kind", "icon", "color", "is_system", "active", "sort_order", "created_at", "updated_at")
+SELECT uuid_generate_v4(), u."id", k."key", k."name", 'expense', '💳', '#ef4444', true, true,
+	(SELECT COALESCE(MAX(c."sort_order"), 0) FROM "categories" c WHERE c."user_id" = u."id") + k."offset", NOW(), NOW()
+FROM "users" u
+CROSS JOIN (VALUES ('credit_card_interest', 'Credit Card Interest', 1), ('credit_card_fee', 'Credit Card Fees', 2)) AS k("key", "name", "offset")
+WHERE EXISTS (SELECT 1 FROM "categories" c WHERE c."user_id" = u."id")
+	AND NOT EXISTS (SELECT 1 FROM "categories" c WHERE c."user_id" = u."id" AND c."key" = k."key");
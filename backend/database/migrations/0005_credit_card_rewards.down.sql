@@ -0,0 +1,14 @@
+DELETE FROM "categories" WHERE "is_system" = true AND "key" = 'credit_card_rewards';
+
+DROP TABLE IF EXISTS "reward_redemptions";
+DROP TABLE IF EXISTS "reward_rules";
+
+DROP INDEX IF EXISTS "idx_rewards_transaction_id";
+ALTER TABLE "rewards" DROP COLUMN IF EXISTS "rule_id";
+ALTER TABLE "rewards" DROP COLUMN IF EXISTS "transaction_id";
+ALTER TABLE "rewards" DROP COLUMN IF EXISTS "redeemed_amount";
+ALTER TABLE "rewards" DROP COLUMN IF EXISTS "expires_at";
+
+ALTER TABLE "credit_cards" DROP COLUMN IF EXISTS "reward_expiry_months";
+ALTER TABLE "credit_cards" DROP COLUMN IF EXISTS "reward_rate";
+ALTER TABLE "credit_cards" DROP COLUMN IF EXISTS "reward_type";
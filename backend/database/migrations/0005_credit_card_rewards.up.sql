@@ -0,0 +1,32 @@
+-- Reward earning settings on credit cards, earning rules, redemptions and
+-- the system category for redeemed rewards
+
+ALTER TABLE "credit_cards" ADD COLUMN "reward_type" text;
+ALTER TABLE "credit_cards" ADD COLUMN "reward_rate" decimal;
+ALTER TABLE "credit_cards" ADD COLUMN "reward_expiry_months" bigint;
+UPDATE "credit_cards" SET "reward_type" = '', "reward_rate" = 0, "reward_expiry_months" = 0;
+
+ALTER TABLE "rewards" ADD COLUMN "expires_at" timestamptz;
+ALTER TABLE "rewards" ADD COLUMN "redeemed_amount" numeric(19,4);
+ALTER TABLE "rewards" ADD COLUMN "transaction_id" uuid;
+ALTER TABLE "rewards" ADD COLUMN "rule_id" uuid;
+UPDATE "rewards" SET "redeemed_amount" = CASE WHEN "redeemed" THEN "amount" ELSE 0 END;
+CREATE INDEX IF NOT EXISTS "idx_rewards_transaction_id" ON "rewards" ("transaction_id");
+
+CREATE TABLE IF NOT EXISTS "reward_rules" ("id" uuid DEFAULT uuid_generate_v4(),"user_id" uuid NOT NULL,"card_id" uuid NOT NULL,"category_id" text NOT NULL,"multiplier" decimal NOT NULL,"cap" numeric(19,4),"cap_period" text,"description" text,"active" boolean DEFAULT true,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_reward_rules_deleted_at" ON "reward_rules" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_reward_rules_card_id" ON "reward_rules" ("card_id");
+CREATE INDEX IF NOT EXISTS "idx_reward_rules_user_id" ON "reward_rules" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "reward_redemptions" ("id" uuid DEFAULT uuid_generate_v4(),"user_id" uuid NOT NULL,"card_id" uuid NOT NULL,"type" text NOT NULL,"amount" numeric(19,4) NOT NULL,"value" numeric(19,4) NOT NULL,"method" text NOT NULL,"account_id" uuid,"transaction_id" uuid,"description" text,"redeemed_at" timestamptz NOT NULL,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_reward_redemptions_deleted_at" ON "reward_redemptions" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_reward_redemptions_transaction_id" ON "reward_redemptions" ("transaction_id");
+CREATE INDEX IF NOT EXISTS "idx_reward_redemptions_card_id" ON "reward_redemptions" ("card_id");
+CREATE INDEX IF NOT EXISTS "idx_reward_redemptions_user_id" ON "reward_redemptions" ("user_id");
+
+INSERT INTO "categories" ("id", "user_id", "key", "name", "kind", "icon", "color", "is_system", "active", "sort_order", "created_at", "updated_at")
+SELECT uuid_generate_v4(), u."id", 'credit_card_rewards', 'Card Rewards', 'income', '🎁', '#10b981', true, true,
+	(SELECT COALESCE(MAX(c."sort_order"), 0) + 1 FROM "categories" c WHERE c."user_id" = u."id"), NOW(), NOW()
+FROM "users" u
+WHERE EXISTS (SELECT 1 FROM "categories" c WHERE c."user_id" = u."id")
+	AND NOT EXISTS (SELECT 1 FROM "categories" c WHERE c."user_id" = u."id" AND c."key" = 'credit_card_rewards');
@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS "idx_bill_payments_transaction_id";
+ALTER TABLE "bill_payments" DROP COLUMN IF EXISTS "auto_pay";
+ALTER TABLE "bill_payments" DROP COLUMN IF EXISTS "transaction_id";
+ALTER TABLE "bill_payments" DROP COLUMN IF EXISTS "credit_card_id";
+
+ALTER TABLE "bills" DROP COLUMN IF EXISTS "auto_pay_failed_at";
+ALTER TABLE "bills" DROP COLUMN IF EXISTS "auto_pay_error";
+ALTER TABLE "bills" DROP COLUMN IF EXISTS "auto_pay_through";
+ALTER TABLE "bills" DROP COLUMN IF EXISTS "credit_card_id";
+ALTER TABLE "bills" DROP COLUMN IF EXISTS "account_id";
@@ -0,0 +1,18 @@
+-- Default payment account or card on bills, autopay state, and the
+-- transaction posted for each bill payment
+
+ALTER TABLE "bills" ADD COLUMN "account_id" uuid;
+ALTER TABLE "bills" ADD COLUMN "credit_card_id" uuid;
+ALTER TABLE "bills" ADD COLUMN "auto_pay_through" timestamptz;
+ALTER TABLE "bills" ADD COLUMN "auto_pay_error" text;
+ALTER TABLE "bills" ADD COLUMN "auto_pay_failed_at" timestamptz;
+UPDATE "bills" SET "auto_pay_error" = '';
+
+-- Autopay on existing bills starts with the due dates from today on
+UPDATE "bills" SET "auto_pay_through" = (CURRENT_DATE - 1)::timestamp AT TIME ZONE 'UTC' WHERE "auto_pay" = true;
+
+ALTER TABLE "bill_payments" ADD COLUMN "credit_card_id" uuid;
+ALTER TABLE "bill_payments" ADD COLUMN "transaction_id" uuid;
+ALTER TABLE "bill_payments" ADD COLUMN "auto_pay" boolean;
+UPDATE "bill_payments" SET "auto_pay" = false;
+CREATE INDEX IF NOT EXISTS "idx_bill_payments_transaction_id" ON "bill_payments" ("transaction_id");
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS "notification_deliveries";
+DROP TABLE IF EXISTS "notifications";
+
+ALTER TABLE "accounts" DROP COLUMN IF EXISTS "minimum_balance";
+
+ALTER TABLE "settings" DROP COLUMN IF EXISTS "notif_webhook_url";
+ALTER TABLE "settings" DROP COLUMN IF EXISTS "notif_maturity_reminder_days";
+ALTER TABLE "settings" DROP COLUMN IF EXISTS "notif_maturity_alerts";
+ALTER TABLE "settings" DROP COLUMN IF EXISTS "notif_low_balance_alerts";
@@ -0,0 +1,22 @@
+-- Notification inbox and deliveries, the new notification preferences and
+-- minimum balances on accounts for low balance alerts
+
+ALTER TABLE "settings" ADD COLUMN "notif_low_balance_alerts" boolean;
+ALTER TABLE "settings" ADD COLUMN "notif_maturity_alerts" boolean;
+ALTER TABLE "settings" ADD COLUMN "notif_maturity_reminder_days" bigint;
+ALTER TABLE "settings" ADD COLUMN "notif_webhook_url" text;
+UPDATE "settings" SET "notif_low_balance_alerts" = true, "notif_maturity_alerts" = true, "notif_maturity_reminder_days" = 7, "notif_webhook_url" = '';
+
+ALTER TABLE "accounts" ADD COLUMN "minimum_balance" numeric(19,4);
+UPDATE "accounts" SET "minimum_balance" = 0;
+
+CREATE TABLE IF NOT EXISTS "notifications" ("id" uuid DEFAULT uuid_generate_v4(),"user_id" uuid NOT NULL,"type" text NOT NULL,"title" text NOT NULL,"message" text,"source_id" uuid,"key" text NOT NULL,"read" boolean DEFAULT false,"read_at" timestamptz,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_notifications_deleted_at" ON "notifications" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_notifications_read" ON "notifications" ("read");
+CREATE INDEX IF NOT EXISTS "idx_notifications_type" ON "notifications" ("type");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_notification_user_key" ON "notifications" ("user_id","key");
+CREATE INDEX IF NOT EXISTS "idx_notifications_user_id" ON "notifications" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "notification_deliveries" ("id" uuid DEFAULT uuid_generate_v4(),"notification_id" uuid NOT NULL,"channel" text NOT NULL,"status" text NOT NULL,"error" text,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_notification_deliveries_deleted_at" ON "notification_deliveries" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_notification_deliveries_notification_id" ON "notification_deliveries" ("notification_id");
@@ -0,0 +1,4 @@
+-- Time zone used for days and budget periods
+
+ALTER TABLE "settings" ADD COLUMN "time_zone" text DEFAULT 'UTC';
+UPDATE "settings" SET "time_zone" = 'UTC';
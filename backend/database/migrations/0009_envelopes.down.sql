@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS "envelope_moves";
+DROP TABLE IF EXISTS "envelope_assignments";
@@ -0,0 +1,12 @@
+-- Envelope budgeting: money assigned to expense categories by month and
+-- money moved between envelopes
+
+CREATE TABLE IF NOT EXISTS "envelope_assignments" ("id" uuid DEFAULT uuid_generate_v4(),"user_id" uuid NOT NULL,"category_id" text NOT NULL,"month" date NOT NULL,"amount" numeric(19,4) NOT NULL,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_envelope_assignments_deleted_at" ON "envelope_assignments" ("deleted_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_envelope_assignment" ON "envelope_assignments" ("user_id","category_id","month");
+CREATE INDEX IF NOT EXISTS "idx_envelope_assignments_user_id" ON "envelope_assignments" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "envelope_moves" ("id" uuid DEFAULT uuid_generate_v4(),"user_id" uuid NOT NULL,"month" date NOT NULL,"from_category_id" text NOT NULL,"to_category_id" text NOT NULL,"amount" numeric(19,4) NOT NULL,"notes" text,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_envelope_moves_deleted_at" ON "envelope_moves" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_envelope_moves_month" ON "envelope_moves" ("month");
+CREATE INDEX IF NOT EXISTS "idx_envelope_moves_user_id" ON "envelope_moves" ("user_id");
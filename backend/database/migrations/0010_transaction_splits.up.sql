@@ -0,0 +1,8 @@
+-- Split transactions: the parts of an income or expense attributed to
+-- different categories
+
+CREATE TABLE IF NOT EXISTS "transaction_splits" ("id" uuid DEFAULT uuid_generate_v4(),"transaction_id" uuid NOT NULL,"user_id" uuid NOT NULL,"category_id" text NOT NULL,"amount" numeric(19,4) NOT NULL,"memo" text,"tags" jsonb,"position" bigint NOT NULL DEFAULT 0,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_transaction_splits_deleted_at" ON "transaction_splits" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_transaction_splits_category_id" ON "transaction_splits" ("category_id");
+CREATE INDEX IF NOT EXISTS "idx_transaction_splits_user_id" ON "transaction_splits" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_transaction_splits_transaction_id" ON "transaction_splits" ("transaction_id");
@@ -0,0 +1,3 @@
+ALTER TABLE "import_rows" DROP COLUMN IF EXISTS "transfer_account_id";
+ALTER TABLE "import_rows" DROP COLUMN IF EXISTS "tags";
+DROP TABLE IF EXISTS "transaction_rules";
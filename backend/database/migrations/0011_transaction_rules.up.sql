@@ -0,0 +1,10 @@
+-- Transaction rules: categorize, tag and rename transactions as they are
+-- created or imported. Import rows keep the tags and transfer account a
+-- rule assigned until the batch is committed.
+
+CREATE TABLE IF NOT EXISTS "transaction_rules" ("id" uuid DEFAULT uuid_generate_v4(),"user_id" uuid NOT NULL,"name" text NOT NULL,"priority" bigint NOT NULL DEFAULT 0,"active" boolean DEFAULT true,"stop_processing" boolean DEFAULT false,"description_pattern" text,"min_amount" numeric(19,4),"max_amount" numeric(19,4),"account_id" uuid,"type" text,"set_category_id" text,"tags" jsonb,"set_description" text,"transfer_account_id" uuid,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_transaction_rules_deleted_at" ON "transaction_rules" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_transaction_rules_user_id" ON "transaction_rules" ("user_id");
+
+ALTER TABLE "import_rows" ADD COLUMN IF NOT EXISTS "tags" jsonb;
+ALTER TABLE "import_rows" ADD COLUMN IF NOT EXISTS "transfer_account_id" uuid;
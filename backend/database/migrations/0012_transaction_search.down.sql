@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS "saved_searches";
+DROP INDEX IF EXISTS "idx_transaction_splits_memo_search";
+DROP INDEX IF EXISTS "idx_credit_card_transactions_merchant_search";
+DROP INDEX IF EXISTS "idx_transactions_description_search";
@@ -0,0 +1,11 @@
+-- Transaction search: text search indexes over descriptions, card merchants
+-- and split memos, and the searches users save to run again. The index
+-- expressions must match the documents the search queries.
+
+CREATE INDEX IF NOT EXISTS "idx_transactions_description_search" ON "transactions" USING GIN (to_tsvector('simple', COALESCE("description", '')));
+CREATE INDEX IF NOT EXISTS "idx_credit_card_transactions_merchant_search" ON "credit_card_transactions" USING GIN (to_tsvector('simple', COALESCE("merchant", '')));
+CREATE INDEX IF NOT EXISTS "idx_transaction_splits_memo_search" ON "transaction_splits" USING GIN (to_tsvector('simple', COALESCE("memo", '')));
+
+CREATE TABLE IF NOT EXISTS "saved_searches" ("id" uuid DEFAULT uuid_generate_v4(),"user_id" uuid NOT NULL,"name" text NOT NULL,"search" jsonb,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_saved_searches_deleted_at" ON "saved_searches" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_saved_searches_user_id" ON "saved_searches" ("user_id");
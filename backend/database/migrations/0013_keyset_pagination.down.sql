@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS "idx_reconciliations_user_keyset";
+DROP INDEX IF EXISTS "idx_credit_card_transactions_card_keyset";
+DROP INDEX IF EXISTS "idx_bill_payments_user_keyset";
+DROP INDEX IF EXISTS "idx_transactions_user_keyset";
@@ -0,0 +1,7 @@
+-- Keyset pagination: indexes in the order cursor-paginated lists are read,
+-- so that each page is an index range scan however deep the cursor is
+
+CREATE INDEX IF NOT EXISTS "idx_transactions_user_keyset" ON "transactions" ("user_id", "date" DESC, "created_at" DESC, "id" DESC);
+CREATE INDEX IF NOT EXISTS "idx_bill_payments_user_keyset" ON "bill_payments" ("user_id", "payment_date" DESC, "created_at" DESC, "id" DESC);
+CREATE INDEX IF NOT EXISTS "idx_credit_card_transactions_card_keyset" ON "credit_card_transactions" ("card_id", "date" DESC, "created_at" DESC, "id" DESC);
+CREATE INDEX IF NOT EXISTS "idx_reconciliations_user_keyset" ON "reconciliations" ("user_id", "reconciliation_date" DESC, "created_at" DESC, "id" DESC);
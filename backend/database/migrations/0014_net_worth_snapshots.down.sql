@@ -0,0 +1 @@
+DROP TABLE IF EXISTS "net_worth_snapshots";
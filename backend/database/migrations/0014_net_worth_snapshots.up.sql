@@ -0,0 +1,6 @@
+-- Net worth snapshots: the user's assets, liabilities and net worth at the
+-- end of each day, with their breakdown by account and holding type
+
+CREATE TABLE IF NOT EXISTS "net_worth_snapshots" ("id" uuid DEFAULT uuid_generate_v4(),"user_id" uuid NOT NULL,"date" date NOT NULL,"currency" text NOT NULL,"assets" numeric(19,4) NOT NULL,"liabilities" numeric(19,4) NOT NULL,"net_worth" numeric(19,4) NOT NULL,"breakdown" jsonb,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_net_worth_snapshot" ON "net_worth_snapshots" ("user_id","date");
+CREATE INDEX IF NOT EXISTS "idx_net_worth_snapshots_user_id" ON "net_worth_snapshots" ("user_id");
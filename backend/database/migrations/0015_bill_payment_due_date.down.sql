@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS "idx_bill_payments_due_date";
+ALTER TABLE "bill_payments" DROP COLUMN IF EXISTS "due_date";
@@ -0,0 +1,5 @@
+-- The due date each bill payment settles. Payments recorded before it are
+-- matched to the first due date on or after their payment date.
+
+ALTER TABLE "bill_payments" ADD COLUMN "due_date" timestamptz;
+CREATE INDEX IF NOT EXISTS "idx_bill_payments_due_date" ON "bill_payments" ("due_date");
@@ -0,0 +1,66 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+
+	"daybook-backend/models"
+
+	"gorm.io/gorm"
+)
+
+var moneyType = reflect.TypeOf(models.Money(0))
+
+// ConvertMoneyColumns changes every existing column backing a models.Money
+// field to numeric(19,4). Float columns are rounded to four decimal places,
+// which keeps every real amount intact while dropping binary float noise such
+// as 0.30000000000000004. Columns that are already numeric(19,4) or do not
+// exist yet are left alone.
+func ConvertMoneyColumns(db *gorm.DB, tables []interface{}) error {
+	for _, model := range tables {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return err
+		}
+
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName == "" {
+				continue
+			}
+			fieldType := field.FieldType
+			if fieldType.Kind() == reflect.Ptr {
+				fieldType = fieldType.Elem()
+			}
+			if fieldType != moneyType {
+				continue
+			}
+
+			var column struct {
+				DataType     string
+				NumericScale *int
+			}
+			result := db.Raw(`SELECT data_type, numeric_scale FROM information_schema.columns
+				WHERE table_schema = CURRENT_SCHEMA() AND table_name = ? AND column_name = ?`,
+				stmt.Schema.Table, field.DBName).Scan(&column)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				continue
+			}
+			if column.DataType == "numeric" && column.NumericScale != nil && *column.NumericScale == models.MoneyScale {
+				continue
+			}
+
+			log.Printf("Converting %s.%s from %s to numeric(19,4)\n", stmt.Schema.Table, field.DBName, column.DataType)
+			sql := fmt.Sprintf(`ALTER TABLE %q ALTER COLUMN %q TYPE numeric(19,4) USING ROUND(%q::numeric, 4)`,
+				stmt.Schema.Table, field.DBName, field.DBName)
+			if err := db.Exec(sql).Error; err != nil {
+				return fmt.Errorf("%s.%s: %w", stmt.Schema.Table, field.DBName, err)
+			}
+		}
+	}
+
+	return nil
+}
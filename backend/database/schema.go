@@ -0,0 +1,46 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// statementRecorder is a logger that keeps every statement it is given
+type statementRecorder struct {
+	logger.Interface
+	statements []string
+}
+
+func (r *statementRecorder) LogMode(logger.LogLevel) logger.Interface {
+	return r
+}
+
+func (r *statementRecorder) Trace(_ context.Context, _ time.Time, fc func() (string, int64), _ error) {
+	sql, _ := fc()
+	r.statements = append(r.statements, sql)
+}
+
+// ModelSchema returns the statements that create the tables of the current
+// models in an empty database, as AutoMigrate would. It does not connect to
+// a database and is the starting point when writing a new migration.
+func ModelSchema() ([]string, error) {
+	recorder := &statementRecorder{Interface: logger.Discard}
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+		Logger:               recorder,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// AutoMigrate would look up existing tables even in dry run mode
+	if err := db.Migrator().CreateTable(migratedModels...); err != nil {
+		return nil, err
+	}
+	return recorder.statements, nil
+}
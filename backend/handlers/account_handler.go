@@ -68,6 +68,15 @@ func CreateAccount(c *gin.Context) {
 
 	account.UserID = userID
 
+	// The opening balance is booked through the journal like any other
+	// transaction, so the account starts empty
+	opening := account.InitialBalance
+	if opening == 0 {
+		opening = account.Balance
+	}
+	account.InitialBalance = opening
+	account.Balance = 0
+
 	// Start transaction to ensure atomicity
 	tx := database.DB.Begin()
 	if tx.Error != nil {
@@ -83,22 +92,34 @@ func CreateAccount(c *gin.Context) {
 	}
 
 	// If there's an initial balance, create an opening balance transaction
-	if account.InitialBalance > 0 {
+	if opening != 0 {
 		transaction := models.Transaction{
 			UserID:      userID,
 			AccountID:   account.ID,
 			Type:        "income",
-			CategoryID:  "opening_balance",
-			Amount:      account.InitialBalance,
+			CategoryID:  models.CategoryOpeningBalance,
+			Amount:      opening,
 			Date:        account.CreatedAt,
 			Description: "Opening balance for " + account.Name,
 		}
+		// An overdrawn account opens with money owed
+		if opening < 0 {
+			transaction.Type = "expense"
+			transaction.Amount = -opening
+		}
 
 		if err := tx.Create(&transaction).Error; err != nil {
 			tx.Rollback()
 			utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to create opening balance transaction")
 			return
 		}
+
+		if err := transaction.ApplyBalance(tx); err != nil {
+			tx.Rollback()
+			utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to record opening balance")
+			return
+		}
+		account.Balance = opening
 	}
 
 	// Commit the transaction
@@ -144,8 +165,10 @@ func UpdateAccount(c *gin.Context) {
 	existingAccount.Institution = updateData.Institution
 	existingAccount.AccountNumber = updateData.AccountNumber
 	existingAccount.Active = updateData.Active
+	existingAccount.MinimumBalance = updateData.MinimumBalance
 
-	if err := database.DB.Save(&existingAccount).Error; err != nil {
+	// Balances only change through the journal
+	if err := database.DB.Omit("balance", "initial_balance").Save(&existingAccount).Error; err != nil {
 		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to update account")
 		return
 	}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"daybook-backend/database"
+	"daybook-backend/services"
+	"daybook-backend/utilities"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// AuditBalances recomputes stored balances from history for one user or,
+// without userId, for everyone
+func AuditBalances(c *gin.Context) {
+	runBalanceAudit(c, false)
+}
+
+// RepairBalances runs the balance audit and corrects every mismatch in a
+// single database transaction
+func RepairBalances(c *gin.Context) {
+	runBalanceAudit(c, true)
+}
+
+func runBalanceAudit(c *gin.Context, repair bool) {
+	userID := uuid.Nil
+	if userParam := c.Query("userId"); userParam != "" {
+		parsedID, err := uuid.Parse(userParam)
+		if err != nil {
+			utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid user ID")
+			return
+		}
+		userID = parsedID
+	}
+
+	report, err := services.AuditBalances(database.DB, userID, repair)
+	if err != nil {
+		log.Printf("Balance audit failed: %v", err)
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to audit balances")
+		return
+	}
+
+	if repair {
+		utilities.SuccessResponse(c, report, "Balances repaired successfully")
+		return
+	}
+	utilities.SuccessResponse(c, report, "Balances audited successfully")
+}
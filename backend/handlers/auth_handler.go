@@ -56,12 +56,8 @@ func Signup(c *gin.Context) {
 		DateFormat:     "MM/DD/YYYY",
 		FirstDayOfWeek: 0,
 		Language:       "en",
-		Notifications: &models.Notifications{
-			Push:          true,
-			Email:         true,
-			BudgetAlerts:  true,
-			BillReminders: true,
-		},
+		TimeZone:       "UTC",
+		Notifications:  models.DefaultNotificationSettings(),
 	}
 	database.DB.Create(&settings)
 
@@ -71,6 +67,12 @@ func Signup(c *gin.Context) {
 		return
 	}
 
+	// Create default categories for the user
+	if err := models.SeedDefaultCategories(database.DB, user.ID); err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to create default categories")
+		return
+	}
+
 	// Generate JWT token
 	token, err := utilities.GenerateToken(&user)
 	if err != nil {
@@ -1,19 +1,30 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 	"time"
 
 	"daybook-backend/database"
 	"daybook-backend/middleware"
 	"daybook-backend/models"
+	"daybook-backend/services"
 	"daybook-backend/utilities"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
-// ListBills returns all bills for the authenticated user
+// billKeyset orders bills by due day
+var billKeyset = keyset{name: "bills", table: "bills", column: "due_day"}
+
+// billPaymentKeyset orders bill payments newest first
+var billPaymentKeyset = keyset{name: "bill payments", table: "bill_payments", column: "payment_date", descending: true}
+
+// ListBills returns all bills for the authenticated user, or a page of them
+// when a limit or cursor is given
 func ListBills(c *gin.Context) {
 	userID, err := middleware.GetUserID(c)
 	if err != nil {
@@ -34,6 +45,17 @@ func ListBills(c *gin.Context) {
 	}
 
 	var bills []models.Bill
+	if wantsPage(c) {
+		pagination, ok := paginate(c, query, billKeyset, func(b *models.Bill) cursorKey {
+			return cursorKey{Sort: b.DueDay, CreatedAt: b.CreatedAt, ID: b.ID}
+		}, &bills)
+		if !ok {
+			return
+		}
+		utilities.SuccessResponse(c, gin.H{"bills": bills, "pagination": pagination}, "Bills retrieved successfully")
+		return
+	}
+
 	if err := query.Order("due_day ASC").Find(&bills).Error; err != nil {
 		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch bills")
 		return
@@ -79,7 +101,22 @@ func CreateBill(c *gin.Context) {
 		return
 	}
 
+	if !models.IsValidFrequency(bill.Frequency) {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid frequency. Must be one of: daily, weekly, biweekly, monthly, quarterly, yearly")
+		return
+	}
+
+	if !validateBillPaymentSource(c, userID, &bill) {
+		return
+	}
+
 	bill.UserID = userID
+	bill.AutoPayThrough = nil
+	bill.AutoPayError = ""
+	bill.AutoPayFailedAt = nil
+	if bill.AutoPay {
+		startAutoPay(&bill)
+	}
 
 	if err := database.DB.Create(&bill).Error; err != nil {
 		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to create bill")
@@ -115,6 +152,20 @@ func UpdateBill(c *gin.Context) {
 		return
 	}
 
+	if !models.IsValidFrequency(updateData.Frequency) {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid frequency. Must be one of: daily, weekly, biweekly, monthly, quarterly, yearly")
+		return
+	}
+
+	if !validateBillPaymentSource(c, userID, &updateData) {
+		return
+	}
+
+	// Autopay turned back on does not pay the due dates missed while it was off
+	if updateData.AutoPay && !existingBill.AutoPay {
+		startAutoPay(&existingBill)
+	}
+
 	// Update allowed fields
 	existingBill.Name = updateData.Name
 	existingBill.Category = updateData.Category
@@ -123,6 +174,8 @@ func UpdateBill(c *gin.Context) {
 	existingBill.StartDate = updateData.StartDate
 	existingBill.DueDay = updateData.DueDay
 	existingBill.AutoPay = updateData.AutoPay
+	existingBill.AccountID = updateData.AccountID
+	existingBill.CreditCardID = updateData.CreditCardID
 	existingBill.ReminderDays = updateData.ReminderDays
 	existingBill.Active = updateData.Active
 	existingBill.Notes = updateData.Notes
@@ -164,7 +217,9 @@ func DeleteBill(c *gin.Context) {
 	utilities.SuccessResponse(c, nil, "Bill deleted successfully")
 }
 
-// PayBill marks a bill as paid and records the payment
+// PayBill records a payment of a bill. Paid from an account or charged to a
+// credit card, given in the request or set on the bill, the payment is also
+// posted as an expense in the bill's category.
 func PayBill(c *gin.Context) {
 	userID, err := middleware.GetUserID(c)
 	if err != nil {
@@ -179,10 +234,12 @@ func PayBill(c *gin.Context) {
 	}
 
 	var paymentData struct {
-		Amount      float64    `json:"amount" binding:"required,gt=0"`
-		PaymentDate *time.Time `json:"paymentDate"`
-		AccountID   *uuid.UUID `json:"accountId"`
-		Notes       string     `json:"notes"`
+		Amount       models.Money `json:"amount" binding:"required,gt=0"`
+		PaymentDate  *time.Time   `json:"paymentDate"`
+		DueDate      *time.Time   `json:"dueDate"` // Due date the payment settles; by default the first on or after the payment date
+		AccountID    *uuid.UUID   `json:"accountId"`
+		CreditCardID *uuid.UUID   `json:"creditCardId"`
+		Notes        string       `json:"notes"`
 	}
 
 	if err := c.ShouldBindJSON(&paymentData); err != nil {
@@ -190,62 +247,73 @@ func PayBill(c *gin.Context) {
 		return
 	}
 
+	if paymentData.AccountID != nil && paymentData.CreditCardID != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Pay from either an account or a credit card, not both")
+		return
+	}
+
 	var bill models.Bill
 	if err := database.DB.Where("id = ? AND user_id = ?", billID, userID).First(&bill).Error; err != nil {
 		utilities.ErrorResponse(c, http.StatusNotFound, "Bill not found")
 		return
 	}
 
-	// If account is specified, verify it belongs to user
-	if paymentData.AccountID != nil {
+	payment := services.BillPaymentRequest{
+		Amount: paymentData.Amount,
+		Date:   time.Now(),
+		Notes:  paymentData.Notes,
+	}
+	if paymentData.PaymentDate != nil {
+		payment.Date = *paymentData.PaymentDate
+	}
+	if paymentData.DueDate != nil {
+		dueDate := time.Date(paymentData.DueDate.Year(), paymentData.DueDate.Month(), paymentData.DueDate.Day(), 0, 0, 0, 0, time.UTC)
+		if next, ok := bill.NextDueDate(dueDate); !ok || !next.Equal(dueDate) {
+			utilities.ErrorResponse(c, http.StatusBadRequest, "dueDate is not a due date of the bill")
+			return
+		}
+		payment.DueDate = &dueDate
+	}
+
+	// If an account or card is specified, verify it belongs to user;
+	// otherwise pay from the bill's own
+	switch {
+	case paymentData.AccountID != nil:
 		var account models.Account
 		if err := database.DB.Where("id = ? AND user_id = ?", *paymentData.AccountID, userID).First(&account).Error; err != nil {
 			utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid account ID")
 			return
 		}
-	}
-
-	// Start transaction
-	tx := database.DB.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
+		payment.Account = &account
+	case paymentData.CreditCardID != nil:
+		var card models.CreditCard
+		if err := database.DB.Where("id = ? AND user_id = ?", *paymentData.CreditCardID, userID).First(&card).Error; err != nil {
+			utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid credit card ID")
+			return
+		}
+		payment.CreditCard = &card
+	default:
+		if err := services.BillPaymentSource(database.DB, &bill, &payment); err != nil {
+			utilities.ErrorResponse(c, http.StatusBadRequest, "The bill's payment account or credit card no longer exists")
+			return
 		}
-	}()
+	}
 
-	// Create bill payment record
-	billPayment := models.BillPayment{
-		UserID: userID,
-		BillID: billID,
-		Amount: paymentData.Amount,
-		PaymentDate: func() time.Time {
-			if paymentData.PaymentDate != nil {
-				return *paymentData.PaymentDate
-			}
-			return time.Now()
-		}(),
-		AccountID: paymentData.AccountID,
-		Notes:     paymentData.Notes,
-	}
-
-	if err := tx.Create(&billPayment).Error; err != nil {
-		tx.Rollback()
-		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to record payment")
+	var billPayment *models.BillPayment
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		billPayment, err = services.PayBill(tx, &bill, payment)
+		return err
+	})
+	if errors.Is(err, services.ErrInsufficientFunds) || errors.Is(err, services.ErrInsufficientCredit) {
+		utilities.ErrorResponse(c, http.StatusBadRequest, err.Error())
 		return
 	}
-
-	// Update bill's last payment info
-	bill.LastPaidDate = &billPayment.PaymentDate
-	bill.LastPaidAmount = paymentData.Amount
-
-	if err := tx.Save(&bill).Error; err != nil {
-		tx.Rollback()
-		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to update bill")
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to record payment")
 		return
 	}
 
-	tx.Commit()
-
 	result := map[string]interface{}{
 		"bill":    bill,
 		"payment": billPayment,
@@ -254,7 +322,8 @@ func PayBill(c *gin.Context) {
 	utilities.SuccessResponse(c, result, "Bill payment recorded successfully")
 }
 
-// GetBillPayments returns payment history for bills
+// GetBillPayments returns payment history for bills, or a page of it when
+// a limit or cursor is given
 func GetBillPayments(c *gin.Context) {
 	userID, err := middleware.GetUserID(c)
 	if err != nil {
@@ -283,6 +352,17 @@ func GetBillPayments(c *gin.Context) {
 	}
 
 	var payments []models.BillPayment
+	if wantsPage(c) {
+		pagination, ok := paginate(c, query, billPaymentKeyset, func(p *models.BillPayment) cursorKey {
+			return cursorKey{Sort: p.PaymentDate, CreatedAt: p.CreatedAt, ID: p.ID}
+		}, &payments)
+		if !ok {
+			return
+		}
+		utilities.SuccessResponse(c, gin.H{"payments": payments, "pagination": pagination}, "Bill payments retrieved successfully")
+		return
+	}
+
 	if err := query.Order("payment_date DESC").Find(&payments).Error; err != nil {
 		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch bill payments")
 		return
@@ -290,3 +370,106 @@ func GetBillPayments(c *gin.Context) {
 
 	utilities.SuccessResponse(c, payments, "Bill payments retrieved successfully")
 }
+
+// GetUpcomingBills returns the due dates of active bills in a window with
+// their paid, unpaid or overdue status, plus any earlier ones still unpaid
+func GetUpcomingBills(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	now := time.Now()
+	days := 30
+	if daysParam := c.Query("days"); daysParam != "" {
+		if parsedDays, err := strconv.Atoi(daysParam); err == nil && parsedDays > 0 && parsedDays <= 366 {
+			days = parsedDays
+		}
+	}
+
+	startDate := services.UserBudgetCalendar(database.DB, userID).Today(now)
+	if startParam := c.Query("startDate"); startParam != "" {
+		if parsedDate, err := time.Parse("2006-01-02", startParam); err == nil {
+			startDate = parsedDate
+		}
+	}
+	endDate := startDate.AddDate(0, 0, days)
+	if endParam := c.Query("endDate"); endParam != "" {
+		if parsedDate, err := time.Parse("2006-01-02", endParam); err == nil {
+			endDate = parsedDate
+		}
+	}
+
+	if endDate.Before(startDate) {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "endDate must not be before startDate")
+		return
+	}
+	if endDate.Sub(startDate) > 366*24*time.Hour {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "The window cannot exceed 366 days")
+		return
+	}
+
+	occurrences, err := services.UpcomingBills(database.DB, userID, startDate, endDate, now)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch upcoming bills")
+		return
+	}
+
+	var totalDue, totalOverdue models.Money
+	for _, occurrence := range occurrences {
+		switch occurrence.Status {
+		case models.BillUnpaid:
+			totalDue += occurrence.Amount
+		case models.BillOverdue:
+			totalOverdue += occurrence.Amount
+		}
+	}
+
+	result := map[string]interface{}{
+		"startDate":    startDate.Format("2006-01-02"),
+		"endDate":      endDate.Format("2006-01-02"),
+		"occurrences":  occurrences,
+		"totalDue":     totalDue,
+		"totalOverdue": totalOverdue,
+	}
+
+	utilities.SuccessResponse(c, result, "Upcoming bills retrieved successfully")
+}
+
+// validateBillPaymentSource checks that the bill's default account or credit
+// card belongs to the user and that autopay has one to pay from, and writes
+// the error response when not
+func validateBillPaymentSource(c *gin.Context, userID uuid.UUID, bill *models.Bill) bool {
+	if bill.AccountID != nil && bill.CreditCardID != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Set either accountId or creditCardId, not both")
+		return false
+	}
+	if bill.AutoPay && bill.AccountID == nil && bill.CreditCardID == nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Autopay requires an accountId or creditCardId to pay from")
+		return false
+	}
+
+	if bill.AccountID != nil {
+		var account models.Account
+		if err := database.DB.Where("id = ? AND user_id = ?", *bill.AccountID, userID).First(&account).Error; err != nil {
+			utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid account ID")
+			return false
+		}
+	}
+	if bill.CreditCardID != nil {
+		var card models.CreditCard
+		if err := database.DB.Where("id = ? AND user_id = ?", *bill.CreditCardID, userID).First(&card).Error; err != nil {
+			utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid credit card ID")
+			return false
+		}
+	}
+	return true
+}
+
+// startAutoPay makes autopay pay the bill's due dates from today, in the
+// user's time zone, on
+func startAutoPay(bill *models.Bill) {
+	yesterday := services.UserBudgetCalendar(database.DB, bill.UserID).Today(time.Now()).AddDate(0, 0, -1)
+	bill.AutoPayThrough = &yesterday
+}
@@ -1,12 +1,15 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 	"time"
 
 	"daybook-backend/database"
 	"daybook-backend/middleware"
 	"daybook-backend/models"
+	"daybook-backend/services"
 	"daybook-backend/utilities"
 
 	"github.com/gin-gonic/gin"
@@ -86,6 +89,14 @@ func CreateBudget(c *gin.Context) {
 
 	budget.UserID = userID
 
+	// Budgets track spending, so the category must be an expense category
+	category, message := resolveCategory(userID, budget.CategoryID, models.CategoryKindExpense)
+	if message != "" {
+		utilities.ErrorResponse(c, http.StatusBadRequest, message)
+		return
+	}
+	budget.CategoryID = category.Key
+
 	// Validate custom period dates
 	if budget.Period == "custom" {
 		if budget.CustomStartDate == nil || budget.CustomEndDate == nil {
@@ -128,8 +139,14 @@ func UpdateBudget(c *gin.Context) {
 		return
 	}
 
+	category, message := resolveCategory(userID, updateData.CategoryID, models.CategoryKindExpense)
+	if message != "" {
+		utilities.ErrorResponse(c, http.StatusBadRequest, message)
+		return
+	}
+
 	// Update allowed fields
-	existingBudget.CategoryID = updateData.CategoryID
+	existingBudget.CategoryID = category.Key
 	existingBudget.Amount = updateData.Amount
 	existingBudget.Period = updateData.Period
 	existingBudget.CustomStartDate = updateData.CustomStartDate
@@ -176,7 +193,8 @@ func DeleteBudget(c *gin.Context) {
 	utilities.SuccessResponse(c, nil, "Budget deleted successfully")
 }
 
-// GetBudgetProgress returns spending progress for a budget
+// GetBudgetProgress returns spending progress for a budget in the period
+// containing the date query parameter, the current period by default
 func GetBudgetProgress(c *gin.Context) {
 	userID, err := middleware.GetUserID(c)
 	if err != nil {
@@ -196,67 +214,128 @@ func GetBudgetProgress(c *gin.Context) {
 		return
 	}
 
-	// Calculate date range based on period
-	var startDate, endDate time.Time
-	now := time.Now()
-
-	switch budget.Period {
-	case "weekly":
-		// Start of current week (Sunday)
-		startDate = now.AddDate(0, 0, -int(now.Weekday()))
-		startDate = time.Date(startDate.Year(), startDate.Month(), startDate.Day(), 0, 0, 0, 0, startDate.Location())
-		endDate = startDate.AddDate(0, 0, 7)
-
-	case "monthly":
-		// Start of current month
-		startDate = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
-		endDate = startDate.AddDate(0, 1, 0)
-
-	case "quarterly":
-		// Start of current quarter
-		currentMonth := int(now.Month())
-		quarterStartMonth := ((currentMonth-1)/3)*3 + 1
-		startDate = time.Date(now.Year(), time.Month(quarterStartMonth), 1, 0, 0, 0, 0, now.Location())
-		endDate = startDate.AddDate(0, 3, 0)
-
-	case "yearly":
-		// Start of current year
-		startDate = time.Date(now.Year(), 1, 1, 0, 0, 0, 0, now.Location())
-		endDate = startDate.AddDate(1, 0, 0)
-
-	case "custom":
-		if budget.CustomStartDate != nil && budget.CustomEndDate != nil {
-			startDate = *budget.CustomStartDate
-			endDate = *budget.CustomEndDate
-		} else {
-			utilities.ErrorResponse(c, http.StatusBadRequest, "Custom budget dates not set")
+	date := budgetDate(c, userID)
+
+	progress, err := services.CalculateBudgetProgress(database.DB, &budget, date)
+	if respondBudgetError(c, err) {
+		return
+	}
+
+	utilities.SuccessResponse(c, progress, "Budget progress retrieved successfully")
+}
+
+// GetBudgetHistory returns spending against the budget for each of the
+// last periods, ending with the period containing the date query parameter
+func GetBudgetHistory(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	budgetID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid budget ID")
+		return
+	}
+
+	var budget models.Budget
+	if err := database.DB.Where("id = ? AND user_id = ?", budgetID, userID).First(&budget).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusNotFound, "Budget not found")
+		return
+	}
+
+	periods := 12
+	if periodsParam := c.Query("periods"); periodsParam != "" {
+		parsedPeriods, err := strconv.Atoi(periodsParam)
+		if err != nil || parsedPeriods < 1 || parsedPeriods > 120 {
+			utilities.ErrorResponse(c, http.StatusBadRequest, "periods must be between 1 and 120")
 			return
 		}
+		periods = parsedPeriods
+	}
 
-	default:
-		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid budget period")
+	date := budgetDate(c, userID)
+
+	history, err := services.CalculateBudgetHistory(database.DB, &budget, date, periods)
+	if respondBudgetError(c, err) {
 		return
 	}
 
-	// Calculate total spending for the category in the period
-	var totalSpent float64
-	database.DB.Model(&models.Transaction{}).
-		Where("user_id = ? AND category_id = ? AND type = ? AND date >= ? AND date < ?",
-			userID, budget.CategoryID, "expense", startDate, endDate).
-		Select("COALESCE(SUM(amount), 0)").
-		Row().Scan(&totalSpent)
+	var totalBudgeted, totalSpent models.Money
+	overBudgetPeriods := 0
+	for _, progress := range history {
+		totalBudgeted += progress.Amount
+		totalSpent += progress.TotalSpent
+		if progress.IsOverBudget {
+			overBudgetPeriods++
+		}
+	}
 
-	// Calculate progress
-	progress := map[string]interface{}{
-		"budget":         budget,
-		"totalSpent":     totalSpent,
-		"remaining":      budget.Amount - totalSpent,
-		"percentageUsed": (totalSpent / budget.Amount) * 100,
-		"startDate":      startDate,
-		"endDate":        endDate,
-		"isOverBudget":   totalSpent > budget.Amount,
-		"alertTriggered": (totalSpent / budget.Amount * 100) >= budget.AlertThreshold,
+	result := map[string]interface{}{
+		"budget":            budget,
+		"currency":          history[0].Currency,
+		"periods":           history,
+		"totalBudgeted":     totalBudgeted,
+		"totalSpent":        totalSpent,
+		"averageSpent":      totalSpent.Div(int64(len(history))),
+		"overBudgetPeriods": overBudgetPeriods,
 	}
 
-	utilities.SuccessResponse(c, progress, "Budget progress retrieved successfully")
+	utilities.SuccessResponse(c, result, "Budget history retrieved successfully")
+}
+
+// GetBudgetSummary returns the progress of every enabled budget in the
+// period containing the date query parameter, with totals, projected spend
+// and spending no budget covers
+func GetBudgetSummary(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	summary, err := services.CalculateBudgetSummary(database.DB, userID, budgetDate(c, userID))
+	if respondBudgetError(c, err) {
+		return
+	}
+
+	utilities.SuccessResponse(c, summary, "Budget summary retrieved successfully")
+}
+
+// budgetDate reads the date query parameter as a day in the user's time
+// zone, defaulting to now
+func budgetDate(c *gin.Context, userID uuid.UUID) time.Time {
+	dateParam := c.Query("date")
+	if dateParam == "" {
+		return time.Now()
+	}
+	settings := models.UserSettings(database.DB, userID)
+	date, err := time.ParseInLocation("2006-01-02", dateParam, settings.Location())
+	if err != nil {
+		return time.Now()
+	}
+	return date
+}
+
+// respondBudgetError writes the response for an error calculating budget
+// progress and reports whether there was one
+func respondBudgetError(c *gin.Context, err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, services.ErrCustomBudgetDates) {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Custom budget dates not set")
+		return true
+	}
+	if errors.Is(err, services.ErrInvalidBudgetPeriod) {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid budget period")
+		return true
+	}
+	if errors.Is(err, models.ErrExchangeRateNotFound) {
+		utilities.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return true
+	}
+	utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to calculate budget progress")
+	return true
 }
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"daybook-backend/database"
+	"daybook-backend/middleware"
+	"daybook-backend/services"
+	"daybook-backend/utilities"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetCalendar returns bill due dates, credit card payment due dates and
+// recurring transactions between two dates, the current month by default
+func GetCalendar(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	now := time.Now()
+	today := services.UserBudgetCalendar(database.DB, userID).Today(now)
+	startDate := today.AddDate(0, 0, 1-today.Day())
+	if startParam := c.Query("startDate"); startParam != "" {
+		if parsedDate, err := time.Parse("2006-01-02", startParam); err == nil {
+			startDate = parsedDate
+		}
+	}
+	endDate := startDate.AddDate(0, 1, -1)
+	if endParam := c.Query("endDate"); endParam != "" {
+		if parsedDate, err := time.Parse("2006-01-02", endParam); err == nil {
+			endDate = parsedDate
+		}
+	}
+
+	if endDate.Before(startDate) {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "endDate must not be before startDate")
+		return
+	}
+	if endDate.Sub(startDate) > 366*24*time.Hour {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "The calendar cannot span more than 366 days")
+		return
+	}
+
+	events, err := services.Calendar(database.DB, userID, startDate, endDate, now)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to build calendar")
+		return
+	}
+
+	result := map[string]interface{}{
+		"startDate": startDate.Format("2006-01-02"),
+		"endDate":   endDate.Format("2006-01-02"),
+		"events":    events,
+	}
+
+	utilities.SuccessResponse(c, result, "Calendar retrieved successfully")
+}
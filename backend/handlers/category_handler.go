@@ -0,0 +1,491 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"daybook-backend/database"
+	"daybook-backend/middleware"
+	"daybook-backend/models"
+	"daybook-backend/services"
+	"daybook-backend/utilities"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// categoryReferences lists every column that stores a category key
+var categoryReferences = []struct {
+	Model  interface{}
+	Column string
+}{
+	{&models.Transaction{}, "category_id"},
+	{&models.TransactionSplit{}, "category_id"},
+	{&models.Budget{}, "category_id"},
+	{&models.Bill{}, "category"},
+	{&models.CreditCardTransaction{}, "category_id"},
+	{&models.RecurringTransaction{}, "template_category_id"},
+	{&models.EnvelopeMove{}, "from_category_id"},
+	{&models.EnvelopeMove{}, "to_category_id"},
+}
+
+// MergeCategoriesRequest represents the request body for merging categories
+type MergeCategoriesRequest struct {
+	SourceCategories []string `json:"sourceCategories" binding:"required,min=1"` // Category IDs or keys, including free-text keys without a category
+	TargetCategory   string   `json:"targetCategory" binding:"required"`         // Category ID or key
+}
+
+// ListCategories returns all categories for the authenticated user
+func ListCategories(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Users created before categories existed get the defaults on first use
+	if err := models.EnsureDefaultCategories(database.DB, userID); err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to create default categories")
+		return
+	}
+
+	query := database.DB.Where("user_id = ?", userID)
+
+	// Optional filter by kind
+	if kind := c.Query("kind"); kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+
+	// Optional filter by active status
+	if active := c.Query("active"); active != "" {
+		query = query.Where("active = ?", active == "true")
+	}
+
+	// Optional filter by parent, "root" returns top-level categories
+	if parentID := c.Query("parentId"); parentID == "root" {
+		query = query.Where("parent_id IS NULL")
+	} else if parentID != "" {
+		query = query.Where("parent_id = ?", parentID)
+	}
+
+	var categories []models.Category
+	if err := query.Order("sort_order ASC, name ASC").Find(&categories).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch categories")
+		return
+	}
+
+	utilities.SuccessResponse(c, categories, "Categories retrieved successfully")
+}
+
+// GetCategory returns a specific category by ID
+func GetCategory(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	categoryID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid category ID")
+		return
+	}
+
+	var category models.Category
+	if err := database.DB.Where("id = ? AND user_id = ?", categoryID, userID).First(&category).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusNotFound, "Category not found")
+		return
+	}
+
+	utilities.SuccessResponse(c, category, "Category retrieved successfully")
+}
+
+// CreateCategory creates a new user-defined category
+func CreateCategory(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var category models.Category
+	if err := c.ShouldBindJSON(&category); err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := models.EnsureDefaultCategories(database.DB, userID); err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to create default categories")
+		return
+	}
+
+	category.ID = uuid.Nil
+	category.UserID = userID
+	category.IsSystem = false
+	category.Name = strings.TrimSpace(category.Name)
+
+	// Derive the key from the name unless one is given, e.g. "Pet Care" -> "pet_care"
+	if category.Key == "" {
+		category.Key = category.Name
+	}
+	category.Key = utilities.ToSnakeCase(category.Key)
+	if category.Key == "" {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Category key must contain letters or digits")
+		return
+	}
+
+	if !models.IsValidCategoryKind(category.Kind) {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid kind. Must be one of: income, expense, transfer")
+		return
+	}
+
+	if _, err := models.FindCategory(database.DB, userID, category.Key); err == nil {
+		utilities.ErrorResponse(c, http.StatusConflict, "A category with this key already exists")
+		return
+	}
+
+	if message := validateCategoryParent(userID, &category); message != "" {
+		utilities.ErrorResponse(c, http.StatusBadRequest, message)
+		return
+	}
+
+	if err := database.DB.Create(&category).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to create category")
+		return
+	}
+
+	utilities.CreatedResponse(c, category, "Category created successfully")
+}
+
+// UpdateCategory updates an existing category. The key never changes once
+// created; use merge to move transactions to a different category.
+func UpdateCategory(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	categoryID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid category ID")
+		return
+	}
+
+	var existingCategory models.Category
+	if err := database.DB.Where("id = ? AND user_id = ?", categoryID, userID).First(&existingCategory).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusNotFound, "Category not found")
+		return
+	}
+
+	var updateData models.Category
+	if err := c.ShouldBindJSON(&updateData); err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !models.IsValidCategoryKind(updateData.Kind) {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid kind. Must be one of: income, expense, transfer")
+		return
+	}
+
+	// System categories are referenced by the backend and keep their name and kind
+	if existingCategory.IsSystem {
+		if updateData.Name != existingCategory.Name || updateData.Kind != existingCategory.Kind {
+			utilities.ErrorResponse(c, http.StatusBadRequest, "System categories cannot be renamed or change kind")
+			return
+		}
+	}
+
+	// Update allowed fields
+	existingCategory.Name = strings.TrimSpace(updateData.Name)
+	existingCategory.Kind = updateData.Kind
+	existingCategory.ParentID = updateData.ParentID
+	existingCategory.Icon = updateData.Icon
+	existingCategory.Color = updateData.Color
+	existingCategory.Active = updateData.Active
+	existingCategory.SortOrder = updateData.SortOrder
+
+	if message := validateCategoryParent(userID, &existingCategory); message != "" {
+		utilities.ErrorResponse(c, http.StatusBadRequest, message)
+		return
+	}
+
+	if err := database.DB.Save(&existingCategory).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to update category")
+		return
+	}
+
+	utilities.SuccessResponse(c, existingCategory, "Category updated successfully")
+}
+
+// DeleteCategory deletes a category. Categories still referenced by
+// transactions, budgets or bills can only be deleted with reassignTo, which
+// merges them into another category first. Child categories move up to the
+// deleted category's parent.
+func DeleteCategory(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	categoryID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid category ID")
+		return
+	}
+
+	var category models.Category
+	if err := database.DB.Where("id = ? AND user_id = ?", categoryID, userID).First(&category).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusNotFound, "Category not found")
+		return
+	}
+
+	if category.IsSystem {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "System categories cannot be deleted")
+		return
+	}
+
+	var target *models.Category
+	if reassignTo := c.Query("reassignTo"); reassignTo != "" {
+		target, err = models.FindCategory(database.DB, userID, reassignTo)
+		if err != nil || target.ID == category.ID {
+			utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid reassignTo category")
+			return
+		}
+	} else {
+		usage, err := countCategoryUsage(database.DB, userID, []string{category.Key})
+		if err != nil {
+			utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to check category usage")
+			return
+		}
+		if usage > 0 {
+			utilities.ErrorResponse(c, http.StatusConflict, "Category is in use, pass reassignTo to move its records to another category")
+			return
+		}
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if target != nil {
+			if _, err := repointCategory(tx, userID, []string{category.Key}, target.Key); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Model(&models.Category{}).Where("user_id = ? AND parent_id = ?", userID, category.ID).
+			Update("parent_id", category.ParentID).Error; err != nil {
+			return err
+		}
+
+		// Soft delete
+		return tx.Delete(&category).Error
+	})
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete category")
+		return
+	}
+
+	utilities.SuccessResponse(c, nil, "Category deleted successfully")
+}
+
+// MergeCategories re-points every transaction, budget, bill, credit card
+// transaction and recurring transaction from the source categories to the
+// target, moves child categories under the target and deletes the sources.
+// Sources may also be free-text keys that never had a category, which is how
+// legacy values such as "Groceries" are folded into "groceries".
+func MergeCategories(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req MergeCategoriesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	target, err := models.FindCategory(database.DB, userID, req.TargetCategory)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Target category not found")
+		return
+	}
+
+	var sourceKeys []string
+	var sourceCategories []models.Category
+	for _, source := range req.SourceCategories {
+		source = strings.TrimSpace(source)
+		if source == "" {
+			continue
+		}
+		if category, err := models.FindCategory(database.DB, userID, source); err == nil {
+			if category.ID == target.ID {
+				continue
+			}
+			if category.IsSystem {
+				utilities.ErrorResponse(c, http.StatusBadRequest, "System category "+category.Key+" cannot be merged")
+				return
+			}
+			sourceCategories = append(sourceCategories, *category)
+			sourceKeys = append(sourceKeys, category.Key)
+		} else if !strings.EqualFold(source, target.Key) {
+			sourceKeys = append(sourceKeys, source)
+		}
+	}
+
+	if len(sourceKeys) == 0 {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "At least one source category different from the target is required")
+		return
+	}
+
+	var updated map[string]int64
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		updated, err = repointCategory(tx, userID, sourceKeys, target.Key)
+		if err != nil {
+			return err
+		}
+
+		for i := range sourceCategories {
+			if err := tx.Model(&models.Category{}).Where("user_id = ? AND parent_id = ?", userID, sourceCategories[i].ID).
+				Update("parent_id", target.ID).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(&sourceCategories[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to merge categories")
+		return
+	}
+
+	result := map[string]interface{}{
+		"category":      target,
+		"mergedKeys":    sourceKeys,
+		"updatedCounts": updated,
+	}
+
+	utilities.SuccessResponse(c, result, "Categories merged successfully")
+}
+
+// resolveCategory validates a category reference (ID or key, matched
+// case-insensitively) for the user and returns the category. For income and
+// expense transactions the category kind must match the transaction type.
+// It returns an error message when the reference is invalid.
+func resolveCategory(userID uuid.UUID, idOrKey string, transactionType string) (*models.Category, string) {
+	if err := models.EnsureDefaultCategories(database.DB, userID); err != nil {
+		return nil, "Failed to load categories"
+	}
+
+	if strings.TrimSpace(idOrKey) == "" {
+		return nil, "Category is required"
+	}
+
+	category, err := models.FindCategory(database.DB, userID, idOrKey)
+	if err != nil {
+		return nil, "Invalid category: " + idOrKey
+	}
+
+	if (transactionType == models.CategoryKindIncome || transactionType == models.CategoryKindExpense) &&
+		category.Kind != transactionType {
+		return nil, "Category " + category.Key + " cannot be used for " + transactionType + " transactions"
+	}
+
+	return category, ""
+}
+
+// validateCategoryParent checks that the parent category belongs to the user,
+// has the same kind and is not the category itself or one of its descendants
+func validateCategoryParent(userID uuid.UUID, category *models.Category) string {
+	if category.ParentID == nil {
+		return ""
+	}
+
+	parentID := *category.ParentID
+	for depth := 0; ; depth++ {
+		if parentID == category.ID {
+			return "A category cannot be its own ancestor"
+		}
+
+		var parent models.Category
+		if err := database.DB.Where("id = ? AND user_id = ?", parentID, userID).First(&parent).Error; err != nil {
+			return "Invalid parent category"
+		}
+		if depth == 0 && parent.Kind != category.Kind {
+			return "Parent category must have the same kind"
+		}
+		if parent.ParentID == nil || depth > 32 {
+			return ""
+		}
+		parentID = *parent.ParentID
+	}
+}
+
+// repointCategory replaces the source category keys (case-insensitively)
+// with the target key on every record that references a category. Returns
+// the number of rows changed per table.
+func repointCategory(tx *gorm.DB, userID uuid.UUID, sourceKeys []string, targetKey string) (map[string]int64, error) {
+	lowered := make([]string, len(sourceKeys))
+	for i, key := range sourceKeys {
+		lowered[i] = strings.ToLower(key)
+	}
+
+	updated := make(map[string]int64)
+	for _, ref := range categoryReferences {
+		result := tx.Model(ref.Model).
+			Where("user_id = ? AND LOWER("+ref.Column+") IN ?", userID, lowered).
+			Update(ref.Column, targetKey)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		stmt := &gorm.Statement{DB: tx}
+		if err := stmt.Parse(ref.Model); err != nil {
+			return nil, err
+		}
+		updated[stmt.Schema.Table] += result.RowsAffected
+	}
+
+	// Assignments in the same month add up, so they cannot simply be re-pointed
+	merged, err := services.MergeEnvelopeAssignments(tx, userID, lowered, targetKey)
+	if err != nil {
+		return nil, err
+	}
+	updated["envelope_assignments"] = merged
+
+	return updated, nil
+}
+
+// countCategoryUsage counts the records referencing any of the category keys
+func countCategoryUsage(db *gorm.DB, userID uuid.UUID, keys []string) (int64, error) {
+	lowered := make([]string, len(keys))
+	for i, key := range keys {
+		lowered[i] = strings.ToLower(key)
+	}
+
+	var total int64
+	for _, ref := range categoryReferences {
+		var count int64
+		if err := db.Model(ref.Model).
+			Where("user_id = ? AND LOWER("+ref.Column+") IN ?", userID, lowered).
+			Count(&count).Error; err != nil {
+			return 0, err
+		}
+		total += count
+	}
+
+	var assignments int64
+	if err := db.Model(&models.EnvelopeAssignment{}).
+		Where("user_id = ? AND LOWER(category_id) IN ?", userID, lowered).
+		Count(&assignments).Error; err != nil {
+		return 0, err
+	}
+	total += assignments
+
+	return total, nil
+}
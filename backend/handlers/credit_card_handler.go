@@ -7,10 +7,12 @@ import (
 	"daybook-backend/database"
 	"daybook-backend/middleware"
 	"daybook-backend/models"
+	"daybook-backend/services"
 	"daybook-backend/utilities"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 // ListCreditCards returns all credit cards for the authenticated user
@@ -67,12 +69,34 @@ func CreateCreditCard(c *gin.Context) {
 		return
 	}
 
+	if card.RewardType != "" && !models.IsValidRewardType(card.RewardType) {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid reward type. Must be one of: cashback, points, miles")
+		return
+	}
+
 	card.UserID = userID
 
-	if err := database.DB.Create(&card).Error; err != nil {
+	// With a billing cycle the statement date is kept by the cycle
+	if card.HasBillingCycle() {
+		statementDate := card.NextStatementDate(time.Now())
+		card.StatementDate = &statementDate
+	}
+
+	// An existing balance is booked through the journal as an opening balance
+	opening := card.CurrentBalance
+	card.CurrentBalance = 0
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&card).Error; err != nil {
+			return err
+		}
+		return models.AdjustCreditCardBalance(tx, &card, opening, models.JournalKindOpening, "Opening balance for "+card.Name)
+	})
+	if err != nil {
 		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to create credit card")
 		return
 	}
+	card.CurrentBalance = opening
 
 	utilities.CreatedResponse(c, card, "Credit card created successfully")
 }
@@ -103,24 +127,52 @@ func UpdateCreditCard(c *gin.Context) {
 		return
 	}
 
+	if updateData.RewardType != "" && !models.IsValidRewardType(updateData.RewardType) {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid reward type. Must be one of: cashback, points, miles")
+		return
+	}
+
 	// Update allowed fields
 	existingCard.Name = updateData.Name
 	existingCard.LastFourDigits = updateData.LastFourDigits
 	existingCard.CardNetwork = updateData.CardNetwork
 	existingCard.CreditLimit = updateData.CreditLimit
-	existingCard.CurrentBalance = updateData.CurrentBalance
 	existingCard.APR = updateData.APR
 	existingCard.DueDate = updateData.DueDate
 	existingCard.StatementDate = updateData.StatementDate
 	existingCard.MinimumPayment = updateData.MinimumPayment
 	existingCard.RewardsProgram = updateData.RewardsProgram
+	existingCard.RewardType = updateData.RewardType
+	existingCard.RewardRate = updateData.RewardRate
+	existingCard.RewardExpiryMonths = updateData.RewardExpiryMonths
+	existingCard.StatementDay = updateData.StatementDay
+	existingCard.GracePeriodDays = updateData.GracePeriodDays
+	existingCard.MinPaymentPercent = updateData.MinPaymentPercent
+	existingCard.MinPaymentFloor = updateData.MinPaymentFloor
+	existingCard.LateFee = updateData.LateFee
 	existingCard.Active = updateData.Active
 	existingCard.Notes = updateData.Notes
 
-	if err := database.DB.Save(&existingCard).Error; err != nil {
+	// With a billing cycle the statement date is kept by the cycle
+	if existingCard.HasBillingCycle() {
+		statementDate := existingCard.NextStatementDate(time.Now())
+		existingCard.StatementDate = &statementDate
+	}
+
+	// A changed balance is booked as an adjustment; the column itself only
+	// changes through the journal
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("current_balance").Save(&existingCard).Error; err != nil {
+			return err
+		}
+		delta := updateData.CurrentBalance - existingCard.CurrentBalance
+		return models.AdjustCreditCardBalance(tx, &existingCard, delta, models.JournalKindAdjustment, "Balance adjustment for "+existingCard.Name)
+	})
+	if err != nil {
 		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to update credit card")
 		return
 	}
+	existingCard.CurrentBalance = updateData.CurrentBalance
 
 	utilities.SuccessResponse(c, existingCard, "Credit card updated successfully")
 }
@@ -181,9 +233,20 @@ func RecordCreditCardTransaction(c *gin.Context) {
 		return
 	}
 
+	// Payments come from an account and are recorded with RecordPayment
+	if ccTransaction.Type == "payment" {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Record card payments with POST /credit-cards/:id/payment")
+		return
+	}
+
 	ccTransaction.UserID = userID
 	ccTransaction.CardID = cardID
 
+	rules, ok := newTransactionRules(c, userID)
+	if !ok {
+		return
+	}
+
 	// Start database transaction
 	tx := database.DB.Begin()
 
@@ -207,6 +270,13 @@ func RecordCreditCardTransaction(c *gin.Context) {
 		mainTransaction.Type = "income"
 	}
 
+	// Rules may match the merchant too; the card record keeps what they set
+	if len(rules.Apply(&mainTransaction, ccTransaction.Merchant)) > 0 {
+		ccTransaction.CategoryID = mainTransaction.CategoryID
+		ccTransaction.Description = mainTransaction.Description
+		ccTransaction.Tags = mainTransaction.Tags
+	}
+
 	if err := tx.Create(&mainTransaction).Error; err != nil {
 		tx.Rollback()
 		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to create transaction record")
@@ -223,19 +293,17 @@ func RecordCreditCardTransaction(c *gin.Context) {
 		return
 	}
 
-	// Update card balance based on transaction type
-	if ccTransaction.Type == "purchase" || ccTransaction.Type == "fee" || ccTransaction.Type == "interest" {
-		card.CurrentBalance += ccTransaction.Amount
-	} else if ccTransaction.Type == "refund" {
-		card.CurrentBalance -= ccTransaction.Amount
-		if card.CurrentBalance < 0 {
-			card.CurrentBalance = 0
-		}
+	// Purchases, fees and interest add to what is owed; refunds reduce it
+	if err := mainTransaction.ApplyBalance(tx); err != nil {
+		tx.Rollback()
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to update card balance")
+		return
 	}
 
-	if err := tx.Save(&card).Error; err != nil {
+	// Purchases earn rewards under the card's rewards program
+	if _, err := services.EarnRewards(tx, &card, &ccTransaction); err != nil {
 		tx.Rollback()
-		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to update card balance")
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to record rewards")
 		return
 	}
 
@@ -244,7 +312,11 @@ func RecordCreditCardTransaction(c *gin.Context) {
 	utilities.CreatedResponse(c, ccTransaction, "Transaction recorded successfully")
 }
 
-// GetCreditCardTransactions returns all transactions for a credit card
+// creditCardTransactionKeyset orders credit card transactions newest first
+var creditCardTransactionKeyset = keyset{name: "transactions", table: "credit_card_transactions", column: "date", descending: true}
+
+// GetCreditCardTransactions returns all transactions for a credit card, or a
+// page of them when a limit or cursor is given
 func GetCreditCardTransactions(c *gin.Context) {
 	userID, err := middleware.GetUserID(c)
 	if err != nil {
@@ -265,9 +337,21 @@ func GetCreditCardTransactions(c *gin.Context) {
 		return
 	}
 
+	query := database.DB.Where("card_id = ? AND user_id = ?", cardID, userID)
+
 	var transactions []models.CreditCardTransaction
-	if err := database.DB.Where("card_id = ? AND user_id = ?", cardID, userID).
-		Order("date DESC").Find(&transactions).Error; err != nil {
+	if wantsPage(c) {
+		pagination, ok := paginate(c, query, creditCardTransactionKeyset, func(t *models.CreditCardTransaction) cursorKey {
+			return cursorKey{Sort: t.Date, CreatedAt: t.CreatedAt, ID: t.ID}
+		}, &transactions)
+		if !ok {
+			return
+		}
+		utilities.SuccessResponse(c, gin.H{"transactions": transactions, "pagination": pagination}, "Transactions retrieved successfully")
+		return
+	}
+
+	if err := query.Order("date DESC").Find(&transactions).Error; err != nil {
 		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch transactions")
 		return
 	}
@@ -302,38 +386,46 @@ func DeleteCreditCardTransaction(c *gin.Context) {
 		return
 	}
 
-	// Get the card
-	var card models.CreditCard
-	if err := database.DB.Where("id = ? AND user_id = ?", cardID, userID).First(&card).Error; err != nil {
-		utilities.ErrorResponse(c, http.StatusNotFound, "Credit card not found")
-		return
-	}
-
 	// Start database transaction
 	tx := database.DB.Begin()
 
-	// Delete the linked main transaction first (if it exists)
+	// Reverse and delete the linked main transaction first (if it exists)
 	if transaction.TransactionID != uuid.Nil {
-		if err := tx.Delete(&models.Transaction{}, "id = ?", transaction.TransactionID).Error; err != nil {
+		var linked models.Transaction
+		if err := tx.Where("id = ? AND user_id = ?", transaction.TransactionID, userID).Limit(1).Find(&linked).Error; err != nil {
 			tx.Rollback()
-			utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete linked transaction")
+			utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to load linked transaction")
 			return
 		}
-	}
 
-	// Reverse the balance change
-	if transaction.Type == "purchase" || transaction.Type == "fee" || transaction.Type == "interest" {
-		card.CurrentBalance -= transaction.Amount
-		if card.CurrentBalance < 0 {
-			card.CurrentBalance = 0
+		if linked.ID != uuid.Nil {
+			if err := linked.RevertBalance(tx); err != nil {
+				tx.Rollback()
+				utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to update card balance")
+				return
+			}
+			if err := tx.Delete(&linked).Error; err != nil {
+				tx.Rollback()
+				utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete linked transaction")
+				return
+			}
+			if err := tx.Where("transaction_id = ?", linked.ID).Delete(&models.CreditCardPayment{}).Error; err != nil {
+				tx.Rollback()
+				utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete payment record")
+				return
+			}
+			if err := services.RemoveTransactionBillPayments(tx, linked.ID); err != nil {
+				tx.Rollback()
+				utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete bill payment")
+				return
+			}
 		}
-	} else if transaction.Type == "refund" {
-		card.CurrentBalance += transaction.Amount
 	}
 
-	if err := tx.Save(&card).Error; err != nil {
+	// Rewards earned by the transaction go with it unless already redeemed
+	if err := tx.Where("transaction_id = ? AND redeemed_amount = 0", transaction.ID).Delete(&models.Reward{}).Error; err != nil {
 		tx.Rollback()
-		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to update card balance")
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete rewards")
 		return
 	}
 
@@ -344,6 +436,15 @@ func DeleteCreditCardTransaction(c *gin.Context) {
 		return
 	}
 
+	// A removed payment may leave a statement unpaid again
+	if transaction.Type == "payment" {
+		if err := services.UpdateStatementPayments(tx, cardID); err != nil {
+			tx.Rollback()
+			utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to update statements")
+			return
+		}
+	}
+
 	tx.Commit()
 
 	utilities.SuccessResponse(c, nil, "Transaction deleted successfully")
@@ -364,10 +465,10 @@ func RecordPayment(c *gin.Context) {
 	}
 
 	var paymentData struct {
-		Amount      float64    `json:"amount" binding:"required,gt=0"`
-		AccountID   string     `json:"accountId" binding:"required"`
-		PaymentDate *time.Time `json:"paymentDate"`
-		Description string     `json:"description"`
+		Amount      models.Money `json:"amount" binding:"required,gt=0"`
+		AccountID   string       `json:"accountId" binding:"required"`
+		PaymentDate *time.Time   `json:"paymentDate"`
+		Description string       `json:"description"`
 	}
 
 	if err := c.ShouldBindJSON(&paymentData); err != nil {
@@ -395,8 +496,25 @@ func RecordPayment(c *gin.Context) {
 		return
 	}
 
+	paymentDate := time.Now()
+	if paymentData.PaymentDate != nil {
+		paymentDate = *paymentData.PaymentDate
+	}
+
+	// The amount is in the account's currency; convert it for the card
+	converter, err := models.NewCurrencyConverter(database.DB, userID)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to load exchange rates")
+		return
+	}
+	cardAmount, err := converter.Convert(paymentData.Amount, account.Currency, card.Currency, paymentDate)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	// Validate payment amount
-	if paymentData.Amount > card.CurrentBalance {
+	if cardAmount > card.CurrentBalance {
 		utilities.ErrorResponse(c, http.StatusBadRequest, "Payment amount exceeds current balance")
 		return
 	}
@@ -406,11 +524,6 @@ func RecordPayment(c *gin.Context) {
 		return
 	}
 
-	paymentDate := time.Now()
-	if paymentData.PaymentDate != nil {
-		paymentDate = *paymentData.PaymentDate
-	}
-
 	// Start transaction
 	tx := database.DB.Begin()
 
@@ -419,7 +532,7 @@ func RecordPayment(c *gin.Context) {
 	transaction := models.Transaction{
 		UserID:       userID,
 		AccountID:    accountID,
-		CategoryID:   "credit_card_payment",
+		CategoryID:   models.CategoryCreditCardPayment,
 		Amount:       paymentData.Amount,
 		Type:         "expense",
 		Date:         paymentDate,
@@ -432,31 +545,37 @@ func RecordPayment(c *gin.Context) {
 		transaction.Description = "Credit card payment: " + card.Name
 	}
 
+	// Keep the amount credited to the card when the currencies differ
+	if card.Currency != account.Currency {
+		rate := cardAmount.Ratio(paymentData.Amount)
+		transaction.ToAmount = &cardAmount
+		transaction.ExchangeRate = &rate
+	}
+
 	if err := tx.Create(&transaction).Error; err != nil {
 		tx.Rollback()
 		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to create transaction")
 		return
 	}
 
-	// Deduct from account balance
-	account.Balance -= paymentData.Amount
-	if err := tx.Save(&account).Error; err != nil {
+	// Move the money from the account to the card
+	if err := transaction.ApplyBalance(tx); err != nil {
 		tx.Rollback()
-		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to update account balance")
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to update balances")
 		return
 	}
 
-	// Update card balance and payment info
-	card.CurrentBalance -= paymentData.Amount
-	if card.CurrentBalance < 0 {
-		card.CurrentBalance = 0
-	}
+	// Update payment info
+	card.CurrentBalance -= cardAmount
 	card.LastPaymentDate = &paymentDate
-	card.LastPaymentAmount = paymentData.Amount
+	card.LastPaymentAmount = cardAmount
 
-	if err := tx.Save(&card).Error; err != nil {
+	if err := tx.Model(&card).Updates(map[string]interface{}{
+		"last_payment_date":   paymentDate,
+		"last_payment_amount": cardAmount,
+	}).Error; err != nil {
 		tx.Rollback()
-		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to update card balance")
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to update card")
 		return
 	}
 
@@ -479,12 +598,13 @@ func RecordPayment(c *gin.Context) {
 
 	// Create credit card transaction record for the payment
 	ccTransaction := models.CreditCardTransaction{
-		UserID:      userID,
-		CardID:      cardID,
-		Amount:      paymentData.Amount,
-		Description: paymentData.Description,
-		Date:        paymentDate,
-		Type:        "payment",
+		UserID:        userID,
+		CardID:        cardID,
+		TransactionID: transaction.ID,
+		Amount:        cardAmount,
+		Description:   paymentData.Description,
+		Date:          paymentDate,
+		Type:          "payment",
 	}
 
 	if err := tx.Create(&ccTransaction).Error; err != nil {
@@ -493,6 +613,12 @@ func RecordPayment(c *gin.Context) {
 		return
 	}
 
+	if err := services.UpdateStatementPayments(tx, cardID); err != nil {
+		tx.Rollback()
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to update statements")
+		return
+	}
+
 	tx.Commit()
 
 	response := map[string]interface{}{
@@ -534,6 +660,63 @@ func GetPayments(c *gin.Context) {
 	utilities.SuccessResponse(c, payments, "Payments retrieved successfully")
 }
 
+// SimulatePayoff compares paying off the card's current balance with the
+// minimum payment, a fixed monthly payment and in full
+func SimulatePayoff(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	cardID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid credit card ID")
+		return
+	}
+
+	var card models.CreditCard
+	if err := database.DB.Where("id = ? AND user_id = ?", cardID, userID).First(&card).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusNotFound, "Credit card not found")
+		return
+	}
+
+	// Optional fixed monthly payment in the card's currency
+	var payment models.Money
+	if paymentParam := c.Query("payment"); paymentParam != "" {
+		payment, err = models.ParseMoney(paymentParam)
+		if err != nil || payment <= 0 {
+			utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid payment amount")
+			return
+		}
+	}
+
+	// The first payment falls on the card's next due date
+	now := time.Now()
+	firstPayment := now.AddDate(0, 1, 0)
+	if card.DueDate != nil && card.DueDate.After(now) {
+		firstPayment = *card.DueDate
+	}
+
+	plans := []services.PayoffPlan{
+		services.SimulatePayoff(&card, card.CurrentBalance, services.PayoffMinimum, 0, firstPayment),
+	}
+	if payment > 0 {
+		plans = append(plans, services.SimulatePayoff(&card, card.CurrentBalance, services.PayoffFixed, payment, firstPayment))
+	}
+	plans = append(plans, services.SimulatePayoff(&card, card.CurrentBalance, services.PayoffPayInFull, 0, firstPayment))
+
+	result := map[string]interface{}{
+		"cardId":   card.ID,
+		"currency": card.Currency,
+		"balance":  card.CurrentBalance,
+		"apr":      card.APR,
+		"plans":    plans,
+	}
+
+	utilities.SuccessResponse(c, result, "Payoff simulation calculated successfully")
+}
+
 // GetStatements returns statements for a credit card
 func GetStatements(c *gin.Context) {
 	userID, err := middleware.GetUserID(c)
@@ -580,6 +763,7 @@ func CreateStatement(c *gin.Context) {
 	}
 
 	statement.UserID = userID
+	statement.Generated = false
 
 	// Verify card belongs to user
 	var card models.CreditCard
@@ -640,6 +824,14 @@ func RecordReward(c *gin.Context) {
 	}
 
 	reward.UserID = userID
+	reward.TransactionID = nil
+	reward.RuleID = nil
+
+	// A reward recorded as redeemed is redeemed in full
+	reward.RedeemedAmount = 0
+	if reward.Redeemed {
+		reward.RedeemedAmount = reward.Amount
+	}
 
 	// Verify card belongs to user
 	var card models.CreditCard
@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"daybook-backend/database"
+	"daybook-backend/middleware"
+	"daybook-backend/models"
+	"daybook-backend/services"
+	"daybook-backend/utilities"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AssignEnvelopeRequest sets the money assigned to an envelope in a month
+type AssignEnvelopeRequest struct {
+	CategoryID string       `json:"categoryId" binding:"required"`
+	Month      string       `json:"month" binding:"required"` // YYYY-MM
+	Amount     models.Money `json:"amount" binding:"min=0"`
+}
+
+// MoveEnvelopeRequest moves money from one envelope to another in a month
+type MoveEnvelopeRequest struct {
+	FromCategoryID string       `json:"fromCategoryId" binding:"required"`
+	ToCategoryID   string       `json:"toCategoryId" binding:"required"`
+	Month          string       `json:"month" binding:"required"` // YYYY-MM
+	Amount         models.Money `json:"amount" binding:"required,gt=0"`
+	Notes          string       `json:"notes"`
+}
+
+// GetEnvelopes returns the envelope budget of the month query parameter,
+// the current month by default
+func GetEnvelopes(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	month := services.EnvelopeMonthStart(time.Now(), services.UserBudgetCalendar(database.DB, userID))
+	if monthParam := c.Query("month"); monthParam != "" {
+		if parsedMonth, err := time.Parse(models.EnvelopeMonthFormat, monthParam); err == nil {
+			month = parsedMonth
+		}
+	}
+
+	view, err := services.CalculateEnvelopeMonth(database.DB, userID, month)
+	if errors.Is(err, models.ErrExchangeRateNotFound) {
+		utilities.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to calculate envelopes")
+		return
+	}
+
+	utilities.SuccessResponse(c, view, "Envelopes retrieved successfully")
+}
+
+// AssignEnvelope sets how much of the money to assign goes to an expense
+// category's envelope in a month, creating the envelope if needed
+func AssignEnvelope(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req AssignEnvelopeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	month, err := time.Parse(models.EnvelopeMonthFormat, req.Month)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid month, expected YYYY-MM")
+		return
+	}
+
+	// Envelopes hold money for spending, so they belong to expense categories
+	category, message := resolveCategory(userID, req.CategoryID, models.CategoryKindExpense)
+	if message != "" {
+		utilities.ErrorResponse(c, http.StatusBadRequest, message)
+		return
+	}
+
+	if err := services.AssignEnvelope(database.DB, userID, category.Key, month, req.Amount); err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to assign money")
+		return
+	}
+
+	view, err := services.CalculateEnvelopeMonth(database.DB, userID, month)
+	if errors.Is(err, models.ErrExchangeRateNotFound) {
+		utilities.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to calculate envelopes")
+		return
+	}
+
+	utilities.SuccessResponse(c, view, "Money assigned successfully")
+}
+
+// MoveEnvelopeMoney moves money between envelopes, such as to cover an
+// overspent envelope
+func MoveEnvelopeMoney(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req MoveEnvelopeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	month, err := time.Parse(models.EnvelopeMonthFormat, req.Month)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid month, expected YYYY-MM")
+		return
+	}
+
+	from, message := resolveCategory(userID, req.FromCategoryID, models.CategoryKindExpense)
+	if message != "" {
+		utilities.ErrorResponse(c, http.StatusBadRequest, message)
+		return
+	}
+	to, message := resolveCategory(userID, req.ToCategoryID, models.CategoryKindExpense)
+	if message != "" {
+		utilities.ErrorResponse(c, http.StatusBadRequest, message)
+		return
+	}
+
+	move := models.EnvelopeMove{
+		UserID:         userID,
+		Month:          month,
+		FromCategoryID: from.Key,
+		ToCategoryID:   to.Key,
+		Amount:         req.Amount,
+		Notes:          req.Notes,
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		return services.MoveEnvelopeMoney(tx, &move)
+	})
+	if errors.Is(err, services.ErrEnvelopeInsufficientFunds) || errors.Is(err, services.ErrEnvelopeSameCategory) ||
+		errors.Is(err, models.ErrExchangeRateNotFound) {
+		utilities.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to move money")
+		return
+	}
+
+	view, err := services.CalculateEnvelopeMonth(database.DB, userID, month)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to calculate envelopes")
+		return
+	}
+
+	result := map[string]interface{}{
+		"move":      move,
+		"envelopes": view,
+	}
+
+	utilities.CreatedResponse(c, result, "Money moved successfully")
+}
+
+// ListEnvelopeMoves returns the money moved between envelopes, optionally
+// in one month, newest first
+func ListEnvelopeMoves(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	query := database.DB.Where("user_id = ?", userID)
+
+	// Optional filter by month
+	if monthParam := c.Query("month"); monthParam != "" {
+		if month, err := time.Parse(models.EnvelopeMonthFormat, monthParam); err == nil {
+			query = query.Where("month = ?", month)
+		}
+	}
+
+	// Optional filter by envelope on either side of the move
+	if categoryID := c.Query("categoryId"); categoryID != "" {
+		query = query.Where("from_category_id = LOWER(?) OR to_category_id = LOWER(?)", categoryID, categoryID)
+	}
+
+	var moves []models.EnvelopeMove
+	if err := query.Order("created_at DESC").Find(&moves).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch moves")
+		return
+	}
+
+	utilities.SuccessResponse(c, moves, "Moves retrieved successfully")
+}
+
+// DeleteEnvelopeMove undoes money moved between envelopes
+func DeleteEnvelopeMove(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	moveID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid move ID")
+		return
+	}
+
+	var move models.EnvelopeMove
+	if err := database.DB.Where("id = ? AND user_id = ?", moveID, userID).First(&move).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusNotFound, "Move not found")
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		return services.UndoEnvelopeMove(tx, &move)
+	})
+	if errors.Is(err, services.ErrEnvelopeInsufficientFunds) || errors.Is(err, models.ErrExchangeRateNotFound) {
+		utilities.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to undo move")
+		return
+	}
+
+	utilities.SuccessResponse(c, nil, "Move undone successfully")
+}
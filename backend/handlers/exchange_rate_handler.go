@@ -0,0 +1,382 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"daybook-backend/database"
+	"daybook-backend/middleware"
+	"daybook-backend/models"
+	"daybook-backend/utilities"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// exchangeRateImportRow is one rate in a JSON import
+type exchangeRateImportRow struct {
+	Date          string  `json:"date"`
+	BaseCurrency  string  `json:"baseCurrency"`
+	QuoteCurrency string  `json:"quoteCurrency"`
+	Rate          float64 `json:"rate"`
+}
+
+// ListExchangeRates returns the user's exchange rates, newest first
+func ListExchangeRates(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	query := database.DB.Where("user_id = ?", userID)
+
+	if base := c.Query("baseCurrency"); base != "" {
+		query = query.Where("base_currency = ?", strings.ToUpper(base))
+	}
+
+	if quote := c.Query("quoteCurrency"); quote != "" {
+		query = query.Where("quote_currency = ?", strings.ToUpper(quote))
+	}
+
+	if startDate := c.Query("startDate"); startDate != "" {
+		if parsedDate, err := time.Parse("2006-01-02", startDate); err == nil {
+			query = query.Where("date >= ?", parsedDate)
+		}
+	}
+
+	if endDate := c.Query("endDate"); endDate != "" {
+		if parsedDate, err := time.Parse("2006-01-02", endDate); err == nil {
+			query = query.Where("date <= ?", parsedDate)
+		}
+	}
+
+	var rates []models.ExchangeRate
+	if err := query.Order("date DESC, base_currency ASC, quote_currency ASC").Find(&rates).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch exchange rates")
+		return
+	}
+
+	utilities.SuccessResponse(c, rates, "Exchange rates retrieved successfully")
+}
+
+// CreateExchangeRate records a manual rate. A rate for the same pair and day
+// is replaced.
+func CreateExchangeRate(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var rate models.ExchangeRate
+	if err := c.ShouldBindJSON(&rate); err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rate.ID = uuid.Nil
+	rate.UserID = userID
+	rate.Source = "manual"
+	rate.Normalize()
+
+	if rate.BaseCurrency == rate.QuoteCurrency {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Base and quote currency must differ")
+		return
+	}
+
+	if err := upsertExchangeRates(database.DB, []models.ExchangeRate{rate}); err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to save exchange rate")
+		return
+	}
+
+	database.DB.Where("user_id = ? AND base_currency = ? AND quote_currency = ? AND date = ?",
+		userID, rate.BaseCurrency, rate.QuoteCurrency, rate.Date).First(&rate)
+
+	utilities.CreatedResponse(c, rate, "Exchange rate saved successfully")
+}
+
+// UpdateExchangeRate changes the rate of an existing entry
+func UpdateExchangeRate(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	rateID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid exchange rate ID")
+		return
+	}
+
+	var existingRate models.ExchangeRate
+	if err := database.DB.Where("id = ? AND user_id = ?", rateID, userID).First(&existingRate).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusNotFound, "Exchange rate not found")
+		return
+	}
+
+	var updateData struct {
+		Rate float64 `json:"rate" binding:"required,gt=0"`
+	}
+	if err := c.ShouldBindJSON(&updateData); err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	existingRate.Rate = updateData.Rate
+	existingRate.Source = "manual"
+
+	if err := database.DB.Save(&existingRate).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to update exchange rate")
+		return
+	}
+
+	utilities.SuccessResponse(c, existingRate, "Exchange rate updated successfully")
+}
+
+// DeleteExchangeRate deletes an exchange rate
+func DeleteExchangeRate(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	rateID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid exchange rate ID")
+		return
+	}
+
+	result := database.DB.Where("id = ? AND user_id = ?", rateID, userID).Delete(&models.ExchangeRate{})
+	if result.Error != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete exchange rate")
+		return
+	}
+	if result.RowsAffected == 0 {
+		utilities.ErrorResponse(c, http.StatusNotFound, "Exchange rate not found")
+		return
+	}
+
+	utilities.SuccessResponse(c, nil, "Exchange rate deleted successfully")
+}
+
+// ImportExchangeRates imports historical rates from CSV or JSON, either as a
+// multipart "file" upload or as the raw request body. CSV files need a header
+// row with date, baseCurrency, quoteCurrency and rate columns; JSON is an
+// array of objects with the same fields. Existing rates for the same pair and
+// day are replaced.
+func ImportExchangeRates(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var body io.Reader = c.Request.Body
+	isJSON := strings.Contains(c.ContentType(), "json")
+	if file, fileHeader, err := c.Request.FormFile("file"); err == nil {
+		defer file.Close()
+		body = file
+		isJSON = strings.EqualFold(filepath.Ext(fileHeader.Filename), ".json")
+	}
+
+	var rows []exchangeRateImportRow
+	if isJSON {
+		err = json.NewDecoder(body).Decode(&rows)
+	} else {
+		rows, err = parseExchangeRateCSV(body)
+	}
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid file: "+err.Error())
+		return
+	}
+
+	var rates []models.ExchangeRate
+	var rowErrors []string
+	for i, row := range rows {
+		rate, err := row.toExchangeRate(userID)
+		if err != nil {
+			rowErrors = append(rowErrors, fmt.Sprintf("row %d: %v", i+1, err))
+			continue
+		}
+		rates = append(rates, rate)
+	}
+
+	if len(rates) == 0 {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "No valid exchange rates found")
+		return
+	}
+
+	if err := upsertExchangeRates(database.DB, rates); err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to import exchange rates")
+		return
+	}
+
+	result := map[string]interface{}{
+		"importedCount": len(rates),
+		"failedCount":   len(rowErrors),
+		"errors":        rowErrors,
+	}
+
+	utilities.SuccessResponse(c, result, "Exchange rates imported successfully")
+}
+
+// ConvertCurrency converts an amount between currencies using the stored rate
+// as of the given date (default today)
+func ConvertCurrency(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	amount, err := models.ParseMoney(c.Query("amount"))
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid amount")
+		return
+	}
+
+	from := strings.ToUpper(c.Query("from"))
+	to := strings.ToUpper(c.DefaultQuery("to", models.UserCurrency(database.DB, userID)))
+	if from == "" {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "from currency is required")
+		return
+	}
+
+	date := time.Now()
+	if dateParam := c.Query("date"); dateParam != "" {
+		if date, err = time.Parse("2006-01-02", dateParam); err != nil {
+			utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid date format, use YYYY-MM-DD")
+			return
+		}
+	}
+
+	converter, err := models.NewCurrencyConverter(database.DB, userID)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to load exchange rates")
+		return
+	}
+
+	rate, err := converter.Rate(from, to, date)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	converted, _ := converter.Convert(amount, from, to, date)
+
+	result := map[string]interface{}{
+		"amount":          amount,
+		"from":            from,
+		"to":              to,
+		"date":            date.Format("2006-01-02"),
+		"rate":            rate,
+		"convertedAmount": converted,
+	}
+
+	utilities.SuccessResponse(c, result, "Amount converted successfully")
+}
+
+// upsertExchangeRates inserts rates, replacing any rate already stored for
+// the same pair and day
+func upsertExchangeRates(db *gorm.DB, rates []models.ExchangeRate) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "base_currency"}, {Name: "quote_currency"}, {Name: "date"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rate", "source", "updated_at"}),
+	}).CreateInBatches(&rates, 500).Error
+}
+
+// parseExchangeRateCSV reads rates from CSV with a header row. Column names
+// are matched case-insensitively, and base/from and quote/to are accepted as
+// aliases.
+func parseExchangeRateCSV(r io.Reader) ([]exchangeRateImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "date":
+			columns["date"] = i
+		case "basecurrency", "base_currency", "base", "from":
+			columns["base"] = i
+		case "quotecurrency", "quote_currency", "quote", "to":
+			columns["quote"] = i
+		case "rate":
+			columns["rate"] = i
+		}
+	}
+	for _, required := range []string{"date", "base", "quote", "rate"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("missing %s column", required)
+		}
+	}
+
+	var rows []exchangeRateImportRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		rate, err := strconv.ParseFloat(strings.TrimSpace(record[columns["rate"]]), 64)
+		if err != nil {
+			rate = 0
+		}
+		rows = append(rows, exchangeRateImportRow{
+			Date:          record[columns["date"]],
+			BaseCurrency:  record[columns["base"]],
+			QuoteCurrency: record[columns["quote"]],
+			Rate:          rate,
+		})
+	}
+
+	return rows, nil
+}
+
+// toExchangeRate validates an imported row
+func (row exchangeRateImportRow) toExchangeRate(userID uuid.UUID) (models.ExchangeRate, error) {
+	date, err := time.Parse("2006-01-02", strings.TrimSpace(row.Date))
+	if err != nil {
+		if date, err = time.Parse(time.RFC3339, strings.TrimSpace(row.Date)); err != nil {
+			return models.ExchangeRate{}, fmt.Errorf("invalid date %q", row.Date)
+		}
+	}
+
+	rate := models.ExchangeRate{
+		UserID:        userID,
+		BaseCurrency:  row.BaseCurrency,
+		QuoteCurrency: row.QuoteCurrency,
+		Rate:          row.Rate,
+		Date:          date,
+		Source:        "import",
+	}
+	rate.Normalize()
+
+	if len(rate.BaseCurrency) != 3 || len(rate.QuoteCurrency) != 3 || rate.BaseCurrency == rate.QuoteCurrency {
+		return models.ExchangeRate{}, fmt.Errorf("invalid currency pair %s/%s", row.BaseCurrency, row.QuoteCurrency)
+	}
+	if rate.Rate <= 0 {
+		return models.ExchangeRate{}, fmt.Errorf("invalid rate")
+	}
+
+	return rate, nil
+}
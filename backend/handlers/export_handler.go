@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"daybook-backend/database"
+	"daybook-backend/middleware"
+	"daybook-backend/services"
+	"daybook-backend/utilities"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ExportResource streams one resource as CSV or JSON
+func ExportResource(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	resource := c.Param("resource")
+	format := strings.ToLower(c.DefaultQuery("format", "csv"))
+	if format != "csv" && format != "json" {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid format. Must be one of: csv, json")
+		return
+	}
+
+	if err := services.ValidateExport(resource, format); err != nil {
+		if errors.Is(err, services.ErrUnknownExport) {
+			utilities.ErrorResponse(c, http.StatusNotFound, "Unknown export resource")
+			return
+		}
+		utilities.ErrorResponse(c, http.StatusBadRequest, "This resource is only available as JSON")
+		return
+	}
+
+	filter := exportFilter(c)
+	fileName := exportFileName(resource, format)
+
+	if format == "csv" {
+		startExport(c, "text/csv; charset=utf-8", fileName)
+		err = services.WriteCSVExport(c.Writer, database.DB, userID, resource, filter)
+	} else {
+		startExport(c, "application/json; charset=utf-8", fileName)
+		err = services.WriteJSONExport(c.Writer, database.DB, userID, resource, filter)
+	}
+	finishExport(c, userID, fileName, err)
+}
+
+// ExportArchive streams all of the user's data as a single JSON document
+func ExportArchive(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	fileName := exportFileName("archive", "json")
+	startExport(c, "application/json; charset=utf-8", fileName)
+	err = services.WriteJSONArchive(c.Writer, database.DB, userID, services.ExportFilter{IncludeTracking: true})
+	finishExport(c, userID, fileName, err)
+}
+
+// ExportLedger streams transactions as a ledger, hledger or beancount journal
+func ExportLedger(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	format := strings.ToLower(c.DefaultQuery("format", services.LedgerFormatLedger))
+	if !services.IsValidLedgerFormat(format) {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid format. Must be one of: ledger, hledger, beancount")
+		return
+	}
+
+	extension := "journal"
+	if format == services.LedgerFormatBeancount {
+		extension = "beancount"
+	}
+
+	fileName := exportFileName("transactions", extension)
+	startExport(c, "text/plain; charset=utf-8", fileName)
+	err = services.WriteLedgerExport(c.Writer, database.DB, userID, format, exportFilter(c))
+	finishExport(c, userID, fileName, err)
+}
+
+// exportFilter reads the optional transaction filters shared by all exports
+func exportFilter(c *gin.Context) services.ExportFilter {
+	var filter services.ExportFilter
+
+	if startDate := c.Query("startDate"); startDate != "" {
+		if parsedDate, err := time.Parse("2006-01-02", startDate); err == nil {
+			filter.StartDate = &parsedDate
+		}
+	}
+
+	if endDate := c.Query("endDate"); endDate != "" {
+		if parsedDate, err := time.Parse("2006-01-02", endDate); err == nil {
+			// Include the whole end day
+			endOfDay := parsedDate.Add(24*time.Hour - time.Nanosecond)
+			filter.EndDate = &endOfDay
+		}
+	}
+
+	if accountID := c.Query("accountId"); accountID != "" {
+		if parsedID, err := uuid.Parse(accountID); err == nil {
+			filter.AccountID = &parsedID
+		}
+	}
+
+	return filter
+}
+
+func exportFileName(resource, extension string) string {
+	return fmt.Sprintf("daybook-%s-%s.%s", resource, time.Now().Format("2006-01-02"), extension)
+}
+
+// startExport sets the download headers. Nothing is sent until the first
+// write, so an export that fails early can still return a JSON error.
+func startExport(c *gin.Context, contentType, fileName string) {
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+	c.Status(http.StatusOK)
+}
+
+// finishExport reports a failed export as an error response if nothing was
+// written yet, and otherwise logs it and ends the truncated download
+func finishExport(c *gin.Context, userID uuid.UUID, fileName string, err error) {
+	if err == nil {
+		return
+	}
+	log.Printf("Export %s for user %s failed: %v", fileName, userID, err)
+	if !c.Writer.Written() {
+		c.Header("Content-Disposition", "")
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to export data")
+		return
+	}
+	c.Abort()
+}
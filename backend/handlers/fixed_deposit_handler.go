@@ -62,7 +62,9 @@ func GetFixedDeposit(c *gin.Context) {
 }
 
 // calculateMaturityAmount calculates the maturity amount based on compounding
-func calculateMaturityAmount(principal float64, rate float64, tenureMonths int, compounding string) float64 {
+func calculateMaturityAmount(principal models.Money, rate float64, tenureMonths int, compounding string) models.Money {
+	p := principal.Float64()
+
 	// Convert annual rate to decimal
 	r := rate / 100.0
 
@@ -74,39 +76,39 @@ func calculateMaturityAmount(principal float64, rate float64, tenureMonths int,
 	switch compounding {
 	case "simple":
 		// Simple Interest: A = P(1 + rt)
-		maturityAmount = principal * (1 + r*t)
+		maturityAmount = p * (1 + r*t)
 
 	case "daily":
 		// Daily compounding: A = P(1 + r/365)^(365*t)
 		n := 365.0
-		maturityAmount = principal * math.Pow(1+r/n, n*t)
+		maturityAmount = p * math.Pow(1+r/n, n*t)
 
 	case "monthly":
 		// Monthly compounding: A = P(1 + r/12)^(12*t)
 		n := 12.0
-		maturityAmount = principal * math.Pow(1+r/n, n*t)
+		maturityAmount = p * math.Pow(1+r/n, n*t)
 
 	case "quarterly":
 		// Quarterly compounding: A = P(1 + r/4)^(4*t)
 		n := 4.0
-		maturityAmount = principal * math.Pow(1+r/n, n*t)
+		maturityAmount = p * math.Pow(1+r/n, n*t)
 
 	case "semi-annually":
 		// Semi-annual compounding: A = P(1 + r/2)^(2*t)
 		n := 2.0
-		maturityAmount = principal * math.Pow(1+r/n, n*t)
+		maturityAmount = p * math.Pow(1+r/n, n*t)
 
 	case "annually":
 		// Annual compounding: A = P(1 + r)^t
-		maturityAmount = principal * math.Pow(1+r, t)
+		maturityAmount = p * math.Pow(1+r, t)
 
 	default:
 		// Default to monthly compounding
 		n := 12.0
-		maturityAmount = principal * math.Pow(1+r/n, n*t)
+		maturityAmount = p * math.Pow(1+r/n, n*t)
 	}
 
-	return maturityAmount
+	return models.NewMoneyFromFloat(maturityAmount)
 }
 
 // CreateFixedDeposit creates a new fixed deposit
@@ -309,9 +311,9 @@ func WithdrawFixedDeposit(c *gin.Context) {
 	}
 
 	var withdrawalData struct {
-		AccountID            uuid.UUID  `json:"accountId" binding:"required"`
-		WithdrawnDate        *time.Time `json:"withdrawnDate"`
-		ActualMaturityAmount float64    `json:"actualMaturityAmount" binding:"required,gt=0"`
+		AccountID            uuid.UUID    `json:"accountId" binding:"required"`
+		WithdrawnDate        *time.Time   `json:"withdrawnDate"`
+		ActualMaturityAmount models.Money `json:"actualMaturityAmount" binding:"required,gt=0"`
 	}
 
 	if err := c.ShouldBindJSON(&withdrawalData); err != nil {
@@ -396,7 +398,7 @@ func WithdrawFixedDeposit(c *gin.Context) {
 	// Calculate interest earned and penalty (if withdrawn early)
 	interestEarned := deposit.ActualMaturityAmount - deposit.Principal
 	isEarlyWithdrawal := deposit.WithdrawnDate.Before(deposit.MaturityDate)
-	var penalty float64
+	var penalty models.Money
 
 	if isEarlyWithdrawal {
 		// If withdrawn early, penalty is the difference between expected and actual
@@ -279,7 +279,7 @@ func AddHolding(c *gin.Context) {
 			AccountID:   trackingAccountID,
 			Type:        "tracking", // Special type that won't appear in regular transaction lists
 			Amount:      holdingData.Amount,
-			CategoryID:  "goal_external_holding",
+			CategoryID:  models.CategoryGoalExternalHolding,
 			Date:        holdingData.PurchaseDate,
 			Description: "External " + holdingData.Name + " tracked for " + goal.Name,
 			Tags:        []string{"goal", "holding", "external", "tracking", "hidden"},
@@ -291,7 +291,7 @@ func AddHolding(c *gin.Context) {
 			AccountID:   *holdingData.AccountID,
 			Type:        "expense",
 			Amount:      holdingData.Amount,
-			CategoryID:  "goal_holding_added",
+			CategoryID:  models.CategoryGoalHoldingAdded,
 			Date:        holdingData.PurchaseDate,
 			Description: "Added to " + goal.Name + ": " + holdingData.Name,
 			Tags:        []string{"goal", "holding"},
@@ -306,16 +306,6 @@ func AddHolding(c *gin.Context) {
 
 	holdingData.TransactionID = transaction.ID
 
-	// Update account balance only for new investments (not existing ones)
-	if !holdingData.IsExisting {
-		account.Balance -= holdingData.Amount
-		if err := tx.Save(&account).Error; err != nil {
-			tx.Rollback()
-			utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to update account balance")
-			return
-		}
-	}
-
 	// Create contribution record
 	contributionNotes := "Added " + holdingData.Name
 	if holdingData.IsExisting {
@@ -339,6 +329,15 @@ func AddHolding(c *gin.Context) {
 		return
 	}
 
+	// Move the money from the account into the holding. This runs after the
+	// contribution is saved because the journal finds the holding through it.
+	// Tracking transactions for existing investments are not booked.
+	if err := transaction.ApplyBalance(tx); err != nil {
+		tx.Rollback()
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to update account balance")
+		return
+	}
+
 	// Update goal metadata (but not currentAmount yet - we'll do that after commit)
 	goal.LastContribution = holdingData.Amount
 	goal.LastContributionDate = &holdingData.PurchaseDate
@@ -450,10 +449,10 @@ func RemoveHolding(c *gin.Context) {
 	}
 
 	var removeData struct {
-		AccountID    uuid.UUID `json:"accountId" binding:"required"`
-		CurrentValue float64   `json:"currentValue" binding:"required,gt=0"`
-		Date         time.Time `json:"date"`
-		Notes        string    `json:"notes"`
+		AccountID    uuid.UUID    `json:"accountId" binding:"required"`
+		CurrentValue models.Money `json:"currentValue" binding:"required,gt=0"`
+		Date         time.Time    `json:"date"`
+		Notes        string       `json:"notes"`
 	}
 
 	if err := c.ShouldBindJSON(&removeData); err != nil {
@@ -496,7 +495,7 @@ func RemoveHolding(c *gin.Context) {
 	}
 
 	// Create transaction (income as money returns)
-	categoryID := "goal_holding_removed"
+	categoryID := models.CategoryGoalHoldingRemoved
 
 	transaction := models.Transaction{
 		UserID:      userID,
@@ -515,14 +514,6 @@ func RemoveHolding(c *gin.Context) {
 		return
 	}
 
-	// Credit account
-	account.Balance += removeData.CurrentValue
-	if err := tx.Save(&account).Error; err != nil {
-		tx.Rollback()
-		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to update account balance")
-		return
-	}
-
 	// Create contribution record
 	contribution := models.GoalContribution{
 		UserID:        userID,
@@ -541,6 +532,13 @@ func RemoveHolding(c *gin.Context) {
 		return
 	}
 
+	// Credit the account and empty the holding, booking any gain or loss
+	if err := transaction.ApplyBalance(tx); err != nil {
+		tx.Rollback()
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to update account balance")
+		return
+	}
+
 	// Update goal
 	var goal models.Goal
 	if err := tx.First(&goal, holding.GoalID).Error; err != nil {
@@ -0,0 +1,475 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"daybook-backend/database"
+	"daybook-backend/middleware"
+	"daybook-backend/models"
+	"daybook-backend/services"
+	"daybook-backend/utilities"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ImportMappingRequest represents a CSV column mapping in a request body
+type ImportMappingRequest struct {
+	DateColumn        string `json:"dateColumn" binding:"required"`
+	AmountColumn      string `json:"amountColumn"`
+	DebitColumn       string `json:"debitColumn"`
+	CreditColumn      string `json:"creditColumn"`
+	DescriptionColumn string `json:"descriptionColumn"`
+	CategoryColumn    string `json:"categoryColumn"`
+	ReferenceColumn   string `json:"referenceColumn"`
+	DateFormat        string `json:"dateFormat"`
+	DecimalSeparator  string `json:"decimalSeparator"`
+	Delimiter         string `json:"delimiter"`
+	HasHeader         *bool  `json:"hasHeader"` // Defaults to true
+	SkipRows          int    `json:"skipRows"`
+	NegateAmounts     bool   `json:"negateAmounts"`
+}
+
+// CreateImportRequest represents the request body for previewing a statement import
+type CreateImportRequest struct {
+	AccountID   uuid.UUID             `json:"accountId" binding:"required"`
+	FileName    string                `json:"fileName" binding:"required"` // Name returned by POST /uploads/single
+	Format      string                `json:"format"`                      // csv, ofx, qif; detected from the extension when empty
+	Mapping     *ImportMappingRequest `json:"mapping"`                     // CSV only, defaults to the account's saved mapping
+	SaveMapping bool                  `json:"saveMapping"`                 // Save the given mapping for the account
+}
+
+// CommitImportRequest represents the request body for committing an import
+type CommitImportRequest struct {
+	IncludeDuplicates []uuid.UUID `json:"includeDuplicates"` // Row IDs flagged as duplicates to import anyway
+}
+
+// UpdateImportRowRequest represents the editable fields of a previewed row
+type UpdateImportRowRequest struct {
+	Skip        *bool   `json:"skip"`
+	CategoryID  *string `json:"categoryId"`
+	Description *string `json:"description"`
+}
+
+// GetImportMapping returns the saved CSV column mapping for an account
+func GetImportMapping(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	accountID, err := uuid.Parse(c.Param("accountId"))
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid account ID")
+		return
+	}
+
+	var mapping models.ImportMapping
+	if err := database.DB.Where("account_id = ? AND user_id = ?", accountID, userID).First(&mapping).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusNotFound, "No import mapping saved for this account")
+		return
+	}
+
+	utilities.SuccessResponse(c, mapping, "Import mapping retrieved successfully")
+}
+
+// SaveImportMapping creates or replaces the CSV column mapping for an account
+func SaveImportMapping(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	accountID, err := uuid.Parse(c.Param("accountId"))
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid account ID")
+		return
+	}
+
+	var account models.Account
+	if err := database.DB.Where("id = ? AND user_id = ?", accountID, userID).First(&account).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusNotFound, "Account not found")
+		return
+	}
+
+	var req ImportMappingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	mapping, err := saveImportMapping(userID, accountID, &req)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to save import mapping")
+		return
+	}
+
+	utilities.SuccessResponse(c, mapping, "Import mapping saved successfully")
+}
+
+// CreateImport parses an uploaded statement file and stores a preview with
+// per-row validation errors and duplicate flags. Nothing is written to the
+// account until the import is committed.
+func CreateImport(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req CreateImportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var account models.Account
+	if err := database.DB.Where("id = ? AND user_id = ?", req.AccountID, userID).First(&account).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid account ID")
+		return
+	}
+
+	// Only files from the user's own upload directory can be imported
+	fileName := filepath.Base(req.FileName)
+	file, err := os.Open(filepath.Join(UploadDir, userID.String(), fileName))
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusNotFound, "Uploaded file not found")
+		return
+	}
+	defer file.Close()
+
+	format := strings.ToLower(req.Format)
+	if format == "" {
+		format = detectImportFormat(fileName)
+	}
+
+	var parsed []services.ParsedRow
+	switch format {
+	case models.ImportFormatCSV:
+		var mapping models.ImportMapping
+		if req.Mapping != nil {
+			mapping = buildImportMapping(req.Mapping)
+			if req.SaveMapping {
+				if _, err := saveImportMapping(userID, account.ID, req.Mapping); err != nil {
+					utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to save import mapping")
+					return
+				}
+			}
+		} else if err := database.DB.Where("account_id = ? AND user_id = ?", account.ID, userID).First(&mapping).Error; err != nil {
+			utilities.ErrorResponse(c, http.StatusBadRequest, "CSV imports need a column mapping; pass one or save one for the account")
+			return
+		}
+		parsed, err = services.ParseCSVStatement(file, mapping)
+	case models.ImportFormatOFX:
+		parsed, err = services.ParseOFXStatement(file)
+	case models.ImportFormatQIF:
+		dateFormat := ""
+		if req.Mapping != nil {
+			dateFormat = req.Mapping.DateFormat
+		}
+		parsed, err = services.ParseQIFStatement(file, dateFormat)
+	default:
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Unsupported format. Must be one of: csv, ofx, qif")
+		return
+	}
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Failed to read file: "+err.Error())
+		return
+	}
+	if len(parsed) == 0 {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "No transactions found in file")
+		return
+	}
+
+	batch, err := services.BuildImportBatch(database.DB, account, fileName, format, parsed)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to create import preview")
+		return
+	}
+
+	utilities.CreatedResponse(c, batch, "Import preview created successfully")
+}
+
+// ListImports returns the user's imports without their rows
+func ListImports(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	query := database.DB.Where("user_id = ?", userID)
+
+	// Optional filter by account
+	if accountID := c.Query("accountId"); accountID != "" {
+		query = query.Where("account_id = ?", accountID)
+	}
+
+	// Optional filter by status
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var batches []models.ImportBatch
+	if err := query.Order("created_at DESC").Find(&batches).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch imports")
+		return
+	}
+
+	utilities.SuccessResponse(c, batches, "Imports retrieved successfully")
+}
+
+// GetImport returns an import with all of its rows
+func GetImport(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	batchID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid import ID")
+		return
+	}
+
+	var batch models.ImportBatch
+	if err := database.DB.Preload("Rows", func(db *gorm.DB) *gorm.DB {
+		return db.Order("row_number ASC")
+	}).Where("id = ? AND user_id = ?", batchID, userID).First(&batch).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusNotFound, "Import not found")
+		return
+	}
+
+	utilities.SuccessResponse(c, batch, "Import retrieved successfully")
+}
+
+// UpdateImportRow skips or edits a row of an import preview
+func UpdateImportRow(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	batchID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid import ID")
+		return
+	}
+
+	rowID, err := uuid.Parse(c.Param("rowId"))
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid row ID")
+		return
+	}
+
+	var batch models.ImportBatch
+	if err := database.DB.Where("id = ? AND user_id = ?", batchID, userID).First(&batch).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusNotFound, "Import not found")
+		return
+	}
+
+	if batch.Status != models.ImportStatusPreview {
+		utilities.ErrorResponse(c, http.StatusConflict, "Import has already been committed")
+		return
+	}
+
+	var row models.ImportRow
+	if err := database.DB.Where("id = ? AND batch_id = ?", rowID, batchID).First(&row).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusNotFound, "Import row not found")
+		return
+	}
+
+	var req UpdateImportRowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if req.Skip != nil {
+		row.Skip = *req.Skip
+	}
+	if req.Description != nil {
+		row.Description = strings.TrimSpace(*req.Description)
+	}
+	if req.CategoryID != nil {
+		category, message := resolveCategory(userID, *req.CategoryID, row.Type)
+		if message != "" {
+			utilities.ErrorResponse(c, http.StatusBadRequest, message)
+			return
+		}
+		row.CategoryID = category.Key
+		row.TransferAccountID = nil
+	}
+
+	if err := database.DB.Save(&row).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to update import row")
+		return
+	}
+
+	utilities.SuccessResponse(c, row, "Import row updated successfully")
+}
+
+// CommitImport creates the transactions of an import preview in a single
+// database transaction. Duplicates are left out unless listed in
+// includeDuplicates, and the commit is refused while any selected row still
+// has errors.
+func CommitImport(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	batchID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid import ID")
+		return
+	}
+
+	var req CommitImportRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utilities.ErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	var batch models.ImportBatch
+	if err := database.DB.Where("id = ? AND user_id = ?", batchID, userID).First(&batch).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusNotFound, "Import not found")
+		return
+	}
+
+	imported, err := services.CommitImportBatch(database.DB, &batch, req.IncludeDuplicates)
+	var validationErr *services.ImportValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		c.JSON(http.StatusBadRequest, utilities.Response{
+			Success: false,
+			Error:   validationErr.Error(),
+			Data:    validationErr.Rows,
+		})
+		return
+	case errors.Is(err, services.ErrImportNotPreview):
+		utilities.ErrorResponse(c, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to commit import")
+		return
+	}
+
+	result := map[string]interface{}{
+		"import":        batch,
+		"importedCount": imported,
+	}
+
+	utilities.SuccessResponse(c, result, "Import committed successfully")
+}
+
+// DeleteImport discards an import preview. Committed imports are kept as a
+// record of where their transactions came from.
+func DeleteImport(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	batchID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid import ID")
+		return
+	}
+
+	var batch models.ImportBatch
+	if err := database.DB.Where("id = ? AND user_id = ?", batchID, userID).First(&batch).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusNotFound, "Import not found")
+		return
+	}
+
+	if batch.Status != models.ImportStatusPreview {
+		utilities.ErrorResponse(c, http.StatusConflict, "Committed imports cannot be deleted")
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("batch_id = ?", batch.ID).Delete(&models.ImportRow{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&batch).Error
+	})
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete import")
+		return
+	}
+
+	utilities.SuccessResponse(c, nil, "Import deleted successfully")
+}
+
+// saveImportMapping creates or replaces the account's saved mapping
+func saveImportMapping(userID, accountID uuid.UUID, req *ImportMappingRequest) (*models.ImportMapping, error) {
+	mapping := buildImportMapping(req)
+	mapping.UserID = userID
+	mapping.AccountID = accountID
+
+	var existing models.ImportMapping
+	if err := database.DB.Where("account_id = ? AND user_id = ?", accountID, userID).First(&existing).Error; err == nil {
+		mapping.ID = existing.ID
+		mapping.CreatedAt = existing.CreatedAt
+	}
+
+	if err := database.DB.Save(&mapping).Error; err != nil {
+		return nil, err
+	}
+	return &mapping, nil
+}
+
+// buildImportMapping converts a request mapping, applying defaults
+func buildImportMapping(req *ImportMappingRequest) models.ImportMapping {
+	mapping := models.ImportMapping{
+		DateColumn:        req.DateColumn,
+		AmountColumn:      req.AmountColumn,
+		DebitColumn:       req.DebitColumn,
+		CreditColumn:      req.CreditColumn,
+		DescriptionColumn: req.DescriptionColumn,
+		CategoryColumn:    req.CategoryColumn,
+		ReferenceColumn:   req.ReferenceColumn,
+		DateFormat:        req.DateFormat,
+		DecimalSeparator:  req.DecimalSeparator,
+		Delimiter:         req.Delimiter,
+		HasHeader:         true,
+		SkipRows:          req.SkipRows,
+		NegateAmounts:     req.NegateAmounts,
+	}
+	if req.HasHeader != nil {
+		mapping.HasHeader = *req.HasHeader
+	}
+	if mapping.DecimalSeparator == "" {
+		mapping.DecimalSeparator = "."
+	}
+	if mapping.Delimiter == "" {
+		mapping.Delimiter = ","
+	}
+	return mapping
+}
+
+// detectImportFormat picks the import format from a file extension
+func detectImportFormat(fileName string) string {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".ofx", ".qfx":
+		return models.ImportFormatOFX
+	case ".qif":
+		return models.ImportFormatQIF
+	default:
+		return models.ImportFormatCSV
+	}
+}
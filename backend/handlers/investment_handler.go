@@ -189,8 +189,8 @@ func BuyShares(c *gin.Context) {
 	}
 
 	var buyData struct {
-		Quantity float64 `json:"quantity" binding:"required,gt=0"`
-		Price    float64 `json:"price" binding:"required,gt=0"`
+		Quantity float64      `json:"quantity" binding:"required,gt=0"`
+		Price    models.Money `json:"price" binding:"required,gt=0"`
 	}
 
 	if err := c.ShouldBindJSON(&buyData); err != nil {
@@ -205,9 +205,9 @@ func BuyShares(c *gin.Context) {
 	}
 
 	// Calculate new cost basis (weighted average)
-	totalCost := (investment.Quantity * investment.CostBasis) + (buyData.Quantity * buyData.Price)
+	totalCost := investment.CostBasis.Mul(investment.Quantity) + buyData.Price.Mul(buyData.Quantity)
 	totalQuantity := investment.Quantity + buyData.Quantity
-	newCostBasis := totalCost / totalQuantity
+	newCostBasis := totalCost.Mul(1 / totalQuantity)
 
 	// Update investment
 	investment.Quantity = totalQuantity
@@ -238,8 +238,8 @@ func SellShares(c *gin.Context) {
 	}
 
 	var sellData struct {
-		Quantity float64 `json:"quantity" binding:"required,gt=0"`
-		Price    float64 `json:"price" binding:"required,gt=0"`
+		Quantity float64      `json:"quantity" binding:"required,gt=0"`
+		Price    models.Money `json:"price" binding:"required,gt=0"`
 	}
 
 	if err := c.ShouldBindJSON(&sellData); err != nil {
@@ -260,8 +260,8 @@ func SellShares(c *gin.Context) {
 	}
 
 	// Calculate realized gain/loss
-	saleProceeds := sellData.Quantity * sellData.Price
-	costOfSharesSold := sellData.Quantity * investment.CostBasis
+	saleProceeds := sellData.Price.Mul(sellData.Quantity)
+	costOfSharesSold := investment.CostBasis.Mul(sellData.Quantity)
 	realizedGainLoss := saleProceeds - costOfSharesSold
 
 	// Update investment
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"daybook-backend/database"
+	"daybook-backend/middleware"
+	"daybook-backend/models"
+	"daybook-backend/utilities"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ListJournalEntries returns the user's journal entries with their postings
+func ListJournalEntries(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	query := database.DB.Model(&models.JournalEntry{}).Where("journal_entries.user_id = ?", userID)
+
+	// Optional filter by transaction
+	if transactionID := c.Query("transactionId"); transactionID != "" {
+		query = query.Where("journal_entries.transaction_id = ?", transactionID)
+	}
+
+	// Optional filter by kind
+	if kind := c.Query("kind"); kind != "" {
+		query = query.Where("journal_entries.kind = ?", kind)
+	}
+
+	// Optional filter by ledger, e.g. every entry touching an account
+	if ledgerType := c.Query("ledgerType"); ledgerType != "" {
+		postings := database.DB.Model(&models.Posting{}).Select("entry_id").Where("ledger_type = ?", ledgerType)
+		if ledgerID := c.Query("ledgerId"); ledgerID != "" {
+			postings = postings.Where("ledger_id = ?", ledgerID)
+		}
+		if ledgerKey := c.Query("ledgerKey"); ledgerKey != "" {
+			postings = postings.Where("ledger_key = ?", ledgerKey)
+		}
+		query = query.Where("journal_entries.id IN (?)", postings)
+	}
+
+	if startDate := c.Query("startDate"); startDate != "" {
+		if parsedDate, err := time.Parse("2006-01-02", startDate); err == nil {
+			query = query.Where("journal_entries.date >= ?", parsedDate)
+		}
+	}
+
+	if endDate := c.Query("endDate"); endDate != "" {
+		if parsedDate, err := time.Parse("2006-01-02", endDate); err == nil {
+			query = query.Where("journal_entries.date < ?", parsedDate.AddDate(0, 0, 1))
+		}
+	}
+
+	page := 1
+	limit := 50
+
+	if pageParam := c.Query("page"); pageParam != "" {
+		if parsedPage, err := strconv.Atoi(pageParam); err == nil && parsedPage > 0 {
+			page = parsedPage
+		}
+	}
+
+	if limitParam := c.Query("limit"); limitParam != "" {
+		if parsedLimit, err := strconv.Atoi(limitParam); err == nil && parsedLimit > 0 && parsedLimit <= 500 {
+			limit = parsedLimit
+		}
+	}
+
+	var totalCount int64
+	if err := query.Count(&totalCount).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to count journal entries")
+		return
+	}
+
+	var entries []models.JournalEntry
+	if err := query.Preload("Postings").
+		Order("journal_entries.date DESC, journal_entries.created_at DESC").
+		Limit(limit).Offset((page - 1) * limit).
+		Find(&entries).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch journal entries")
+		return
+	}
+
+	totalPages := int((totalCount + int64(limit) - 1) / int64(limit))
+
+	response := map[string]interface{}{
+		"entries": entries,
+		"pagination": map[string]interface{}{
+			"currentPage": page,
+			"limit":       limit,
+			"totalCount":  totalCount,
+			"totalPages":  totalPages,
+			"hasNext":     page < totalPages,
+			"hasPrev":     page > 1,
+		},
+	}
+
+	utilities.SuccessResponse(c, response, "Journal entries retrieved successfully")
+}
+
+// GetLedgerBalance returns the balance of one ledger derived from its postings
+func GetLedgerBalance(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	ledgerType := c.Param("ledgerType")
+	ledgerID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid ledger ID")
+		return
+	}
+
+	var owner interface{}
+	switch ledgerType {
+	case models.LedgerAccount:
+		owner = &models.Account{}
+	case models.LedgerCreditCard:
+		owner = &models.CreditCard{}
+	case models.LedgerGoalHolding:
+		owner = &models.GoalHolding{}
+	default:
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid ledger type. Must be one of: account, credit_card, goal_holding")
+		return
+	}
+
+	var count int64
+	if err := database.DB.Model(owner).Where("id = ? AND user_id = ?", ledgerID, userID).Count(&count).Error; err != nil || count == 0 {
+		utilities.ErrorResponse(c, http.StatusNotFound, "Ledger not found")
+		return
+	}
+
+	balances, err := models.LedgerBalance(database.DB, ledgerType, ledgerID)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to calculate balance")
+		return
+	}
+
+	result := map[string]interface{}{
+		"ledgerType": ledgerType,
+		"ledgerId":   ledgerID,
+		"balances":   balances,
+	}
+
+	utilities.SuccessResponse(c, result, "Ledger balance retrieved successfully")
+}
+
+// VerifyBalances lists accounts and credit cards whose stored balance
+// differs from the balance derived from the journal
+func VerifyBalances(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	drifts, err := models.FindBalanceDrift(database.DB, userID)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to verify balances")
+		return
+	}
+	if drifts == nil {
+		drifts = []models.BalanceDrift{}
+	}
+
+	result := map[string]interface{}{
+		"consistent": len(drifts) == 0,
+		"mismatches": drifts,
+	}
+
+	utilities.SuccessResponse(c, result, "Balances verified successfully")
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"daybook-backend/database"
+	"daybook-backend/middleware"
+	"daybook-backend/models"
+	"daybook-backend/services"
+	"daybook-backend/utilities"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NetWorthHistory is the net worth snapshots of a range, one per day, week
+// or month
+type NetWorthHistory struct {
+	Interval      string                    `json:"interval"`
+	Snapshots     []models.NetWorthSnapshot `json:"snapshots"` // Oldest first; the last of each week or month
+	Change        models.Money              `json:"change"`    // From the first snapshot to the last
+	ChangePercent *float64                  `json:"changePercent"`
+}
+
+// GetNetWorth returns the user's assets, liabilities and net worth at the
+// end of the date query parameter, today by default, reconstructed from
+// their transactions
+func GetNetWorth(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	date := time.Now()
+	if dateParam := c.Query("date"); dateParam != "" {
+		settings := models.UserSettings(database.DB, userID)
+		parsedDate, err := time.ParseInLocation("2006-01-02", dateParam, settings.Location())
+		if err != nil {
+			utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid date. Use YYYY-MM-DD")
+			return
+		}
+		date = parsedDate
+	}
+
+	netWorth, err := services.CalculateNetWorth(database.DB, userID, date)
+	if errors.Is(err, models.ErrExchangeRateNotFound) {
+		utilities.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to calculate net worth")
+		return
+	}
+
+	utilities.SuccessResponse(c, netWorth, "Net worth retrieved successfully")
+}
+
+// GetNetWorthHistory returns the stored net worth snapshots of the range,
+// bringing them up to date first
+func GetNetWorthHistory(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	from, to, ok := reportRange(c, userID)
+	if !ok {
+		return
+	}
+	interval := c.DefaultQuery("interval", "day")
+	if interval != "day" && interval != "week" && interval != "month" {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid interval. Must be one of: day, week, month")
+		return
+	}
+
+	if _, err := services.TakeNetWorthSnapshots(database.DB, userID, time.Now(), false); err != nil {
+		if errors.Is(err, models.ErrExchangeRateNotFound) {
+			utilities.ErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to take net worth snapshots")
+		return
+	}
+
+	var snapshots []models.NetWorthSnapshot
+	err = database.DB.Where("user_id = ? AND date >= ? AND date < ?", userID, from.Format("2006-01-02"), to.Format("2006-01-02")).
+		Order("date ASC").
+		Find(&snapshots).Error
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch net worth snapshots")
+		return
+	}
+
+	// Keep the last snapshot of each week or month
+	if interval != "day" {
+		cal := services.UserBudgetCalendar(database.DB, userID)
+		period := func(date time.Time) time.Time {
+			if interval == "month" {
+				return services.StartOfReportMonth(date)
+			}
+			offset := (int(date.Weekday()) - int(cal.WeekStart) + 7) % 7
+			return date.AddDate(0, 0, -offset)
+		}
+		kept := snapshots[:0]
+		for i, snapshot := range snapshots {
+			if i == len(snapshots)-1 || !period(snapshots[i+1].Date).Equal(period(snapshot.Date)) {
+				kept = append(kept, snapshot)
+			}
+		}
+		snapshots = kept
+	}
+
+	history := NetWorthHistory{Interval: interval, Snapshots: snapshots}
+	if len(snapshots) > 0 {
+		first, last := snapshots[0].NetWorth, snapshots[len(snapshots)-1].NetWorth
+		history.Change = last - first
+		if first != 0 {
+			change := history.Change.Ratio(first.Abs()) * 100
+			history.ChangePercent = &change
+		}
+	}
+
+	utilities.SuccessResponse(c, history, "Net worth history retrieved successfully")
+}
+
+// TakeNetWorthSnapshots retakes today's net worth snapshot and fills in the
+// days missed since the last one. With rebuild=true the last year of
+// snapshots is reconstructed, for after backdated changes.
+func TakeNetWorthSnapshots(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	rebuild := false
+	if rebuildParam := c.Query("rebuild"); rebuildParam != "" {
+		if rebuild, err = strconv.ParseBool(rebuildParam); err != nil {
+			utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid rebuild. Must be true or false")
+			return
+		}
+	}
+
+	taken, err := services.TakeNetWorthSnapshots(database.DB, userID, time.Now(), rebuild)
+	if errors.Is(err, models.ErrExchangeRateNotFound) {
+		utilities.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to take net worth snapshots")
+		return
+	}
+
+	utilities.SuccessResponse(c, gin.H{"snapshots": taken}, "Net worth snapshots taken successfully")
+}
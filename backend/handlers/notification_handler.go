@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"daybook-backend/database"
+	"daybook-backend/middleware"
+	"daybook-backend/models"
+	"daybook-backend/services"
+	"daybook-backend/utilities"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ListNotifications returns the user's notification inbox, newest first
+func ListNotifications(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	query := database.DB.Model(&models.Notification{}).Where("user_id = ?", userID)
+
+	// Optional filter by read status
+	if read := c.Query("read"); read != "" {
+		query = query.Where("read = ?", read == "true")
+	}
+
+	// Optional filter by type
+	if notificationType := c.Query("type"); notificationType != "" {
+		query = query.Where("type = ?", notificationType)
+	}
+
+	// Pagination parameters
+	page := 1
+	limit := 20
+
+	if pageParam := c.Query("page"); pageParam != "" {
+		if parsedPage, err := strconv.Atoi(pageParam); err == nil && parsedPage > 0 {
+			page = parsedPage
+		}
+	}
+
+	if limitParam := c.Query("limit"); limitParam != "" {
+		if parsedLimit, err := strconv.Atoi(limitParam); err == nil && parsedLimit > 0 {
+			switch parsedLimit {
+			case 20, 50, 100:
+				limit = parsedLimit
+			}
+		}
+	}
+
+	var totalCount int64
+	if err := query.Count(&totalCount).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to count notifications")
+		return
+	}
+
+	var notifications []models.Notification
+	if err := query.Order("created_at DESC").Limit(limit).Offset((page - 1) * limit).Find(&notifications).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch notifications")
+		return
+	}
+
+	var unreadCount int64
+	if err := database.DB.Model(&models.Notification{}).Where("user_id = ? AND read = ?", userID, false).Count(&unreadCount).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to count notifications")
+		return
+	}
+
+	totalPages := int((totalCount + int64(limit) - 1) / int64(limit))
+
+	response := map[string]interface{}{
+		"notifications": notifications,
+		"unreadCount":   unreadCount,
+		"pagination": map[string]interface{}{
+			"currentPage": page,
+			"limit":       limit,
+			"totalCount":  totalCount,
+			"totalPages":  totalPages,
+			"hasNext":     page < totalPages,
+			"hasPrev":     page > 1,
+		},
+	}
+
+	utilities.SuccessResponse(c, response, "Notifications retrieved successfully")
+}
+
+// GetUnreadNotificationCount returns how many notifications the user has not read
+func GetUnreadNotificationCount(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var unreadCount int64
+	if err := database.DB.Model(&models.Notification{}).Where("user_id = ? AND read = ?", userID, false).Count(&unreadCount).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to count notifications")
+		return
+	}
+
+	utilities.SuccessResponse(c, map[string]interface{}{"unreadCount": unreadCount}, "Unread count retrieved successfully")
+}
+
+// MarkNotificationRead marks a notification as read
+func MarkNotificationRead(c *gin.Context) {
+	setNotificationRead(c, true)
+}
+
+// MarkNotificationUnread marks a notification as unread again
+func MarkNotificationUnread(c *gin.Context) {
+	setNotificationRead(c, false)
+}
+
+func setNotificationRead(c *gin.Context, read bool) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	notificationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid notification ID")
+		return
+	}
+
+	var notification models.Notification
+	if err := database.DB.Where("id = ? AND user_id = ?", notificationID, userID).First(&notification).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusNotFound, "Notification not found")
+		return
+	}
+
+	notification.Read = read
+	notification.ReadAt = nil
+	if read {
+		now := time.Now()
+		notification.ReadAt = &now
+	}
+
+	if err := database.DB.Model(&notification).Updates(map[string]interface{}{
+		"read":    notification.Read,
+		"read_at": notification.ReadAt,
+	}).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to update notification")
+		return
+	}
+
+	utilities.SuccessResponse(c, notification, "Notification updated successfully")
+}
+
+// MarkAllNotificationsRead marks every unread notification of the user as read
+func MarkAllNotificationsRead(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	result := database.DB.Model(&models.Notification{}).
+		Where("user_id = ? AND read = ?", userID, false).
+		Updates(map[string]interface{}{"read": true, "read_at": time.Now()})
+	if result.Error != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to update notifications")
+		return
+	}
+
+	utilities.SuccessResponse(c, map[string]interface{}{"updated": result.RowsAffected}, "Notifications marked as read")
+}
+
+// DeleteNotification removes a notification from the inbox. The condition it
+// was about is not notified again.
+func DeleteNotification(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	notificationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid notification ID")
+		return
+	}
+
+	var notification models.Notification
+	if err := database.DB.Where("id = ? AND user_id = ?", notificationID, userID).First(&notification).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusNotFound, "Notification not found")
+		return
+	}
+
+	// Soft delete keeps the key, so the same condition is not sent again
+	if err := database.DB.Delete(&notification).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete notification")
+		return
+	}
+
+	utilities.SuccessResponse(c, nil, "Notification deleted successfully")
+}
+
+// GetNotificationDeliveries returns how a notification was delivered through
+// each channel
+func GetNotificationDeliveries(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	notificationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid notification ID")
+		return
+	}
+
+	var notification models.Notification
+	if err := database.DB.Where("id = ? AND user_id = ?", notificationID, userID).First(&notification).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusNotFound, "Notification not found")
+		return
+	}
+
+	var deliveries []models.NotificationDelivery
+	if err := database.DB.Where("notification_id = ?", notification.ID).Order("created_at ASC").Find(&deliveries).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch deliveries")
+		return
+	}
+
+	utilities.SuccessResponse(c, deliveries, "Deliveries retrieved successfully")
+}
+
+// CheckNotifications evaluates the user's alert conditions now instead of
+// waiting for the scheduler and returns the resulting unread count
+func CheckNotifications(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := services.CheckNotifications(database.DB, userID, time.Now()); err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to check notifications")
+		return
+	}
+
+	var unreadCount int64
+	if err := database.DB.Model(&models.Notification{}).Where("user_id = ? AND read = ?", userID, false).Count(&unreadCount).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to count notifications")
+		return
+	}
+
+	utilities.SuccessResponse(c, map[string]interface{}{"unreadCount": unreadCount}, "Notifications checked successfully")
+}
+
+// SendTestNotification sends a test notification through the user's enabled
+// channels and reports how each delivery went
+func SendTestNotification(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	notification, deliveries, err := services.SendTestNotification(database.DB, userID, time.Now())
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to send test notification")
+		return
+	}
+
+	result := map[string]interface{}{
+		"notification": notification,
+		"deliveries":   deliveries,
+	}
+
+	utilities.CreatedResponse(c, result, "Test notification sent")
+}
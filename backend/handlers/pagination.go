@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"daybook-backend/utilities"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 500
+)
+
+// keyset orders a list by a column, then by creation time and ID, so that
+// every row has a unique position a cursor can point at
+type keyset struct {
+	name       string // Plural name of the rows, for error messages
+	table      string
+	column     string // Time or integer column sorted on first
+	descending bool
+}
+
+// cursorKey is the position of a row in a keyset order
+type cursorKey struct {
+	Sort      interface{} // time.Time or int
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// pageCursor is the decoded form of the opaque cursor tokens handed to
+// clients
+type pageCursor struct {
+	Sort      json.RawMessage `json:"s"`
+	CreatedAt time.Time       `json:"c"`
+	ID        uuid.UUID       `json:"i"`
+	Before    bool            `json:"b,omitempty"` // The page ends just before the position instead of starting after it
+}
+
+// CursorPagination describes a page of a cursor-paginated list. Passing
+// NextCursor or PrevCursor as the cursor query parameter fetches the page
+// after or before it.
+type CursorPagination struct {
+	Limit      int    `json:"limit"`
+	NextCursor string `json:"nextCursor,omitempty"`
+	PrevCursor string `json:"prevCursor,omitempty"`
+	HasNext    bool   `json:"hasNext"`
+	HasPrev    bool   `json:"hasPrev"`
+}
+
+// pageLimit reads the limit query parameter, 20 by default and at most 500
+func pageLimit(c *gin.Context) int {
+	limit := defaultPageLimit
+	if limitParam := c.Query("limit"); limitParam != "" {
+		if parsedLimit, err := strconv.Atoi(limitParam); err == nil && parsedLimit > 0 {
+			limit = min(parsedLimit, maxPageLimit)
+		}
+	}
+	return limit
+}
+
+// wantsPage reports whether a list that used to return every row was asked
+// for a page with the limit or cursor query parameters
+func wantsPage(c *gin.Context) bool {
+	return c.Query("limit") != "" || c.Query("cursor") != ""
+}
+
+// wantsCursorPage reports whether a list paged by offset was asked for a
+// cursor page instead, with the cursor query parameter or paging=cursor for
+// the first page
+func wantsCursorPage(c *gin.Context) bool {
+	return c.Query("cursor") != "" || c.Query("paging") == "cursor"
+}
+
+// paginate loads into rows the page of a query, in keyset order, that the
+// limit and cursor query parameters ask for. It writes the error response
+// and returns false when the cursor is malformed or the query fails.
+func paginate[T any](c *gin.Context, query *gorm.DB, order keyset, key func(*T) cursorKey, rows *[]T) (CursorPagination, bool) {
+	limit := pageLimit(c)
+	pagination := CursorPagination{Limit: limit}
+
+	var cursor *pageCursor
+	var cursorSort interface{}
+	if token := c.Query("cursor"); token != "" {
+		var err error
+		cursor, cursorSort, err = decodeCursor(token)
+		if err != nil {
+			utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid cursor")
+			return pagination, false
+		}
+	}
+
+	// A page before the cursor is read backwards from it and then reversed
+	backward := cursor != nil && cursor.Before
+	direction, comparison := "ASC", ">"
+	if order.descending != backward {
+		direction, comparison = "DESC", "<"
+	}
+
+	columns := fmt.Sprintf("%[1]s.%[2]s, %[1]s.created_at, %[1]s.id", order.table, order.column)
+	if cursor != nil {
+		query = query.Where(fmt.Sprintf("(%s) %s (?, ?, ?)", columns, comparison), cursorSort, cursor.CreatedAt, cursor.ID)
+	}
+	orderBy := fmt.Sprintf("%[1]s.%[2]s %[3]s, %[1]s.created_at %[3]s, %[1]s.id %[3]s", order.table, order.column, direction)
+	if err := query.Order(orderBy).Limit(limit + 1).Find(rows).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch "+order.name)
+		return pagination, false
+	}
+
+	more := len(*rows) > limit
+	if more {
+		*rows = (*rows)[:limit]
+	}
+	if backward {
+		slices.Reverse(*rows)
+		pagination.HasPrev = more
+		pagination.HasNext = true
+	} else {
+		pagination.HasNext = more
+		pagination.HasPrev = cursor != nil
+	}
+
+	if len(*rows) > 0 {
+		if pagination.HasNext {
+			pagination.NextCursor = encodeCursor(key(&(*rows)[len(*rows)-1]), false)
+		}
+		if pagination.HasPrev {
+			pagination.PrevCursor = encodeCursor(key(&(*rows)[0]), true)
+		}
+	}
+
+	return pagination, true
+}
+
+// encodeCursor turns a row position into an opaque cursor token
+func encodeCursor(key cursorKey, before bool) string {
+	sort, _ := json.Marshal(key.Sort)
+	encoded, _ := json.Marshal(pageCursor{Sort: sort, CreatedAt: key.CreatedAt, ID: key.ID, Before: before})
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+// decodeCursor reads a cursor token and the sort value it holds, a time for
+// a JSON string and an integer otherwise
+func decodeCursor(token string) (*pageCursor, interface{}, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, nil, err
+	}
+	var cursor pageCursor
+	if err := json.Unmarshal(decoded, &cursor); err != nil {
+		return nil, nil, err
+	}
+
+	if bytes.HasPrefix(cursor.Sort, []byte(`"`)) {
+		var sort time.Time
+		err = json.Unmarshal(cursor.Sort, &sort)
+		return &cursor, sort, err
+	}
+	var sort int64
+	err = json.Unmarshal(cursor.Sort, &sort)
+	return &cursor, sort, err
+}
+
+// transactionPage reads the page and limit query parameters of a list paged
+// by offset
+func transactionPage(c *gin.Context) (int, int) {
+	page := 1
+	if pageParam := c.Query("page"); pageParam != "" {
+		if parsedPage, err := strconv.Atoi(pageParam); err == nil && parsedPage > 0 {
+			page = parsedPage
+		}
+	}
+	return page, pageLimit(c)
+}
+
+// paginationResponse describes where a page sits in a list of totalCount items
+func paginationResponse(page, limit int, totalCount int64) map[string]interface{} {
+	totalPages := int((totalCount + int64(limit) - 1) / int64(limit))
+	return map[string]interface{}{
+		"currentPage": page,
+		"limit":       limit,
+		"totalCount":  totalCount,
+		"totalPages":  totalPages,
+		"hasNext":     page < totalPages,
+		"hasPrev":     page > 1,
+	}
+}
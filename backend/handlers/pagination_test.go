@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	dhaka := time.FixedZone("Asia/Dhaka", 6*60*60)
+	createdAt := time.Date(2024, 5, 1, 8, 30, 15, 123456789, time.UTC)
+	id := uuid.New()
+
+	tests := []struct {
+		name   string
+		sort   interface{}
+		before bool
+		want   interface{}
+	}{
+		{"time", time.Date(2024, 4, 30, 23, 59, 59, 999999999, dhaka), false, time.Date(2024, 4, 30, 23, 59, 59, 999999999, dhaka)},
+		{"time before", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), true, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"int", 42, false, int64(42)},
+		{"negative int before", -7, true, int64(-7)},
+		{"zero", 0, false, int64(0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := encodeCursor(cursorKey{Sort: tt.sort, CreatedAt: createdAt, ID: id}, tt.before)
+			cursor, sort, err := decodeCursor(token)
+			if err != nil {
+				t.Fatalf("decodeCursor(%q) error: %v", token, err)
+			}
+			if !cursor.CreatedAt.Equal(createdAt) || cursor.ID != id || cursor.Before != tt.before {
+				t.Errorf("decodeCursor(%q) = %+v, want created at %v, ID %s, before %v", token, cursor, createdAt, id, tt.before)
+			}
+			switch want := tt.want.(type) {
+			case time.Time:
+				if got, ok := sort.(time.Time); !ok || !got.Equal(want) {
+					t.Errorf("sort = %#v, want %v", sort, want)
+				}
+			default:
+				if sort != want {
+					t.Errorf("sort = %#v, want %#v", sort, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	tokens := []string{
+		"not base64!",
+		encode("not json"),
+		encode(`{"s":"yesterday","c":"2024-05-01T00:00:00Z","i":"` + uuid.NewString() + `"}`),
+		encode(`{"s":1.5,"c":"2024-05-01T00:00:00Z","i":"` + uuid.NewString() + `"}`),
+		encode(`{"s":true,"c":"2024-05-01T00:00:00Z","i":"` + uuid.NewString() + `"}`),
+		encode(`{"c":"2024-05-01T00:00:00Z","i":"` + uuid.NewString() + `"}`),
+		encode(`{"s":1,"c":"2024-05-01T00:00:00Z","i":"not a uuid"}`),
+		encode(`{"s":1,"c":"May 1","i":"` + uuid.NewString() + `"}`),
+	}
+	for _, token := range tokens {
+		if cursor, sort, err := decodeCursor(token); err == nil {
+			t.Errorf("decodeCursor(%q) = %+v, %v, want an error", token, cursor, sort)
+		}
+	}
+}
+
+func TestPageLimit(t *testing.T) {
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"", defaultPageLimit},
+		{"limit=50", 50},
+		{"limit=100000", maxPageLimit},
+		{"limit=0", defaultPageLimit},
+		{"limit=-3", defaultPageLimit},
+		{"limit=ten", defaultPageLimit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "/?"+tt.query, nil)
+			if got := pageLimit(c); got != tt.want {
+				t.Errorf("pageLimit(%q) = %d, want %d", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWantsCursorPage(t *testing.T) {
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"", false},
+		{"page=2&limit=50", false},
+		{"limit=50", false},
+		{"paging=cursor", true},
+		{"paging=offset", false},
+		{"cursor=abc", true},
+		{"page=3&cursor=abc", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "/?"+tt.query, nil)
+			if got := wantsCursorPage(c); got != tt.want {
+				t.Errorf("wantsCursorPage(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
@@ -13,7 +13,11 @@ import (
 	"github.com/google/uuid"
 )
 
-// ListReconciliations returns all reconciliations for a specific account or user
+// reconciliationKeyset orders reconciliations newest first
+var reconciliationKeyset = keyset{name: "reconciliations", table: "reconciliations", column: "reconciliation_date", descending: true}
+
+// ListReconciliations returns all reconciliations for a specific account or
+// user, or a page of them when a limit or cursor is given
 func ListReconciliations(c *gin.Context) {
 	userID, err := middleware.GetUserID(c)
 	if err != nil {
@@ -35,6 +39,17 @@ func ListReconciliations(c *gin.Context) {
 		query = query.Where("account_id = ?", accID)
 	}
 
+	if wantsPage(c) {
+		pagination, ok := paginate(c, query, reconciliationKeyset, func(r *models.Reconciliation) cursorKey {
+			return cursorKey{Sort: r.ReconciliationDate, CreatedAt: r.CreatedAt, ID: r.ID}
+		}, &reconciliations)
+		if !ok {
+			return
+		}
+		utilities.SuccessResponse(c, gin.H{"reconciliations": reconciliations, "pagination": pagination}, "Reconciliations retrieved successfully")
+		return
+	}
+
 	if err := query.Order("reconciliation_date DESC").Find(&reconciliations).Error; err != nil {
 		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch reconciliations")
 		return
@@ -72,11 +87,11 @@ func GetReconciliation(c *gin.Context) {
 
 // CreateReconciliationRequest represents the request body for creating a reconciliation
 type CreateReconciliationRequest struct {
-	AccountID          uuid.UUID   `json:"accountId" binding:"required"`
-	ReconciliationDate time.Time   `json:"reconciliationDate" binding:"required"`
-	StatementBalance   float64     `json:"statementBalance" binding:"required"`
-	Notes              string      `json:"notes"`
-	TransactionIDs     []uuid.UUID `json:"transactionIds"` // Optional: specific transactions to reconcile
+	AccountID          uuid.UUID    `json:"accountId" binding:"required"`
+	ReconciliationDate time.Time    `json:"reconciliationDate" binding:"required"`
+	StatementBalance   models.Money `json:"statementBalance" binding:"required"`
+	Notes              string       `json:"notes"`
+	TransactionIDs     []uuid.UUID  `json:"transactionIds"` // Optional: specific transactions to reconcile
 }
 
 // CreateReconciliation creates a new reconciliation record
@@ -160,14 +175,14 @@ func CreateReconciliation(c *gin.Context) {
 	if reconciliation.Status == models.ReconciliationCompleted {
 		account.LastReconciled = &reconciliation.ReconciliationDate
 		account.ReconciliationDifference = 0
-		if err := tx.Save(&account).Error; err != nil {
+		if err := tx.Omit("balance", "initial_balance").Save(&account).Error; err != nil {
 			tx.Rollback()
 			utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to update account")
 			return
 		}
 	} else {
 		account.ReconciliationDifference = reconciliation.Difference
-		if err := tx.Save(&account).Error; err != nil {
+		if err := tx.Omit("balance", "initial_balance").Save(&account).Error; err != nil {
 			tx.Rollback()
 			utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to update account")
 			return
@@ -231,7 +246,7 @@ func UpdateReconciliation(c *gin.Context) {
 		if err := database.DB.Where("id = ?", existingReconciliation.AccountID).First(&account).Error; err == nil {
 			account.LastReconciled = &existingReconciliation.ReconciliationDate
 			account.ReconciliationDifference = 0
-			database.DB.Save(&account)
+			database.DB.Omit("balance", "initial_balance").Save(&account)
 		}
 	}
 
@@ -334,12 +349,12 @@ func GetUnreconciledTransactions(c *gin.Context) {
 
 // ReconciliationStatsResponse represents reconciliation statistics
 type ReconciliationStatsResponse struct {
-	TotalReconciliations   int64   `json:"totalReconciliations"`
-	CompletedReconciliations int64 `json:"completedReconciliations"`
-	PendingReconciliations int64   `json:"pendingReconciliations"`
-	DiscrepancyReconciliations int64 `json:"discrepancyReconciliations"`
-	LastReconciliationDate *time.Time `json:"lastReconciliationDate"`
-	AverageDifference      float64 `json:"averageDifference"`
+	TotalReconciliations       int64        `json:"totalReconciliations"`
+	CompletedReconciliations   int64        `json:"completedReconciliations"`
+	PendingReconciliations     int64        `json:"pendingReconciliations"`
+	DiscrepancyReconciliations int64        `json:"discrepancyReconciliations"`
+	LastReconciliationDate     *time.Time   `json:"lastReconciliationDate"`
+	AverageDifference          models.Money `json:"averageDifference"`
 }
 
 // GetReconciliationStats returns reconciliation statistics for an account
@@ -396,7 +411,7 @@ func GetReconciliationStats(c *gin.Context) {
 
 	// Average difference
 	var avgDiff struct {
-		AvgDifference float64
+		AvgDifference models.Money
 	}
 	database.DB.Model(&models.Reconciliation{}).
 		Select("AVG(ABS(difference)) as avg_difference").
@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"daybook-backend/database"
+	"daybook-backend/middleware"
+	"daybook-backend/models"
+	"daybook-backend/utilities"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RecurringTransactionRequest represents the request body for creating or updating a recurring transaction
+type RecurringTransactionRequest struct {
+	TransactionTemplate struct {
+		AccountID    uuid.UUID     `json:"accountId"`
+		ToAccountID  *uuid.UUID    `json:"toAccountId"`
+		CreditCardID *uuid.UUID    `json:"creditCardId"`
+		Type         string        `json:"type" binding:"required"`
+		Amount       models.Money  `json:"amount" binding:"required,gt=0"`
+		ToAmount     *models.Money `json:"toAmount"`     // Fixed destination amount for cross-currency transfers
+		ExchangeRate *float64      `json:"exchangeRate"` // Fixed rate, otherwise the stored rate on each occurrence date
+		CategoryID   string        `json:"categoryId" binding:"required"`
+		Description  string        `json:"description"`
+		Tags         []string      `json:"tags"`
+	} `json:"transactionTemplate" binding:"required"`
+	Frequency string     `json:"frequency" binding:"required"`
+	StartDate time.Time  `json:"startDate" binding:"required"`
+	EndDate   *time.Time `json:"endDate"`
+	Enabled   *bool      `json:"enabled"`
+}
+
+// ListRecurringTransactions returns all recurring transactions for the authenticated user
+func ListRecurringTransactions(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	query := database.DB.Where("user_id = ?", userID)
+
+	// Optional filter by enabled status
+	if enabled := c.Query("enabled"); enabled != "" {
+		query = query.Where("enabled = ?", enabled == "true")
+	}
+
+	var recurring []models.RecurringTransaction
+	if err := query.Order("start_date ASC").Find(&recurring).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch recurring transactions")
+		return
+	}
+
+	utilities.SuccessResponse(c, recurring, "Recurring transactions retrieved successfully")
+}
+
+// GetRecurringTransaction returns a specific recurring transaction by ID
+func GetRecurringTransaction(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	recurringID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid recurring transaction ID")
+		return
+	}
+
+	var recurring models.RecurringTransaction
+	if err := database.DB.Where("id = ? AND user_id = ?", recurringID, userID).First(&recurring).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusNotFound, "Recurring transaction not found")
+		return
+	}
+
+	utilities.SuccessResponse(c, recurring, "Recurring transaction retrieved successfully")
+}
+
+// CreateRecurringTransaction creates a new recurring transaction template
+func CreateRecurringTransaction(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req RecurringTransactionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	recurring := models.RecurringTransaction{
+		UserID:  userID,
+		Enabled: true,
+	}
+	applyRecurringTransactionRequest(&recurring, &req)
+
+	if message := validateRecurringTransaction(userID, &recurring); message != "" {
+		utilities.ErrorResponse(c, http.StatusBadRequest, message)
+		return
+	}
+
+	if err := database.DB.Create(&recurring).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to create recurring transaction")
+		return
+	}
+
+	utilities.CreatedResponse(c, recurring, "Recurring transaction created successfully")
+}
+
+// UpdateRecurringTransaction updates an existing recurring transaction
+func UpdateRecurringTransaction(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	recurringID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid recurring transaction ID")
+		return
+	}
+
+	var existingRecurring models.RecurringTransaction
+	if err := database.DB.Where("id = ? AND user_id = ?", recurringID, userID).First(&existingRecurring).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusNotFound, "Recurring transaction not found")
+		return
+	}
+
+	var req RecurringTransactionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	applyRecurringTransactionRequest(&existingRecurring, &req)
+
+	if message := validateRecurringTransaction(userID, &existingRecurring); message != "" {
+		utilities.ErrorResponse(c, http.StatusBadRequest, message)
+		return
+	}
+
+	// LastProcessed is owned by the scheduler, which may have moved it on
+	// since the row was read
+	if err := database.DB.Omit("last_processed").Save(&existingRecurring).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to update recurring transaction")
+		return
+	}
+
+	utilities.SuccessResponse(c, existingRecurring, "Recurring transaction updated successfully")
+}
+
+// DeleteRecurringTransaction deletes a recurring transaction. Transactions it
+// already created are kept.
+func DeleteRecurringTransaction(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	recurringID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid recurring transaction ID")
+		return
+	}
+
+	var recurring models.RecurringTransaction
+	if err := database.DB.Where("id = ? AND user_id = ?", recurringID, userID).First(&recurring).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusNotFound, "Recurring transaction not found")
+		return
+	}
+
+	// Soft delete
+	if err := database.DB.Delete(&recurring).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete recurring transaction")
+		return
+	}
+
+	utilities.SuccessResponse(c, nil, "Recurring transaction deleted successfully")
+}
+
+// applyRecurringTransactionRequest copies the request fields onto the model
+func applyRecurringTransactionRequest(recurring *models.RecurringTransaction, req *RecurringTransactionRequest) {
+	template := &recurring.TransactionTemplate
+	template.UserID = recurring.UserID
+	template.AccountID = req.TransactionTemplate.AccountID
+	template.ToAccountID = req.TransactionTemplate.ToAccountID
+	template.CreditCardID = req.TransactionTemplate.CreditCardID
+	template.Type = req.TransactionTemplate.Type
+	template.Amount = req.TransactionTemplate.Amount
+	template.ToAmount = req.TransactionTemplate.ToAmount
+	template.ExchangeRate = req.TransactionTemplate.ExchangeRate
+	template.CategoryID = req.TransactionTemplate.CategoryID
+	template.Description = req.TransactionTemplate.Description
+	template.Tags = req.TransactionTemplate.Tags
+	template.Date = req.StartDate
+
+	recurring.Frequency = req.Frequency
+	recurring.StartDate = req.StartDate
+	recurring.EndDate = req.EndDate
+	if req.Enabled != nil {
+		recurring.Enabled = *req.Enabled
+	}
+}
+
+// validateRecurringTransaction checks the schedule and category and verifies
+// that the template's account, destination account or credit card belong to
+// the user.
+// It returns an error message, or an empty string when the template is valid.
+func validateRecurringTransaction(userID uuid.UUID, recurring *models.RecurringTransaction) string {
+	if !models.IsValidFrequency(recurring.Frequency) {
+		return "Invalid frequency. Must be one of: daily, weekly, biweekly, monthly, quarterly, yearly"
+	}
+
+	if recurring.EndDate != nil && recurring.EndDate.Before(recurring.StartDate) {
+		return "End date must be after start date"
+	}
+
+	template := recurring.TransactionTemplate
+	switch template.Type {
+	case "income", "expense", "transfer":
+	default:
+		return "Invalid transaction type. Must be one of: income, expense, transfer"
+	}
+
+	category, message := resolveCategory(userID, template.CategoryID, template.Type)
+	if message != "" {
+		return message
+	}
+	recurring.TransactionTemplate.CategoryID = category.Key
+
+	if template.CreditCardID != nil {
+		var creditCard models.CreditCard
+		if err := database.DB.Where("id = ? AND user_id = ?", *template.CreditCardID, userID).First(&creditCard).Error; err != nil {
+			return "Invalid credit card ID"
+		}
+		return ""
+	}
+
+	var account models.Account
+	if err := database.DB.Where("id = ? AND user_id = ?", template.AccountID, userID).First(&account).Error; err != nil {
+		return "Invalid account ID"
+	}
+
+	if template.Type == "transfer" {
+		if template.ToAccountID == nil {
+			return "Destination account is required for transfers"
+		}
+		var toAccount models.Account
+		if err := database.DB.Where("id = ? AND user_id = ?", *template.ToAccountID, userID).First(&toAccount).Error; err != nil {
+			return "Invalid destination account ID"
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"daybook-backend/database"
+	"daybook-backend/middleware"
+	"daybook-backend/models"
+	"daybook-backend/services"
+	"daybook-backend/utilities"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultTopPayees = 10
+	maxTopPayees     = 100
+	maxReportYears   = 10
+)
+
+// GetCashFlowReport returns income against expenses for each month, quarter
+// or year of the range
+func GetCashFlowReport(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	from, to, ok := reportRange(c, userID)
+	if !ok {
+		return
+	}
+	interval, ok := reportInterval(c)
+	if !ok {
+		return
+	}
+
+	report, err := services.CalculateCashFlow(database.DB, userID, from, to, interval)
+	if respondReportError(c, err) {
+		return
+	}
+
+	utilities.SuccessResponse(c, report, "Cash flow retrieved successfully")
+}
+
+// GetIncomeStatement returns income and expenses by category over the range
+func GetIncomeStatement(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	from, to, ok := reportRange(c, userID)
+	if !ok {
+		return
+	}
+
+	statement, err := services.CalculateIncomeStatement(database.DB, userID, from, to)
+	if respondReportError(c, err) {
+		return
+	}
+
+	utilities.SuccessResponse(c, statement, "Income statement retrieved successfully")
+}
+
+// GetCategoryTrends returns the spending or income in each category for
+// each month, quarter or year of the range
+func GetCategoryTrends(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	from, to, ok := reportRange(c, userID)
+	if !ok {
+		return
+	}
+	interval, ok := reportInterval(c)
+	if !ok {
+		return
+	}
+	transactionType, ok := reportType(c)
+	if !ok {
+		return
+	}
+
+	rollup := true
+	if rollupParam := c.Query("rollup"); rollupParam != "" {
+		if rollup, err = strconv.ParseBool(rollupParam); err != nil {
+			utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid rollup. Must be true or false")
+			return
+		}
+	}
+
+	report, err := services.CalculateCategoryTrends(database.DB, userID, from, to, interval, transactionType, rollup)
+	if respondReportError(c, err) {
+		return
+	}
+
+	utilities.SuccessResponse(c, report, "Category trends retrieved successfully")
+}
+
+// GetTopPayees returns the merchants and payees with the most spending or
+// income over the range
+func GetTopPayees(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	from, to, ok := reportRange(c, userID)
+	if !ok {
+		return
+	}
+	transactionType, ok := reportType(c)
+	if !ok {
+		return
+	}
+
+	limit := defaultTopPayees
+	if limitParam := c.Query("limit"); limitParam != "" {
+		if parsedLimit, err := strconv.Atoi(limitParam); err == nil && parsedLimit > 0 {
+			limit = min(parsedLimit, maxTopPayees)
+		}
+	}
+
+	report, err := services.CalculateTopPayees(database.DB, userID, from, to, transactionType, limit)
+	if respondReportError(c, err) {
+		return
+	}
+
+	utilities.SuccessResponse(c, report, "Top payees retrieved successfully")
+}
+
+// GetYearOverYear compares each month of the year query parameter, the
+// current year by default, with the year before
+func GetYearOverYear(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	settings := models.UserSettings(database.DB, userID)
+	year := time.Now().In(settings.Location()).Year()
+	if yearParam := c.Query("year"); yearParam != "" {
+		parsedYear, err := strconv.Atoi(yearParam)
+		if err != nil || parsedYear < 1900 || parsedYear > 9999 {
+			utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid year")
+			return
+		}
+		year = parsedYear
+	}
+
+	report, err := services.CalculateYearOverYear(database.DB, userID, year)
+	if respondReportError(c, err) {
+		return
+	}
+
+	utilities.SuccessResponse(c, report, "Year over year comparison retrieved successfully")
+}
+
+// reportRange reads the startDate and endDate query parameters as days in
+// the user's time zone and returns the range from the start of startDate up
+// to the end of endDate. It defaults to the last twelve months including
+// the current one, and writes the error response when the range is invalid.
+func reportRange(c *gin.Context, userID uuid.UUID) (time.Time, time.Time, bool) {
+	settings := models.UserSettings(database.DB, userID)
+	location := settings.Location()
+
+	thisMonth := services.StartOfReportMonth(time.Now().In(location))
+	from := thisMonth.AddDate(0, -11, 0)
+	to := thisMonth.AddDate(0, 1, 0)
+
+	if startParam := c.Query("startDate"); startParam != "" {
+		startDate, err := time.ParseInLocation("2006-01-02", startParam, location)
+		if err != nil {
+			utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid start date. Use YYYY-MM-DD")
+			return from, to, false
+		}
+		from = startDate
+	}
+	if endParam := c.Query("endDate"); endParam != "" {
+		endDate, err := time.ParseInLocation("2006-01-02", endParam, location)
+		if err != nil {
+			utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid end date. Use YYYY-MM-DD")
+			return from, to, false
+		}
+		to = endDate.AddDate(0, 0, 1)
+	}
+
+	if !to.After(from) {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "End date cannot be before the start date")
+		return from, to, false
+	}
+	if to.After(from.AddDate(maxReportYears, 0, 0)) {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Date range cannot exceed 10 years")
+		return from, to, false
+	}
+	return from, to, true
+}
+
+// reportInterval reads the interval query parameter, month by default
+func reportInterval(c *gin.Context) (string, bool) {
+	interval := c.DefaultQuery("interval", services.ReportIntervalMonth)
+	if !services.IsValidReportInterval(interval) {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid interval. Must be one of: month, quarter, year")
+		return "", false
+	}
+	return interval, true
+}
+
+// reportType reads the type query parameter, expense by default
+func reportType(c *gin.Context) (string, bool) {
+	transactionType := c.DefaultQuery("type", "expense")
+	if transactionType != "income" && transactionType != "expense" {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid type. Must be one of: income, expense")
+		return "", false
+	}
+	return transactionType, true
+}
+
+// respondReportError writes the response for an error calculating a report
+// and reports whether there was one
+func respondReportError(c *gin.Context, err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, models.ErrExchangeRateNotFound) {
+		utilities.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return true
+	}
+	utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to calculate report")
+	return true
+}
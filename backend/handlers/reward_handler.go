@@ -0,0 +1,359 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"daybook-backend/database"
+	"daybook-backend/middleware"
+	"daybook-backend/models"
+	"daybook-backend/services"
+	"daybook-backend/utilities"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ListRewardRules returns the earning rules of a credit card
+func ListRewardRules(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	cardID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid credit card ID")
+		return
+	}
+
+	var card models.CreditCard
+	if err := database.DB.Where("id = ? AND user_id = ?", cardID, userID).First(&card).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusNotFound, "Credit card not found")
+		return
+	}
+
+	var rules []models.RewardRule
+	if err := database.DB.Where("card_id = ? AND user_id = ?", cardID, userID).
+		Order("category_id ASC").Find(&rules).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch reward rules")
+		return
+	}
+
+	utilities.SuccessResponse(c, rules, "Reward rules retrieved successfully")
+}
+
+// CreateRewardRule adds an earning rule to a credit card
+func CreateRewardRule(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	cardID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid credit card ID")
+		return
+	}
+
+	var card models.CreditCard
+	if err := database.DB.Where("id = ? AND user_id = ?", cardID, userID).First(&card).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusNotFound, "Credit card not found")
+		return
+	}
+
+	var rule models.RewardRule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !normalizeRewardRule(c, userID, &rule) {
+		return
+	}
+
+	rule.UserID = userID
+	rule.CardID = cardID
+
+	if err := database.DB.Create(&rule).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to create reward rule")
+		return
+	}
+
+	utilities.CreatedResponse(c, rule, "Reward rule created successfully")
+}
+
+// UpdateRewardRule updates an earning rule of a credit card
+func UpdateRewardRule(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	cardID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid credit card ID")
+		return
+	}
+
+	ruleID, err := uuid.Parse(c.Param("ruleId"))
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid reward rule ID")
+		return
+	}
+
+	var existingRule models.RewardRule
+	if err := database.DB.Where("id = ? AND card_id = ? AND user_id = ?", ruleID, cardID, userID).First(&existingRule).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusNotFound, "Reward rule not found")
+		return
+	}
+
+	var updateData models.RewardRule
+	if err := c.ShouldBindJSON(&updateData); err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !normalizeRewardRule(c, userID, &updateData) {
+		return
+	}
+
+	// Update allowed fields
+	existingRule.CategoryID = updateData.CategoryID
+	existingRule.Multiplier = updateData.Multiplier
+	existingRule.Cap = updateData.Cap
+	existingRule.CapPeriod = updateData.CapPeriod
+	existingRule.Description = updateData.Description
+	existingRule.Active = updateData.Active
+
+	if err := database.DB.Save(&existingRule).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to update reward rule")
+		return
+	}
+
+	utilities.SuccessResponse(c, existingRule, "Reward rule updated successfully")
+}
+
+// DeleteRewardRule deletes an earning rule of a credit card. Rewards already
+// earned under it are kept.
+func DeleteRewardRule(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	cardID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid credit card ID")
+		return
+	}
+
+	ruleID, err := uuid.Parse(c.Param("ruleId"))
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid reward rule ID")
+		return
+	}
+
+	var rule models.RewardRule
+	if err := database.DB.Where("id = ? AND card_id = ? AND user_id = ?", ruleID, cardID, userID).First(&rule).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusNotFound, "Reward rule not found")
+		return
+	}
+
+	if err := database.DB.Delete(&rule).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete reward rule")
+		return
+	}
+
+	utilities.SuccessResponse(c, nil, "Reward rule deleted successfully")
+}
+
+// normalizeRewardRule validates the rule's category and cap period, storing
+// the category's key, and writes the error response when they are invalid
+func normalizeRewardRule(c *gin.Context, userID uuid.UUID, rule *models.RewardRule) bool {
+	category, err := models.FindCategory(database.DB, userID, rule.CategoryID)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid category")
+		return false
+	}
+	rule.CategoryID = category.Key
+
+	if rule.CapPeriod == "" {
+		rule.CapPeriod = models.CapMonthly
+	}
+	if !models.IsValidCapPeriod(rule.CapPeriod) {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid cap period. Must be one of: monthly, quarterly, yearly")
+		return false
+	}
+	return true
+}
+
+// RedeemRewards redeems a credit card's rewards as a statement credit or as
+// income into an account
+func RedeemRewards(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	cardID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid credit card ID")
+		return
+	}
+
+	var req struct {
+		Type        string       `json:"type"`
+		Amount      models.Money `json:"amount" binding:"min=0"`
+		Value       models.Money `json:"value" binding:"min=0"`
+		Method      string       `json:"method" binding:"required"`
+		AccountID   string       `json:"accountId"`
+		Description string       `json:"description"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var card models.CreditCard
+	if err := database.DB.Where("id = ? AND user_id = ?", cardID, userID).First(&card).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusNotFound, "Credit card not found")
+		return
+	}
+
+	// Redeem the card's own reward type unless told otherwise
+	if req.Type == "" {
+		req.Type = card.RewardType
+	}
+	if !models.IsValidRewardType(req.Type) {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid reward type. Must be one of: cashback, points, miles")
+		return
+	}
+
+	redemption := services.RewardRedemptionRequest{
+		Type:        req.Type,
+		Amount:      req.Amount,
+		Value:       req.Value,
+		Method:      req.Method,
+		Description: req.Description,
+	}
+
+	switch req.Method {
+	case models.RedeemStatementCredit:
+	case models.RedeemAccount:
+		accountID, err := uuid.Parse(req.AccountID)
+		if err != nil {
+			utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid account ID")
+			return
+		}
+		var account models.Account
+		if err := database.DB.Where("id = ? AND user_id = ?", accountID, userID).First(&account).Error; err != nil {
+			utilities.ErrorResponse(c, http.StatusNotFound, "Account not found")
+			return
+		}
+		redemption.Account = &account
+	default:
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid method. Must be one of: statement_credit, account")
+		return
+	}
+
+	var result *models.RewardRedemption
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		result, err = services.RedeemRewards(tx, &card, redemption, time.Now())
+		return err
+	})
+	if errors.Is(err, services.ErrInsufficientRewards) || errors.Is(err, services.ErrRedemptionValueNeeded) ||
+		errors.Is(err, models.ErrExchangeRateNotFound) {
+		utilities.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to redeem rewards")
+		return
+	}
+
+	utilities.CreatedResponse(c, result, "Rewards redeemed successfully")
+}
+
+// ListRewardRedemptions returns a credit card's reward redemptions
+func ListRewardRedemptions(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	cardID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid credit card ID")
+		return
+	}
+
+	var card models.CreditCard
+	if err := database.DB.Where("id = ? AND user_id = ?", cardID, userID).First(&card).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusNotFound, "Credit card not found")
+		return
+	}
+
+	var redemptions []models.RewardRedemption
+	if err := database.DB.Where("card_id = ? AND user_id = ?", cardID, userID).
+		Order("redeemed_at DESC").Find(&redemptions).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch redemptions")
+		return
+	}
+
+	utilities.SuccessResponse(c, redemptions, "Redemptions retrieved successfully")
+}
+
+// GetRewardBalance reports a credit card's reward balance per type and the
+// rewards about to expire
+func GetRewardBalance(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	cardID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid credit card ID")
+		return
+	}
+
+	var card models.CreditCard
+	if err := database.DB.Where("id = ? AND user_id = ?", cardID, userID).First(&card).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusNotFound, "Credit card not found")
+		return
+	}
+
+	days := 90
+	if daysParam := c.Query("days"); daysParam != "" {
+		if parsedDays, err := strconv.Atoi(daysParam); err == nil && parsedDays > 0 && parsedDays <= 3650 {
+			days = parsedDays
+		}
+	}
+
+	balances, err := services.RewardBalances(database.DB, cardID, time.Now(), days)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to calculate reward balance")
+		return
+	}
+
+	result := map[string]interface{}{
+		"cardId":       card.ID,
+		"rewardType":   card.RewardType,
+		"expiringDays": days,
+		"balances":     balances,
+	}
+
+	utilities.SuccessResponse(c, result, "Reward balance retrieved successfully")
+}
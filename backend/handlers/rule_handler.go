@@ -0,0 +1,387 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"daybook-backend/database"
+	"daybook-backend/middleware"
+	"daybook-backend/models"
+	"daybook-backend/services"
+	"daybook-backend/utilities"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ApplyRulesRequest selects the rules to run over existing transactions
+type ApplyRulesRequest struct {
+	RuleIDs []uuid.UUID `json:"ruleIds"` // Defaults to every active rule
+}
+
+// ListRules returns the user's transaction rules in the order they run
+func ListRules(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var rules []models.TransactionRule
+	if err := database.DB.Where("user_id = ?", userID).Order("priority ASC, created_at ASC").Find(&rules).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch rules")
+		return
+	}
+
+	utilities.SuccessResponse(c, rules, "Rules retrieved successfully")
+}
+
+// GetRule returns a specific transaction rule
+func GetRule(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	ruleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid rule ID")
+		return
+	}
+
+	var rule models.TransactionRule
+	if err := database.DB.Where("id = ? AND user_id = ?", ruleID, userID).First(&rule).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusNotFound, "Rule not found")
+		return
+	}
+
+	utilities.SuccessResponse(c, rule, "Rule retrieved successfully")
+}
+
+// CreateRule creates a transaction rule
+func CreateRule(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var rule models.TransactionRule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !normalizeTransactionRule(c, userID, &rule) {
+		return
+	}
+	rule.UserID = userID
+
+	if err := database.DB.Create(&rule).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to create rule")
+		return
+	}
+
+	utilities.CreatedResponse(c, rule, "Rule created successfully")
+}
+
+// UpdateRule updates a transaction rule. Transactions it already changed
+// are left as they are.
+func UpdateRule(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	ruleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid rule ID")
+		return
+	}
+
+	var existingRule models.TransactionRule
+	if err := database.DB.Where("id = ? AND user_id = ?", ruleID, userID).First(&existingRule).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusNotFound, "Rule not found")
+		return
+	}
+
+	var updateData models.TransactionRule
+	if err := c.ShouldBindJSON(&updateData); err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !normalizeTransactionRule(c, userID, &updateData) {
+		return
+	}
+
+	// Update allowed fields
+	existingRule.Name = updateData.Name
+	existingRule.Priority = updateData.Priority
+	existingRule.Active = updateData.Active
+	existingRule.StopProcessing = updateData.StopProcessing
+	existingRule.DescriptionPattern = updateData.DescriptionPattern
+	existingRule.MinAmount = updateData.MinAmount
+	existingRule.MaxAmount = updateData.MaxAmount
+	existingRule.AccountID = updateData.AccountID
+	existingRule.Type = updateData.Type
+	existingRule.SetCategoryID = updateData.SetCategoryID
+	existingRule.Tags = updateData.Tags
+	existingRule.SetDescription = updateData.SetDescription
+	existingRule.TransferAccountID = updateData.TransferAccountID
+
+	if err := database.DB.Save(&existingRule).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to update rule")
+		return
+	}
+
+	utilities.SuccessResponse(c, existingRule, "Rule updated successfully")
+}
+
+// DeleteRule deletes a transaction rule. Transactions it already changed
+// are left as they are.
+func DeleteRule(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	ruleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid rule ID")
+		return
+	}
+
+	var rule models.TransactionRule
+	if err := database.DB.Where("id = ? AND user_id = ?", ruleID, userID).First(&rule).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusNotFound, "Rule not found")
+		return
+	}
+
+	if err := database.DB.Delete(&rule).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete rule")
+		return
+	}
+
+	utilities.SuccessResponse(c, nil, "Rule deleted successfully")
+}
+
+// TestRule tries a rule, saved or not, against the user's existing
+// transactions and reports what it would change without saving anything
+func TestRule(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var rule models.TransactionRule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !normalizeTransactionRule(c, userID, &rule) {
+		return
+	}
+	rule.UserID = userID
+
+	engine, err := services.NewRuleEngine(database.DB, userID, []models.TransactionRule{rule})
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to load rules")
+		return
+	}
+
+	run, err := services.TestRules(database.DB, userID, engine, ruleHistoryFilter(c), ruleMatchLimit(c))
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to test rule")
+		return
+	}
+
+	utilities.SuccessResponse(c, run, "Rule tested successfully")
+}
+
+// ApplyRules runs rules over the user's existing transactions and saves
+// what they change, all in one database transaction
+func ApplyRules(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req ApplyRulesRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utilities.ErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	var engine *services.RuleEngine
+	if len(req.RuleIDs) == 0 {
+		engine, err = services.LoadRuleEngine(database.DB, userID)
+	} else {
+		var rules []models.TransactionRule
+		if err := database.DB.Where("id IN ? AND user_id = ?", req.RuleIDs, userID).
+			Order("priority ASC, created_at ASC").Find(&rules).Error; err != nil {
+			utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch rules")
+			return
+		}
+		if len(rules) != len(req.RuleIDs) {
+			utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid rule IDs")
+			return
+		}
+		engine, err = services.NewRuleEngine(database.DB, userID, rules)
+	}
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to load rules")
+		return
+	}
+
+	var run *services.RuleRun
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		run, err = services.ApplyRulesToHistory(tx, userID, engine, ruleHistoryFilter(c), ruleMatchLimit(c))
+		return err
+	})
+	if errors.Is(err, models.ErrExchangeRateNotFound) {
+		utilities.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to apply rules")
+		return
+	}
+
+	utilities.SuccessResponse(c, run, "Rules applied successfully")
+}
+
+// normalizeTransactionRule validates the rule's conditions and actions,
+// storing the category's key and tidying its tags, and writes the error
+// response when they are invalid
+func normalizeTransactionRule(c *gin.Context, userID uuid.UUID, rule *models.TransactionRule) bool {
+	rule.Name = strings.TrimSpace(rule.Name)
+	if rule.Name == "" {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Name is required")
+		return false
+	}
+
+	switch rule.Type {
+	case "", "income", "expense", "transfer":
+	default:
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid type. Must be one of: income, expense, transfer")
+		return false
+	}
+
+	if rule.DescriptionPattern != "" {
+		if _, err := services.CompileRulePattern(rule.DescriptionPattern); err != nil {
+			utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid description pattern: "+err.Error())
+			return false
+		}
+	}
+
+	if (rule.MinAmount != nil && *rule.MinAmount < 0) || (rule.MaxAmount != nil && *rule.MaxAmount < 0) {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Amounts cannot be negative")
+		return false
+	}
+	if rule.MinAmount != nil && rule.MaxAmount != nil && *rule.MinAmount > *rule.MaxAmount {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Minimum amount cannot be more than the maximum amount")
+		return false
+	}
+
+	if rule.AccountID != nil {
+		var accounts, cards int64
+		database.DB.Model(&models.Account{}).Where("id = ? AND user_id = ?", *rule.AccountID, userID).Count(&accounts)
+		database.DB.Model(&models.CreditCard{}).Where("id = ? AND user_id = ?", *rule.AccountID, userID).Count(&cards)
+		if accounts == 0 && cards == 0 {
+			utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid account ID")
+			return false
+		}
+	}
+
+	if rule.SetCategoryID != "" {
+		category, err := models.FindCategory(database.DB, userID, rule.SetCategoryID)
+		if err != nil {
+			utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid category: "+rule.SetCategoryID)
+			return false
+		}
+		if category.IsSystem || category.Kind == models.CategoryKindTransfer {
+			utilities.ErrorResponse(c, http.StatusBadRequest, "Category "+category.Key+" cannot be set by a rule")
+			return false
+		}
+		if (rule.Type == "income" || rule.Type == "expense") && category.Kind != rule.Type {
+			utilities.ErrorResponse(c, http.StatusBadRequest, "Category "+category.Key+" cannot be used for "+rule.Type+" transactions")
+			return false
+		}
+		rule.SetCategoryID = category.Key
+	}
+
+	rule.Tags = uniqueTags(rule.Tags)
+
+	if rule.TransferAccountID != nil {
+		var account models.Account
+		if err := database.DB.Where("id = ? AND user_id = ?", *rule.TransferAccountID, userID).First(&account).Error; err != nil {
+			utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid transfer account ID")
+			return false
+		}
+		if rule.Type == "transfer" {
+			utilities.ErrorResponse(c, http.StatusBadRequest, "Only income and expense transactions can be turned into transfers")
+			return false
+		}
+	}
+
+	if rule.SetCategoryID == "" && len(rule.Tags) == 0 && rule.SetDescription == "" && rule.TransferAccountID == nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "A rule needs at least one action: setCategoryId, tags, setDescription or transferAccountId")
+		return false
+	}
+
+	return true
+}
+
+// ruleHistoryFilter reads the startDate, endDate and accountId query
+// parameters that narrow the transactions rules are run on
+func ruleHistoryFilter(c *gin.Context) services.RuleHistoryFilter {
+	filter := exportFilter(c)
+	return services.RuleHistoryFilter{
+		StartDate: filter.StartDate,
+		EndDate:   filter.EndDate,
+		AccountID: filter.AccountID,
+	}
+}
+
+// ruleMatchLimit reads how many changed transactions to list, 100 by
+// default and at most 500
+func ruleMatchLimit(c *gin.Context) int {
+	limit := 100
+	if limitParam := c.Query("limit"); limitParam != "" {
+		if parsedLimit, err := strconv.Atoi(limitParam); err == nil && parsedLimit > 0 {
+			limit = parsedLimit
+		}
+	}
+	if limit > 500 {
+		limit = 500
+	}
+	return limit
+}
+
+// newTransactionRules loads the user's rules to run on new transactions, or
+// none when the request passes applyRules=false. It writes the error
+// response when the rules cannot be loaded.
+func newTransactionRules(c *gin.Context, userID uuid.UUID) (*services.RuleEngine, bool) {
+	if c.Query("applyRules") == "false" {
+		return nil, true
+	}
+	engine, err := services.LoadRuleEngine(database.DB, userID)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to load rules")
+		return nil, false
+	}
+	return engine, true
+}
@@ -186,10 +186,10 @@ func AddContribution(c *gin.Context) {
 	}
 
 	var contributionData struct {
-		Amount    float64    `json:"amount" binding:"required,gt=0"`
-		AccountID uuid.UUID  `json:"accountId" binding:"required"`
-		Date      *time.Time `json:"date"`
-		Notes     string     `json:"notes"`
+		Amount    models.Money `json:"amount" binding:"required,gt=0"`
+		AccountID uuid.UUID    `json:"accountId" binding:"required"`
+		Date      *time.Time   `json:"date"`
+		Notes     string       `json:"notes"`
 	}
 
 	if err := c.ShouldBindJSON(&contributionData); err != nil {
@@ -311,10 +311,10 @@ func WithdrawFromGoal(c *gin.Context) {
 	}
 
 	var withdrawalData struct {
-		Amount    float64    `json:"amount" binding:"required,gt=0"`
-		AccountID uuid.UUID  `json:"accountId" binding:"required"`
-		Date      *time.Time `json:"date"`
-		Notes     string     `json:"notes"`
+		Amount    models.Money `json:"amount" binding:"required,gt=0"`
+		AccountID uuid.UUID    `json:"accountId" binding:"required"`
+		Date      *time.Time   `json:"date"`
+		Notes     string       `json:"notes"`
 	}
 
 	if err := c.ShouldBindJSON(&withdrawalData); err != nil {
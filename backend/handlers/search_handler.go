@@ -0,0 +1,320 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"daybook-backend/database"
+	"daybook-backend/middleware"
+	"daybook-backend/models"
+	"daybook-backend/services"
+	"daybook-backend/utilities"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// SearchTransactions finds transactions by free text, amount, category,
+// account, tags and more, in the order the search asks for
+func SearchTransactions(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var search models.TransactionSearch
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&search); err != nil {
+			utilities.ErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	if !normalizeTransactionSearch(c, userID, &search) {
+		return
+	}
+
+	respondTransactionSearch(c, userID, search)
+}
+
+// ListSavedSearches returns the user's saved searches by name
+func ListSavedSearches(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var searches []models.SavedSearch
+	if err := database.DB.Where("user_id = ?", userID).Order("LOWER(name) ASC").Find(&searches).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch saved searches")
+		return
+	}
+
+	utilities.SuccessResponse(c, searches, "Saved searches retrieved successfully")
+}
+
+// GetSavedSearch returns a specific saved search
+func GetSavedSearch(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	searchID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid saved search ID")
+		return
+	}
+
+	var savedSearch models.SavedSearch
+	if err := database.DB.Where("id = ? AND user_id = ?", searchID, userID).First(&savedSearch).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusNotFound, "Saved search not found")
+		return
+	}
+
+	utilities.SuccessResponse(c, savedSearch, "Saved search retrieved successfully")
+}
+
+// CreateSavedSearch saves a transaction search under a name
+func CreateSavedSearch(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var savedSearch models.SavedSearch
+	if err := c.ShouldBindJSON(&savedSearch); err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	savedSearch.Name = strings.TrimSpace(savedSearch.Name)
+	if savedSearch.Name == "" {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Name is required")
+		return
+	}
+	if !normalizeTransactionSearch(c, userID, &savedSearch.Search) {
+		return
+	}
+	savedSearch.UserID = userID
+
+	if err := database.DB.Create(&savedSearch).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to create saved search")
+		return
+	}
+
+	utilities.CreatedResponse(c, savedSearch, "Saved search created successfully")
+}
+
+// UpdateSavedSearch renames a saved search or replaces its search
+func UpdateSavedSearch(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	searchID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid saved search ID")
+		return
+	}
+
+	var existingSearch models.SavedSearch
+	if err := database.DB.Where("id = ? AND user_id = ?", searchID, userID).First(&existingSearch).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusNotFound, "Saved search not found")
+		return
+	}
+
+	var updateData models.SavedSearch
+	if err := c.ShouldBindJSON(&updateData); err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	updateData.Name = strings.TrimSpace(updateData.Name)
+	if updateData.Name == "" {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Name is required")
+		return
+	}
+	if !normalizeTransactionSearch(c, userID, &updateData.Search) {
+		return
+	}
+
+	// Update allowed fields
+	existingSearch.Name = updateData.Name
+	existingSearch.Search = updateData.Search
+
+	if err := database.DB.Save(&existingSearch).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to update saved search")
+		return
+	}
+
+	utilities.SuccessResponse(c, existingSearch, "Saved search updated successfully")
+}
+
+// DeleteSavedSearch deletes a saved search
+func DeleteSavedSearch(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	searchID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid saved search ID")
+		return
+	}
+
+	var savedSearch models.SavedSearch
+	if err := database.DB.Where("id = ? AND user_id = ?", searchID, userID).First(&savedSearch).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusNotFound, "Saved search not found")
+		return
+	}
+
+	if err := database.DB.Delete(&savedSearch).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete saved search")
+		return
+	}
+
+	utilities.SuccessResponse(c, nil, "Saved search deleted successfully")
+}
+
+// RunSavedSearch returns the transactions a saved search finds now
+func RunSavedSearch(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	searchID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid saved search ID")
+		return
+	}
+
+	var savedSearch models.SavedSearch
+	if err := database.DB.Where("id = ? AND user_id = ?", searchID, userID).First(&savedSearch).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusNotFound, "Saved search not found")
+		return
+	}
+
+	// Categories may have been deleted since the search was saved
+	if !normalizeTransactionSearch(c, userID, &savedSearch.Search) {
+		return
+	}
+
+	respondTransactionSearch(c, userID, savedSearch.Search)
+}
+
+// respondTransactionSearch runs a normalized search and writes the page of
+// transactions the page and limit query parameters select
+func respondTransactionSearch(c *gin.Context, userID uuid.UUID, search models.TransactionSearch) {
+	page, limit := transactionPage(c)
+
+	transactions, totalCount, err := services.SearchTransactions(database.DB, userID, search, page, limit)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to search transactions")
+		return
+	}
+
+	if err := models.LoadTransactionSplits(database.DB, transactions); err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch transaction splits")
+		return
+	}
+
+	response := map[string]interface{}{
+		"transactions": enrichTransactions(transactions),
+		"pagination":   paginationResponse(page, limit, totalCount),
+		"search":       search,
+	}
+
+	utilities.SuccessResponse(c, response, "Transactions retrieved successfully")
+}
+
+// normalizeTransactionSearch validates a search, storing category keys and
+// tidying its text and tags, and writes the error response when it is invalid
+func normalizeTransactionSearch(c *gin.Context, userID uuid.UUID, search *models.TransactionSearch) bool {
+	search.Text = strings.TrimSpace(search.Text)
+
+	for _, transactionType := range search.Types {
+		switch transactionType {
+		case "income", "expense", "transfer", "tracking":
+		default:
+			utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid type. Must be one of: income, expense, transfer, tracking")
+			return false
+		}
+	}
+
+	for i, categoryID := range search.CategoryIDs {
+		category, err := models.FindCategory(database.DB, userID, categoryID)
+		if err != nil {
+			utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid category: "+categoryID)
+			return false
+		}
+		search.CategoryIDs[i] = category.Key
+	}
+
+	if (search.MinAmount != nil && *search.MinAmount < 0) || (search.MaxAmount != nil && *search.MaxAmount < 0) {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Amounts cannot be negative")
+		return false
+	}
+	if search.MinAmount != nil && search.MaxAmount != nil && *search.MinAmount > *search.MaxAmount {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Minimum amount cannot be more than the maximum amount")
+		return false
+	}
+
+	var startDate, endDate time.Time
+	var err error
+	if search.StartDate != "" {
+		if startDate, err = time.Parse("2006-01-02", search.StartDate); err != nil {
+			utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid start date. Use YYYY-MM-DD")
+			return false
+		}
+	}
+	if search.EndDate != "" {
+		if endDate, err = time.Parse("2006-01-02", search.EndDate); err != nil {
+			utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid end date. Use YYYY-MM-DD")
+			return false
+		}
+	}
+	if search.StartDate != "" && search.EndDate != "" && endDate.Before(startDate) {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "End date cannot be before the start date")
+		return false
+	}
+
+	search.AllTags = uniqueTags(search.AllTags)
+	search.AnyTags = uniqueTags(search.AnyTags)
+	search.NoTags = uniqueTags(search.NoTags)
+
+	switch search.Source {
+	case "", "account", "credit_card":
+	default:
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid source. Must be one of: account, credit_card")
+		return false
+	}
+
+	switch search.Sort {
+	case "", "date", "amount", "description", "relevance":
+	default:
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid sort. Must be one of: date, amount, description, relevance")
+		return false
+	}
+
+	search.Order = strings.ToLower(search.Order)
+	switch search.Order {
+	case "", "asc", "desc":
+	default:
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid order. Must be one of: asc, desc")
+		return false
+	}
+
+	return true
+}
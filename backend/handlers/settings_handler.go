@@ -1,11 +1,15 @@
 package handlers
 
 import (
+	"net"
 	"net/http"
+	"net/url"
+	"strings"
 
 	"daybook-backend/database"
 	"daybook-backend/middleware"
 	"daybook-backend/models"
+	"daybook-backend/services"
 	"daybook-backend/utilities"
 
 	"github.com/gin-gonic/gin"
@@ -29,12 +33,8 @@ func GetSettings(c *gin.Context) {
 			DateFormat:     "MM/DD/YYYY",
 			FirstDayOfWeek: 0,
 			Language:       "en",
-			Notifications: &models.Notifications{
-				Push:          true,
-				Email:         true,
-				BudgetAlerts:  true,
-				BillReminders: true,
-			},
+			TimeZone:       "UTC",
+			Notifications:  models.DefaultNotificationSettings(),
 		}
 
 		if err := database.DB.Create(&settings).Error; err != nil {
@@ -60,6 +60,29 @@ func UpdateSettings(c *gin.Context) {
 		return
 	}
 
+	if updateData.TimeZone == "" {
+		updateData.TimeZone = "UTC"
+	}
+	if _, err := models.LoadTimeZone(updateData.TimeZone); err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Unknown time zone")
+		return
+	}
+
+	// Webhook delivery posts to this URL, so only accept http(s) endpoints
+	if updateData.Notifications != nil && updateData.Notifications.WebhookURL != "" {
+		webhookURL, err := url.Parse(updateData.Notifications.WebhookURL)
+		if err != nil || (webhookURL.Scheme != "http" && webhookURL.Scheme != "https") || webhookURL.Host == "" {
+			utilities.ErrorResponse(c, http.StatusBadRequest, "Webhook URL must be an http or https URL")
+			return
+		}
+		// Delivery refuses private addresses too; catch the obvious ones here
+		host := webhookURL.Hostname()
+		if ip := net.ParseIP(host); !services.WebhookAllowPrivate && (strings.EqualFold(host, "localhost") || (ip != nil && !services.IsPublicAddress(ip))) {
+			utilities.ErrorResponse(c, http.StatusBadRequest, "Webhook URL must be a public address")
+			return
+		}
+	}
+
 	var settings models.Settings
 	result := database.DB.Where("user_id = ?", userID).First(&settings)
 
@@ -72,6 +95,7 @@ func UpdateSettings(c *gin.Context) {
 			DateFormat:     updateData.DateFormat,
 			FirstDayOfWeek: updateData.FirstDayOfWeek,
 			Language:       updateData.Language,
+			TimeZone:       updateData.TimeZone,
 			Notifications:  updateData.Notifications,
 		}
 
@@ -86,6 +110,7 @@ func UpdateSettings(c *gin.Context) {
 		settings.DateFormat = updateData.DateFormat
 		settings.FirstDayOfWeek = updateData.FirstDayOfWeek
 		settings.Language = updateData.Language
+		settings.TimeZone = updateData.TimeZone
 
 		// Update notifications if provided
 		if updateData.Notifications != nil {
@@ -0,0 +1,503 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"daybook-backend/database"
+	"daybook-backend/middleware"
+	"daybook-backend/models"
+	"daybook-backend/utilities"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// taggedTables lists the tables whose jsonb tags column holds tag names
+var taggedTables = []string{"transactions", "transaction_splits", "credit_card_transactions"}
+
+// TagResponse is a tag together with how often it is used
+type TagResponse struct {
+	models.Tag
+	TransactionCount           int64 `json:"transactionCount"`
+	CreditCardTransactionCount int64 `json:"creditCardTransactionCount"`
+}
+
+// TagUsage reports how often a tag name is used, whether or not it is a managed tag
+type TagUsage struct {
+	Name                       string     `json:"name"`
+	TagID                      *uuid.UUID `json:"tagId"`
+	TransactionCount           int64      `json:"transactionCount"`
+	CreditCardTransactionCount int64      `json:"creditCardTransactionCount"`
+}
+
+// MergeTagsRequest represents the request body for merging tags
+type MergeTagsRequest struct {
+	SourceTags []string `json:"sourceTags" binding:"required,min=1"`
+	TargetTag  string   `json:"targetTag" binding:"required"`
+}
+
+// ListTags returns all tags for the authenticated user with usage counts
+func ListTags(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var tags []models.Tag
+	if err := database.DB.Where("user_id = ?", userID).Order("name ASC").Find(&tags).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch tags")
+		return
+	}
+
+	usage, err := tagUsageCounts(database.DB, userID)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to count tag usage")
+		return
+	}
+
+	response := make([]TagResponse, len(tags))
+	for i, tag := range tags {
+		response[i] = TagResponse{Tag: tag}
+		if counts, ok := usage[tag.Name]; ok {
+			response[i].TransactionCount = counts.TransactionCount
+			response[i].CreditCardTransactionCount = counts.CreditCardTransactionCount
+		}
+	}
+
+	utilities.SuccessResponse(c, response, "Tags retrieved successfully")
+}
+
+// GetTagUsage returns usage counts for every tag name found on transactions,
+// including free-form tags that have no managed Tag record yet
+func GetTagUsage(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	usage, err := tagUsageCounts(database.DB, userID)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to count tag usage")
+		return
+	}
+
+	var tags []models.Tag
+	if err := database.DB.Where("user_id = ?", userID).Find(&tags).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch tags")
+		return
+	}
+
+	for i := range tags {
+		counts, ok := usage[tags[i].Name]
+		if !ok {
+			counts = &TagUsage{Name: tags[i].Name}
+			usage[tags[i].Name] = counts
+		}
+		counts.TagID = &tags[i].ID
+	}
+
+	response := make([]*TagUsage, 0, len(usage))
+	for _, counts := range usage {
+		response = append(response, counts)
+	}
+	sortTagUsage(response)
+
+	utilities.SuccessResponse(c, response, "Tag usage retrieved successfully")
+}
+
+// GetTag returns a specific tag by ID
+func GetTag(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	tagID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid tag ID")
+		return
+	}
+
+	var tag models.Tag
+	if err := database.DB.Where("id = ? AND user_id = ?", tagID, userID).First(&tag).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusNotFound, "Tag not found")
+		return
+	}
+
+	utilities.SuccessResponse(c, tag, "Tag retrieved successfully")
+}
+
+// CreateTag creates a new tag
+func CreateTag(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var tag models.Tag
+	if err := c.ShouldBindJSON(&tag); err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tag.UserID = userID
+	tag.Name = strings.TrimSpace(tag.Name)
+	if tag.Name == "" {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Tag name is required")
+		return
+	}
+
+	var existingTag models.Tag
+	if err := database.DB.Where("user_id = ? AND name = ?", userID, tag.Name).First(&existingTag).Error; err == nil {
+		utilities.ErrorResponse(c, http.StatusConflict, "Tag already exists")
+		return
+	}
+
+	if err := database.DB.Create(&tag).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to create tag")
+		return
+	}
+
+	utilities.CreatedResponse(c, tag, "Tag created successfully")
+}
+
+// UpdateTag updates a tag. Renaming a tag rewrites it on every transaction
+// and credit card transaction that uses it.
+func UpdateTag(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	tagID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid tag ID")
+		return
+	}
+
+	var existingTag models.Tag
+	if err := database.DB.Where("id = ? AND user_id = ?", tagID, userID).First(&existingTag).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusNotFound, "Tag not found")
+		return
+	}
+
+	var updateData models.Tag
+	if err := c.ShouldBindJSON(&updateData); err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	newName := strings.TrimSpace(updateData.Name)
+	if newName == "" {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Tag name is required")
+		return
+	}
+
+	oldName := existingTag.Name
+	if newName != oldName {
+		var conflictingTag models.Tag
+		if err := database.DB.Where("user_id = ? AND name = ? AND id != ?", userID, newName, tagID).First(&conflictingTag).Error; err == nil {
+			utilities.ErrorResponse(c, http.StatusConflict, "A tag with this name already exists, merge the tags instead")
+			return
+		}
+	}
+
+	existingTag.Name = newName
+	existingTag.Color = updateData.Color
+
+	var updatedCount int64
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&existingTag).Error; err != nil {
+			return err
+		}
+		if newName == oldName {
+			return nil
+		}
+		updatedCount, err = rewriteTags(tx, userID, []string{oldName}, newName)
+		return err
+	})
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to update tag")
+		return
+	}
+
+	result := map[string]interface{}{
+		"tag":                 existingTag,
+		"updatedTransactions": updatedCount,
+	}
+
+	utilities.SuccessResponse(c, result, "Tag updated successfully")
+}
+
+// DeleteTag deletes a tag. With removeFromTransactions=true the tag is also
+// stripped from every transaction that uses it.
+func DeleteTag(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	tagID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid tag ID")
+		return
+	}
+
+	var tag models.Tag
+	if err := database.DB.Where("id = ? AND user_id = ?", tagID, userID).First(&tag).Error; err != nil {
+		utilities.ErrorResponse(c, http.StatusNotFound, "Tag not found")
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if c.Query("removeFromTransactions") == "true" {
+			if _, err := rewriteTags(tx, userID, []string{tag.Name}, ""); err != nil {
+				return err
+			}
+		}
+		// Soft delete
+		return tx.Delete(&tag).Error
+	})
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete tag")
+		return
+	}
+
+	utilities.SuccessResponse(c, nil, "Tag deleted successfully")
+}
+
+// MergeTags folds one or more source tag names into a target tag. Source
+// names may be managed tags or free-form tags only found on transactions.
+// The target tag is created if it does not exist yet.
+func MergeTags(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req MergeTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	targetName := strings.TrimSpace(req.TargetTag)
+	if targetName == "" {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "Target tag is required")
+		return
+	}
+
+	sources := make([]string, 0, len(req.SourceTags))
+	for _, name := range req.SourceTags {
+		if name != targetName && name != "" {
+			sources = append(sources, name)
+		}
+	}
+	if len(sources) == 0 {
+		utilities.ErrorResponse(c, http.StatusBadRequest, "At least one source tag different from the target is required")
+		return
+	}
+
+	var target models.Tag
+	var updatedCount int64
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND name = ?", userID, targetName).First(&target).Error; err != nil {
+			target = models.Tag{UserID: userID, Name: targetName}
+			// Inherit the colour of the first managed source tag
+			var source models.Tag
+			if err := tx.Where("user_id = ? AND name IN ?", userID, sources).Order("created_at ASC").First(&source).Error; err == nil {
+				target.Color = source.Color
+			}
+			if err := tx.Create(&target).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Where("user_id = ? AND name IN ?", userID, sources).Delete(&models.Tag{}).Error; err != nil {
+			return err
+		}
+
+		var err error
+		updatedCount, err = rewriteTags(tx, userID, sources, targetName)
+		return err
+	})
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to merge tags")
+		return
+	}
+
+	result := map[string]interface{}{
+		"tag":                 target,
+		"mergedTags":          sources,
+		"updatedTransactions": updatedCount,
+	}
+
+	utilities.SuccessResponse(c, result, "Tags merged successfully")
+}
+
+// rewriteTags replaces every tag in sources with target in the jsonb tag
+// arrays of the user's transactions, their splits and credit card
+// transactions, and in the tags their rules add. Duplicates created by the
+// rewrite are dropped and the original order is kept. An empty target
+// removes the source tags instead. Returns the number of transactions changed.
+func rewriteTags(tx *gorm.DB, userID uuid.UUID, sources []string, target string) (int64, error) {
+	var total int64
+
+	for _, table := range append(slices.Clip(taggedTables), "transaction_rules") {
+		query := fmt.Sprintf(`
+			UPDATE %s SET tags = (
+				SELECT COALESCE(jsonb_agg(t.tag ORDER BY t.pos), '[]'::jsonb)
+				FROM (
+					SELECT m.tag, MIN(m.pos) AS pos
+					FROM (
+						SELECT CASE WHEN e.tag IN ? THEN ? ELSE e.tag END AS tag, e.pos
+						FROM jsonb_array_elements_text(%s.tags) WITH ORDINALITY AS e(tag, pos)
+					) m
+					WHERE m.tag <> ''
+					GROUP BY m.tag
+				) t
+			), updated_at = NOW()
+			WHERE user_id = ? AND deleted_at IS NULL AND jsonb_typeof(tags) = 'array'
+				AND EXISTS (SELECT 1 FROM jsonb_array_elements_text(tags) AS s(tag) WHERE s.tag IN ?)`,
+			table, table)
+
+		result := tx.Exec(query, sources, target, userID, sources)
+		if result.Error != nil {
+			return total, result.Error
+		}
+		if table != "transaction_rules" {
+			total += result.RowsAffected
+		}
+	}
+
+	return total, nil
+}
+
+// transactionTagUsageSQL counts the transactions carrying each tag, on the
+// transaction itself or on any of its splits. A transaction tagged in both
+// places counts once.
+const transactionTagUsageSQL = `
+	SELECT u.tag, COUNT(DISTINCT u.transaction_id) AS count
+	FROM (
+		SELECT e.tag, transactions.id AS transaction_id
+		FROM transactions, jsonb_array_elements_text(transactions.tags) AS e(tag)
+		WHERE transactions.user_id = @user AND transactions.deleted_at IS NULL AND jsonb_typeof(transactions.tags) = 'array'
+		UNION ALL
+		SELECT e.tag, transaction_splits.transaction_id
+		FROM transaction_splits, jsonb_array_elements_text(transaction_splits.tags) AS e(tag)
+		WHERE transaction_splits.user_id = @user AND transaction_splits.deleted_at IS NULL AND jsonb_typeof(transaction_splits.tags) = 'array'
+	) AS u
+	GROUP BY u.tag`
+
+// creditCardTagUsageSQL counts the credit card transactions carrying each tag
+const creditCardTagUsageSQL = `
+	SELECT e.tag, COUNT(DISTINCT credit_card_transactions.id) AS count
+	FROM credit_card_transactions, jsonb_array_elements_text(credit_card_transactions.tags) AS e(tag)
+	WHERE credit_card_transactions.user_id = @user AND credit_card_transactions.deleted_at IS NULL AND jsonb_typeof(credit_card_transactions.tags) = 'array'
+	GROUP BY e.tag`
+
+// tagUsageCounts counts how many transactions and credit card transactions
+// carry each tag name
+func tagUsageCounts(db *gorm.DB, userID uuid.UUID) (map[string]*TagUsage, error) {
+	usage := make(map[string]*TagUsage)
+	args := map[string]interface{}{"user": userID}
+
+	for _, query := range []string{transactionTagUsageSQL, creditCardTagUsageSQL} {
+		var rows []struct {
+			Tag   string
+			Count int64
+		}
+		if err := db.Raw(query, args).Scan(&rows).Error; err != nil {
+			return nil, err
+		}
+
+		for _, row := range rows {
+			counts, ok := usage[row.Tag]
+			if !ok {
+				counts = &TagUsage{Name: row.Tag}
+				usage[row.Tag] = counts
+			}
+			if query == creditCardTagUsageSQL {
+				counts.CreditCardTransactionCount = row.Count
+			} else {
+				counts.TransactionCount = row.Count
+			}
+		}
+	}
+
+	return usage, nil
+}
+
+// sortTagUsage orders tag usage by total usage, most used first, then by name
+func sortTagUsage(usage []*TagUsage) {
+	total := func(u *TagUsage) int64 { return u.TransactionCount + u.CreditCardTransactionCount }
+	sort.Slice(usage, func(i, j int) bool {
+		if total(usage[i]) != total(usage[j]) {
+			return total(usage[i]) > total(usage[j])
+		}
+		return usage[i].Name < usage[j].Name
+	})
+}
+
+// applyTagFilters adds the tags, anyTag and allTags query filters to a
+// transaction query. tags and allTags match transactions carrying every
+// listed tag, anyTag matches transactions carrying at least one of them.
+// A split transaction carries the tags of its splits too. Each parameter
+// takes a comma-separated list of tag names.
+func applyTagFilters(c *gin.Context, query *gorm.DB) *gorm.DB {
+	const splitTags = "EXISTS (SELECT 1 FROM transaction_splits WHERE transaction_splits.transaction_id = transactions.id AND transaction_splits.deleted_at IS NULL AND "
+
+	for _, param := range []string{"tags", "allTags"} {
+		// Every tag on the transaction or on one of its splits
+		for _, name := range splitTagList(c.Query(param)) {
+			encoded, _ := json.Marshal([]string{name})
+			query = query.Where("transactions.tags @> ?::jsonb OR "+splitTags+"transaction_splits.tags @> ?::jsonb)", string(encoded), string(encoded))
+		}
+	}
+
+	if names := splitTagList(c.Query("anyTag")); len(names) > 0 {
+		query = query.Where("(jsonb_typeof(transactions.tags) = 'array' AND EXISTS (SELECT 1 FROM jsonb_array_elements_text(transactions.tags) AS e(tag) WHERE e.tag IN ?)) OR "+
+			splitTags+"jsonb_typeof(transaction_splits.tags) = 'array' AND EXISTS (SELECT 1 FROM jsonb_array_elements_text(transaction_splits.tags) AS e(tag) WHERE e.tag IN ?))", names, names)
+	}
+
+	return query
+}
+
+// splitTagList splits a comma-separated tag list, dropping empty entries
+func splitTagList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// uniqueTags trims tag names, dropping empty and repeated ones
+func uniqueTags(tags []string) []string {
+	unique := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag != "" && !slices.Contains(unique, tag) {
+			unique = append(unique, tag)
+		}
+	}
+	return unique
+}
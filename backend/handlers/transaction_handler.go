@@ -1,13 +1,16 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
-	"strconv"
+	"sort"
+	"strings"
 	"time"
 
 	"daybook-backend/database"
 	"daybook-backend/middleware"
 	"daybook-backend/models"
+	"daybook-backend/services"
 	"daybook-backend/utilities"
 
 	"github.com/gin-gonic/gin"
@@ -34,14 +37,18 @@ func ListTransactions(c *gin.Context) {
 		query = query.Where("type = ?", transactionType)
 	}
 
+	// A split transaction matches the categories of its splits too
 	if categoryID := c.Query("categoryId"); categoryID != "" {
-		query = query.Where("category_id = ?", categoryID)
+		query = query.Where("category_id = ? OR EXISTS (SELECT 1 FROM transaction_splits WHERE transaction_splits.transaction_id = transactions.id AND transaction_splits.category_id = ? AND transaction_splits.deleted_at IS NULL)", categoryID, categoryID)
 	}
 
 	if accountID := c.Query("accountId"); accountID != "" {
 		query = query.Where("account_id = ?", accountID)
 	}
 
+	// Tag filters (tags, anyTag, allTags)
+	query = applyTagFilters(c, query)
+
 	if startDate := c.Query("startDate"); startDate != "" {
 		if parsedDate, err := time.Parse("2006-01-02", startDate); err == nil {
 			// Set to beginning of day
@@ -58,97 +65,115 @@ func ListTransactions(c *gin.Context) {
 		}
 	}
 
-	// Pagination parameters
-	page := 1
-	limit := 20 // default limit
+	var transactions []models.Transaction
+	response := map[string]interface{}{}
 
-	if pageParam := c.Query("page"); pageParam != "" {
-		if parsedPage, err := strconv.Atoi(pageParam); err == nil && parsedPage > 0 {
-			page = parsedPage
+	// Pages are by offset with a total count unless a cursor page is asked for
+	if wantsCursorPage(c) {
+		pagination, ok := paginate(c, query, transactionKeyset, transactionCursorKey, &transactions)
+		if !ok {
+			return
 		}
-	}
+		response["pagination"] = pagination
+	} else {
+		page, limit := transactionPage(c)
 
-	if limitParam := c.Query("limit"); limitParam != "" {
-		if parsedLimit, err := strconv.Atoi(limitParam); err == nil && parsedLimit > 0 {
-			// Validate limit is one of the allowed values: 20, 50, 100, 500
-			switch parsedLimit {
-			case 20, 50, 100, 500:
-				limit = parsedLimit
-			default:
-				limit = 20 // fallback to default if invalid value
-			}
+		// Get total count before pagination
+		var totalCount int64
+		if err := query.Model(&models.Transaction{}).Count(&totalCount).Error; err != nil {
+			utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to count transactions")
+			return
 		}
+
+		// Calculate offset
+		offset := (page - 1) * limit
+
+		if err := query.Order("date DESC, created_at DESC, id DESC").Limit(limit).Offset(offset).Find(&transactions).Error; err != nil {
+			utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch transactions")
+			return
+		}
+		response["pagination"] = paginationResponse(page, limit, totalCount)
 	}
 
-	// Get total count before pagination
-	var totalCount int64
-	if err := query.Model(&models.Transaction{}).Count(&totalCount).Error; err != nil {
-		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to count transactions")
+	if err := models.LoadTransactionSplits(database.DB, transactions); err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch transaction splits")
 		return
 	}
+	response["transactions"] = enrichTransactions(transactions)
 
-	// Calculate offset
-	offset := (page - 1) * limit
+	utilities.SuccessResponse(c, response, "Transactions retrieved successfully")
+}
 
-	var transactions []models.Transaction
-	if err := query.Order("date DESC, created_at DESC").Limit(limit).Offset(offset).Find(&transactions).Error; err != nil {
-		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch transactions")
-		return
+// TransactionResponse is a transaction with the names of its account or
+// credit card and destination account
+type TransactionResponse struct {
+	models.Transaction
+	AccountName    *string `json:"accountName,omitempty"`
+	CreditCardName *string `json:"creditCardName,omitempty"`
+	ToAccountName  *string `json:"toAccountName,omitempty"`
+}
+
+// transactionKeyset orders transactions newest first
+var transactionKeyset = keyset{name: "transactions", table: "transactions", column: "date", descending: true}
+
+func transactionCursorKey(t *models.Transaction) cursorKey {
+	return cursorKey{Sort: t.Date, CreatedAt: t.CreatedAt, ID: t.ID}
+}
+
+// enrichTransactions adds account and credit card names to transactions,
+// looking the names up with one query per table
+func enrichTransactions(transactions []models.Transaction) []TransactionResponse {
+	var accountIDs, cardIDs []uuid.UUID
+	for _, txn := range transactions {
+		// If transaction has a credit card, the accountId is actually the credit card ID
+		if txn.CreditCardID != nil {
+			cardIDs = append(cardIDs, *txn.CreditCardID)
+		} else {
+			accountIDs = append(accountIDs, txn.AccountID)
+		}
+		if txn.ToAccountID != nil {
+			accountIDs = append(accountIDs, *txn.ToAccountID)
+		}
 	}
 
-	// Enrich transactions with account/credit card names
-	type TransactionResponse struct {
-		models.Transaction
-		AccountName    *string `json:"accountName,omitempty"`
-		CreditCardName *string `json:"creditCardName,omitempty"`
-		ToAccountName  *string `json:"toAccountName,omitempty"`
+	accountNames := make(map[uuid.UUID]string)
+	if len(accountIDs) > 0 {
+		var accounts []models.Account
+		database.DB.Select("id, name").Where("id IN ?", accountIDs).Find(&accounts)
+		for _, account := range accounts {
+			accountNames[account.ID] = account.Name
+		}
+	}
+	cardNames := make(map[uuid.UUID]string)
+	if len(cardIDs) > 0 {
+		var cards []models.CreditCard
+		database.DB.Select("id, name").Where("id IN ?", cardIDs).Find(&cards)
+		for _, card := range cards {
+			cardNames[card.ID] = card.Name
+		}
 	}
 
 	enrichedTransactions := make([]TransactionResponse, len(transactions))
 	for i, txn := range transactions {
 		enrichedTransactions[i] = TransactionResponse{Transaction: txn}
 
-		// If transaction has a credit card, the accountId is actually the credit card ID
-		// So we need to fetch the credit card name as the account name
 		if txn.CreditCardID != nil {
-			var card models.CreditCard
-			if err := database.DB.Select("name").Where("id = ?", *txn.CreditCardID).First(&card).Error; err == nil {
-				enrichedTransactions[i].CreditCardName = &card.Name
-				enrichedTransactions[i].AccountName = &card.Name // Use credit card name as account name
-			}
-		} else {
-			// For regular transactions, fetch the account name
-			var account models.Account
-			if err := database.DB.Select("name").Where("id = ?", txn.AccountID).First(&account).Error; err == nil {
-				enrichedTransactions[i].AccountName = &account.Name
+			if name, ok := cardNames[*txn.CreditCardID]; ok {
+				enrichedTransactions[i].CreditCardName = &name
+				enrichedTransactions[i].AccountName = &name // Use credit card name as account name
 			}
+		} else if name, ok := accountNames[txn.AccountID]; ok {
+			enrichedTransactions[i].AccountName = &name
 		}
 
-		// For transfers, fetch the destination account name
+		// For transfers, add the destination account name
 		if txn.ToAccountID != nil {
-			var toAccount models.Account
-			if err := database.DB.Select("name").Where("id = ?", *txn.ToAccountID).First(&toAccount).Error; err == nil {
-				enrichedTransactions[i].ToAccountName = &toAccount.Name
+			if name, ok := accountNames[*txn.ToAccountID]; ok {
+				enrichedTransactions[i].ToAccountName = &name
 			}
 		}
 	}
-
-	// Calculate pagination metadata
-	totalPages := int((totalCount + int64(limit) - 1) / int64(limit))
-
-	response := map[string]interface{}{
-		"transactions": enrichedTransactions,
-		"pagination": map[string]interface{}{
-			"currentPage": page,
-			"limit":       limit,
-			"totalCount":  totalCount,
-			"totalPages":  totalPages,
-			"hasNext":     page < totalPages,
-			"hasPrev":     page > 1,
-		},
-	}
-
-	utilities.SuccessResponse(c, response, "Transactions retrieved successfully")
+	return enrichedTransactions
 }
 
 // GetTransaction returns a specific transaction by ID
@@ -171,39 +196,12 @@ func GetTransaction(c *gin.Context) {
 		return
 	}
 
-	// Enrich transaction with account/credit card names
-	type TransactionResponse struct {
-		models.Transaction
-		AccountName    *string `json:"accountName,omitempty"`
-		CreditCardName *string `json:"creditCardName,omitempty"`
-		ToAccountName  *string `json:"toAccountName,omitempty"`
-	}
-
-	response := TransactionResponse{Transaction: transaction}
-
-	// If transaction has a credit card, the accountId is actually the credit card ID
-	// So we need to fetch the credit card name as the account name
-	if transaction.CreditCardID != nil {
-		var card models.CreditCard
-		if err := database.DB.Select("name").Where("id = ?", *transaction.CreditCardID).First(&card).Error; err == nil {
-			response.CreditCardName = &card.Name
-			response.AccountName = &card.Name // Use credit card name as account name
-		}
-	} else {
-		// For regular transactions, fetch the account name
-		var account models.Account
-		if err := database.DB.Select("name").Where("id = ?", transaction.AccountID).First(&account).Error; err == nil {
-			response.AccountName = &account.Name
-		}
+	if err := transaction.LoadSplits(database.DB); err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch transaction splits")
+		return
 	}
 
-	// For transfers, fetch the destination account name
-	if transaction.ToAccountID != nil {
-		var toAccount models.Account
-		if err := database.DB.Select("name").Where("id = ?", *transaction.ToAccountID).First(&toAccount).Error; err == nil {
-			response.ToAccountName = &toAccount.Name
-		}
-	}
+	response := enrichTransactions([]models.Transaction{transaction})[0]
 
 	utilities.SuccessResponse(c, response, "Transaction retrieved successfully")
 }
@@ -224,6 +222,28 @@ func CreateTransaction(c *gin.Context) {
 
 	transaction.UserID = userID
 
+	// Let the user's rules fill in the category, tags and description
+	rules, ok := newTransactionRules(c, userID)
+	if !ok {
+		return
+	}
+	requestedType := transaction.Type
+	rules.Apply(&transaction, "")
+	markedAsTransfer := transaction.Type == "transfer" && requestedType != "transfer"
+
+	// Validate the category and store its canonical key
+	category, message := resolveCategory(userID, transaction.CategoryID, transaction.Type)
+	if message != "" {
+		utilities.ErrorResponse(c, http.StatusBadRequest, message)
+		return
+	}
+	transaction.CategoryID = category.Key
+
+	if message := resolveSplits(userID, &transaction, category); message != "" {
+		utilities.ErrorResponse(c, http.StatusBadRequest, message)
+		return
+	}
+
 	// Determine if this is a credit card transaction or account transaction
 	isCreditCardTransaction := transaction.CreditCardID != nil
 
@@ -241,13 +261,23 @@ func CreateTransaction(c *gin.Context) {
 			return
 		}
 
-		// For transfers, verify the destination account
+		// For transfers, verify the destination account and convert between currencies
 		if transaction.Type == "transfer" && transaction.ToAccountID != nil {
 			var toAccount models.Account
 			if err := database.DB.Where("id = ? AND user_id = ?", *transaction.ToAccountID, userID).First(&toAccount).Error; err != nil {
 				utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid destination account ID")
 				return
 			}
+			resolve := transaction.ResolveTransferAmounts
+			if markedAsTransfer {
+				// A rule made it a transfer, so the amount is in the currency
+				// of the account the request named
+				resolve = transaction.ResolveMarkedTransferAmounts
+			}
+			if err := resolve(database.DB, account.Currency, toAccount.Currency); err != nil {
+				utilities.ErrorResponse(c, http.StatusBadRequest, err.Error())
+				return
+			}
 		}
 	}
 
@@ -266,53 +296,17 @@ func CreateTransaction(c *gin.Context) {
 		return
 	}
 
-	// Update balance
-	if isCreditCardTransaction {
-		// Update credit card balance
-		var creditCard models.CreditCard
-		if err := tx.Where("id = ?", transaction.CreditCardID).First(&creditCard).Error; err != nil {
-			tx.Rollback()
-			utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch credit card")
-			return
-		}
-
-		if transaction.Type == "income" {
-			creditCard.CurrentBalance += transaction.Amount
-		} else if transaction.Type == "expense" {
-			creditCard.CurrentBalance += transaction.Amount
-		}
-
-		if err := tx.Save(&creditCard).Error; err != nil {
-			tx.Rollback()
-			utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to update credit card balance")
-			return
-		}
-	} else {
-		// Update account balance
-		var account models.Account
-		if err := tx.Where("id = ?", transaction.AccountID).First(&account).Error; err != nil {
-			tx.Rollback()
-			utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch account")
-			return
-		}
-
-		if transaction.Type == "income" {
-			account.Balance += transaction.Amount
-		} else if transaction.Type == "expense" {
-			account.Balance -= transaction.Amount
-		} else if transaction.Type == "transfer" && transaction.ToAccountID != nil {
-			// Deduct from source account
-			account.Balance -= transaction.Amount
-			// Add to destination account
-			tx.Model(&models.Account{}).Where("id = ?", *transaction.ToAccountID).
-				UpdateColumn("balance", database.DB.Raw("balance + ?", transaction.Amount))
-		}
+	if err := transaction.SaveSplits(tx); err != nil {
+		tx.Rollback()
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to save transaction splits")
+		return
+	}
 
-		if err := tx.Save(&account).Error; err != nil {
-			tx.Rollback()
-			utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to update account balance")
-			return
-		}
+	// Update account or credit card balance
+	if err := transaction.ApplyBalance(tx); err != nil {
+		tx.Rollback()
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to update balance")
+		return
 	}
 
 	tx.Commit()
@@ -346,9 +340,21 @@ func UpdateTransaction(c *gin.Context) {
 		return
 	}
 
+	// Validate the category and store its canonical key
+	category, message := resolveCategory(userID, updateData.CategoryID, updateData.Type)
+	if message != "" {
+		utilities.ErrorResponse(c, http.StatusBadRequest, message)
+		return
+	}
+	updateData.CategoryID = category.Key
+
+	if message := resolveSplits(userID, &updateData, category); message != "" {
+		utilities.ErrorResponse(c, http.StatusBadRequest, message)
+		return
+	}
+
 	// Determine if this is a credit card transaction or account transaction
 	isCreditCardTransaction := updateData.CreditCardID != nil
-	wasOldCreditCardTransaction := existingTransaction.CreditCardID != nil
 
 	// Verify account or credit card belongs to user
 	if isCreditCardTransaction {
@@ -363,6 +369,20 @@ func UpdateTransaction(c *gin.Context) {
 			utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid account ID")
 			return
 		}
+
+		// For transfers, verify the destination account and convert between currencies
+		if updateData.Type == "transfer" && updateData.ToAccountID != nil {
+			var toAccount models.Account
+			if err := database.DB.Where("id = ? AND user_id = ?", *updateData.ToAccountID, userID).First(&toAccount).Error; err != nil {
+				utilities.ErrorResponse(c, http.StatusBadRequest, "Invalid destination account ID")
+				return
+			}
+			updateData.UserID = userID
+			if err := updateData.ResolveTransferAmounts(database.DB, account.Currency, toAccount.Currency); err != nil {
+				utilities.ErrorResponse(c, http.StatusBadRequest, err.Error())
+				return
+			}
+		}
 	}
 
 	// Start transaction
@@ -374,50 +394,10 @@ func UpdateTransaction(c *gin.Context) {
 	}()
 
 	// Revert old balance changes
-	if wasOldCreditCardTransaction {
-		// Revert credit card balance
-		var oldCreditCard models.CreditCard
-		if err := tx.Where("id = ?", existingTransaction.CreditCardID).First(&oldCreditCard).Error; err != nil {
-			tx.Rollback()
-			utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch old credit card")
-			return
-		}
-
-		if existingTransaction.Type == "income" {
-			oldCreditCard.CurrentBalance -= existingTransaction.Amount
-		} else if existingTransaction.Type == "expense" {
-			oldCreditCard.CurrentBalance -= existingTransaction.Amount
-		}
-
-		if err := tx.Save(&oldCreditCard).Error; err != nil {
-			tx.Rollback()
-			utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to revert old credit card balance")
-			return
-		}
-	} else {
-		// Revert account balance
-		var oldAccount models.Account
-		if err := tx.Where("id = ?", existingTransaction.AccountID).First(&oldAccount).Error; err != nil {
-			tx.Rollback()
-			utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch old account")
-			return
-		}
-
-		if existingTransaction.Type == "income" {
-			oldAccount.Balance -= existingTransaction.Amount
-		} else if existingTransaction.Type == "expense" {
-			oldAccount.Balance += existingTransaction.Amount
-		} else if existingTransaction.Type == "transfer" && existingTransaction.ToAccountID != nil {
-			oldAccount.Balance += existingTransaction.Amount
-			tx.Model(&models.Account{}).Where("id = ?", *existingTransaction.ToAccountID).
-				UpdateColumn("balance", database.DB.Raw("balance - ?", existingTransaction.Amount))
-		}
-
-		if err := tx.Save(&oldAccount).Error; err != nil {
-			tx.Rollback()
-			utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to revert old balance")
-			return
-		}
+	if err := existingTransaction.RevertBalance(tx); err != nil {
+		tx.Rollback()
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to revert old balance")
+		return
 	}
 
 	// Update transaction
@@ -426,11 +406,14 @@ func UpdateTransaction(c *gin.Context) {
 	existingTransaction.ToAccountID = updateData.ToAccountID
 	existingTransaction.Type = updateData.Type
 	existingTransaction.Amount = updateData.Amount
+	existingTransaction.ToAmount = updateData.ToAmount
+	existingTransaction.ExchangeRate = updateData.ExchangeRate
 	existingTransaction.CategoryID = updateData.CategoryID
 	existingTransaction.Date = updateData.Date
 	existingTransaction.Description = updateData.Description
 	existingTransaction.Tags = updateData.Tags
 	existingTransaction.Attachments = updateData.Attachments
+	existingTransaction.Splits = updateData.Splits
 
 	if err := tx.Save(&existingTransaction).Error; err != nil {
 		tx.Rollback()
@@ -438,51 +421,18 @@ func UpdateTransaction(c *gin.Context) {
 		return
 	}
 
-	// Apply new balance changes
-	if isCreditCardTransaction {
-		// Update credit card balance
-		var newCreditCard models.CreditCard
-		if err := tx.Where("id = ?", updateData.CreditCardID).First(&newCreditCard).Error; err != nil {
-			tx.Rollback()
-			utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch new credit card")
-			return
-		}
-
-		if updateData.Type == "income" {
-			newCreditCard.CurrentBalance += updateData.Amount
-		} else if updateData.Type == "expense" {
-			newCreditCard.CurrentBalance += updateData.Amount
-		}
-
-		if err := tx.Save(&newCreditCard).Error; err != nil {
-			tx.Rollback()
-			utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to update new credit card balance")
-			return
-		}
-	} else {
-		// Update account balance
-		var newAccount models.Account
-		if err := tx.Where("id = ?", updateData.AccountID).First(&newAccount).Error; err != nil {
-			tx.Rollback()
-			utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch new account")
-			return
-		}
-
-		if updateData.Type == "income" {
-			newAccount.Balance += updateData.Amount
-		} else if updateData.Type == "expense" {
-			newAccount.Balance -= updateData.Amount
-		} else if updateData.Type == "transfer" && updateData.ToAccountID != nil {
-			newAccount.Balance -= updateData.Amount
-			tx.Model(&models.Account{}).Where("id = ?", *updateData.ToAccountID).
-				UpdateColumn("balance", database.DB.Raw("balance + ?", updateData.Amount))
-		}
+	// The splits sent replace the stored ones; sending none removes them
+	if err := existingTransaction.SaveSplits(tx); err != nil {
+		tx.Rollback()
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to save transaction splits")
+		return
+	}
 
-		if err := tx.Save(&newAccount).Error; err != nil {
-			tx.Rollback()
-			utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to update new balance")
-			return
-		}
+	// Apply new balance changes
+	if err := existingTransaction.ApplyBalance(tx); err != nil {
+		tx.Rollback()
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to update new balance")
+		return
 	}
 
 	tx.Commit()
@@ -518,54 +468,11 @@ func DeleteTransaction(c *gin.Context) {
 		}
 	}()
 
-	// Determine if this is a credit card transaction or account transaction
-	isCreditCardTransaction := transaction.CreditCardID != nil
-
 	// Revert balance changes
-	if isCreditCardTransaction {
-		// Revert credit card balance
-		var creditCard models.CreditCard
-		if err := tx.Where("id = ?", transaction.CreditCardID).First(&creditCard).Error; err != nil {
-			tx.Rollback()
-			utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch credit card")
-			return
-		}
-
-		if transaction.Type == "income" {
-			creditCard.CurrentBalance -= transaction.Amount
-		} else if transaction.Type == "expense" {
-			creditCard.CurrentBalance -= transaction.Amount
-		}
-
-		if err := tx.Save(&creditCard).Error; err != nil {
-			tx.Rollback()
-			utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to update credit card balance")
-			return
-		}
-	} else {
-		// Revert account balance
-		var account models.Account
-		if err := tx.Where("id = ?", transaction.AccountID).First(&account).Error; err != nil {
-			tx.Rollback()
-			utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch account")
-			return
-		}
-
-		if transaction.Type == "income" {
-			account.Balance -= transaction.Amount
-		} else if transaction.Type == "expense" {
-			account.Balance += transaction.Amount
-		} else if transaction.Type == "transfer" && transaction.ToAccountID != nil {
-			account.Balance += transaction.Amount
-			tx.Model(&models.Account{}).Where("id = ?", *transaction.ToAccountID).
-				UpdateColumn("balance", database.DB.Raw("balance - ?", transaction.Amount))
-		}
-
-		if err := tx.Save(&account).Error; err != nil {
-			tx.Rollback()
-			utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to update account balance")
-			return
-		}
+	if err := transaction.RevertBalance(tx); err != nil {
+		tx.Rollback()
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to update balance")
+		return
 	}
 
 	// Delete transaction (soft delete)
@@ -575,6 +482,19 @@ func DeleteTransaction(c *gin.Context) {
 		return
 	}
 
+	if err := tx.Where("transaction_id = ?", transaction.ID).Delete(&models.TransactionSplit{}).Error; err != nil {
+		tx.Rollback()
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete transaction splits")
+		return
+	}
+
+	// A deleted bill payment leaves its due date unpaid again
+	if err := services.RemoveTransactionBillPayments(tx, transaction.ID); err != nil {
+		tx.Rollback()
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete bill payment")
+		return
+	}
+
 	tx.Commit()
 
 	utilities.SuccessResponse(c, nil, "Transaction deleted successfully")
@@ -594,6 +514,11 @@ func BulkImportTransactions(c *gin.Context) {
 		return
 	}
 
+	rules, ok := newTransactionRules(c, userID)
+	if !ok {
+		return
+	}
+
 	// Start transaction
 	tx := database.DB.Begin()
 	defer func() {
@@ -603,37 +528,68 @@ func BulkImportTransactions(c *gin.Context) {
 	}()
 
 	successCount := 0
-	failedCount := 0
+	var rowErrors []string
+	fail := func(i int, message string) {
+		rowErrors = append(rowErrors, fmt.Sprintf("row %d: %s", i+1, message))
+	}
 
 	for i := range transactions {
 		transactions[i].UserID = userID
+		rules.Apply(&transactions[i], "")
 
 		// Verify account belongs to user
 		var account models.Account
 		if err := tx.Where("id = ? AND user_id = ?", transactions[i].AccountID, userID).First(&account).Error; err != nil {
-			failedCount++
+			fail(i, "invalid account ID")
+			continue
+		}
+
+		category, message := resolveCategory(userID, transactions[i].CategoryID, transactions[i].Type)
+		if message != "" {
+			fail(i, message)
 			continue
 		}
+		transactions[i].CategoryID = category.Key
+
+		if message := resolveSplits(userID, &transactions[i], category); message != "" {
+			fail(i, message)
+			continue
+		}
+
+		// For transfers, verify the destination account and convert between currencies
+		if transactions[i].Type == "transfer" && transactions[i].ToAccountID != nil {
+			var toAccount models.Account
+			if err := tx.Where("id = ? AND user_id = ?", *transactions[i].ToAccountID, userID).First(&toAccount).Error; err != nil {
+				fail(i, "invalid destination account ID")
+				continue
+			}
+			if err := transactions[i].ResolveTransferAmounts(tx, account.Currency, toAccount.Currency); err != nil {
+				fail(i, err.Error())
+				continue
+			}
+		}
+
+		// A failed statement aborts the database transaction, so roll back to
+		// a savepoint to keep the rows imported so far
+		tx.SavePoint("bulk_row")
 
 		// Create transaction
 		if err := tx.Create(&transactions[i]).Error; err != nil {
-			failedCount++
+			tx.RollbackTo("bulk_row")
+			fail(i, "failed to create transaction")
+			continue
+		}
+
+		if err := transactions[i].SaveSplits(tx); err != nil {
+			tx.RollbackTo("bulk_row")
+			fail(i, "failed to save splits")
 			continue
 		}
 
 		// Update account balance
-		if transactions[i].Type == "income" {
-			account.Balance += transactions[i].Amount
-		} else if transactions[i].Type == "expense" {
-			account.Balance -= transactions[i].Amount
-		} else if transactions[i].Type == "transfer" && transactions[i].ToAccountID != nil {
-			account.Balance -= transactions[i].Amount
-			tx.Model(&models.Account{}).Where("id = ?", *transactions[i].ToAccountID).
-				UpdateColumn("balance", database.DB.Raw("balance + ?", transactions[i].Amount))
-		}
-
-		if err := tx.Save(&account).Error; err != nil {
-			failedCount++
+		if err := transactions[i].ApplyBalance(tx); err != nil {
+			tx.RollbackTo("bulk_row")
+			fail(i, "failed to update balance")
 			continue
 		}
 
@@ -644,13 +600,49 @@ func BulkImportTransactions(c *gin.Context) {
 
 	result := map[string]interface{}{
 		"successCount": successCount,
-		"failedCount":  failedCount,
+		"failedCount":  len(rowErrors),
 		"totalCount":   len(transactions),
+		"errors":       rowErrors,
 	}
 
 	utilities.SuccessResponse(c, result, "Bulk import completed")
 }
 
+// resolveSplits validates the splits of an income or expense and stores the
+// canonical key of each split's category. Splits must add up to the
+// transaction amount. System categories, which the ledger books specially,
+// cannot be split or used in a split.
+func resolveSplits(userID uuid.UUID, transaction *models.Transaction, category *models.Category) string {
+	if len(transaction.Splits) == 0 {
+		return ""
+	}
+	if transaction.Type != "income" && transaction.Type != "expense" {
+		return "Only income and expense transactions can be split"
+	}
+	if category.IsSystem {
+		return "Transactions in category " + category.Key + " cannot be split"
+	}
+
+	var total models.Money
+	for i := range transaction.Splits {
+		split := &transaction.Splits[i]
+		splitCategory, message := resolveCategory(userID, split.CategoryID, transaction.Type)
+		if message != "" {
+			return fmt.Sprintf("Split %d: %s", i+1, message)
+		}
+		if splitCategory.IsSystem {
+			return fmt.Sprintf("Split %d: category %s cannot be used in a split", i+1, splitCategory.Key)
+		}
+		split.CategoryID = splitCategory.Key
+		total += split.Amount
+	}
+
+	if total != transaction.Amount {
+		return fmt.Sprintf("Splits add up to %s but the transaction amount is %s", total, transaction.Amount)
+	}
+	return ""
+}
+
 // GetTransactionStats returns transaction statistics
 func GetTransactionStats(c *gin.Context) {
 	userID, err := middleware.GetUserID(c)
@@ -663,46 +655,94 @@ func GetTransactionStats(c *gin.Context) {
 	startDate := c.Query("startDate")
 	endDate := c.Query("endDate")
 
-	query := database.DB.Model(&models.Transaction{}).Where("user_id = ?", userID)
+	query := database.DB.Model(&models.Transaction{}).Where("transactions.user_id = ?", userID)
 
 	// Exclude tracking transactions from statistics
-	query = query.Where("type != ?", "tracking")
+	query = query.Where("transactions.type != ?", "tracking")
 
 	if startDate != "" {
 		if parsedDate, err := time.Parse("2006-01-02", startDate); err == nil {
-			query = query.Where("date >= ?", parsedDate)
+			query = query.Where("transactions.date >= ?", parsedDate)
 		}
 	}
 
 	if endDate != "" {
 		if parsedDate, err := time.Parse("2006-01-02", endDate); err == nil {
-			query = query.Where("date <= ?", parsedDate)
+			query = query.Where("transactions.date <= ?", parsedDate)
 		}
 	}
 
-	// Calculate totals by type
-	var stats struct {
-		TotalIncome      float64
-		TotalExpense     float64
-		TotalTransfer    float64
-		NetIncome        float64
-		TransactionCount int64
+	// Split transactions contribute each split to its own category
+	rows, err := services.LoadTransactionAmounts(query)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch transactions")
+		return
 	}
 
-	// Total income
-	query.Where("type = ?", "income").Select("COALESCE(SUM(amount), 0)").Row().Scan(&stats.TotalIncome)
+	// Convert every amount to the user's currency as of its transaction date
+	currency := models.UserCurrency(database.DB, userID)
+	converter, err := models.NewCurrencyConverter(database.DB, userID)
+	if err != nil {
+		utilities.ErrorResponse(c, http.StatusInternalServerError, "Failed to load exchange rates")
+		return
+	}
+	if err := services.ConvertTransactionAmounts(converter, rows, currency); err != nil {
+		utilities.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	// Total expense
-	query.Where("type = ?", "expense").Select("COALESCE(SUM(amount), 0)").Row().Scan(&stats.TotalExpense)
+	type categoryStat struct {
+		Type       string
+		CategoryID string
+		Amount     models.Money
+	}
+
+	// Calculate totals by type and by category
+	var stats struct {
+		TotalIncome      models.Money
+		TotalExpense     models.Money
+		TotalTransfer    models.Money
+		NetIncome        models.Money
+		TransactionCount int64
+		Currency         string
+		ByCategory       []categoryStat
+	}
+
+	transactionIDs := make(map[uuid.UUID]bool)
+	categoryIndex := make(map[string]int)
+	stats.ByCategory = []categoryStat{}
+	for _, row := range rows {
+		transactionIDs[row.TransactionID] = true
+
+		switch row.Type {
+		case "income":
+			stats.TotalIncome += row.Amount
+		case "expense":
+			stats.TotalExpense += row.Amount
+		case "transfer":
+			stats.TotalTransfer += row.Amount
+			continue
+		default:
+			continue
+		}
 
-	// Total transfers
-	query.Where("type = ?", "transfer").Select("COALESCE(SUM(amount), 0)").Row().Scan(&stats.TotalTransfer)
+		key := row.Type + ":" + strings.ToLower(row.CategoryID)
+		i, ok := categoryIndex[key]
+		if !ok {
+			i = len(stats.ByCategory)
+			categoryIndex[key] = i
+			stats.ByCategory = append(stats.ByCategory, categoryStat{Type: row.Type, CategoryID: strings.ToLower(row.CategoryID)})
+		}
+		stats.ByCategory[i].Amount += row.Amount
+	}
+	sort.Slice(stats.ByCategory, func(i, j int) bool {
+		return stats.ByCategory[i].Amount > stats.ByCategory[j].Amount
+	})
 
 	// Net income
 	stats.NetIncome = stats.TotalIncome - stats.TotalExpense
-
-	// Transaction count
-	query.Count(&stats.TransactionCount)
+	stats.TransactionCount = int64(len(transactionIDs))
+	stats.Currency = currency
 
 	utilities.SuccessResponse(c, stats, "Statistics retrieved successfully")
 }
@@ -34,6 +34,9 @@ var AllowedFileTypes = map[string]bool{
 	".xlsx": true,
 	".txt":  true,
 	".csv":  true,
+	".ofx":  true,
+	".qfx":  true,
+	".qif":  true,
 }
 
 // FileUploadResponse represents the response for uploaded files
@@ -8,9 +8,11 @@ import (
 	"syscall"
 	"time"
 
+	"daybook-backend/cmd"
 	"daybook-backend/config"
 	"daybook-backend/database"
 	"daybook-backend/routes"
+	"daybook-backend/services"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -23,6 +25,11 @@ func main() {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
+	// Subcommands such as `daybook audit` run instead of the server
+	if len(os.Args) > 1 {
+		os.Exit(cmd.Run(cfg, os.Args[1], os.Args[2:]))
+	}
+
 	// Initialize database
 	if err := database.InitDatabase(cfg); err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
@@ -34,6 +41,14 @@ func main() {
 		log.Printf("Warning: Redis initialization failed: %v", err)
 	}
 
+	// Email and webhook delivery of notifications
+	services.ConfigureNotifications(cfg.Notify)
+
+	// Start background jobs (recurring transactions, ...)
+	if cfg.Scheduler.Enabled {
+		services.StartScheduler(database.DB, time.Duration(cfg.Scheduler.IntervalMinutes)*time.Minute)
+	}
+
 	// Set Gin mode
 	gin.SetMode(cfg.Server.Mode)
 
@@ -62,6 +77,9 @@ func main() {
 
 		log.Println("Shutting down server...")
 
+		// Stop background jobs before closing the database
+		services.StopScheduler()
+
 		// Close database connections
 		if err := database.CloseDatabase(); err != nil {
 			log.Printf("Error closing database: %v", err)
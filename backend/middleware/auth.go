@@ -58,3 +58,17 @@ func GetUserID(c *gin.Context) (uuid.UUID, error) {
 
 	return uid, nil
 }
+
+// AdminMiddleware restricts a route group to users with the admin role.
+// It must run after AuthMiddleware.
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if role, _ := c.Get("role"); role != "admin" {
+			utilities.ErrorResponse(c, http.StatusForbidden, "Admin access required")
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -12,14 +12,15 @@ type Account struct {
 	UserID                   uuid.UUID      `gorm:"type:uuid;not null;index" json:"userId"`
 	Name                     string         `gorm:"not null" json:"name" binding:"required"`
 	Type                     string         `gorm:"not null" json:"type" binding:"required"` // cash, checking, savings, credit_card, etc
-	InitialBalance           float64        `gorm:"default:0" json:"initialBalance"`         // Opening balance - never changes
-	Balance                  float64        `gorm:"default:0" json:"balance"`                // Current balance - updated with transactions
+	InitialBalance           Money          `gorm:"default:0" json:"initialBalance"`         // Opening balance - never changes
+	Balance                  Money          `gorm:"default:0" json:"balance"`                // Current balance - updated with transactions
 	Currency                 string         `gorm:"default:'BDT'" json:"currency"`
 	Description              string         `json:"description"`
 	Institution              string         `json:"institution"`
 	AccountNumber            string         `json:"accountNumber"`
 	LastReconciled           *time.Time     `json:"lastReconciled"`
-	ReconciliationDifference float64        `gorm:"default:0" json:"reconciliationDifference"`
+	ReconciliationDifference Money          `gorm:"default:0" json:"reconciliationDifference"`
+	MinimumBalance           Money          `json:"minimumBalance" binding:"min=0"` // Notify when the balance falls below it; 0 for never
 	Active                   bool           `gorm:"default:true" json:"active"`
 	CreatedAt                time.Time      `json:"createdAt"`
 	UpdatedAt                time.Time      `json:"updatedAt"`
@@ -8,23 +8,28 @@ import (
 )
 
 type Bill struct {
-	ID             uuid.UUID      `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
-	UserID         uuid.UUID      `gorm:"type:uuid;not null;index" json:"userId"`
-	Name           string         `gorm:"not null" json:"name" binding:"required"`
-	Category       string         `gorm:"not null" json:"category" binding:"required"` // utilities, subscriptions, insurance, etc
-	Amount         float64        `gorm:"not null" json:"amount" binding:"required,gt=0"`
-	Frequency      string         `gorm:"not null" json:"frequency" binding:"required"` // weekly, biweekly, monthly, quarterly, etc
-	StartDate      time.Time      `gorm:"not null" json:"startDate"`
-	DueDay         int            `json:"dueDay"` // Day of month for monthly bills
-	LastPaidDate   *time.Time     `json:"lastPaidDate"`
-	LastPaidAmount float64        `gorm:"default:0" json:"lastPaidAmount"`
-	AutoPay        bool           `gorm:"default:false" json:"autoPay"`
-	ReminderDays   int            `gorm:"default:3" json:"reminderDays"` // Days before due date to remind
-	Active         bool           `gorm:"default:true" json:"active"`
-	Notes          string         `json:"notes"`
-	CreatedAt      time.Time      `json:"createdAt"`
-	UpdatedAt      time.Time      `json:"updatedAt"`
-	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
+	ID              uuid.UUID      `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	UserID          uuid.UUID      `gorm:"type:uuid;not null;index" json:"userId"`
+	Name            string         `gorm:"not null" json:"name" binding:"required"`
+	Category        string         `gorm:"not null" json:"category" binding:"required"` // utilities, subscriptions, insurance, etc
+	Amount          Money          `gorm:"not null" json:"amount" binding:"required,gt=0"`
+	Frequency       string         `gorm:"not null" json:"frequency" binding:"required"` // daily, weekly, biweekly, monthly, quarterly, yearly
+	StartDate       time.Time      `gorm:"not null" json:"startDate"`
+	DueDay          int            `json:"dueDay" binding:"min=0,max=31"` // Day of month for monthly, quarterly and yearly bills; clamped to the month's last day
+	LastPaidDate    *time.Time     `json:"lastPaidDate"`
+	LastPaidAmount  Money          `gorm:"default:0" json:"lastPaidAmount"`
+	AutoPay         bool           `gorm:"default:false" json:"autoPay"`
+	AccountID       *uuid.UUID     `gorm:"type:uuid" json:"accountId"`    // Default account the bill is paid from
+	CreditCardID    *uuid.UUID     `gorm:"type:uuid" json:"creditCardId"` // Default card the bill is charged to, instead of an account
+	AutoPayThrough  *time.Time     `json:"autoPayThrough"`                // Last due date autopay has handled
+	AutoPayError    string         `json:"autoPayError"`                  // Why autopay last skipped a due date; cleared by the next payment
+	AutoPayFailedAt *time.Time     `json:"autoPayFailedAt"`
+	ReminderDays    int            `gorm:"default:3" json:"reminderDays"` // Days before due date to remind
+	Active          bool           `gorm:"default:true" json:"active"`
+	Notes           string         `json:"notes"`
+	CreatedAt       time.Time      `json:"createdAt"`
+	UpdatedAt       time.Time      `json:"updatedAt"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 func (b *Bill) BeforeCreate(tx *gorm.DB) error {
@@ -35,16 +40,20 @@ func (b *Bill) BeforeCreate(tx *gorm.DB) error {
 }
 
 type BillPayment struct {
-	ID          uuid.UUID      `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
-	UserID      uuid.UUID      `gorm:"type:uuid;not null;index" json:"userId"`
-	BillID      uuid.UUID      `gorm:"type:uuid;not null;index" json:"billId"`
-	Amount      float64        `gorm:"not null" json:"amount" binding:"required,gt=0"`
-	PaymentDate time.Time      `gorm:"not null;index" json:"paymentDate"`
-	AccountID   *uuid.UUID     `gorm:"type:uuid" json:"accountId"`
-	Notes       string         `json:"notes"`
-	CreatedAt   time.Time      `json:"createdAt"`
-	UpdatedAt   time.Time      `json:"updatedAt"`
-	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+	ID            uuid.UUID      `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	UserID        uuid.UUID      `gorm:"type:uuid;not null;index" json:"userId"`
+	BillID        uuid.UUID      `gorm:"type:uuid;not null;index" json:"billId"`
+	Amount        Money          `gorm:"not null" json:"amount" binding:"required,gt=0"`
+	PaymentDate   time.Time      `gorm:"not null;index" json:"paymentDate"`
+	DueDate       *time.Time     `gorm:"index" json:"dueDate"` // Due date the payment settles; matched by PaymentDate when nil
+	AccountID     *uuid.UUID     `gorm:"type:uuid" json:"accountId"`
+	CreditCardID  *uuid.UUID     `gorm:"type:uuid" json:"creditCardId"`
+	TransactionID *uuid.UUID     `gorm:"type:uuid;index" json:"transactionId"` // Expense posted for the payment, if any
+	AutoPay       bool           `json:"autoPay"`                              // Made by autopay
+	Notes         string         `json:"notes"`
+	CreatedAt     time.Time      `json:"createdAt"`
+	UpdatedAt     time.Time      `json:"updatedAt"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 func (bp *BillPayment) BeforeCreate(tx *gorm.DB) error {
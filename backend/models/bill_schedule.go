@@ -0,0 +1,88 @@
+package models
+
+import "time"
+
+// Bill occurrence statuses
+const (
+	BillPaid    = "paid"
+	BillUnpaid  = "unpaid"
+	BillOverdue = "overdue" // Unpaid and past its due date
+)
+
+// billMonths returns the number of months between due dates of a month based
+// frequency, or 0 for frequencies counted in days
+func billMonths(frequency string) int {
+	switch frequency {
+	case FrequencyMonthly:
+		return 1
+	case FrequencyQuarterly:
+		return 3
+	case FrequencyYearly:
+		return 12
+	}
+	return 0
+}
+
+// FirstDueDate returns the bill's first due date. Month based bills with a
+// DueDay fall due on the first such day on or after the start date; other
+// bills fall due on the start date itself.
+func (b *Bill) FirstDueDate() time.Time {
+	start := truncateToDay(b.StartDate)
+	if b.DueDay <= 0 || billMonths(b.Frequency) == 0 {
+		return start
+	}
+
+	due := addMonthsClamped(start, 0, b.DueDay)
+	if due.Before(start) {
+		due = addMonthsClamped(start, 1, b.DueDay)
+	}
+	return due
+}
+
+// DueDate returns the bill's n-th due date, counting from zero. Month based
+// bills stay on DueDay, clamped to the last day of shorter months, so a bill
+// due on the 31st falls due on Feb 28/29. The second return value is false
+// when the bill's frequency is not supported.
+func (b *Bill) DueDate(n int) (time.Time, bool) {
+	first := b.FirstDueDate()
+	months := billMonths(b.Frequency)
+	if months == 0 {
+		return AddFrequency(first, b.Frequency, n)
+	}
+
+	day := b.DueDay
+	if day <= 0 {
+		day = first.Day()
+	}
+	return addMonthsClamped(first, months*n, day), true
+}
+
+// NextDueDate returns the first due date on or after the day of t
+func (b *Bill) NextDueDate(t time.Time) (time.Time, bool) {
+	day := truncateToDay(t)
+	for n := 0; ; n++ {
+		due, ok := b.DueDate(n)
+		if !ok {
+			return time.Time{}, false
+		}
+		if !due.Before(day) {
+			return due, true
+		}
+	}
+}
+
+// DueDatesBetween returns the bill's due dates from the day of from up to but
+// not including the day of to
+func (b *Bill) DueDatesBetween(from, to time.Time) []time.Time {
+	start, end := truncateToDay(from), truncateToDay(to)
+	dates := []time.Time{}
+	for n := 0; ; n++ {
+		due, ok := b.DueDate(n)
+		if !ok || !due.Before(end) {
+			return dates
+		}
+		if !due.Before(start) {
+			dates = append(dates, due)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestBillDueDates(t *testing.T) {
+	tests := []struct {
+		name string
+		bill Bill
+		want []time.Time
+	}{
+		{
+			"31st clamped in short months",
+			Bill{Frequency: FrequencyMonthly, DueDay: 31, StartDate: date(2024, 1, 10)},
+			[]time.Time{date(2024, 1, 31), date(2024, 2, 29), date(2024, 3, 31), date(2024, 4, 30)},
+		},
+		{
+			"31st in a non-leap February",
+			Bill{Frequency: FrequencyMonthly, DueDay: 31, StartDate: date(2025, 1, 31)},
+			[]time.Time{date(2025, 1, 31), date(2025, 2, 28), date(2025, 3, 31), date(2025, 4, 30)},
+		},
+		{
+			"due day already passed in the start month",
+			Bill{Frequency: FrequencyMonthly, DueDay: 5, StartDate: date(2024, 1, 20)},
+			[]time.Time{date(2024, 2, 5), date(2024, 3, 5), date(2024, 4, 5), date(2024, 5, 5)},
+		},
+		{
+			"quarterly on the 30th",
+			Bill{Frequency: FrequencyQuarterly, DueDay: 30, StartDate: date(2023, 11, 1)},
+			[]time.Time{date(2023, 11, 30), date(2024, 2, 29), date(2024, 5, 30), date(2024, 8, 30)},
+		},
+		{
+			"monthly without a due day keeps the start day",
+			Bill{Frequency: FrequencyMonthly, StartDate: date(2024, 1, 31)},
+			[]time.Time{date(2024, 1, 31), date(2024, 2, 29), date(2024, 3, 31), date(2024, 4, 30)},
+		},
+		{
+			"weekly ignores the due day",
+			Bill{Frequency: FrequencyWeekly, DueDay: 15, StartDate: date(2024, 2, 26)},
+			[]time.Time{date(2024, 2, 26), date(2024, 3, 4), date(2024, 3, 11), date(2024, 3, 18)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for n, want := range tt.want {
+				got, ok := tt.bill.DueDate(n)
+				if !ok || !got.Equal(want) {
+					t.Errorf("DueDate(%d) = %v, %v, want %v", n, got, ok, want)
+				}
+			}
+		})
+	}
+}
+
+func TestBillDueDateInvalidFrequency(t *testing.T) {
+	bill := Bill{Frequency: "fortnightly", StartDate: date(2024, 1, 1)}
+	if _, ok := bill.DueDate(1); ok {
+		t.Error("DueDate with an invalid frequency is ok")
+	}
+	if _, ok := bill.NextDueDate(date(2024, 3, 1)); ok {
+		t.Error("NextDueDate with an invalid frequency is ok")
+	}
+}
+
+func TestBillNextDueDate(t *testing.T) {
+	bill := Bill{Frequency: FrequencyMonthly, DueDay: 31, StartDate: date(2024, 1, 1)}
+
+	tests := []struct {
+		at   time.Time
+		want time.Time
+	}{
+		{date(2023, 12, 1), date(2024, 1, 31)}, // Before the start
+		{date(2024, 1, 31), date(2024, 1, 31)}, // On a due date
+		{time.Date(2024, 2, 1, 23, 59, 0, 0, time.UTC), date(2024, 2, 29)},
+		{date(2024, 2, 29), date(2024, 2, 29)},
+		{date(2024, 3, 1), date(2024, 3, 31)},
+	}
+	for _, tt := range tests {
+		got, ok := bill.NextDueDate(tt.at)
+		if !ok || !got.Equal(tt.want) {
+			t.Errorf("NextDueDate(%v) = %v, %v, want %v", tt.at, got, ok, tt.want)
+		}
+	}
+}
+
+func TestBillDueDatesBetween(t *testing.T) {
+	bill := Bill{Frequency: FrequencyMonthly, DueDay: 31, StartDate: date(2024, 1, 1)}
+
+	got := bill.DueDatesBetween(date(2024, 2, 29), date(2024, 4, 30))
+	want := []time.Time{date(2024, 2, 29), date(2024, 3, 31)} // The end day is left out
+	if len(got) != len(want) {
+		t.Fatalf("DueDatesBetween = %v, want %v", got, want)
+	}
+	for i := range want {
+		if !got[i].Equal(want[i]) {
+			t.Errorf("DueDatesBetween[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package models
+
+import "time"
+
+// Billing cycle defaults used when a card leaves the setting at zero
+const (
+	DefaultGracePeriodDays   = 21
+	DefaultMinPaymentPercent = 5.0
+)
+
+// HasBillingCycle reports whether statements are generated for the card
+func (cc *CreditCard) HasBillingCycle() bool {
+	return cc.StatementDay > 0
+}
+
+// statementDateIn returns the day the cycle closes in the month of t. A
+// statement day past the end of a short month closes on its last day.
+func (cc *CreditCard) statementDateIn(t time.Time, months int) time.Time {
+	start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return addMonthsClamped(start, months, cc.StatementDay)
+}
+
+// NextStatementDate returns the first statement date after t
+func (cc *CreditCard) NextStatementDate(t time.Time) time.Time {
+	day := truncateToDay(t)
+	next := cc.statementDateIn(day, 0)
+	if !next.After(day) {
+		next = cc.statementDateIn(day, 1)
+	}
+	return next
+}
+
+// PreviousStatementDate returns the last statement date before t
+func (cc *CreditCard) PreviousStatementDate(t time.Time) time.Time {
+	day := truncateToDay(t)
+	previous := cc.statementDateIn(day, 0)
+	if !previous.Before(day) {
+		previous = cc.statementDateIn(day, -1)
+	}
+	return previous
+}
+
+// LastClosedStatementDate returns the most recent statement date whose
+// cycle had ended by now. A cycle includes its whole statement day, so the
+// cycle closing today is still open.
+func (cc *CreditCard) LastClosedStatementDate(now time.Time) time.Time {
+	return cc.PreviousStatementDate(now)
+}
+
+// PaymentDueDate returns the due date of a statement closing on statementDate
+func (cc *CreditCard) PaymentDueDate(statementDate time.Time) time.Time {
+	days := cc.GracePeriodDays
+	if days <= 0 {
+		days = DefaultGracePeriodDays
+	}
+	return statementDate.AddDate(0, 0, days)
+}
+
+// MinimumPaymentFor returns the minimum payment of a statement: the card's
+// percentage of the closing balance plus the interest and fees charged in
+// the cycle, at least MinPaymentFloor and never more than the balance.
+// Cards with neither a percentage nor a floor use DefaultMinPaymentPercent.
+func (cc *CreditCard) MinimumPaymentFor(closingBalance, interest, fees Money) Money {
+	if closingBalance <= 0 {
+		return 0
+	}
+
+	percent := cc.MinPaymentPercent
+	if percent <= 0 && cc.MinPaymentFloor <= 0 {
+		percent = DefaultMinPaymentPercent
+	}
+
+	minimum := closingBalance.Mul(percent/100) + interest + fees
+	if minimum < cc.MinPaymentFloor {
+		minimum = cc.MinPaymentFloor
+	}
+	minimum = minimum.Round(cc.Currency)
+	if minimum > closingBalance {
+		minimum = closingBalance
+	}
+	return minimum
+}
+
+func truncateToDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
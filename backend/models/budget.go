@@ -11,7 +11,7 @@ type Budget struct {
 	ID              uuid.UUID      `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
 	UserID          uuid.UUID      `gorm:"type:uuid;not null;index" json:"userId"`
 	CategoryID      string         `gorm:"not null;index" json:"categoryId" binding:"required"`
-	Amount          float64        `gorm:"not null" json:"amount" binding:"required,gt=0"`
+	Amount          Money          `gorm:"not null" json:"amount" binding:"required,gt=0"`
 	Period          string         `gorm:"not null" json:"period" binding:"required"` // weekly, monthly, quarterly, yearly, custom
 	CustomStartDate *time.Time     `json:"customStartDate"`
 	CustomEndDate   *time.Time     `json:"customEndDate"`
@@ -0,0 +1,215 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Category is a user-defined or system transaction category. Transactions,
+// budgets, bills and credit card transactions reference a category by its Key.
+type Category struct {
+	ID        uuid.UUID      `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	UserID    uuid.UUID      `gorm:"type:uuid;not null;index" json:"userId"`
+	ParentID  *uuid.UUID     `gorm:"type:uuid;index" json:"parentId"`
+	Key       string         `gorm:"not null;index" json:"key"` // Stable identifier stored in CategoryID fields, e.g. "food"
+	Name      string         `gorm:"not null" json:"name" binding:"required"`
+	Kind      string         `gorm:"not null" json:"kind" binding:"required"` // income, expense, transfer
+	Icon      string         `json:"icon"`
+	Color     string         `json:"color"`
+	IsSystem  bool           `gorm:"default:false" json:"isSystem"` // Created by daybook, cannot be renamed or deleted
+	Active    bool           `gorm:"default:true" json:"active"`
+	SortOrder int            `gorm:"default:0" json:"sortOrder"`
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (c *Category) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+	return nil
+}
+
+// Category kinds
+const (
+	CategoryKindIncome   = "income"
+	CategoryKindExpense  = "expense"
+	CategoryKindTransfer = "transfer"
+)
+
+// System category keys used by the backend itself
+const (
+	CategoryOpeningBalance      = "opening_balance"
+	CategoryCreditCardPayment   = "credit_card_payment"
+	CategoryCreditCardInterest  = "credit_card_interest"
+	CategoryCreditCardFee       = "credit_card_fee"
+	CategoryCreditCardRewards   = "credit_card_rewards"
+	CategoryGoalHoldingAdded    = "goal_holding_added"
+	CategoryGoalHoldingRemoved  = "goal_holding_removed"
+	CategoryGoalExternalHolding = "goal_external_holding"
+	CategoryTransfer            = "transfer"
+)
+
+// NonSpendingCategories are the system categories of transactions that are
+// neither income nor spending, left out of reports, budgets and envelopes.
+// Opening balances existed before daybook. Card payments settle purchases
+// already counted on the card. Goal holdings move money into and out of
+// investments the user still owns, whether bought, sold or only tracked.
+var NonSpendingCategories = []string{
+	CategoryOpeningBalance,
+	CategoryCreditCardPayment,
+	CategoryGoalHoldingAdded,
+	CategoryGoalHoldingRemoved,
+	CategoryGoalExternalHolding,
+}
+
+// IsNonSpendingCategory reports whether the category key is one of the
+// NonSpendingCategories, ignoring case
+func IsNonSpendingCategory(key string) bool {
+	for _, excluded := range NonSpendingCategories {
+		if strings.EqualFold(key, excluded) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidCategoryKind reports whether kind is a supported category kind
+func IsValidCategoryKind(kind string) bool {
+	return kind == CategoryKindIncome || kind == CategoryKindExpense || kind == CategoryKindTransfer
+}
+
+// FindCategory looks up one of the user's categories by ID or by key. Keys are
+// matched case-insensitively so "Groceries" and "groceries" resolve to the
+// same category.
+func FindCategory(db *gorm.DB, userID uuid.UUID, idOrKey string) (*Category, error) {
+	var category Category
+	query := db.Where("user_id = ?", userID)
+	if id, err := uuid.Parse(idOrKey); err == nil {
+		query = query.Where("id = ?", id)
+	} else {
+		query = query.Where("LOWER(key) = ?", strings.ToLower(strings.TrimSpace(idOrKey)))
+	}
+	if err := query.First(&category).Error; err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+// CategoryKeysWithDescendants returns the key of the category and the keys of
+// all of its descendants, lowercased for case-insensitive matching, so a
+// parent category also covers its children
+func CategoryKeysWithDescendants(db *gorm.DB, userID uuid.UUID, key string) ([]string, error) {
+	var categories []Category
+	if err := db.Where("user_id = ?", userID).Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	return CategoryDescendantKeys(categories, key), nil
+}
+
+// CategoryDescendantKeys is CategoryKeysWithDescendants over categories that
+// are already loaded, for resolving many keys with one query
+func CategoryDescendantKeys(categories []Category, key string) []string {
+	children := make(map[uuid.UUID][]Category)
+	var root *Category
+	for i := range categories {
+		if categories[i].ParentID != nil {
+			children[*categories[i].ParentID] = append(children[*categories[i].ParentID], categories[i])
+		}
+		if strings.EqualFold(categories[i].Key, key) {
+			root = &categories[i]
+		}
+	}
+
+	if root == nil {
+		return []string{strings.ToLower(key)}
+	}
+
+	keys := []string{strings.ToLower(root.Key)}
+	queue := []uuid.UUID{root.ID}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, child := range children[id] {
+			keys = append(keys, strings.ToLower(child.Key))
+			queue = append(queue, child.ID)
+		}
+	}
+
+	return keys
+}
+
+// EnsureDefaultCategories seeds the default categories for users created
+// before categories existed
+func EnsureDefaultCategories(tx *gorm.DB, userID uuid.UUID) error {
+	var count int64
+	if err := tx.Unscoped().Model(&Category{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	return SeedDefaultCategories(tx, userID)
+}
+
+// SeedDefaultCategories creates default categories for a new user
+func SeedDefaultCategories(tx *gorm.DB, userID uuid.UUID) error {
+	defaultCategories := []Category{
+		// Income
+		{Key: CategoryOpeningBalance, Name: "Opening Balance", Kind: CategoryKindIncome, Icon: "🏁", Color: "#10b981", IsSystem: true},
+		{Key: "salary", Name: "Salary", Kind: CategoryKindIncome, Icon: "💼", Color: "#10b981"},
+		{Key: "freelance", Name: "Freelance", Kind: CategoryKindIncome, Icon: "💻", Color: "#10b981"},
+		{Key: "investment_income", Name: "Investment Income", Kind: CategoryKindIncome, Icon: "📈", Color: "#10b981"},
+		{Key: "dividend_income", Name: "Dividend", Kind: CategoryKindIncome, Icon: "💰", Color: "#10b981"},
+		{Key: "investment_sale", Name: "Investment Sale", Kind: CategoryKindIncome, Icon: "📊", Color: "#10b981"},
+		{Key: "fixed_deposit_maturity", Name: "FD Maturity", Kind: CategoryKindIncome, Icon: "🏦", Color: "#10b981"},
+		{Key: CategoryCreditCardRewards, Name: "Card Rewards", Kind: CategoryKindIncome, Icon: "🎁", Color: "#10b981", IsSystem: true},
+		{Key: "savings_withdrawal", Name: "Savings Withdrawal", Kind: CategoryKindIncome, Icon: "🎯", Color: "#10b981"},
+		{Key: "goal_withdrawal", Name: "Goal Withdrawal", Kind: CategoryKindIncome, Icon: "🎯", Color: "#10b981"},
+		{Key: CategoryGoalHoldingRemoved, Name: "Goal Holding Sold", Kind: CategoryKindIncome, Icon: "💹", Color: "#10b981", IsSystem: true},
+		{Key: "other_income", Name: "Other Income", Kind: CategoryKindIncome, Icon: "💵", Color: "#10b981"},
+
+		// Expenses
+		{Key: "food", Name: "Food & Dining", Kind: CategoryKindExpense, Icon: "🍔", Color: "#ef4444"},
+		{Key: "transport", Name: "Transportation", Kind: CategoryKindExpense, Icon: "🚗", Color: "#ef4444"},
+		{Key: "shopping", Name: "Shopping", Kind: CategoryKindExpense, Icon: "🛍️", Color: "#ef4444"},
+		{Key: "entertainment", Name: "Entertainment", Kind: CategoryKindExpense, Icon: "🎬", Color: "#ef4444"},
+		{Key: "utilities", Name: "Utilities", Kind: CategoryKindExpense, Icon: "💡", Color: "#ef4444"},
+		{Key: "healthcare", Name: "Healthcare", Kind: CategoryKindExpense, Icon: "🏥", Color: "#ef4444"},
+		{Key: "education", Name: "Education", Kind: CategoryKindExpense, Icon: "📚", Color: "#ef4444"},
+		{Key: "housing", Name: "Housing", Kind: CategoryKindExpense, Icon: "🏠", Color: "#ef4444"},
+		{Key: "insurance", Name: "Insurance", Kind: CategoryKindExpense, Icon: "🛡️", Color: "#ef4444"},
+		{Key: "subscriptions", Name: "Subscriptions", Kind: CategoryKindExpense, Icon: "📱", Color: "#ef4444"},
+		{Key: CategoryCreditCardPayment, Name: "Credit Card Payment", Kind: CategoryKindExpense, Icon: "💳", Color: "#ef4444", IsSystem: true},
+		{Key: CategoryCreditCardInterest, Name: "Credit Card Interest", Kind: CategoryKindExpense, Icon: "💳", Color: "#ef4444", IsSystem: true},
+		{Key: CategoryCreditCardFee, Name: "Credit Card Fees", Kind: CategoryKindExpense, Icon: "💳", Color: "#ef4444", IsSystem: true},
+		{Key: "other_expense", Name: "Other Expense", Kind: CategoryKindExpense, Icon: "💸", Color: "#ef4444"},
+
+		// Savings & investments
+		{Key: "savings_contribution", Name: "Savings Contribution", Kind: CategoryKindExpense, Icon: "🎯", Color: "#8b5cf6"},
+		{Key: "fixed_deposit_investment", Name: "Fixed Deposit", Kind: CategoryKindExpense, Icon: "🏦", Color: "#3b82f6"},
+		{Key: "investment_purchase", Name: "Investment Purchase", Kind: CategoryKindExpense, Icon: "📈", Color: "#6366f1"},
+		{Key: "goal_contribution", Name: "Goal Contribution", Kind: CategoryKindExpense, Icon: "🎯", Color: "#8b5cf6"},
+		{Key: CategoryGoalHoldingAdded, Name: "Goal Holding Added", Kind: CategoryKindExpense, Icon: "💹", Color: "#6366f1", IsSystem: true},
+		{Key: CategoryGoalExternalHolding, Name: "External Goal Holding", Kind: CategoryKindExpense, Icon: "📌", Color: "#6366f1", IsSystem: true},
+
+		// Transfers
+		{Key: CategoryTransfer, Name: "Transfer", Kind: CategoryKindTransfer, Icon: "🔄", Color: "#3b82f6", IsSystem: true},
+	}
+
+	// Create all categories
+	for i := range defaultCategories {
+		defaultCategories[i].UserID = userID
+		defaultCategories[i].Active = true
+		defaultCategories[i].SortOrder = i + 1
+		if err := tx.Create(&defaultCategories[i]).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
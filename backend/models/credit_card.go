@@ -8,25 +8,34 @@ import (
 )
 
 type CreditCard struct {
-	ID                uuid.UUID      `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
-	UserID            uuid.UUID      `gorm:"type:uuid;not null;index" json:"userId"`
-	Name              string         `gorm:"not null" json:"name" binding:"required"`
-	LastFourDigits    string         `json:"lastFourDigits"`
-	CardNetwork       string         `json:"cardNetwork"` // Visa, Mastercard, Amex, etc
-	CreditLimit       float64        `gorm:"not null" json:"creditLimit" binding:"required,gt=0"`
-	CurrentBalance    float64        `gorm:"default:0" json:"currentBalance"`
-	APR               float64        `json:"apr"`
-	DueDate           *time.Time     `json:"dueDate"`
-	StatementDate     *time.Time     `json:"statementDate"`
-	MinimumPayment    float64        `gorm:"default:0" json:"minimumPayment"`
-	LastPaymentDate   *time.Time     `json:"lastPaymentDate"`
-	LastPaymentAmount float64        `gorm:"default:0" json:"lastPaymentAmount"`
-	RewardsProgram    string         `json:"rewardsProgram"`
-	Active            bool           `gorm:"default:true" json:"active"`
-	Notes             string         `json:"notes"`
-	CreatedAt         time.Time      `json:"createdAt"`
-	UpdatedAt         time.Time      `json:"updatedAt"`
-	DeletedAt         gorm.DeletedAt `gorm:"index" json:"-"`
+	ID                 uuid.UUID      `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	UserID             uuid.UUID      `gorm:"type:uuid;not null;index" json:"userId"`
+	Name               string         `gorm:"not null" json:"name" binding:"required"`
+	LastFourDigits     string         `json:"lastFourDigits"`
+	CardNetwork        string         `json:"cardNetwork"` // Visa, Mastercard, Amex, etc
+	CreditLimit        Money          `gorm:"not null" json:"creditLimit" binding:"required,gt=0"`
+	CurrentBalance     Money          `gorm:"default:0" json:"currentBalance"`
+	Currency           string         `gorm:"default:'BDT'" json:"currency"`
+	APR                float64        `json:"apr"`
+	DueDate            *time.Time     `json:"dueDate"`
+	StatementDate      *time.Time     `json:"statementDate"`
+	MinimumPayment     Money          `gorm:"default:0" json:"minimumPayment"`
+	LastPaymentDate    *time.Time     `json:"lastPaymentDate"`
+	LastPaymentAmount  Money          `gorm:"default:0" json:"lastPaymentAmount"`
+	RewardsProgram     string         `json:"rewardsProgram"`
+	StatementDay       int            `json:"statementDay" binding:"min=0,max=31"`       // Day of month the billing cycle closes; 0 disables automatic statements
+	GracePeriodDays    int            `json:"gracePeriodDays" binding:"min=0"`           // Days from statement to due date; 0 uses DefaultGracePeriodDays
+	MinPaymentPercent  float64        `json:"minPaymentPercent" binding:"min=0,max=100"` // Percent of the statement balance due at minimum
+	MinPaymentFloor    Money          `json:"minPaymentFloor" binding:"min=0"`           // Smallest minimum payment
+	LateFee            Money          `json:"lateFee" binding:"min=0"`                   // Charged when a statement's minimum is not paid by its due date
+	RewardType         string         `json:"rewardType"`                                // cashback, points or miles earned on purchases; empty when rewards are recorded by hand
+	RewardRate         float64        `json:"rewardRate" binding:"min=0"`                // Reward per unit of the card's currency spent, e.g. 0.015 for 1.5% cashback
+	RewardExpiryMonths int            `json:"rewardExpiryMonths" binding:"min=0"`        // Months until earned rewards expire; 0 for never
+	Active             bool           `gorm:"default:true" json:"active"`
+	Notes              string         `json:"notes"`
+	CreatedAt          time.Time      `json:"createdAt"`
+	UpdatedAt          time.Time      `json:"updatedAt"`
+	DeletedAt          gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 func (cc *CreditCard) BeforeCreate(tx *gorm.DB) error {
@@ -39,15 +48,21 @@ func (cc *CreditCard) BeforeCreate(tx *gorm.DB) error {
 type Statement struct {
 	ID              uuid.UUID      `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
 	UserID          uuid.UUID      `gorm:"type:uuid;not null;index" json:"userId"`
-	CardID          uuid.UUID      `gorm:"type:uuid;not null;index" json:"cardId"`
-	StatementDate   time.Time      `gorm:"not null" json:"statementDate"`
+	CardID          uuid.UUID      `gorm:"type:uuid;not null;index;index:idx_statement_card_date,priority:1" json:"cardId"`
+	StatementDate   time.Time      `gorm:"not null;index:idx_statement_card_date,priority:2" json:"statementDate"`
 	DueDate         time.Time      `gorm:"not null" json:"dueDate"`
-	OpeningBalance  float64        `json:"openingBalance"`
-	ClosingBalance  float64        `json:"closingBalance"`
-	MinimumPayment  float64        `json:"minimumPayment"`
-	TotalCharges    float64        `json:"totalCharges"`
-	TotalPayments   float64        `json:"totalPayments"`
-	InterestCharged float64        `json:"interestCharged"`
+	PeriodStart     *time.Time     `json:"periodStart"` // First day of the billing cycle; the cycle ends on StatementDate
+	OpeningBalance  Money          `json:"openingBalance"`
+	ClosingBalance  Money          `json:"closingBalance"`
+	MinimumPayment  Money          `json:"minimumPayment"`
+	TotalCharges    Money          `json:"totalCharges"` // Purchases
+	TotalFees       Money          `json:"totalFees"`
+	TotalRefunds    Money          `json:"totalRefunds"`
+	TotalPayments   Money          `json:"totalPayments"`
+	InterestCharged Money          `json:"interestCharged"`
+	Generated       bool           `json:"generated"` // Created when the billing cycle closed rather than by hand
+	LateFeeCharged  Money          `json:"lateFeeCharged"`
+	LateFeeAssessed bool           `json:"lateFeeAssessed"` // The due date has passed and the late fee, if any, was charged
 	Paid            bool           `gorm:"default:false" json:"paid"`
 	PaidDate        *time.Time     `json:"paidDate"`
 	CreatedAt       time.Time      `json:"createdAt"`
@@ -63,18 +78,22 @@ func (s *Statement) BeforeCreate(tx *gorm.DB) error {
 }
 
 type Reward struct {
-	ID          uuid.UUID      `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
-	UserID      uuid.UUID      `gorm:"type:uuid;not null;index" json:"userId"`
-	CardID      uuid.UUID      `gorm:"type:uuid;not null;index" json:"cardId"`
-	Type        string         `json:"type"` // cashback, points, miles
-	Amount      float64        `json:"amount"`
-	Description string         `json:"description"`
-	EarnedDate  time.Time      `gorm:"not null" json:"earnedDate"`
-	Redeemed    bool           `gorm:"default:false" json:"redeemed"`
-	RedeemedAt  *time.Time     `json:"redeemedAt"`
-	CreatedAt   time.Time      `json:"createdAt"`
-	UpdatedAt   time.Time      `json:"updatedAt"`
-	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+	ID             uuid.UUID      `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	UserID         uuid.UUID      `gorm:"type:uuid;not null;index" json:"userId"`
+	CardID         uuid.UUID      `gorm:"type:uuid;not null;index" json:"cardId"`
+	Type           string         `json:"type"` // cashback, points, miles
+	Amount         Money          `json:"amount"`
+	Description    string         `json:"description"`
+	EarnedDate     time.Time      `gorm:"not null" json:"earnedDate"`
+	ExpiresAt      *time.Time     `json:"expiresAt"`
+	Redeemed       bool           `gorm:"default:false" json:"redeemed"` // Fully redeemed
+	RedeemedAt     *time.Time     `json:"redeemedAt"`
+	RedeemedAmount Money          `json:"redeemedAmount"`                       // Part of Amount redeemed; redemptions use the oldest rewards first
+	TransactionID  *uuid.UUID     `gorm:"type:uuid;index" json:"transactionId"` // Credit card transaction that earned it
+	RuleID         *uuid.UUID     `gorm:"type:uuid" json:"ruleId"`              // Earning rule that applied, if any
+	CreatedAt      time.Time      `json:"createdAt"`
+	UpdatedAt      time.Time      `json:"updatedAt"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 func (r *Reward) BeforeCreate(tx *gorm.DB) error {
@@ -91,11 +110,11 @@ type CreditCardTransaction struct {
 	CardID        uuid.UUID      `gorm:"type:uuid;not null;index" json:"cardId"`
 	TransactionID uuid.UUID      `gorm:"type:uuid;index" json:"transactionId"` // Link to main Transaction record
 	CategoryID    string         `json:"categoryId"`
-	Amount        float64        `gorm:"not null" json:"amount" binding:"required,gt=0"`
+	Amount        Money          `gorm:"not null" json:"amount" binding:"required,gt=0"`
 	Description   string         `json:"description"`
 	Merchant      string         `json:"merchant"`
 	Date          time.Time      `gorm:"not null" json:"date"`
-	Type          string         `gorm:"not null" json:"type"` // purchase, payment, refund, fee, interest
+	Type          string         `gorm:"not null" json:"type"` // purchase, payment, refund, fee, interest, reward
 	Tags          []string       `gorm:"type:jsonb;serializer:json" json:"tags"`
 	Attachments   []string       `gorm:"type:jsonb;serializer:json" json:"attachments"`
 	CreatedAt     time.Time      `json:"createdAt"`
@@ -116,7 +135,7 @@ type CreditCardPayment struct {
 	UserID        uuid.UUID      `gorm:"type:uuid;not null;index" json:"userId"`
 	CardID        uuid.UUID      `gorm:"type:uuid;not null;index" json:"cardId"`
 	AccountID     uuid.UUID      `gorm:"type:uuid;not null;index" json:"accountId"` // Account used to pay
-	Amount        float64        `gorm:"not null" json:"amount" binding:"required,gt=0"`
+	Amount        Money          `gorm:"not null" json:"amount" binding:"required,gt=0"`
 	PaymentDate   time.Time      `gorm:"not null" json:"paymentDate"`
 	Description   string         `json:"description"`
 	TransactionID uuid.UUID      `gorm:"type:uuid;index" json:"transactionId"` // Link to Transaction record
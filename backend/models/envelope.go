@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// EnvelopeMonthFormat is how envelope months are written in requests
+const EnvelopeMonthFormat = "2006-01"
+
+// EnvelopeAssignment is money assigned to an expense category's envelope in
+// a month. Every expense category that has ever been assigned money is an
+// envelope.
+type EnvelopeAssignment struct {
+	ID         uuid.UUID      `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	UserID     uuid.UUID      `gorm:"type:uuid;not null;index;uniqueIndex:idx_envelope_assignment,priority:1" json:"userId"`
+	CategoryID string         `gorm:"not null;uniqueIndex:idx_envelope_assignment,priority:2" json:"categoryId"`
+	Month      time.Time      `gorm:"type:date;not null;uniqueIndex:idx_envelope_assignment,priority:3" json:"month"` // First day of the month
+	Amount     Money          `gorm:"not null" json:"amount"`                                                         // Negative when more was moved out than assigned
+	CreatedAt  time.Time      `json:"createdAt"`
+	UpdatedAt  time.Time      `json:"updatedAt"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (a *EnvelopeAssignment) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	return nil
+}
+
+// EnvelopeMove records money moved from one envelope to another in a month
+type EnvelopeMove struct {
+	ID             uuid.UUID      `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	UserID         uuid.UUID      `gorm:"type:uuid;not null;index" json:"userId"`
+	Month          time.Time      `gorm:"type:date;not null;index" json:"month"`
+	FromCategoryID string         `gorm:"not null" json:"fromCategoryId"`
+	ToCategoryID   string         `gorm:"not null" json:"toCategoryId"`
+	Amount         Money          `gorm:"not null" json:"amount"`
+	Notes          string         `json:"notes"`
+	CreatedAt      time.Time      `json:"createdAt"`
+	UpdatedAt      time.Time      `json:"updatedAt"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (m *EnvelopeMove) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == uuid.Nil {
+		m.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ExchangeRate is the number of units of QuoteCurrency one unit of
+// BaseCurrency bought on Date. A rate applies from its date until the next
+// rate for the same pair. Rates are reference data and are deleted outright,
+// so a pair has at most one rate per day.
+type ExchangeRate struct {
+	ID            uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	UserID        uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_exchange_rate_pair_date" json:"userId"`
+	BaseCurrency  string    `gorm:"type:varchar(3);not null;uniqueIndex:idx_exchange_rate_pair_date" json:"baseCurrency" binding:"required,len=3"`
+	QuoteCurrency string    `gorm:"type:varchar(3);not null;uniqueIndex:idx_exchange_rate_pair_date" json:"quoteCurrency" binding:"required,len=3"`
+	Rate          float64   `gorm:"type:numeric(24,10);not null" json:"rate" binding:"required,gt=0"`
+	Date          time.Time `gorm:"type:date;not null;uniqueIndex:idx_exchange_rate_pair_date" json:"date" binding:"required"`
+	Source        string    `gorm:"default:'manual'" json:"source"` // manual, import
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+func (r *ExchangeRate) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
+
+// Normalize upper-cases the currency codes and truncates the date to a day
+func (r *ExchangeRate) Normalize() {
+	r.BaseCurrency = strings.ToUpper(strings.TrimSpace(r.BaseCurrency))
+	r.QuoteCurrency = strings.ToUpper(strings.TrimSpace(r.QuoteCurrency))
+	r.Date = time.Date(r.Date.Year(), r.Date.Month(), r.Date.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// ErrExchangeRateNotFound is returned when no rate covers a currency pair on a date
+var ErrExchangeRateNotFound = errors.New("exchange rate not found")
+
+// UserCurrency returns the user's reporting currency from Settings
+func UserCurrency(db *gorm.DB, userID uuid.UUID) string {
+	var settings Settings
+	if err := db.Where("user_id = ?", userID).First(&settings).Error; err != nil || settings.Currency == "" {
+		return "BDT"
+	}
+	return strings.ToUpper(settings.Currency)
+}
+
+// CurrencyConverter converts amounts between currencies using a user's
+// stored exchange rates. It loads the rates once, so it suits converting
+// many transactions in a single request.
+type CurrencyConverter struct {
+	rates map[string][]ExchangeRate // "BASE/QUOTE" -> rates sorted by date
+}
+
+// NewCurrencyConverter loads all exchange rates for the user
+func NewCurrencyConverter(db *gorm.DB, userID uuid.UUID) (*CurrencyConverter, error) {
+	var rates []ExchangeRate
+	if err := db.Where("user_id = ?", userID).Order("date ASC").Find(&rates).Error; err != nil {
+		return nil, err
+	}
+
+	converter := &CurrencyConverter{rates: make(map[string][]ExchangeRate)}
+	for _, rate := range rates {
+		key := rate.BaseCurrency + "/" + rate.QuoteCurrency
+		converter.rates[key] = append(converter.rates[key], rate)
+	}
+	return converter, nil
+}
+
+// Rate returns the rate from one currency to another as of the date. It uses
+// the latest rate on or before the date, falling back to the inverse pair and
+// then to the earliest known rate when the date precedes all rates.
+func (cc *CurrencyConverter) Rate(from, to string, date time.Time) (float64, error) {
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	if from == "" || to == "" || from == to {
+		return 1, nil
+	}
+
+	if rate, ok := cc.lookup(from+"/"+to, date, false); ok {
+		return rate, nil
+	}
+	if rate, ok := cc.lookup(to+"/"+from, date, false); ok {
+		return 1 / rate, nil
+	}
+	if rate, ok := cc.lookup(from+"/"+to, date, true); ok {
+		return rate, nil
+	}
+	if rate, ok := cc.lookup(to+"/"+from, date, true); ok {
+		return 1 / rate, nil
+	}
+
+	return 0, fmt.Errorf("%w: %s to %s on %s", ErrExchangeRateNotFound, from, to, date.Format("2006-01-02"))
+}
+
+// Convert converts the amount and rounds it to the target currency's minor units
+func (cc *CurrencyConverter) Convert(amount Money, from, to string, date time.Time) (Money, error) {
+	rate, err := cc.Rate(from, to, date)
+	if err != nil {
+		return 0, err
+	}
+	if rate == 1 {
+		return amount, nil
+	}
+	return amount.Mul(rate).Round(to), nil
+}
+
+// lookup finds the latest rate on or before the date, or the earliest rate
+// when earliest is set
+func (cc *CurrencyConverter) lookup(key string, date time.Time, earliest bool) (float64, bool) {
+	rates := cc.rates[key]
+	if len(rates) == 0 {
+		return 0, false
+	}
+	if earliest {
+		return rates[0].Rate, true
+	}
+
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	i := sort.Search(len(rates), func(i int) bool { return rates[i].Date.After(day) })
+	if i == 0 {
+		return 0, false
+	}
+	return rates[i-1].Rate, true
+}
+
+// ResolveTransferAmounts fills in the destination side of a transfer between
+// accounts in different currencies. A given ToAmount fixes the applied rate,
+// otherwise a given ExchangeRate is used, otherwise the user's stored rate as
+// of the transaction date. Same-currency transfers clear both fields.
+func (t *Transaction) ResolveTransferAmounts(db *gorm.DB, fromCurrency, toCurrency string) error {
+	if t.Type != "transfer" || t.ToAccountID == nil || strings.EqualFold(fromCurrency, toCurrency) {
+		t.ToAmount = nil
+		t.ExchangeRate = nil
+		return nil
+	}
+
+	switch {
+	case t.ToAmount != nil && *t.ToAmount > 0:
+		rate := t.ToAmount.Ratio(t.Amount)
+		t.ExchangeRate = &rate
+	case t.ExchangeRate != nil && *t.ExchangeRate > 0:
+		toAmount := t.Amount.Mul(*t.ExchangeRate).Round(toCurrency)
+		t.ToAmount = &toAmount
+	default:
+		converter, err := NewCurrencyConverter(db, t.UserID)
+		if err != nil {
+			return err
+		}
+		rate, err := converter.Rate(fromCurrency, toCurrency, t.Date)
+		if err != nil {
+			return err
+		}
+		toAmount := t.Amount.Mul(rate).Round(toCurrency)
+		t.ToAmount = &toAmount
+		t.ExchangeRate = &rate
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package models
+
+import "time"
+
+// Schedule frequencies shared by recurring transactions and bills
+const (
+	FrequencyDaily     = "daily"
+	FrequencyWeekly    = "weekly"
+	FrequencyBiweekly  = "biweekly"
+	FrequencyMonthly   = "monthly"
+	FrequencyQuarterly = "quarterly"
+	FrequencyYearly    = "yearly"
+)
+
+// IsValidFrequency reports whether frequency is a supported schedule frequency
+func IsValidFrequency(frequency string) bool {
+	switch frequency {
+	case FrequencyDaily, FrequencyWeekly, FrequencyBiweekly,
+		FrequencyMonthly, FrequencyQuarterly, FrequencyYearly:
+		return true
+	}
+	return false
+}
+
+// AddFrequency returns the n-th occurrence of a schedule that starts on start.
+// Month based frequencies keep the day of month of start and clamp it to the
+// last day of shorter months, so a schedule starting on Jan 31 falls on
+// Feb 28/29 instead of spilling over into March.
+func AddFrequency(start time.Time, frequency string, n int) (time.Time, bool) {
+	switch frequency {
+	case FrequencyDaily:
+		return start.AddDate(0, 0, n), true
+	case FrequencyWeekly:
+		return start.AddDate(0, 0, 7*n), true
+	case FrequencyBiweekly:
+		return start.AddDate(0, 0, 14*n), true
+	case FrequencyMonthly:
+		return addMonthsClamped(start, n, start.Day()), true
+	case FrequencyQuarterly:
+		return addMonthsClamped(start, 3*n, start.Day()), true
+	case FrequencyYearly:
+		return addMonthsClamped(start, 12*n, start.Day()), true
+	}
+	return time.Time{}, false
+}
+
+// OccurrenceAfter returns the first occurrence after t of a schedule that
+// starts on start. It skips straight to the occurrences around t rather than
+// stepping through every earlier one.
+func OccurrenceAfter(start time.Time, frequency string, t time.Time) (time.Time, bool) {
+	n := 0
+	if t.After(start) {
+		days := int(t.Sub(start).Hours() / 24)
+		months := (t.Year()-start.Year())*12 + int(t.Month()) - int(start.Month())
+		switch frequency {
+		case FrequencyDaily:
+			n = days
+		case FrequencyWeekly:
+			n = days / 7
+		case FrequencyBiweekly:
+			n = days / 14
+		case FrequencyMonthly:
+			n = months
+		case FrequencyQuarterly:
+			n = months / 3
+		case FrequencyYearly:
+			n = months / 12
+		}
+		// Back off one occurrence for daylight saving changes and clamped
+		// days, which can put the estimate past t
+		if n > 0 {
+			n--
+		}
+	}
+
+	for ; ; n++ {
+		occurrence, ok := AddFrequency(start, frequency, n)
+		if !ok {
+			return time.Time{}, false
+		}
+		if occurrence.After(t) {
+			return occurrence, true
+		}
+	}
+}
+
+// addMonthsClamped moves t forward by months and places it on day, clamped to
+// the length of the resulting month
+func addMonthsClamped(t time.Time, months int, day int) time.Time {
+	firstOfMonth := time.Date(t.Year(), t.Month()+time.Month(months), 1,
+		t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	if last := DaysInMonth(firstOfMonth.Year(), firstOfMonth.Month()); day > last {
+		day = last
+	}
+	return firstOfMonth.AddDate(0, 0, day-1)
+}
+
+// DaysInMonth returns the number of days in the given month
+func DaysInMonth(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOccurrenceAfter(t *testing.T) {
+	dhaka := time.FixedZone("Asia/Dhaka", 6*60*60)
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	starts := []time.Time{
+		time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC),
+		time.Date(2023, 2, 28, 9, 30, 0, 0, dhaka),
+		time.Date(2024, 3, 9, 12, 0, 0, 0, newYork), // Daylight saving starts the next day
+	}
+	frequencies := []string{FrequencyDaily, FrequencyWeekly, FrequencyBiweekly, FrequencyMonthly, FrequencyQuarterly, FrequencyYearly}
+
+	for _, start := range starts {
+		for _, frequency := range frequencies {
+			// Every seven hours across two years, from a day before the start
+			for t0 := start.AddDate(0, 0, -1); t0.Before(start.AddDate(2, 0, 0)); t0 = t0.Add(7 * time.Hour) {
+				got, ok := OccurrenceAfter(start, frequency, t0)
+				if !ok {
+					t.Fatalf("OccurrenceAfter(%v, %s, %v) not ok", start, frequency, t0)
+				}
+				want := bruteOccurrenceAfter(start, frequency, t0)
+				if !got.Equal(want) {
+					t.Fatalf("OccurrenceAfter(%v, %s, %v) = %v, want %v", start, frequency, t0, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestOccurrenceAfterInvalidFrequency(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if _, ok := OccurrenceAfter(start, "fortnightly", start.AddDate(1, 0, 0)); ok {
+		t.Error("OccurrenceAfter with an invalid frequency is ok")
+	}
+}
+
+func TestNextOccurrence(t *testing.T) {
+	start := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+	processed := time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 4, 15, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		lastProcessed *time.Time
+		endDate       *time.Time
+		want          time.Time
+		wantOK        bool
+	}{
+		{"not processed yet", nil, nil, start, true},
+		{"after last processed", &processed, nil, time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC), true},
+		{"before end date", &processed, &end, time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC), true},
+		{"past end date", &end, &end, time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := RecurringTransaction{Frequency: FrequencyMonthly, StartDate: start, LastProcessed: tt.lastProcessed, EndDate: tt.endDate}
+			got, ok := rt.NextOccurrence()
+			if ok != tt.wantOK || !got.Equal(tt.want) {
+				t.Errorf("NextOccurrence() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+// bruteOccurrenceAfter counts occurrences from the start, as NextOccurrence
+// once did
+func bruteOccurrenceAfter(start time.Time, frequency string, t time.Time) time.Time {
+	for n := 0; ; n++ {
+		occurrence, _ := AddFrequency(start, frequency, n)
+		if occurrence.After(t) {
+			return occurrence
+		}
+	}
+}
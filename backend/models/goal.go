@@ -24,10 +24,10 @@ type Goal struct {
 	Priority string `json:"priority"` // high, medium, low
 
 	// Financial Targets
-	TargetAmount        float64    `gorm:"not null" json:"targetAmount"`
-	CurrentAmount       float64    `gorm:"default:0" json:"currentAmount"`
+	TargetAmount        Money      `gorm:"not null" json:"targetAmount"`
+	CurrentAmount       Money      `gorm:"default:0" json:"currentAmount"`
 	TargetDate          *time.Time `json:"targetDate"`
-	MonthlyContribution float64    `json:"monthlyContribution"`
+	MonthlyContribution Money      `json:"monthlyContribution"`
 
 	// Status
 	Status       string     `gorm:"default:active" json:"status"` // active, achieved, paused, archived
@@ -35,7 +35,7 @@ type Goal struct {
 	AchievedDate *time.Time `json:"achievedDate"`
 
 	// Tracking
-	LastContribution     float64    `json:"lastContribution"`
+	LastContribution     Money      `json:"lastContribution"`
 	LastContributionDate *time.Time `json:"lastContributionDate"`
 
 	// Relationships
@@ -59,25 +59,25 @@ type GoalHolding struct {
 	Type         string    `gorm:"not null;index" json:"type"`   // savings, fixed_deposit, dps, recurring_deposit, stocks, mutual_fund, etf, bonds, crypto, real_estate, gold, pension_fund, ulip, ppf, nsc, custom
 	Status       string    `gorm:"default:active" json:"status"` // active, matured, sold, closed, withdrawn
 	PurchaseDate time.Time `gorm:"not null" json:"purchaseDate"`
-	Amount       float64   `gorm:"not null" json:"amount"` // Initial investment amount
-	CurrentValue float64   `json:"currentValue"`           // Current market value
+	Amount       Money     `gorm:"not null" json:"amount"` // Initial investment amount
+	CurrentValue Money     `json:"currentValue"`           // Current market value
 
 	// Common Fields (for bank products)
 	Institution    *string    `json:"institution"`    // Bank/Fund house name
 	AccountNumber  *string    `json:"accountNumber"`  // Account/Policy number
 	InterestRate   *float64   `json:"interestRate"`   // Annual interest rate
 	MaturityDate   *time.Time `json:"maturityDate"`   // When it matures
-	MaturityAmount *float64   `json:"maturityAmount"` // Expected maturity value
+	MaturityAmount *Money     `json:"maturityAmount"` // Expected maturity value
 	TenureMonths   *int       `json:"tenureMonths"`   // Duration in months
 
 	// For market instruments (stocks, mutual funds, ETF, crypto)
 	Symbol       *string  `json:"symbol"`       // Ticker symbol (AAPL, VTSAX, BTC)
 	Quantity     *float64 `json:"quantity"`     // Number of shares/units
-	CostBasis    *float64 `json:"costBasis"`    // Price per unit when purchased
-	CurrentPrice *float64 `json:"currentPrice"` // Current market price per unit
+	CostBasis    *Money   `json:"costBasis"`    // Price per unit when purchased
+	CurrentPrice *Money   `json:"currentPrice"` // Current market price per unit
 
 	// For DPS/Recurring Deposits
-	MonthlyDeposit *float64 `json:"monthlyDeposit"` // Monthly contribution amount
+	MonthlyDeposit *Money `json:"monthlyDeposit"` // Monthly contribution amount
 
 	// Additional metadata stored as JSON
 	Details map[string]interface{} `gorm:"type:jsonb;serializer:json" json:"details"`
@@ -98,7 +98,7 @@ type GoalContribution struct {
 	HoldingID *uuid.UUID `gorm:"type:uuid" json:"holdingId"` // Link to specific holding
 
 	Type   string    `gorm:"not null" json:"type"` // contribution, withdrawal, dividend, interest, appreciation, depreciation, maturity
-	Amount float64   `gorm:"not null" json:"amount"`
+	Amount Money     `gorm:"not null" json:"amount"`
 	Date   time.Time `gorm:"not null;index" json:"date"`
 	Notes  string    `json:"notes"`
 
@@ -139,7 +139,7 @@ func (g *Goal) CalculateProgress() float64 {
 	if g.TargetAmount <= 0 {
 		return 0
 	}
-	progress := (g.CurrentAmount / g.TargetAmount) * 100
+	progress := g.CurrentAmount.Ratio(g.TargetAmount) * 100
 	if progress > 100 {
 		return 100
 	}
@@ -148,7 +148,7 @@ func (g *Goal) CalculateProgress() float64 {
 
 // UpdateCurrentAmount recalculates current amount from all holdings
 func (g *Goal) UpdateCurrentAmount(db *gorm.DB) error {
-	var total float64
+	var total Money
 
 	// DEBUG: Log query parameters
 	fmt.Printf("DEBUG UpdateCurrentAmount: GoalID=%s\n", g.ID)
@@ -166,18 +166,18 @@ func (g *Goal) UpdateCurrentAmount(db *gorm.DB) error {
 		Select("COALESCE(SUM(current_value), 0)").
 		Scan(&total)
 
-	fmt.Printf("DEBUG UpdateCurrentAmount: Total sum=%.2f\n", total)
+	fmt.Printf("DEBUG UpdateCurrentAmount: Total sum=%s\n", total)
 
 	g.CurrentAmount = total
 	return db.Save(g).Error
 }
 
 // CalculateGainLoss returns the gain/loss for a holding
-func (h *GoalHolding) CalculateGainLoss() (float64, float64) {
+func (h *GoalHolding) CalculateGainLoss() (Money, float64) {
 	gainLoss := h.CurrentValue - h.Amount
 	gainLossPercent := float64(0)
 	if h.Amount > 0 {
-		gainLossPercent = (gainLoss / h.Amount) * 100
+		gainLossPercent = gainLoss.Ratio(h.Amount) * 100
 	}
 	return gainLoss, gainLossPercent
 }
@@ -185,7 +185,7 @@ func (h *GoalHolding) CalculateGainLoss() (float64, float64) {
 // UpdateMarketValue updates current value for market instruments
 func (h *GoalHolding) UpdateMarketValue() {
 	if h.Quantity != nil && h.CurrentPrice != nil {
-		h.CurrentValue = *h.Quantity * *h.CurrentPrice
+		h.CurrentValue = h.CurrentPrice.Mul(*h.Quantity)
 	} else if h.CurrentValue == 0 {
 		// If no market value set, use initial amount
 		h.CurrentValue = h.Amount
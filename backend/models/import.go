@@ -0,0 +1,107 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ImportMapping is the saved CSV layout for an account's bank exports
+type ImportMapping struct {
+	ID                uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	UserID            uuid.UUID `gorm:"type:uuid;not null;index" json:"userId"`
+	AccountID         uuid.UUID `gorm:"type:uuid;not null;uniqueIndex" json:"accountId"`
+	DateColumn        string    `json:"dateColumn"`        // Header name, or 1-based column number without a header
+	AmountColumn      string    `json:"amountColumn"`      // Signed amount; leave empty when using debit/credit columns
+	DebitColumn       string    `json:"debitColumn"`       // Money out
+	CreditColumn      string    `json:"creditColumn"`      // Money in
+	DescriptionColumn string    `json:"descriptionColumn"` // Several columns may be joined with "+", e.g. "Payee+Memo"
+	CategoryColumn    string    `json:"categoryColumn"`
+	ReferenceColumn   string    `json:"referenceColumn"` // Bank reference used for duplicate detection
+	DateFormat        string    `json:"dateFormat"`      // e.g. DD/MM/YYYY, MM/DD/YYYY, YYYY-MM-DD
+	DecimalSeparator  string    `gorm:"default:'.'" json:"decimalSeparator"`
+	Delimiter         string    `gorm:"default:','" json:"delimiter"`
+	HasHeader         bool      `json:"hasHeader"`
+	SkipRows          int       `gorm:"default:0" json:"skipRows"`          // Lines to skip before the header
+	NegateAmounts     bool      `gorm:"default:false" json:"negateAmounts"` // For exports where money out is positive
+	CreatedAt         time.Time `json:"createdAt"`
+	UpdatedAt         time.Time `json:"updatedAt"`
+}
+
+func (m *ImportMapping) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == uuid.Nil {
+		m.ID = uuid.New()
+	}
+	return nil
+}
+
+// Import formats
+const (
+	ImportFormatCSV = "csv"
+	ImportFormatOFX = "ofx"
+	ImportFormatQIF = "qif"
+)
+
+// Import batch statuses
+const (
+	ImportStatusPreview   = "preview"
+	ImportStatusCommitted = "committed"
+)
+
+// ImportBatch is one uploaded statement file. Its rows are kept as a preview
+// until the batch is committed, which creates all transactions at once.
+type ImportBatch struct {
+	ID            uuid.UUID      `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	UserID        uuid.UUID      `gorm:"type:uuid;not null;index" json:"userId"`
+	AccountID     uuid.UUID      `gorm:"type:uuid;not null;index" json:"accountId"`
+	FileName      string         `gorm:"not null" json:"fileName"`
+	Format        string         `gorm:"not null" json:"format"`                // csv, ofx, qif
+	Status        string         `gorm:"default:'preview';index" json:"status"` // preview, committed
+	TotalRows     int            `json:"totalRows"`
+	ErrorRows     int            `json:"errorRows"`
+	DuplicateRows int            `json:"duplicateRows"`
+	ImportedRows  int            `json:"importedRows"`
+	CommittedAt   *time.Time     `json:"committedAt"`
+	Rows          []ImportRow    `gorm:"foreignKey:BatchID" json:"rows,omitempty"`
+	CreatedAt     time.Time      `json:"createdAt"`
+	UpdatedAt     time.Time      `json:"updatedAt"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (b *ImportBatch) BeforeCreate(tx *gorm.DB) error {
+	if b.ID == uuid.Nil {
+		b.ID = uuid.New()
+	}
+	return nil
+}
+
+// ImportRow is a parsed statement line with its validation errors and
+// duplicate match
+type ImportRow struct {
+	ID                uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	BatchID           uuid.UUID  `gorm:"type:uuid;not null;index" json:"batchId"`
+	RowNumber         int        `gorm:"not null" json:"rowNumber"` // Line or record number in the file
+	Date              *time.Time `json:"date"`
+	Type              string     `json:"type"` // income, expense
+	Amount            Money      `json:"amount"`
+	Description       string     `json:"description"`
+	CategoryID        string     `json:"categoryId"`
+	ExternalID        string     `json:"externalId"` // OFX FITID or bank reference
+	Errors            []string   `gorm:"type:jsonb;serializer:json" json:"errors"`
+	Duplicate         string     `json:"duplicate"`                              // Why the row looks like a duplicate: external_id, fuzzy or file
+	DuplicateOfID     *uuid.UUID `gorm:"type:uuid" json:"duplicateOfId"`         // Existing transaction the row matches
+	Tags              []string   `gorm:"type:jsonb;serializer:json" json:"tags"` // Added by rules
+	TransferAccountID *uuid.UUID `gorm:"type:uuid" json:"transferAccountId"`     // Set by a rule that turns the row into a transfer with this account
+	Skip              bool       `gorm:"default:false" json:"skip"`
+	TransactionID     *uuid.UUID `gorm:"type:uuid" json:"transactionId"` // Set once committed
+	CreatedAt         time.Time  `json:"createdAt"`
+	UpdatedAt         time.Time  `json:"updatedAt"`
+}
+
+func (r *ImportRow) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
@@ -15,11 +15,11 @@ type Investment struct {
 	Name             string         `gorm:"not null" json:"name" binding:"required"`
 	AssetType        string         `gorm:"not null" json:"assetType" binding:"required"` // stocks, bonds, mutual_funds, etf, crypto, etc
 	Quantity         float64        `gorm:"not null" json:"quantity" binding:"required,gt=0"`
-	CostBasis        float64        `gorm:"not null" json:"costBasis" binding:"required,gt=0"`
-	CurrentPrice     float64        `gorm:"not null" json:"currentPrice" binding:"required,gt=0"`
+	CostBasis        Money          `gorm:"not null" json:"costBasis" binding:"required,gt=0"`
+	CurrentPrice     Money          `gorm:"not null" json:"currentPrice" binding:"required,gt=0"`
 	PurchaseDate     time.Time      `json:"purchaseDate"`
 	LastUpdated      time.Time      `json:"lastUpdated"`
-	RealizedGainLoss float64        `gorm:"default:0" json:"realizedGainLoss"`
+	RealizedGainLoss Money          `gorm:"default:0" json:"realizedGainLoss"`
 	Notes            string         `json:"notes"`
 	CreatedAt        time.Time      `json:"createdAt"`
 	UpdatedAt        time.Time      `json:"updatedAt"`
@@ -55,7 +55,7 @@ type Dividend struct {
 	ID           uuid.UUID      `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
 	UserID       uuid.UUID      `gorm:"type:uuid;not null;index" json:"userId"`
 	InvestmentID uuid.UUID      `gorm:"type:uuid;not null;index" json:"investmentId"`
-	Amount       float64        `gorm:"not null" json:"amount" binding:"required,gt=0"`
+	Amount       Money          `gorm:"not null" json:"amount" binding:"required,gt=0"`
 	PaymentDate  time.Time      `gorm:"not null" json:"paymentDate"`
 	Reinvested   bool           `gorm:"default:false" json:"reinvested"`
 	CreatedAt    time.Time      `json:"createdAt"`
@@ -0,0 +1,572 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Ledgers a posting can be booked against. Accounts, credit cards and goal
+// holdings are identified by LedgerID; income, expense and equity ledgers by
+// LedgerKey (a category key or one of the Equity* keys).
+const (
+	LedgerAccount     = "account"      // Asset; Account.Balance is the sum of its postings
+	LedgerCreditCard  = "credit_card"  // Liability; CreditCard.CurrentBalance is minus the sum of its postings
+	LedgerGoalHolding = "goal_holding" // Asset; the net amount put into the holding
+	LedgerIncome      = "income"
+	LedgerExpense     = "expense"
+	LedgerEquity      = "equity"
+)
+
+// Equity ledger keys
+const (
+	EquityOpeningBalance = "opening_balance" // Balances that existed before daybook
+	EquityConversion     = "conversion"      // Offsets the two sides of a currency exchange
+	EquityAdjustment     = "adjustment"      // Manual corrections and drift found by the backfill
+	EquityExternal       = "external"        // Money from or to outside daybook, e.g. a transfer without a destination
+)
+
+// Journal entry kinds
+const (
+	JournalKindTransaction = "transaction" // Records a Transaction
+	JournalKindReversal    = "reversal"    // Undoes a transaction's earlier entries before an edit or delete
+	JournalKindOpening     = "opening"     // Opening balance of a credit card
+	JournalKindAdjustment  = "adjustment"  // Manual balance change or backfilled drift
+)
+
+// ErrUnbalancedEntry is returned when an entry's postings do not sum to zero
+// in every currency
+var ErrUnbalancedEntry = errors.New("journal entry is not balanced")
+
+// JournalEntry is one financial event recorded as balanced postings. Entries
+// are never edited or deleted; corrections are made with new entries.
+type JournalEntry struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	UserID        uuid.UUID  `gorm:"type:uuid;not null;index" json:"userId"`
+	TransactionID *uuid.UUID `gorm:"type:uuid;index" json:"transactionId"` // Transaction the entry records or reverses
+	Kind          string     `gorm:"not null" json:"kind"`                 // transaction, reversal, opening, adjustment
+	Date          time.Time  `gorm:"not null;index" json:"date"`
+	Description   string     `json:"description"`
+	Postings      []Posting  `gorm:"foreignKey:EntryID" json:"postings"`
+	CreatedAt     time.Time  `json:"createdAt"`
+}
+
+func (e *JournalEntry) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return nil
+}
+
+// Posting is one side of a journal entry. Debits are positive and credits
+// negative, so an asset's balance is the sum of its postings.
+type Posting struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	EntryID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"entryId"`
+	UserID     uuid.UUID  `gorm:"type:uuid;not null;index" json:"userId"`
+	LedgerType string     `gorm:"not null;index:idx_posting_ledger" json:"ledgerType"`
+	LedgerID   *uuid.UUID `gorm:"type:uuid;index:idx_posting_ledger" json:"ledgerId"`
+	LedgerKey  string     `json:"ledgerKey"`
+	Amount     Money      `gorm:"not null" json:"amount"`
+	Currency   string     `gorm:"not null" json:"currency"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+func (p *Posting) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	return nil
+}
+
+// PostJournalEntry records a balanced entry and moves the cached balances of
+// the accounts and credit cards it touches
+func PostJournalEntry(tx *gorm.DB, entry *JournalEntry) error {
+	if err := RecordJournalEntry(tx, entry); err != nil {
+		return err
+	}
+
+	for _, p := range entry.Postings {
+		if p.LedgerID == nil {
+			continue
+		}
+		switch p.LedgerType {
+		case LedgerAccount:
+			if err := adjustColumn(tx, &Account{}, *p.LedgerID, "balance", p.Amount); err != nil {
+				return err
+			}
+		case LedgerCreditCard:
+			if err := adjustColumn(tx, &CreditCard{}, *p.LedgerID, "current_balance", -p.Amount); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RecordJournalEntry stores a balanced entry without touching cached
+// balances. It is used when the balances already reflect the event.
+func RecordJournalEntry(tx *gorm.DB, entry *JournalEntry) error {
+	if len(entry.Postings) == 0 {
+		return nil
+	}
+
+	totals := make(map[string]Money)
+	for _, p := range entry.Postings {
+		totals[p.Currency] += p.Amount
+	}
+	for currency, total := range totals {
+		if total != 0 {
+			return fmt.Errorf("%w: %s is off by %s", ErrUnbalancedEntry, currency, total)
+		}
+	}
+
+	for i := range entry.Postings {
+		entry.Postings[i].UserID = entry.UserID
+	}
+	return tx.Create(entry).Error
+}
+
+// LedgerBalance returns the sum of a ledger's postings in each currency
+func LedgerBalance(db *gorm.DB, ledgerType string, ledgerID uuid.UUID) (map[string]Money, error) {
+	var rows []struct {
+		Currency string
+		Total    Money
+	}
+	err := db.Model(&Posting{}).
+		Select("currency, COALESCE(SUM(amount), 0) AS total").
+		Where("ledger_type = ? AND ledger_id = ?", ledgerType, ledgerID).
+		Group("currency").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	balances := make(map[string]Money, len(rows))
+	for _, row := range rows {
+		balances[row.Currency] = row.Total
+	}
+	return balances, nil
+}
+
+// postingBuilder collects the postings of one entry
+type postingBuilder []Posting
+
+func (b *postingBuilder) add(ledgerType string, ledgerID *uuid.UUID, key string, amount Money, currency string) {
+	if amount == 0 {
+		return
+	}
+	*b = append(*b, Posting{LedgerType: ledgerType, LedgerID: ledgerID, LedgerKey: key, Amount: amount, Currency: currency})
+}
+
+// exchange moves amount out of one ledger and toAmount into another, booking
+// the currency difference against Equity:conversion
+func (b *postingBuilder) exchange(fromType string, fromID *uuid.UUID, amount Money, currency string, toType string, toID *uuid.UUID, toAmount Money, toCurrency string) {
+	b.add(fromType, fromID, "", -amount, currency)
+	if toCurrency == currency {
+		b.add(toType, toID, "", amount, currency)
+		return
+	}
+	b.add(LedgerEquity, nil, EquityConversion, amount, currency)
+	b.add(LedgerEquity, nil, EquityConversion, -toAmount, toCurrency)
+	b.add(toType, toID, "", toAmount, toCurrency)
+}
+
+// journalPostings builds the postings that record the transaction.
+// Transactions that never move a balance, such as goal tracking entries,
+// have none.
+func (t *Transaction) journalPostings(tx *gorm.DB) ([]Posting, error) {
+	var b postingBuilder
+
+	switch {
+	case t.Type == "tracking":
+		return nil, nil
+
+	case t.CreditCardID != nil && *t.CreditCardID == t.AccountID:
+		// Card purchase, fee or interest (expense) or refund (income)
+		currency, err := ledgerCurrency(tx, &CreditCard{}, t.AccountID)
+		if err != nil {
+			return nil, err
+		}
+		shares, err := t.categoryShares(tx)
+		if err != nil {
+			return nil, err
+		}
+		if t.Type == "income" {
+			b.add(LedgerCreditCard, t.CreditCardID, "", t.Amount, currency)
+			for _, share := range shares {
+				b.add(LedgerIncome, nil, share.CategoryID, -share.Amount, currency)
+			}
+		} else if t.Type == "expense" {
+			for _, share := range shares {
+				b.add(LedgerExpense, nil, share.CategoryID, share.Amount, currency)
+			}
+			b.add(LedgerCreditCard, t.CreditCardID, "", -t.Amount, currency)
+		}
+		return b, nil
+	}
+
+	accountID := t.AccountID
+	currency, err := ledgerCurrency(tx, &Account{}, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case t.CreditCardID != nil:
+		// Card payment from an account; ToAmount holds the amount in the
+		// card's currency when it differs
+		cardCurrency, err := ledgerCurrency(tx, &CreditCard{}, *t.CreditCardID)
+		if err != nil {
+			return nil, err
+		}
+		cardAmount := t.Amount
+		if t.ToAmount != nil {
+			cardAmount = *t.ToAmount
+		}
+		b.exchange(LedgerAccount, &accountID, t.Amount, currency, LedgerCreditCard, t.CreditCardID, cardAmount, cardCurrency)
+
+	case t.Type == "transfer" && t.ToAccountID != nil:
+		toCurrency, err := ledgerCurrency(tx, &Account{}, *t.ToAccountID)
+		if err != nil {
+			return nil, err
+		}
+		toAmount := t.Amount
+		if t.ToAmount != nil {
+			toAmount = *t.ToAmount
+		}
+		b.exchange(LedgerAccount, &accountID, t.Amount, currency, LedgerAccount, t.ToAccountID, toAmount, toCurrency)
+
+	case t.Type == "transfer":
+		b.add(LedgerEquity, nil, EquityExternal, t.Amount, currency)
+		b.add(LedgerAccount, &accountID, "", -t.Amount, currency)
+
+	case t.CategoryID == CategoryOpeningBalance && (t.Type == "income" || t.Type == "expense"):
+		amount := t.Amount
+		if t.Type == "expense" {
+			amount = -amount
+		}
+		b.add(LedgerAccount, &accountID, "", amount, currency)
+		b.add(LedgerEquity, nil, EquityOpeningBalance, -amount, currency)
+
+	case t.CategoryID == CategoryGoalHoldingAdded || t.CategoryID == CategoryGoalHoldingRemoved:
+		holdingID, err := transactionHolding(tx, t.ID)
+		if err != nil {
+			return nil, err
+		}
+		if holdingID == nil {
+			return t.categoryPostings(tx, accountID, currency)
+		}
+		if t.Type == "expense" {
+			b.add(LedgerGoalHolding, holdingID, "", t.Amount, currency)
+			b.add(LedgerAccount, &accountID, "", -t.Amount, currency)
+			break
+		}
+		// Selling a holding empties it; the difference to what was put in
+		// is a gain or loss
+		invested, err := LedgerBalance(tx, LedgerGoalHolding, *holdingID)
+		if err != nil {
+			return nil, err
+		}
+		b.add(LedgerAccount, &accountID, "", t.Amount, currency)
+		b.add(LedgerGoalHolding, holdingID, "", -invested[currency], currency)
+		b.add(LedgerIncome, nil, t.CategoryID, invested[currency]-t.Amount, currency)
+
+	default:
+		return t.categoryPostings(tx, accountID, currency)
+	}
+
+	return b, nil
+}
+
+// categoryPostings books an income or expense against its category, or
+// against the category of each of its splits
+func (t *Transaction) categoryPostings(tx *gorm.DB, accountID uuid.UUID, currency string) ([]Posting, error) {
+	if t.Type != "income" && t.Type != "expense" {
+		return nil, nil
+	}
+	shares, err := t.categoryShares(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	var b postingBuilder
+	switch t.Type {
+	case "income":
+		b.add(LedgerAccount, &accountID, "", t.Amount, currency)
+		for _, share := range shares {
+			b.add(LedgerIncome, nil, share.CategoryID, -share.Amount, currency)
+		}
+	case "expense":
+		for _, share := range shares {
+			b.add(LedgerExpense, nil, share.CategoryID, share.Amount, currency)
+		}
+		b.add(LedgerAccount, &accountID, "", -t.Amount, currency)
+	}
+	return b, nil
+}
+
+// ledgerCurrency returns the currency of an account or credit card
+func ledgerCurrency(tx *gorm.DB, model interface{}, id uuid.UUID) (string, error) {
+	var currency string
+	err := tx.Model(model).Unscoped().Where("id = ?", id).Select("currency").Row().Scan(&currency)
+	if err != nil {
+		return "", fmt.Errorf("%T %s: %w", model, id, err)
+	}
+	return currency, nil
+}
+
+// transactionHolding returns the goal holding a transaction moved money in
+// or out of, if any
+func transactionHolding(tx *gorm.DB, transactionID uuid.UUID) (*uuid.UUID, error) {
+	var contribution GoalContribution
+	err := tx.Unscoped().Where("transaction_id = ? AND holding_id IS NOT NULL", transactionID).Limit(1).Find(&contribution).Error
+	if err != nil {
+		return nil, err
+	}
+	return contribution.HoldingID, nil
+}
+
+// transactionEntry builds the entry recording the transaction
+func (t *Transaction) transactionEntry(tx *gorm.DB) (*JournalEntry, error) {
+	postings, err := t.journalPostings(tx)
+	if err != nil {
+		return nil, err
+	}
+	return &JournalEntry{
+		UserID:        t.UserID,
+		TransactionID: &t.ID,
+		Kind:          JournalKindTransaction,
+		Date:          t.Date,
+		Description:   t.Description,
+		Postings:      postings,
+	}, nil
+}
+
+// reversalEntry builds an entry that cancels everything still booked for the
+// transaction, so that it nets to zero afterwards
+func (t *Transaction) reversalEntry(tx *gorm.DB) (*JournalEntry, error) {
+	var rows []struct {
+		LedgerType string
+		LedgerID   *uuid.UUID
+		LedgerKey  string
+		Currency   string
+		Total      Money
+	}
+	err := tx.Model(&Posting{}).
+		Select("postings.ledger_type, postings.ledger_id, postings.ledger_key, postings.currency, SUM(postings.amount) AS total").
+		Joins("JOIN journal_entries ON journal_entries.id = postings.entry_id").
+		Where("journal_entries.transaction_id = ?", t.ID).
+		Group("postings.ledger_type, postings.ledger_id, postings.ledger_key, postings.currency").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var b postingBuilder
+	for _, row := range rows {
+		b.add(row.LedgerType, row.LedgerID, row.LedgerKey, -row.Total, row.Currency)
+	}
+	return &JournalEntry{
+		UserID:        t.UserID,
+		TransactionID: &t.ID,
+		Kind:          JournalKindReversal,
+		Date:          t.Date,
+		Description:   "Reversal: " + t.Description,
+		Postings:      b,
+	}, nil
+}
+
+// BackfillJournal records entries for transactions created before the
+// journal existed, then books any remaining difference between the cached
+// balances and the journal as an adjustment so that the two agree. Cached
+// balances are left unchanged. Transactions whose account no longer exists
+// are skipped and logged. It returns the number of entries recorded.
+func BackfillJournal(db *gorm.DB) (int, error) {
+	recorded := 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var transactions []Transaction
+		result := tx.Where("type != ? AND NOT EXISTS (SELECT 1 FROM journal_entries WHERE journal_entries.transaction_id = transactions.id)", "tracking").
+			FindInBatches(&transactions, 500, func(batch *gorm.DB, _ int) error {
+				for i := range transactions {
+					// The backfill runs before splits existed, and before
+					// their table does, so never look them up
+					transactions[i].Splits = []TransactionSplit{}
+					entry, err := transactions[i].transactionEntry(tx)
+					if err != nil {
+						log.Printf("Skipping journal backfill of transaction %s: %v", transactions[i].ID, err)
+						continue
+					}
+					if len(entry.Postings) == 0 {
+						continue
+					}
+					if err := RecordJournalEntry(tx, entry); err != nil {
+						return err
+					}
+					recorded++
+				}
+				return nil
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		adjustments, err := balanceAdjustments(tx)
+		if err != nil {
+			return err
+		}
+		for i := range adjustments {
+			if err := RecordJournalEntry(tx, &adjustments[i]); err != nil {
+				return err
+			}
+		}
+		recorded += len(adjustments)
+		return nil
+	})
+	return recorded, err
+}
+
+// BalanceDrift is a cached balance that differs from the journal
+type BalanceDrift struct {
+	LedgerType string    `json:"ledgerType"` // account or credit_card
+	LedgerID   uuid.UUID `json:"ledgerId"`
+	UserID     uuid.UUID `json:"userId"`
+	Name       string    `json:"name"`
+	Currency   string    `json:"currency"`
+	Cached     Money     `json:"cached"`  // Account.Balance or CreditCard.CurrentBalance
+	Journal    Money     `json:"journal"` // The same balance derived from postings
+}
+
+// FindBalanceDrift compares the cached balance of every account and credit
+// card with the balance derived from its postings in its own currency. Pass
+// uuid.Nil to check all users.
+func FindBalanceDrift(db *gorm.DB, userID uuid.UUID) ([]BalanceDrift, error) {
+	var drifts []BalanceDrift
+
+	accounts := db.Table("accounts").
+		Select("accounts.id AS ledger_id, accounts.user_id, accounts.name, accounts.currency, accounts.balance AS cached, COALESCE(SUM(postings.amount), 0) AS journal").
+		Joins("LEFT JOIN postings ON postings.ledger_type = ? AND postings.ledger_id = accounts.id AND postings.currency = accounts.currency", LedgerAccount).
+		Where("accounts.deleted_at IS NULL")
+	if userID != uuid.Nil {
+		accounts = accounts.Where("accounts.user_id = ?", userID)
+	}
+	var accountRows []BalanceDrift
+	if err := accounts.Group("accounts.id").Having("accounts.balance <> COALESCE(SUM(postings.amount), 0)").Scan(&accountRows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range accountRows {
+		row.LedgerType = LedgerAccount
+		drifts = append(drifts, row)
+	}
+
+	// Card postings are credits, so the amount owed is minus their sum
+	cards := db.Table("credit_cards").
+		Select("credit_cards.id AS ledger_id, credit_cards.user_id, credit_cards.name, credit_cards.currency, credit_cards.current_balance AS cached, -COALESCE(SUM(postings.amount), 0) AS journal").
+		Joins("LEFT JOIN postings ON postings.ledger_type = ? AND postings.ledger_id = credit_cards.id AND postings.currency = credit_cards.currency", LedgerCreditCard).
+		Where("credit_cards.deleted_at IS NULL")
+	if userID != uuid.Nil {
+		cards = cards.Where("credit_cards.user_id = ?", userID)
+	}
+	var cardRows []BalanceDrift
+	if err := cards.Group("credit_cards.id").Having("credit_cards.current_balance <> -COALESCE(SUM(postings.amount), 0)").Scan(&cardRows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range cardRows {
+		row.LedgerType = LedgerCreditCard
+		drifts = append(drifts, row)
+	}
+
+	return drifts, nil
+}
+
+// balanceAdjustments builds one adjustment entry per drifted balance that
+// brings the journal in line with the cached balance
+func balanceAdjustments(tx *gorm.DB) ([]JournalEntry, error) {
+	drifts, err := FindBalanceDrift(tx, uuid.Nil)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	entries := make([]JournalEntry, 0, len(drifts))
+	for _, drift := range drifts {
+		id := drift.LedgerID
+		difference := drift.Cached - drift.Journal
+		if drift.LedgerType == LedgerCreditCard {
+			difference = -difference
+		}
+
+		var b postingBuilder
+		b.add(drift.LedgerType, &id, "", difference, drift.Currency)
+		b.add(LedgerEquity, nil, EquityAdjustment, -difference, drift.Currency)
+		entries = append(entries, JournalEntry{
+			UserID:      drift.UserID,
+			Kind:        JournalKindAdjustment,
+			Date:        now,
+			Description: "Balance difference found when creating the journal: " + drift.Name,
+			Postings:    b,
+		})
+	}
+	return entries, nil
+}
+
+// AdjustCreditCardBalance books a change in what is owed on a card against
+// equity, for an opening balance or a manual correction
+func AdjustCreditCardBalance(tx *gorm.DB, card *CreditCard, delta Money, kind, description string) error {
+	key := EquityAdjustment
+	if kind == JournalKindOpening {
+		key = EquityOpeningBalance
+	}
+
+	var b postingBuilder
+	b.add(LedgerCreditCard, &card.ID, "", -delta, card.Currency)
+	b.add(LedgerEquity, nil, key, delta, card.Currency)
+	return PostJournalEntry(tx, &JournalEntry{
+		UserID:      card.UserID,
+		Kind:        kind,
+		Date:        time.Now(),
+		Description: description,
+		Postings:    b,
+	})
+}
+
+// RebookJournal replaces what the journal holds for the transaction with an
+// entry built from its current fields, or with nothing if it was deleted.
+// Cached balances are not changed; callers correct them separately.
+func (t *Transaction) RebookJournal(tx *gorm.DB) error {
+	reversal, err := t.reversalEntry(tx)
+	if err != nil {
+		return err
+	}
+	if err := RecordJournalEntry(tx, reversal); err != nil {
+		return err
+	}
+	if t.DeletedAt.Valid {
+		return nil
+	}
+
+	entry, err := t.transactionEntry(tx)
+	if err != nil {
+		return err
+	}
+	return RecordJournalEntry(tx, entry)
+}
+
+// RecordLedgerAdjustment books a correction of difference on one ledger
+// against Equity:adjustment without touching cached balances
+func RecordLedgerAdjustment(tx *gorm.DB, userID uuid.UUID, ledgerType string, ledgerID uuid.UUID, difference Money, currency, description string) error {
+	var b postingBuilder
+	b.add(ledgerType, &ledgerID, "", difference, currency)
+	b.add(LedgerEquity, nil, EquityAdjustment, -difference, currency)
+	return RecordJournalEntry(tx, &JournalEntry{
+		UserID:      userID,
+		Kind:        JournalKindAdjustment,
+		Date:        time.Now(),
+		Description: description,
+		Postings:    b,
+	})
+}
@@ -0,0 +1,300 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Money is an exact fixed-point amount stored as an integer number of
+// ten-thousandths. Four decimal places cover the minor units of every ISO 4217
+// currency, so sums, differences and comparisons never pick up float noise.
+// In JSON it is a plain number (or a numeric string on input) and in the
+// database a numeric(19,4) column.
+type Money int64
+
+// MoneyScale is the number of decimal places Money keeps
+const MoneyScale = 4
+
+const moneyFactor = 10000
+
+// currencyMinorUnits lists currencies whose minor unit is not two decimals
+var currencyMinorUnits = map[string]int{
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+}
+
+// MinorUnits returns the number of decimal places used by the currency
+func MinorUnits(currency string) int {
+	if units, ok := currencyMinorUnits[strings.ToUpper(currency)]; ok {
+		return units
+	}
+	return 2
+}
+
+// NewMoneyFromFloat converts a float to Money, rounding half away from zero
+// to four decimal places
+func NewMoneyFromFloat(f float64) Money {
+	return Money(math.Round(f * moneyFactor))
+}
+
+// ParseMoney parses a decimal string such as "-1234.5" exactly. Digits beyond
+// four decimal places are rounded half away from zero.
+func ParseMoney(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+
+	negative := false
+	switch s[0] {
+	case '-':
+		negative = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	whole, fraction, _ := strings.Cut(s, ".")
+	if whole == "" && fraction == "" {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	for _, r := range whole + fraction {
+		if r < '0' || r > '9' {
+			return parseMoneyExponent(s, negative)
+		}
+	}
+
+	roundUp := false
+	if len(fraction) > MoneyScale {
+		roundUp = fraction[MoneyScale] >= '5'
+		fraction = fraction[:MoneyScale]
+	}
+	fraction += strings.Repeat("0", MoneyScale-len(fraction))
+
+	units, err := strconv.ParseInt(whole+fraction, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("amount %q out of range", s)
+	}
+	if roundUp {
+		if units == math.MaxInt64 {
+			return 0, fmt.Errorf("amount %q out of range", s)
+		}
+		units++
+	}
+	if negative {
+		units = -units
+	}
+
+	return Money(units), nil
+}
+
+// parseMoneyExponent parses an unsigned amount in exponent notation such as
+// 1e3. Only digits, a point and an exponent are accepted, so a second sign,
+// NaN, Inf and hexadecimal floats are refused, as are amounts too large for
+// Money.
+func parseMoneyExponent(s string, negative bool) (Money, error) {
+	if s == "" || (s[0] != '.' && (s[0] < '0' || s[0] > '9')) {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	for _, r := range s {
+		if (r < '0' || r > '9') && r != '.' && r != 'e' && r != 'E' && r != '+' && r != '-' {
+			return 0, fmt.Errorf("invalid amount %q", s)
+		}
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	// float64(math.MaxInt64) rounds up to 2^63, which is already too large
+	units := math.Round(f * moneyFactor)
+	if math.IsNaN(units) || math.IsInf(units, 0) || units >= float64(math.MaxInt64) {
+		return 0, fmt.Errorf("amount %q out of range", s)
+	}
+	if negative {
+		units = -units
+	}
+	return Money(units), nil
+}
+
+// Float64 returns the amount as a float for ratios and display only
+func (m Money) Float64() float64 {
+	return float64(m) / moneyFactor
+}
+
+// Abs returns the absolute amount
+func (m Money) Abs() Money {
+	if m < 0 {
+		return -m
+	}
+	return m
+}
+
+// Mul multiplies the amount by a factor such as a quantity or rate and
+// rounds the result to four decimal places
+func (m Money) Mul(factor float64) Money {
+	return NewMoneyFromFloat(m.Float64() * factor)
+}
+
+// Div divides the amount into n parts, rounding half away from zero
+func (m Money) Div(n int64) Money {
+	if n == 0 {
+		return 0
+	}
+	negative := (m < 0) != (n < 0)
+	dividend, divisor := int64(m.Abs()), n
+	if divisor < 0 {
+		divisor = -divisor
+	}
+
+	quotient := dividend / divisor
+	if (dividend%divisor)*2 >= divisor {
+		quotient++
+	}
+	if negative {
+		quotient = -quotient
+	}
+	return Money(quotient)
+}
+
+// Ratio returns m / other as a float, or 0 when other is zero
+func (m Money) Ratio(other Money) float64 {
+	if other == 0 {
+		return 0
+	}
+	return float64(m) / float64(other)
+}
+
+// Round rounds the amount to the currency's minor units, half away from zero
+func (m Money) Round(currency string) Money {
+	step := pow10(MoneyScale - MinorUnits(currency))
+	return m.Div(step) * Money(step)
+}
+
+// IsZero reports whether the amount is zero
+func (m Money) IsZero() bool {
+	return m == 0
+}
+
+// Minor returns the amount in the currency's minor units, e.g. cents
+func (m Money) Minor(currency string) int64 {
+	return int64(m.Round(currency)) / pow10(MoneyScale-MinorUnits(currency))
+}
+
+// String formats the amount with at least two decimal places, e.g. "12.50"
+// or "0.0125"
+func (m Money) String() string {
+	units := int64(m)
+	sign := ""
+	if units < 0 {
+		sign = "-"
+		units = -units
+	}
+
+	fraction := fmt.Sprintf("%04d", units%moneyFactor)
+	fraction = strings.TrimRight(fraction, "0")
+	for len(fraction) < 2 {
+		fraction += "0"
+	}
+
+	return fmt.Sprintf("%s%d.%s", sign, units/moneyFactor, fraction)
+}
+
+// StringFixed formats the amount with the currency's minor units, e.g.
+// "12.50" for USD and "1250" for JPY
+func (m Money) StringFixed(currency string) string {
+	units := int64(m.Round(currency))
+	sign := ""
+	if units < 0 {
+		sign = "-"
+		units = -units
+	}
+
+	decimals := MinorUnits(currency)
+	whole := units / moneyFactor
+	if decimals == 0 {
+		return fmt.Sprintf("%s%d", sign, whole)
+	}
+	fraction := fmt.Sprintf("%04d", units%moneyFactor)[:decimals]
+	return fmt.Sprintf("%s%d.%s", sign, whole, fraction)
+}
+
+// MarshalJSON encodes the amount as a JSON number without float rounding
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or a numeric string
+func (m *Money) UnmarshalJSON(data []byte) error {
+	text := strings.TrimSpace(string(data))
+	if text == "null" {
+		return nil
+	}
+	if strings.HasPrefix(text, "\"") {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		text = s
+	}
+
+	parsed, err := ParseMoney(text)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// GormDataType stores Money as an exact numeric column
+func (Money) GormDataType() string {
+	return "numeric(19,4)"
+}
+
+// Scan implements sql.Scanner
+func (m *Money) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*m = 0
+	case []byte:
+		parsed, err := ParseMoney(string(v))
+		if err != nil {
+			return err
+		}
+		*m = parsed
+	case string:
+		parsed, err := ParseMoney(v)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+	case float64:
+		*m = NewMoneyFromFloat(v)
+	case int64:
+		*m = Money(v * moneyFactor)
+	default:
+		return fmt.Errorf("cannot scan %T into Money", value)
+	}
+	return nil
+}
+
+// Value implements driver.Valuer
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+func pow10(n int) int64 {
+	result := int64(1)
+	for i := 0; i < n; i++ {
+		result *= 10
+	}
+	return result
+}
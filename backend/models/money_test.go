@@ -0,0 +1,124 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Money
+		wantErr bool
+	}{
+		{"0", 0, false},
+		{"12", 120000, false},
+		{"12.5", 125000, false},
+		{"-1234.5", -12345000, false},
+		{"+7.25", 72500, false},
+		{" 3.1 ", 31000, false},
+		{".5", 5000, false},
+		{"5.", 50000, false},
+		{"0.00005", 1, false}, // Rounds half away from zero
+		{"0.00004", 0, false},
+		{"-0.00005", -1, false},
+		{"1.99995", 20000, false},
+		{"922337203685477.5807", Money(1<<63 - 1), false},
+		{"922337203685477.58075", 0, true}, // Rounding up overflows
+		{"922337203685477.5808", 0, true},
+		{"1e3", 10000000, false},
+		{"1.5E2", 1500000, false},
+		{"-2.5e-1", -2500, false},
+		{"1e30", 0, true},
+		{"1e15", 0, true},
+		{"", 0, true},
+		{"-", 0, true},
+		{".", 0, true},
+		{"--5", 0, true},
+		{"-+5", 0, true},
+		{"+-5", 0, true},
+		{"1,000", 0, true},
+		{"12abc", 0, true},
+		{"NaN", 0, true},
+		{"-NaN", 0, true},
+		{"Inf", 0, true},
+		{"+Inf", 0, true},
+		{"-Infinity", 0, true},
+		{"0x1p4", 0, true},
+		{"1_000", 0, true},
+		{"e5", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseMoney(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseMoney(%q) = %v, want an error", tt.input, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseMoney(%q) error: %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseMoney(%q) = %d, want %d", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Money
+		wantErr bool
+	}{
+		{`12.34`, 123400, false},
+		{`"12.34"`, 123400, false},
+		{`-0.1`, -1000, false},
+		{`"-0.1"`, -1000, false},
+		{`1e2`, 1000000, false},
+		{`null`, 0, false},
+		{`""`, 0, true},
+		{`"NaN"`, 0, true},
+		{`"Infinity"`, 0, true},
+		{`"--5"`, 0, true},
+		{`1e30`, 0, true},
+		{`"12`, 0, true},
+		{`true`, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			var got Money
+			err := json.Unmarshal([]byte(tt.input), &got)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Unmarshal(%s) = %v, want an error", tt.input, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unmarshal(%s) error: %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Errorf("Unmarshal(%s) = %d, want %d", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyJSONRoundTrip(t *testing.T) {
+	for _, m := range []Money{0, 1, -1, 125000, -12345678, Money(1<<63 - 1)} {
+		data, err := json.Marshal(m)
+		if err != nil {
+			t.Fatalf("Marshal(%d) error: %v", m, err)
+		}
+		var got Money
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("Unmarshal(%s) error: %v", data, err)
+		}
+		if got != m {
+			t.Errorf("round trip of %d gave %d via %s", m, got, data)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Kinds and sources of net worth lines
+const (
+	NetWorthAsset     = "asset"
+	NetWorthLiability = "liability"
+
+	NetWorthSourceAccount    = "account"
+	NetWorthSourceCreditCard = "credit_card"
+	NetWorthSourceHolding    = "holding"
+)
+
+// NetWorthLine totals the assets or liabilities of one account type, the
+// credit cards or one goal holding type
+type NetWorthLine struct {
+	Kind   string `json:"kind"`   // asset, liability
+	Source string `json:"source"` // account, credit_card, holding
+	Type   string `json:"type"`   // Account type or holding type; credit_card for cards
+	Amount Money  `json:"amount"` // In the snapshot's currency; liabilities are positive
+	Count  int    `json:"count"`
+}
+
+// NetWorthSnapshot is the user's net worth at the end of a day, stored so
+// that the series can be charted without reconstructing it
+type NetWorthSnapshot struct {
+	ID          uuid.UUID      `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	UserID      uuid.UUID      `gorm:"type:uuid;not null;index;uniqueIndex:idx_net_worth_snapshot,priority:1" json:"userId"`
+	Date        time.Time      `gorm:"type:date;not null;uniqueIndex:idx_net_worth_snapshot,priority:2" json:"date"` // Day in the user's time zone
+	Currency    string         `gorm:"not null" json:"currency"`                                                     // The user's currency when the snapshot was taken
+	Assets      Money          `gorm:"not null" json:"assets"`
+	Liabilities Money          `gorm:"not null" json:"liabilities"`
+	NetWorth    Money          `gorm:"not null" json:"netWorth"`
+	Breakdown   []NetWorthLine `gorm:"type:jsonb;serializer:json" json:"breakdown"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	UpdatedAt   time.Time      `json:"updatedAt"` // When the snapshot was last retaken
+}
+
+func (s *NetWorthSnapshot) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Notification types
+const (
+	NotificationBudgetAlert  = "budget_alert"  // A budget reached its alert threshold or was exceeded
+	NotificationBillReminder = "bill_reminder" // A bill is due within its reminder days, overdue or failed to autopay
+	NotificationLowBalance   = "low_balance"   // An account fell below its minimum balance
+	NotificationMaturity     = "maturity"      // A fixed deposit or other holding matures soon
+	NotificationTest         = "test"          // Sent on request to check the delivery channels
+)
+
+// DefaultMaturityReminderDays is how many days before maturity holdings are
+// notified when the user has not chosen
+const DefaultMaturityReminderDays = 7
+
+// Notification delivery statuses
+const (
+	DeliverySent    = "sent"
+	DeliveryFailed  = "failed"
+	DeliverySkipped = "skipped" // The channel is not configured
+)
+
+// Notification is a message in a user's in-app inbox
+type Notification struct {
+	ID        uuid.UUID      `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	UserID    uuid.UUID      `gorm:"type:uuid;not null;index;uniqueIndex:idx_notification_user_key,priority:1" json:"userId"`
+	Type      string         `gorm:"not null;index" json:"type"`
+	Title     string         `gorm:"not null" json:"title"`
+	Message   string         `json:"message"`
+	SourceID  *uuid.UUID     `gorm:"type:uuid" json:"sourceId"`                                          // The budget, bill, account or holding it is about
+	Key       string         `gorm:"not null;uniqueIndex:idx_notification_user_key,priority:2" json:"-"` // Identifies the condition so it is notified once
+	Read      bool           `gorm:"default:false;index" json:"read"`
+	ReadAt    *time.Time     `json:"readAt"`
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (n *Notification) BeforeCreate(tx *gorm.DB) error {
+	if n.ID == uuid.Nil {
+		n.ID = uuid.New()
+	}
+	return nil
+}
+
+// NotificationDelivery records sending a notification through a channel
+type NotificationDelivery struct {
+	ID             uuid.UUID      `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	NotificationID uuid.UUID      `gorm:"type:uuid;not null;index" json:"notificationId"`
+	Channel        string         `gorm:"not null" json:"channel"` // email, webhook
+	Status         string         `gorm:"not null" json:"status"`  // sent, failed, skipped
+	Error          string         `json:"error"`
+	CreatedAt      time.Time      `json:"createdAt"`
+	UpdatedAt      time.Time      `json:"updatedAt"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (d *NotificationDelivery) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == uuid.Nil {
+		d.ID = uuid.New()
+	}
+	return nil
+}
@@ -22,9 +22,9 @@ type Reconciliation struct {
 	UserID             uuid.UUID            `gorm:"type:uuid;not null;index" json:"userId"`
 	AccountID          uuid.UUID            `gorm:"type:uuid;not null;index" json:"accountId"`
 	ReconciliationDate time.Time            `gorm:"not null" json:"reconciliationDate" binding:"required"`
-	StatementBalance   float64              `gorm:"type:decimal(15,2);not null" json:"statementBalance" binding:"required"`
-	BookBalance        float64              `gorm:"type:decimal(15,2);not null" json:"bookBalance"`
-	Difference         float64              `gorm:"type:decimal(15,2);not null" json:"difference"`
+	StatementBalance   Money                `gorm:"not null" json:"statementBalance" binding:"required"`
+	BookBalance        Money                `gorm:"not null" json:"bookBalance"`
+	Difference         Money                `gorm:"not null" json:"difference"`
 	Notes              string               `gorm:"type:text" json:"notes"`
 	Status             ReconciliationStatus `gorm:"type:varchar(20);default:'pending'" json:"status"`
 	CreatedAt          time.Time            `json:"createdAt"`
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Reward types
+const (
+	RewardCashback = "cashback" // Earned in the card's currency
+	RewardPoints   = "points"
+	RewardMiles    = "miles"
+)
+
+// Reward redemption methods
+const (
+	RedeemStatementCredit = "statement_credit" // Credited to the card
+	RedeemAccount         = "account"          // Paid into an account as income
+)
+
+// Reward cap periods
+const (
+	CapMonthly   = "monthly"
+	CapQuarterly = "quarterly"
+	CapYearly    = "yearly"
+)
+
+// IsValidRewardType reports whether rewardType is a supported reward type
+func IsValidRewardType(rewardType string) bool {
+	return rewardType == RewardCashback || rewardType == RewardPoints || rewardType == RewardMiles
+}
+
+// IsValidCapPeriod reports whether period is a supported cap period
+func IsValidCapPeriod(period string) bool {
+	return period == CapMonthly || period == CapQuarterly || period == CapYearly
+}
+
+// RewardRule boosts the rewards a card earns on purchases in a category
+type RewardRule struct {
+	ID          uuid.UUID      `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	UserID      uuid.UUID      `gorm:"type:uuid;not null;index" json:"userId"`
+	CardID      uuid.UUID      `gorm:"type:uuid;not null;index" json:"cardId"`
+	CategoryID  string         `gorm:"not null" json:"categoryId" binding:"required"` // Category key; covers its subcategories too
+	Multiplier  float64        `gorm:"not null" json:"multiplier" binding:"gt=0"`     // Applied to the card's RewardRate
+	Cap         Money          `json:"cap" binding:"min=0"`                           // Most reward earned under the rule per CapPeriod; 0 for no cap
+	CapPeriod   string         `json:"capPeriod"`                                     // monthly (default), quarterly, yearly
+	Description string         `json:"description"`
+	Active      bool           `gorm:"default:true" json:"active"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	UpdatedAt   time.Time      `json:"updatedAt"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (r *RewardRule) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
+
+// CapPeriodBounds returns the start and end of the cap period containing date
+func (r *RewardRule) CapPeriodBounds(date time.Time) (time.Time, time.Time) {
+	date = date.UTC()
+	switch r.CapPeriod {
+	case CapYearly:
+		start := time.Date(date.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(1, 0, 0)
+	case CapQuarterly:
+		month := date.Month() - (date.Month()-1)%3
+		start := time.Date(date.Year(), month, 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 3, 0)
+	}
+	start := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(0, 1, 0)
+}
+
+// RewardRedemption records rewards turned into money
+type RewardRedemption struct {
+	ID            uuid.UUID      `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	UserID        uuid.UUID      `gorm:"type:uuid;not null;index" json:"userId"`
+	CardID        uuid.UUID      `gorm:"type:uuid;not null;index" json:"cardId"`
+	Type          string         `gorm:"not null" json:"type"`   // cashback, points, miles
+	Amount        Money          `gorm:"not null" json:"amount"` // Rewards redeemed
+	Value         Money          `gorm:"not null" json:"value"`  // Money credited, in the card's or account's currency
+	Method        string         `gorm:"not null" json:"method"` // statement_credit, account
+	AccountID     *uuid.UUID     `gorm:"type:uuid" json:"accountId"`
+	TransactionID *uuid.UUID     `gorm:"type:uuid;index" json:"transactionId"` // Transaction that credited the value
+	Description   string         `json:"description"`
+	RedeemedAt    time.Time      `gorm:"not null" json:"redeemedAt"`
+	CreatedAt     time.Time      `json:"createdAt"`
+	UpdatedAt     time.Time      `json:"updatedAt"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (r *RewardRedemption) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
+
+// RoundReward rounds an earned reward: cashback to the card currency's minor
+// units, points and miles to whole units
+func RoundReward(amount Money, rewardType, currency string) Money {
+	if rewardType == RewardCashback {
+		return amount.Round(currency)
+	}
+	return amount.Div(moneyFactor) * moneyFactor
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TransactionRule categorizes, tags and renames transactions as they are
+// created or imported. Every condition that is set must match; a rule
+// without conditions matches every transaction. Rules run in ascending
+// priority and each sees the changes made by the rules before it.
+type TransactionRule struct {
+	ID             uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	UserID         uuid.UUID `gorm:"type:uuid;not null;index" json:"userId"`
+	Name           string    `gorm:"not null" json:"name" binding:"required"`
+	Priority       int       `gorm:"not null;default:0" json:"priority"`
+	Active         bool      `gorm:"default:true" json:"active"`
+	StopProcessing bool      `gorm:"default:false" json:"stopProcessing"` // Later rules are skipped once this one matches
+
+	// Conditions
+	DescriptionPattern string     `json:"descriptionPattern"` // Case-insensitive regular expression matched against the description or card merchant
+	MinAmount          *Money     `json:"minAmount"`
+	MaxAmount          *Money     `json:"maxAmount"`
+	AccountID          *uuid.UUID `gorm:"type:uuid" json:"accountId"` // Account or credit card
+	Type               string     `json:"type"`                       // income, expense, transfer; empty for any
+
+	// Actions
+	SetCategoryID     string     `json:"setCategoryId"`                          // Only applied to transactions of the category's kind
+	Tags              []string   `gorm:"type:jsonb;serializer:json" json:"tags"` // Added to the transaction's tags
+	SetDescription    string     `json:"setDescription"`                         // May refer to pattern groups as $1 or ${name}
+	TransferAccountID *uuid.UUID `gorm:"type:uuid" json:"transferAccountId"`     // Turns an income or expense into a transfer with this account
+
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (r *TransactionRule) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
+
+// MarkAsTransfer turns an income or expense on an account into a transfer
+// with another account: money spent goes to the other account and money
+// received comes from it. Amount stays in the currency of the transaction's
+// own account, so for money received it becomes ToAmount; call
+// ResolveMarkedTransferAmounts to fill in the other side.
+func (t *Transaction) MarkAsTransfer(accountID uuid.UUID) {
+	t.ToAmount = nil
+	if t.Type == "income" {
+		ownAccount, received := t.AccountID, t.Amount
+		t.AccountID = accountID
+		t.ToAccountID = &ownAccount
+		t.ToAmount = &received
+	} else {
+		t.ToAccountID = &accountID
+	}
+	t.Type = "transfer"
+	t.CategoryID = CategoryTransfer
+	t.ExchangeRate = nil
+}
+
+// ResolveMarkedTransferAmounts is ResolveTransferAmounts for a transaction
+// MarkAsTransfer turned into a transfer. Money received keeps its amount as
+// ToAmount, and the amount sent is converted back from it at the user's rate
+// on the transaction's date.
+func (t *Transaction) ResolveMarkedTransferAmounts(db *gorm.DB, fromCurrency, toCurrency string) error {
+	if t.ToAmount != nil && !strings.EqualFold(fromCurrency, toCurrency) {
+		converter, err := NewCurrencyConverter(db, t.UserID)
+		if err != nil {
+			return err
+		}
+		sent, err := converter.Convert(*t.ToAmount, toCurrency, fromCurrency, t.Date)
+		if err != nil {
+			return err
+		}
+		t.Amount = sent
+	}
+	return t.ResolveTransferAmounts(db, fromCurrency, toCurrency)
+}
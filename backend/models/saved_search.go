@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TransactionSearch describes which transactions to find and how to order
+// them. Every field that is set must match; an empty search finds every
+// transaction.
+type TransactionSearch struct {
+	Text            string      `json:"text"`        // Words matched against descriptions, card merchants and split memos; each word also matches as a prefix
+	Types           []string    `json:"types"`       // income, expense, transfer
+	CategoryIDs     []string    `json:"categoryIds"` // A category also matches its subcategories and the splits in them
+	AccountIDs      []uuid.UUID `json:"accountIds"`  // Source or destination account, or credit card
+	MinAmount       *Money      `json:"minAmount"`
+	MaxAmount       *Money      `json:"maxAmount"`
+	StartDate       string      `json:"startDate"` // YYYY-MM-DD in the user's time zone
+	EndDate         string      `json:"endDate"`   // YYYY-MM-DD in the user's time zone, inclusive
+	AllTags         []string    `json:"allTags"`   // Transactions carrying every one of these tags
+	AnyTags         []string    `json:"anyTags"`   // Transactions carrying at least one of these tags
+	NoTags          []string    `json:"noTags"`    // Transactions carrying none of these tags
+	Reconciled      *bool       `json:"reconciled"`
+	HasAttachments  *bool       `json:"hasAttachments"`
+	Source          string      `json:"source"` // account, credit_card; empty for both
+	IncludeTracking bool        `json:"includeTracking"`
+	Sort            string      `json:"sort"`  // date, amount, description, relevance; defaults to date, or relevance with text
+	Order           string      `json:"order"` // asc, desc; defaults to desc, or asc when sorting by description
+}
+
+// SavedSearch is a transaction search the user has named to run again
+type SavedSearch struct {
+	ID        uuid.UUID         `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	UserID    uuid.UUID         `gorm:"type:uuid;not null;index" json:"userId"`
+	Name      string            `gorm:"not null" json:"name" binding:"required"`
+	Search    TransactionSearch `gorm:"type:jsonb;serializer:json" json:"search"`
+	CreatedAt time.Time         `json:"createdAt"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+	DeletedAt gorm.DeletedAt    `gorm:"index" json:"-"`
+}
+
+func (s *SavedSearch) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return nil
+}
@@ -12,17 +12,17 @@ type SavingsGoal struct {
 	UserID               uuid.UUID      `gorm:"type:uuid;not null;index" json:"userId"`
 	Name                 string         `gorm:"not null" json:"name" binding:"required"`
 	Description          string         `json:"description"`
-	TargetAmount         float64        `gorm:"not null" json:"targetAmount" binding:"required,gt=0"`
-	CurrentAmount        float64        `gorm:"default:0" json:"currentAmount"`
+	TargetAmount         Money          `gorm:"not null" json:"targetAmount" binding:"required,gt=0"`
+	CurrentAmount        Money          `gorm:"default:0" json:"currentAmount"`
 	TargetDate           *time.Time     `json:"targetDate"`
-	MonthlyContribution  float64        `gorm:"default:0" json:"monthlyContribution"`
+	MonthlyContribution  Money          `gorm:"default:0" json:"monthlyContribution"`
 	Category             string         `json:"category"` // emergency, vacation, purchase, etc
 	Priority             string         `json:"priority"` // high, medium, low
 	Achieved             bool           `gorm:"default:false" json:"achieved"`
 	AchievedDate         *time.Time     `json:"achievedDate"`
 	Archived             bool           `gorm:"default:false" json:"archived"`
 	ArchivedDate         *time.Time     `json:"archivedDate"`
-	LastContribution     float64        `gorm:"default:0" json:"lastContribution"`
+	LastContribution     Money          `gorm:"default:0" json:"lastContribution"`
 	LastContributionDate *time.Time     `json:"lastContributionDate"`
 	Attachments          []string       `gorm:"type:text[]" json:"attachments"`
 	CreatedAt            time.Time      `json:"createdAt"`
@@ -41,7 +41,7 @@ type SavingsContribution struct {
 	ID          uuid.UUID      `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
 	UserID      uuid.UUID      `gorm:"type:uuid;not null;index" json:"userId"`
 	GoalID      uuid.UUID      `gorm:"type:uuid;not null;index" json:"goalId"`
-	Amount      float64        `gorm:"not null" json:"amount" binding:"required,gt=0"`
+	Amount      Money          `gorm:"not null" json:"amount" binding:"required,gt=0"`
 	Date        time.Time      `gorm:"not null;index" json:"date"`
 	Notes       string         `json:"notes"`
 	Attachments []string       `gorm:"type:text[]" json:"attachments"`
@@ -62,7 +62,7 @@ type AutomatedRule struct {
 	UserID     uuid.UUID      `gorm:"type:uuid;not null;index" json:"userId"`
 	GoalID     uuid.UUID      `gorm:"type:uuid;not null;index" json:"goalId"`
 	RuleType   string         `gorm:"not null" json:"ruleType"` // percentage_of_income, fixed_amount, round_up
-	Amount     float64        `json:"amount"`
+	Amount     Money          `json:"amount"`
 	Percentage float64        `json:"percentage"`
 	Frequency  string         `json:"frequency"` // daily, weekly, monthly
 	Enabled    bool           `gorm:"default:true" json:"enabled"`
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,8 +14,9 @@ type Settings struct {
 	Currency       string         `gorm:"default:'BDT'" json:"currency"`
 	DarkMode       bool           `gorm:"default:false" json:"darkMode"`
 	DateFormat     string         `gorm:"default:'MM/DD/YYYY'" json:"dateFormat"`
-	FirstDayOfWeek int            `gorm:"default:0" json:"firstDayOfWeek"` // 0 = Sunday
+	FirstDayOfWeek int            `gorm:"default:0" json:"firstDayOfWeek" binding:"min=0,max=6"` // 0 = Sunday
 	Language       string         `gorm:"default:'en'" json:"language"`
+	TimeZone       string         `gorm:"default:'UTC'" json:"timeZone"` // IANA name, such as Asia/Dhaka; days and budget periods start at midnight here
 	Notifications  *Notifications `gorm:"embedded;embeddedPrefix:notif_" json:"notifications"`
 	CreatedAt      time.Time      `json:"createdAt"`
 	UpdatedAt      time.Time      `json:"updatedAt"`
@@ -22,10 +24,28 @@ type Settings struct {
 }
 
 type Notifications struct {
-	Push          bool `json:"push"`
-	Email         bool `json:"email"`
-	BudgetAlerts  bool `json:"budgetAlerts"`
-	BillReminders bool `json:"billReminders"`
+	Push                 bool   `json:"push"`  // Deliver to WebhookURL
+	Email                bool   `json:"email"` // Deliver to the user's email address
+	BudgetAlerts         bool   `json:"budgetAlerts"`
+	BillReminders        bool   `json:"billReminders"`
+	LowBalanceAlerts     bool   `json:"lowBalanceAlerts"`                     // Accounts below their MinimumBalance
+	MaturityAlerts       bool   `json:"maturityAlerts"`                       // Fixed deposits and other holdings about to mature
+	MaturityReminderDays int    `json:"maturityReminderDays" binding:"min=0"` // Days ahead of maturity to remind; 0 uses DefaultMaturityReminderDays
+	WebhookURL           string `json:"webhookUrl"`
+}
+
+// DefaultNotificationSettings returns the notification settings of users who
+// have not changed them: everything on
+func DefaultNotificationSettings() *Notifications {
+	return &Notifications{
+		Push:                 true,
+		Email:                true,
+		BudgetAlerts:         true,
+		BillReminders:        true,
+		LowBalanceAlerts:     true,
+		MaturityAlerts:       true,
+		MaturityReminderDays: DefaultMaturityReminderDays,
+	}
 }
 
 func (s *Settings) BeforeCreate(tx *gorm.DB) error {
@@ -34,3 +54,42 @@ func (s *Settings) BeforeCreate(tx *gorm.DB) error {
 	}
 	return nil
 }
+
+// Location returns the user's time zone, UTC when it is not set or unknown
+func (s *Settings) Location() *time.Location {
+	if s.TimeZone == "" {
+		return time.UTC
+	}
+	loc, err := LoadTimeZone(s.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// LoadTimeZone loads an IANA time zone such as "Asia/Dhaka". Unlike
+// time.LoadLocation it refuses "Local", the server's zone, whose name the
+// database does not know.
+func LoadTimeZone(name string) (*time.Location, error) {
+	if name == "" || name == "Local" {
+		return nil, fmt.Errorf("unknown time zone %q", name)
+	}
+	return time.LoadLocation(name)
+}
+
+// UserSettings returns the user's settings, or the defaults when they have
+// none saved
+func UserSettings(db *gorm.DB, userID uuid.UUID) Settings {
+	var settings Settings
+	if err := db.Where("user_id = ?", userID).First(&settings).Error; err != nil {
+		return Settings{
+			UserID:        userID,
+			Currency:      "BDT",
+			DateFormat:    "MM/DD/YYYY",
+			Language:      "en",
+			TimeZone:      "UTC",
+			Notifications: DefaultNotificationSettings(),
+		}
+	}
+	return settings
+}
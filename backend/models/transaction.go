@@ -1,6 +1,7 @@
 package models
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -8,27 +9,32 @@ import (
 )
 
 type Transaction struct {
-	ID               uuid.UUID      `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
-	UserID           uuid.UUID      `gorm:"type:uuid;not null;index" json:"userId"`
-	AccountID        uuid.UUID      `gorm:"type:uuid;not null;index" json:"accountId"`
-	ToAccountID      *uuid.UUID     `gorm:"type:uuid;index" json:"toAccountId"`      // For transfers
-	Type             string         `gorm:"not null" json:"type" binding:"required"` // income, expense, transfer
-	Amount           float64        `gorm:"not null" json:"amount" binding:"required,gt=0"`
-	CategoryID       string         `gorm:"not null;index" json:"categoryId" binding:"required"`
-	Date             time.Time      `gorm:"not null;index" json:"date" binding:"required"`
-	Description      string         `json:"description"`
-	Tags             []string       `gorm:"type:jsonb;serializer:json" json:"tags"`
-	SavingsGoalID    *uuid.UUID     `gorm:"type:uuid;index" json:"savingsGoalId"`
-	FixedDepositID   *uuid.UUID     `gorm:"type:uuid;index" json:"fixedDepositId"`
-	InvestmentID     *uuid.UUID     `gorm:"type:uuid;index" json:"investmentId"`
-	RecurringID      *uuid.UUID     `gorm:"type:uuid" json:"recurringId"`
-	CreditCardID     *uuid.UUID     `gorm:"type:uuid" json:"creditCardId"`
-	Attachments      []string       `gorm:"type:jsonb;serializer:json" json:"attachments"`
-	Reconciled       bool           `gorm:"default:false;index" json:"reconciled"`
-	ReconciliationID *uuid.UUID     `gorm:"type:uuid" json:"reconciliationId"`
-	CreatedAt        time.Time      `json:"createdAt"`
-	UpdatedAt        time.Time      `json:"updatedAt"`
-	DeletedAt        gorm.DeletedAt `gorm:"index" json:"-"`
+	ID               uuid.UUID          `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	UserID           uuid.UUID          `gorm:"type:uuid;not null;index" json:"userId"`
+	AccountID        uuid.UUID          `gorm:"type:uuid;not null;index" json:"accountId"`
+	ToAccountID      *uuid.UUID         `gorm:"type:uuid;index" json:"toAccountId"`      // For transfers
+	Type             string             `gorm:"not null" json:"type" binding:"required"` // income, expense, transfer
+	Amount           Money              `gorm:"not null" json:"amount" binding:"required,gt=0"`
+	ToAmount         *Money             `json:"toAmount"`                         // Amount credited to ToAccountID when its currency differs
+	ExchangeRate     *float64           `json:"exchangeRate"`                     // Rate applied from the source to the destination currency
+	CategoryID       string             `gorm:"not null;index" json:"categoryId"` // Required unless a rule sets it
+	Date             time.Time          `gorm:"not null;index" json:"date" binding:"required"`
+	Description      string             `json:"description"`
+	Tags             []string           `gorm:"type:jsonb;serializer:json" json:"tags"`
+	Splits           []TransactionSplit `gorm:"-" json:"splits,omitempty" binding:"omitempty,min=2,dive"` // Optional division between categories; loaded explicitly
+	SavingsGoalID    *uuid.UUID         `gorm:"type:uuid;index" json:"savingsGoalId"`
+	FixedDepositID   *uuid.UUID         `gorm:"type:uuid;index" json:"fixedDepositId"`
+	InvestmentID     *uuid.UUID         `gorm:"type:uuid;index" json:"investmentId"`
+	RecurringID      *uuid.UUID         `gorm:"type:uuid" json:"recurringId"`
+	CreditCardID     *uuid.UUID         `gorm:"type:uuid" json:"creditCardId"`
+	Attachments      []string           `gorm:"type:jsonb;serializer:json" json:"attachments"`
+	Reconciled       bool               `gorm:"default:false;index" json:"reconciled"`
+	ReconciliationID *uuid.UUID         `gorm:"type:uuid" json:"reconciliationId"`
+	ExternalID       string             `gorm:"index" json:"externalId"` // Bank reference such as an OFX FITID
+	ImportBatchID    *uuid.UUID         `gorm:"type:uuid;index" json:"importBatchId"`
+	CreatedAt        time.Time          `json:"createdAt"`
+	UpdatedAt        time.Time          `json:"updatedAt"`
+	DeletedAt        gorm.DeletedAt     `gorm:"index" json:"-"`
 }
 
 func (t *Transaction) BeforeCreate(tx *gorm.DB) error {
@@ -38,6 +44,39 @@ func (t *Transaction) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
+// ApplyBalance records the transaction in the journal and applies its
+// postings to the account or credit card balances
+func (t *Transaction) ApplyBalance(tx *gorm.DB) error {
+	entry, err := t.transactionEntry(tx)
+	if err != nil {
+		return err
+	}
+	return PostJournalEntry(tx, entry)
+}
+
+// RevertBalance posts a reversal of everything the journal holds for this
+// transaction. It undoes what was actually booked, so it stays correct even
+// if the transaction was edited without going through ApplyBalance.
+func (t *Transaction) RevertBalance(tx *gorm.DB) error {
+	entry, err := t.reversalEntry(tx)
+	if err != nil {
+		return err
+	}
+	return PostJournalEntry(tx, entry)
+}
+
+// adjustColumn atomically adds delta to a numeric column of the row identified by id
+func adjustColumn(tx *gorm.DB, model interface{}, id uuid.UUID, column string, delta Money) error {
+	result := tx.Model(model).Where("id = ?", id).Update(column, gorm.Expr(column+" + ?::numeric", delta))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%T %s not found", model, id)
+	}
+	return nil
+}
+
 type RecurringTransaction struct {
 	ID                  uuid.UUID      `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
 	UserID              uuid.UUID      `gorm:"type:uuid;not null;index" json:"userId"`
@@ -59,6 +98,40 @@ func (rt *RecurringTransaction) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
+// NextOccurrence returns the first scheduled date after LastProcessed, or the
+// start date if nothing has been processed yet. The second return value is
+// false once the schedule has passed its end date.
+func (rt *RecurringTransaction) NextOccurrence() (time.Time, bool) {
+	occurrence, ok := AddFrequency(rt.StartDate, rt.Frequency, 0)
+	if rt.LastProcessed != nil {
+		occurrence, ok = OccurrenceAfter(rt.StartDate, rt.Frequency, *rt.LastProcessed)
+	}
+	if !ok || (rt.EndDate != nil && occurrence.After(*rt.EndDate)) {
+		return time.Time{}, false
+	}
+	return occurrence, true
+}
+
+// BuildTransaction creates a transaction from the template for the given occurrence date
+func (rt *RecurringTransaction) BuildTransaction(date time.Time) Transaction {
+	template := rt.TransactionTemplate
+	return Transaction{
+		UserID:       rt.UserID,
+		AccountID:    template.AccountID,
+		ToAccountID:  template.ToAccountID,
+		CreditCardID: template.CreditCardID,
+		Type:         template.Type,
+		Amount:       template.Amount,
+		ToAmount:     template.ToAmount,
+		ExchangeRate: template.ExchangeRate,
+		CategoryID:   template.CategoryID,
+		Date:         date,
+		Description:  template.Description,
+		Tags:         template.Tags,
+		RecurringID:  &rt.ID,
+	}
+}
+
 type Tag struct {
 	ID        uuid.UUID      `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
 	UserID    uuid.UUID      `gorm:"type:uuid;not null;index" json:"userId"`
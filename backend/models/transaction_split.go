@@ -0,0 +1,105 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TransactionSplit is the part of an income or expense attributed to one
+// category. The splits of a transaction add up to its amount; a transaction
+// without splits belongs entirely to its own category.
+type TransactionSplit struct {
+	ID            uuid.UUID      `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	TransactionID uuid.UUID      `gorm:"type:uuid;not null;index" json:"transactionId"`
+	UserID        uuid.UUID      `gorm:"type:uuid;not null;index" json:"userId"`
+	CategoryID    string         `gorm:"not null;index" json:"categoryId" binding:"required"`
+	Amount        Money          `gorm:"not null" json:"amount" binding:"required,gt=0"`
+	Memo          string         `json:"memo"`
+	Tags          []string       `gorm:"type:jsonb;serializer:json" json:"tags"`
+	Position      int            `gorm:"not null;default:0" json:"-"` // Order within the transaction
+	CreatedAt     time.Time      `json:"createdAt"`
+	UpdatedAt     time.Time      `json:"updatedAt"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (s *TransactionSplit) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return nil
+}
+
+// LoadSplits loads the transaction's splits in order
+func (t *Transaction) LoadSplits(tx *gorm.DB) error {
+	splits := []TransactionSplit{}
+	if err := tx.Where("transaction_id = ?", t.ID).Order("position ASC").Find(&splits).Error; err != nil {
+		return err
+	}
+	t.Splits = splits
+	return nil
+}
+
+// LoadTransactionSplits loads the splits of every transaction with one query
+func LoadTransactionSplits(tx *gorm.DB, transactions []Transaction) error {
+	if len(transactions) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, len(transactions))
+	for i := range transactions {
+		ids[i] = transactions[i].ID
+	}
+
+	var splits []TransactionSplit
+	if err := tx.Where("transaction_id IN ?", ids).Order("position ASC").Find(&splits).Error; err != nil {
+		return err
+	}
+	byTransaction := make(map[uuid.UUID][]TransactionSplit)
+	for _, split := range splits {
+		byTransaction[split.TransactionID] = append(byTransaction[split.TransactionID], split)
+	}
+	for i := range transactions {
+		transactions[i].Splits = byTransaction[transactions[i].ID]
+		if transactions[i].Splits == nil {
+			transactions[i].Splits = []TransactionSplit{}
+		}
+	}
+	return nil
+}
+
+// SaveSplits replaces the splits stored for the transaction with its Splits
+func (t *Transaction) SaveSplits(tx *gorm.DB) error {
+	if err := tx.Where("transaction_id = ?", t.ID).Delete(&TransactionSplit{}).Error; err != nil {
+		return err
+	}
+	if t.Splits == nil {
+		t.Splits = []TransactionSplit{}
+	}
+	for i := range t.Splits {
+		split := &t.Splits[i]
+		split.ID = uuid.Nil
+		split.TransactionID = t.ID
+		split.UserID = t.UserID
+		split.Position = i
+	}
+	if len(t.Splits) == 0 {
+		return nil
+	}
+	return tx.Create(&t.Splits).Error
+}
+
+// categoryShares returns how an income or expense divides between
+// categories: its splits, or its whole amount in its own category. Splits
+// are loaded if they have not been already.
+func (t *Transaction) categoryShares(tx *gorm.DB) ([]TransactionSplit, error) {
+	if t.Splits == nil {
+		if err := t.LoadSplits(tx); err != nil {
+			return nil, err
+		}
+	}
+	if len(t.Splits) > 0 {
+		return t.Splits, nil
+	}
+	return []TransactionSplit{{CategoryID: t.CategoryID, Amount: t.Amount}}, nil
+}
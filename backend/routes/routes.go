@@ -70,10 +70,107 @@ func SetupRoutes(router *gin.Engine) {
 				transactionRoutes.GET("/:id", handlers.GetTransaction)
 				transactionRoutes.POST("", handlers.CreateTransaction)
 				transactionRoutes.POST("/bulk", handlers.BulkImportTransactions)
+				transactionRoutes.POST("/search", handlers.SearchTransactions)
 				transactionRoutes.PUT("/:id", handlers.UpdateTransaction)
 				transactionRoutes.DELETE("/:id", handlers.DeleteTransaction)
 			}
 
+			// Saved search routes
+			searchRoutes := protected.Group("/searches")
+			{
+				searchRoutes.GET("", handlers.ListSavedSearches)
+				searchRoutes.POST("", handlers.CreateSavedSearch)
+				searchRoutes.GET("/:id", handlers.GetSavedSearch)
+				searchRoutes.GET("/:id/transactions", handlers.RunSavedSearch)
+				searchRoutes.PUT("/:id", handlers.UpdateSavedSearch)
+				searchRoutes.DELETE("/:id", handlers.DeleteSavedSearch)
+			}
+
+			// Transaction rule routes
+			ruleRoutes := protected.Group("/rules")
+			{
+				ruleRoutes.GET("", handlers.ListRules)
+				ruleRoutes.POST("", handlers.CreateRule)
+				ruleRoutes.POST("/test", handlers.TestRule)
+				ruleRoutes.POST("/apply", handlers.ApplyRules)
+				ruleRoutes.GET("/:id", handlers.GetRule)
+				ruleRoutes.PUT("/:id", handlers.UpdateRule)
+				ruleRoutes.DELETE("/:id", handlers.DeleteRule)
+			}
+
+			// Journal routes
+			journalRoutes := protected.Group("/journal")
+			{
+				journalRoutes.GET("/entries", handlers.ListJournalEntries)
+				journalRoutes.GET("/balances/:ledgerType/:id", handlers.GetLedgerBalance)
+				journalRoutes.GET("/verify", handlers.VerifyBalances)
+			}
+
+			// Recurring transaction routes
+			recurringRoutes := protected.Group("/recurring-transactions")
+			{
+				recurringRoutes.GET("", handlers.ListRecurringTransactions)
+				recurringRoutes.GET("/:id", handlers.GetRecurringTransaction)
+				recurringRoutes.POST("", handlers.CreateRecurringTransaction)
+				recurringRoutes.PUT("/:id", handlers.UpdateRecurringTransaction)
+				recurringRoutes.DELETE("/:id", handlers.DeleteRecurringTransaction)
+			}
+
+			// Tag routes
+			tagRoutes := protected.Group("/tags")
+			{
+				tagRoutes.GET("", handlers.ListTags)
+				tagRoutes.GET("/usage", handlers.GetTagUsage)
+				tagRoutes.POST("/merge", handlers.MergeTags)
+				tagRoutes.GET("/:id", handlers.GetTag)
+				tagRoutes.POST("", handlers.CreateTag)
+				tagRoutes.PUT("/:id", handlers.UpdateTag)
+				tagRoutes.DELETE("/:id", handlers.DeleteTag)
+			}
+
+			// Category routes
+			categoryRoutes := protected.Group("/categories")
+			{
+				categoryRoutes.GET("", handlers.ListCategories)
+				categoryRoutes.POST("/merge", handlers.MergeCategories)
+				categoryRoutes.GET("/:id", handlers.GetCategory)
+				categoryRoutes.POST("", handlers.CreateCategory)
+				categoryRoutes.PUT("/:id", handlers.UpdateCategory)
+				categoryRoutes.DELETE("/:id", handlers.DeleteCategory)
+			}
+
+			// Exchange rate routes
+			exchangeRateRoutes := protected.Group("/exchange-rates")
+			{
+				exchangeRateRoutes.GET("", handlers.ListExchangeRates)
+				exchangeRateRoutes.GET("/convert", handlers.ConvertCurrency)
+				exchangeRateRoutes.POST("", handlers.CreateExchangeRate)
+				exchangeRateRoutes.POST("/import", handlers.ImportExchangeRates)
+				exchangeRateRoutes.PUT("/:id", handlers.UpdateExchangeRate)
+				exchangeRateRoutes.DELETE("/:id", handlers.DeleteExchangeRate)
+			}
+
+			// Statement import routes
+			importRoutes := protected.Group("/imports")
+			{
+				importRoutes.GET("", handlers.ListImports)
+				importRoutes.POST("", handlers.CreateImport)
+				importRoutes.GET("/mappings/:accountId", handlers.GetImportMapping)
+				importRoutes.PUT("/mappings/:accountId", handlers.SaveImportMapping)
+				importRoutes.GET("/:id", handlers.GetImport)
+				importRoutes.PUT("/:id/rows/:rowId", handlers.UpdateImportRow)
+				importRoutes.POST("/:id/commit", handlers.CommitImport)
+				importRoutes.DELETE("/:id", handlers.DeleteImport)
+			}
+
+			// Export routes
+			exportRoutes := protected.Group("/export")
+			{
+				exportRoutes.GET("/archive", handlers.ExportArchive)
+				exportRoutes.GET("/ledger", handlers.ExportLedger)
+				exportRoutes.GET("/:resource", handlers.ExportResource)
+			}
+
 			// Credit card routes
 			creditCardRoutes := protected.Group("/credit-cards")
 			{
@@ -94,6 +191,18 @@ func SetupRoutes(router *gin.Engine) {
 
 				// Statement routes
 				creditCardRoutes.GET("/:id/statements", handlers.GetStatements)
+
+				// Payoff simulation
+				creditCardRoutes.GET("/:id/payoff", handlers.SimulatePayoff)
+
+				// Reward earning rules and redemption
+				creditCardRoutes.GET("/:id/reward-rules", handlers.ListRewardRules)
+				creditCardRoutes.POST("/:id/reward-rules", handlers.CreateRewardRule)
+				creditCardRoutes.PUT("/:id/reward-rules/:ruleId", handlers.UpdateRewardRule)
+				creditCardRoutes.DELETE("/:id/reward-rules/:ruleId", handlers.DeleteRewardRule)
+				creditCardRoutes.GET("/:id/rewards/balance", handlers.GetRewardBalance)
+				creditCardRoutes.POST("/:id/rewards/redeem", handlers.RedeemRewards)
+				creditCardRoutes.GET("/:id/rewards/redemptions", handlers.ListRewardRedemptions)
 			}
 
 			// Statement routes
@@ -114,6 +223,7 @@ func SetupRoutes(router *gin.Engine) {
 			billRoutes := protected.Group("/bills")
 			{
 				billRoutes.GET("", handlers.ListBills)
+				billRoutes.GET("/upcoming", handlers.GetUpcomingBills)
 				billRoutes.GET("/:id", handlers.GetBill)
 				billRoutes.POST("", handlers.CreateBill)
 				billRoutes.PUT("/:id", handlers.UpdateBill)
@@ -124,12 +234,17 @@ func SetupRoutes(router *gin.Engine) {
 			// Bill payment routes
 			protected.GET("/bill-payments", handlers.GetBillPayments)
 
+			// Calendar of bills, credit card due dates and recurring transactions
+			protected.GET("/calendar", handlers.GetCalendar)
+
 			// Budget routes
 			budgetRoutes := protected.Group("/budgets")
 			{
 				budgetRoutes.GET("", handlers.ListBudgets)
+				budgetRoutes.GET("/summary", handlers.GetBudgetSummary)
 				budgetRoutes.GET("/:id", handlers.GetBudget)
 				budgetRoutes.GET("/:id/progress", handlers.GetBudgetProgress)
+				budgetRoutes.GET("/:id/history", handlers.GetBudgetHistory)
 				budgetRoutes.POST("", handlers.CreateBudget)
 				budgetRoutes.PUT("/:id", handlers.UpdateBudget)
 				budgetRoutes.DELETE("/:id", handlers.DeleteBudget)
@@ -153,6 +268,34 @@ func SetupRoutes(router *gin.Engine) {
 				goalRoutes.GET("/holding-types", handlers.GetHoldingTypes)
 			}
 
+			// Envelope budgeting routes
+			envelopeRoutes := protected.Group("/envelopes")
+			{
+				envelopeRoutes.GET("", handlers.GetEnvelopes)
+				envelopeRoutes.PUT("/assign", handlers.AssignEnvelope)
+				envelopeRoutes.POST("/move", handlers.MoveEnvelopeMoney)
+				envelopeRoutes.GET("/moves", handlers.ListEnvelopeMoves)
+				envelopeRoutes.DELETE("/moves/:id", handlers.DeleteEnvelopeMove)
+			}
+
+			// Report routes
+			reportRoutes := protected.Group("/reports")
+			{
+				reportRoutes.GET("/cash-flow", handlers.GetCashFlowReport)
+				reportRoutes.GET("/income-statement", handlers.GetIncomeStatement)
+				reportRoutes.GET("/category-trends", handlers.GetCategoryTrends)
+				reportRoutes.GET("/top-payees", handlers.GetTopPayees)
+				reportRoutes.GET("/year-over-year", handlers.GetYearOverYear)
+			}
+
+			// Net worth routes
+			netWorthRoutes := protected.Group("/net-worth")
+			{
+				netWorthRoutes.GET("", handlers.GetNetWorth)
+				netWorthRoutes.GET("/history", handlers.GetNetWorthHistory)
+				netWorthRoutes.POST("/snapshots", handlers.TakeNetWorthSnapshots)
+			}
+
 			// Settings routes
 			settingsRoutes := protected.Group("/settings")
 			{
@@ -160,6 +303,20 @@ func SetupRoutes(router *gin.Engine) {
 				settingsRoutes.PUT("", handlers.UpdateSettings)
 			}
 
+			// Notification routes
+			notificationRoutes := protected.Group("/notifications")
+			{
+				notificationRoutes.GET("", handlers.ListNotifications)
+				notificationRoutes.GET("/unread-count", handlers.GetUnreadNotificationCount)
+				notificationRoutes.PUT("/read-all", handlers.MarkAllNotificationsRead)
+				notificationRoutes.POST("/check", handlers.CheckNotifications)
+				notificationRoutes.POST("/test", handlers.SendTestNotification)
+				notificationRoutes.GET("/:id/deliveries", handlers.GetNotificationDeliveries)
+				notificationRoutes.PUT("/:id/read", handlers.MarkNotificationRead)
+				notificationRoutes.PUT("/:id/unread", handlers.MarkNotificationUnread)
+				notificationRoutes.DELETE("/:id", handlers.DeleteNotification)
+			}
+
 			// Reconciliation routes
 			reconciliationRoutes := protected.Group("/reconciliations")
 			{
@@ -170,6 +327,14 @@ func SetupRoutes(router *gin.Engine) {
 				reconciliationRoutes.DELETE("/:id", handlers.DeleteReconciliation)
 			}
 
+			// Admin routes
+			adminRoutes := protected.Group("/admin")
+			adminRoutes.Use(middleware.AdminMiddleware())
+			{
+				adminRoutes.GET("/audit", handlers.AuditBalances)
+				adminRoutes.POST("/audit/repair", handlers.RepairBalances)
+			}
+
 			// File upload routes
 			uploadRoutes := protected.Group("/uploads")
 			{
@@ -0,0 +1,450 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"daybook-backend/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Kinds of balances checked by the audit
+const (
+	AuditAccount    = "account"
+	AuditCreditCard = "credit_card"
+	AuditGoal       = "goal"
+)
+
+// AuditReport lists every stored balance that differs from the balance
+// recomputed from history
+type AuditReport struct {
+	UserID      *uuid.UUID      `json:"userId,omitempty"` // Nil when all users were audited
+	CheckedAt   time.Time       `json:"checkedAt"`
+	Accounts    int             `json:"accounts"`
+	CreditCards int             `json:"creditCards"`
+	Goals       int             `json:"goals"`
+	Mismatches  []AuditMismatch `json:"mismatches"`
+	Repaired    bool            `json:"repaired"`
+}
+
+// Consistent reports whether no mismatches were found
+func (r *AuditReport) Consistent() bool {
+	return len(r.Mismatches) == 0
+}
+
+// AuditMismatch is one account, credit card or goal whose stored balance,
+// recomputed balance and journal do not all agree. Card amounts are what is
+// owed, as in CreditCard.CurrentBalance.
+type AuditMismatch struct {
+	Kind         string             `json:"kind"` // account, credit_card, goal
+	ID           uuid.UUID          `json:"id"`
+	UserID       uuid.UUID          `json:"userId"`
+	Name         string             `json:"name"`
+	Currency     string             `json:"currency,omitempty"`
+	Stored       models.Money       `json:"stored"`            // Balance, CurrentBalance or CurrentAmount
+	Expected     models.Money       `json:"expected"`          // Recomputed from history
+	Journal      *models.Money      `json:"journal,omitempty"` // Derived from postings; goals have none
+	Difference   models.Money       `json:"difference"`        // Stored minus expected
+	Transactions []AuditTransaction `json:"transactions"`      // Transactions the journal records wrongly
+	Adjustments  []AuditAdjustment  `json:"adjustments"`       // Journal entries not backed by a transaction
+}
+
+// AuditTransaction is a transaction whose effect on a balance differs from
+// what the journal holds for it
+type AuditTransaction struct {
+	ID          uuid.UUID    `json:"id"`
+	Date        time.Time    `json:"date"`
+	Type        string       `json:"type"`
+	Description string       `json:"description"`
+	Amount      models.Money `json:"amount"`
+	Deleted     bool         `json:"deleted"`
+	Expected    models.Money `json:"expected"` // Effect on the balance according to the transaction
+	Journal     models.Money `json:"journal"`  // Effect recorded in the journal
+}
+
+// AuditAdjustment is an opening or adjustment journal entry on a balance
+type AuditAdjustment struct {
+	EntryID     uuid.UUID    `json:"entryId"`
+	Date        time.Time    `json:"date"`
+	Kind        string       `json:"kind"`
+	Description string       `json:"description"`
+	Amount      models.Money `json:"amount"`
+}
+
+// transactionEffectsSQL derives the change every live transaction makes to
+// account and credit card ledgers, with the same signs as its postings:
+// positive adds to an account and reduces what is owed on a card. It must
+// follow Transaction.journalPostings.
+const transactionEffectsSQL = `effects AS (
+	SELECT t.id AS transaction_id, 'account' AS ledger_type, t.account_id AS ledger_id,
+		CASE WHEN t.credit_card_id IS NULL AND t.type = 'income' THEN t.amount ELSE -t.amount END AS amount
+	FROM transactions t
+	WHERE t.deleted_at IS NULL AND (@all OR t.user_id = @user)
+		AND ((t.credit_card_id IS NULL AND t.type IN ('income', 'expense', 'transfer'))
+			OR (t.credit_card_id <> t.account_id AND t.type <> 'tracking'))
+	UNION ALL
+	SELECT t.id, 'account', t.to_account_id, COALESCE(t.to_amount, t.amount)
+	FROM transactions t
+	WHERE t.deleted_at IS NULL AND (@all OR t.user_id = @user)
+		AND t.credit_card_id IS NULL AND t.type = 'transfer' AND t.to_account_id IS NOT NULL
+	UNION ALL
+	SELECT t.id, 'credit_card', t.credit_card_id, COALESCE(t.to_amount, t.amount)
+	FROM transactions t
+	WHERE t.deleted_at IS NULL AND (@all OR t.user_id = @user)
+		AND t.credit_card_id <> t.account_id AND t.type <> 'tracking'
+	UNION ALL
+	SELECT t.id, 'credit_card', t.credit_card_id,
+		CASE WHEN t.type = 'income' THEN t.amount ELSE -t.amount END
+	FROM transactions t
+	WHERE t.deleted_at IS NULL AND (@all OR t.user_id = @user)
+		AND t.credit_card_id = t.account_id AND t.type IN ('income', 'expense')
+)`
+
+// ledgerTotalsSQL sums the effects and postings of every ledger. Standalone
+// postings are those of entries not recording a transaction, such as card
+// opening balances.
+const ledgerTotalsSQL = `WITH ` + transactionEffectsSQL + `,
+effect_totals AS (
+	SELECT ledger_type, ledger_id, SUM(amount) AS amount FROM effects GROUP BY ledger_type, ledger_id
+),
+journal_totals AS (
+	SELECT p.ledger_type, p.ledger_id, SUM(p.amount) AS amount,
+		SUM(CASE WHEN e.transaction_id IS NULL THEN p.amount ELSE 0 END) AS standalone
+	FROM postings p JOIN journal_entries e ON e.id = p.entry_id
+	WHERE p.ledger_type IN ('account', 'credit_card') AND (@all OR p.user_id = @user)
+	GROUP BY p.ledger_type, p.ledger_id
+)`
+
+// accountAuditSQL recomputes every account balance as its transactions plus
+// the initial balance when no opening balance transaction records it.
+// Standalone adjustments are not history, so drift absorbed by them shows.
+const accountAuditSQL = ledgerTotalsSQL + `
+SELECT a.id, a.user_id, a.name, a.currency, a.balance AS stored,
+	COALESCE(f.amount, 0) + CASE WHEN EXISTS (
+		SELECT 1 FROM transactions o WHERE o.account_id = a.id AND o.category_id = @opening
+	) THEN 0 ELSE a.initial_balance END AS expected,
+	COALESCE(j.amount, 0) AS journal
+FROM accounts a
+LEFT JOIN effect_totals f ON f.ledger_type = 'account' AND f.ledger_id = a.id
+LEFT JOIN journal_totals j ON j.ledger_type = 'account' AND j.ledger_id = a.id
+WHERE a.deleted_at IS NULL AND (@all OR a.user_id = @user)`
+
+// creditCardAuditSQL recomputes every card balance as its transactions plus
+// its opening balance and manual adjustments, which exist only as entries
+const creditCardAuditSQL = ledgerTotalsSQL + `
+SELECT c.id, c.user_id, c.name, c.currency, -c.current_balance AS stored,
+	COALESCE(f.amount, 0) + COALESCE(j.standalone, 0) AS expected,
+	COALESCE(j.amount, 0) AS journal
+FROM credit_cards c
+LEFT JOIN effect_totals f ON f.ledger_type = 'credit_card' AND f.ledger_id = c.id
+LEFT JOIN journal_totals j ON j.ledger_type = 'credit_card' AND j.ledger_id = c.id
+WHERE c.deleted_at IS NULL AND (@all OR c.user_id = @user)`
+
+// offendingTransactionsSQL finds transactions whose effect on a ledger
+// differs from what the journal holds for them, including deleted
+// transactions that were never reversed
+const offendingTransactionsSQL = `WITH ` + transactionEffectsSQL + `,
+expected AS (
+	SELECT transaction_id, ledger_type, ledger_id, SUM(amount) AS amount
+	FROM effects GROUP BY transaction_id, ledger_type, ledger_id
+),
+recorded AS (
+	SELECT e.transaction_id, p.ledger_type, p.ledger_id, SUM(p.amount) AS amount
+	FROM postings p JOIN journal_entries e ON e.id = p.entry_id
+	WHERE e.transaction_id IS NOT NULL AND p.ledger_type IN ('account', 'credit_card') AND (@all OR p.user_id = @user)
+	GROUP BY e.transaction_id, p.ledger_type, p.ledger_id
+)
+SELECT COALESCE(x.transaction_id, r.transaction_id) AS transaction_id,
+	COALESCE(x.ledger_type, r.ledger_type) AS ledger_type,
+	COALESCE(x.ledger_id, r.ledger_id) AS ledger_id,
+	COALESCE(x.amount, 0) AS expected, COALESCE(r.amount, 0) AS journal
+FROM expected x
+FULL OUTER JOIN recorded r ON r.transaction_id = x.transaction_id AND r.ledger_type = x.ledger_type AND r.ledger_id = x.ledger_id
+WHERE COALESCE(x.amount, 0) <> COALESCE(r.amount, 0)`
+
+// goalAuditSQL recomputes goal progress the way Goal.UpdateCurrentAmount does
+const goalAuditSQL = `SELECT g.id, g.user_id, g.name, g.current_amount AS stored,
+	COALESCE(SUM(h.current_value), 0) AS expected
+FROM goals g
+LEFT JOIN goal_holdings h ON h.goal_id = g.id AND h.deleted_at IS NULL AND h.status IN ('active', 'matured', 'achieved')
+WHERE g.deleted_at IS NULL AND (@all OR g.user_id = @user)
+GROUP BY g.id`
+
+// auditedBalance is one recomputed balance in posting signs
+type auditedBalance struct {
+	ID       uuid.UUID
+	UserID   uuid.UUID
+	Name     string
+	Currency string
+	Stored   models.Money
+	Expected models.Money
+	Journal  models.Money
+}
+
+type offendingTransaction struct {
+	TransactionID uuid.UUID
+	LedgerType    string
+	LedgerID      uuid.UUID
+	Expected      models.Money
+	Journal       models.Money
+}
+
+// AuditBalances recomputes the balances of accounts, credit cards and goals
+// from history and reports every one that differs from its stored value or
+// its journal. Pass uuid.Nil to audit all users. With repair, the journal
+// entries of offending transactions are rebooked, remaining journal
+// differences are booked as adjustments and the stored balances are
+// overwritten, all in a single database transaction.
+func AuditBalances(db *gorm.DB, userID uuid.UUID, repair bool) (*AuditReport, error) {
+	report := &AuditReport{CheckedAt: time.Now(), Mismatches: []AuditMismatch{}}
+	if userID != uuid.Nil {
+		report.UserID = &userID
+	}
+
+	audit := func(tx *gorm.DB) error {
+		accounts, cards, goals, offenders, err := auditBalances(tx, userID)
+		if err != nil {
+			return err
+		}
+		report.Accounts, report.CreditCards, report.Goals = len(accounts), len(cards), len(goals)
+
+		if err := report.addLedgerMismatches(tx, AuditAccount, accounts, offenders); err != nil {
+			return err
+		}
+		if err := report.addLedgerMismatches(tx, AuditCreditCard, cards, offenders); err != nil {
+			return err
+		}
+		for _, goal := range goals {
+			if goal.Stored == goal.Expected {
+				continue
+			}
+			report.Mismatches = append(report.Mismatches, AuditMismatch{
+				Kind:         AuditGoal,
+				ID:           goal.ID,
+				UserID:       goal.UserID,
+				Name:         goal.Name,
+				Stored:       goal.Stored,
+				Expected:     goal.Expected,
+				Difference:   goal.Stored - goal.Expected,
+				Transactions: []AuditTransaction{},
+				Adjustments:  []AuditAdjustment{},
+			})
+		}
+
+		if !repair || (report.Consistent() && len(offenders) == 0) {
+			return nil
+		}
+		if err := repairBalances(tx, userID, offenders); err != nil {
+			return err
+		}
+		report.Repaired = true
+		return nil
+	}
+
+	var err error
+	if repair {
+		err = db.Transaction(audit)
+	} else {
+		err = audit(db)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// auditBalances loads every recomputed balance and offending transaction
+func auditBalances(db *gorm.DB, userID uuid.UUID) (accounts, cards, goals []auditedBalance, offenders []offendingTransaction, err error) {
+	args := map[string]interface{}{
+		"all":     userID == uuid.Nil,
+		"user":    userID,
+		"opening": models.CategoryOpeningBalance,
+	}
+
+	if err = db.Raw(accountAuditSQL, args).Scan(&accounts).Error; err != nil {
+		return
+	}
+	if err = db.Raw(creditCardAuditSQL, args).Scan(&cards).Error; err != nil {
+		return
+	}
+	if err = db.Raw(goalAuditSQL, args).Scan(&goals).Error; err != nil {
+		return
+	}
+	err = db.Raw(offendingTransactionsSQL, args).Scan(&offenders).Error
+	return
+}
+
+// addLedgerMismatches reports the accounts or cards whose stored balance,
+// recomputed balance and journal disagree, with the transactions and
+// adjustments that explain the difference
+func (r *AuditReport) addLedgerMismatches(db *gorm.DB, kind string, balances []auditedBalance, offenders []offendingTransaction) error {
+	for _, balance := range balances {
+		var ledgerOffenders []offendingTransaction
+		for _, offender := range offenders {
+			if offender.LedgerType == kind && offender.LedgerID == balance.ID {
+				ledgerOffenders = append(ledgerOffenders, offender)
+			}
+		}
+		if balance.Stored == balance.Expected && balance.Journal == balance.Expected && len(ledgerOffenders) == 0 {
+			continue
+		}
+
+		transactions, err := auditTransactions(db, kind, ledgerOffenders)
+		if err != nil {
+			return err
+		}
+		adjustments, err := auditAdjustments(db, kind, balance.ID)
+		if err != nil {
+			return err
+		}
+
+		journal := reportedAmount(kind, balance.Journal)
+		r.Mismatches = append(r.Mismatches, AuditMismatch{
+			Kind:         kind,
+			ID:           balance.ID,
+			UserID:       balance.UserID,
+			Name:         balance.Name,
+			Currency:     balance.Currency,
+			Stored:       reportedAmount(kind, balance.Stored),
+			Expected:     reportedAmount(kind, balance.Expected),
+			Journal:      &journal,
+			Difference:   reportedAmount(kind, balance.Stored-balance.Expected),
+			Transactions: transactions,
+			Adjustments:  adjustments,
+		})
+	}
+	return nil
+}
+
+// reportedAmount turns a posting amount into the sign the balance is shown
+// with; cards show what is owed, the opposite of their postings
+func reportedAmount(kind string, amount models.Money) models.Money {
+	if kind == AuditCreditCard {
+		return -amount
+	}
+	return amount
+}
+
+// auditTransactions loads the offending transactions of one ledger,
+// including deleted ones
+func auditTransactions(db *gorm.DB, kind string, offenders []offendingTransaction) ([]AuditTransaction, error) {
+	result := make([]AuditTransaction, 0, len(offenders))
+	if len(offenders) == 0 {
+		return result, nil
+	}
+
+	ids := make([]uuid.UUID, len(offenders))
+	for i, offender := range offenders {
+		ids[i] = offender.TransactionID
+	}
+	var transactions []models.Transaction
+	if err := db.Unscoped().Where("id IN ?", ids).Find(&transactions).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uuid.UUID]models.Transaction, len(transactions))
+	for _, t := range transactions {
+		byID[t.ID] = t
+	}
+
+	for _, offender := range offenders {
+		t := byID[offender.TransactionID]
+		result = append(result, AuditTransaction{
+			ID:          offender.TransactionID,
+			Date:        t.Date,
+			Type:        t.Type,
+			Description: t.Description,
+			Amount:      t.Amount,
+			Deleted:     t.DeletedAt.Valid,
+			Expected:    reportedAmount(kind, offender.Expected),
+			Journal:     reportedAmount(kind, offender.Journal),
+		})
+	}
+	return result, nil
+}
+
+// auditAdjustments lists the journal entries on a ledger that do not record
+// a transaction
+func auditAdjustments(db *gorm.DB, ledgerType string, ledgerID uuid.UUID) ([]AuditAdjustment, error) {
+	var adjustments []AuditAdjustment
+	err := db.Table("postings").
+		Select("journal_entries.id AS entry_id, journal_entries.date, journal_entries.kind, journal_entries.description, postings.amount").
+		Joins("JOIN journal_entries ON journal_entries.id = postings.entry_id").
+		Where("postings.ledger_type = ? AND postings.ledger_id = ? AND journal_entries.transaction_id IS NULL", ledgerType, ledgerID).
+		Order("journal_entries.date, journal_entries.created_at").
+		Scan(&adjustments).Error
+	if err != nil {
+		return nil, err
+	}
+	if adjustments == nil {
+		adjustments = []AuditAdjustment{}
+	}
+	for i := range adjustments {
+		adjustments[i].Amount = reportedAmount(ledgerType, adjustments[i].Amount)
+	}
+	return adjustments, nil
+}
+
+// repairBalances rebooks the offending transactions, books what still
+// separates each journal from its recomputed balance as an adjustment and
+// overwrites the stored balances
+func repairBalances(tx *gorm.DB, userID uuid.UUID, offenders []offendingTransaction) error {
+	rebooked := make(map[uuid.UUID]bool)
+	for _, offender := range offenders {
+		if rebooked[offender.TransactionID] {
+			continue
+		}
+		rebooked[offender.TransactionID] = true
+
+		var transaction models.Transaction
+		if err := tx.Unscoped().First(&transaction, "id = ?", offender.TransactionID).Error; err != nil {
+			return fmt.Errorf("transaction %s: %w", offender.TransactionID, err)
+		}
+		if err := transaction.RebookJournal(tx); err != nil {
+			return fmt.Errorf("transaction %s: %w", offender.TransactionID, err)
+		}
+	}
+
+	// Recompute after rebooking so the adjustments cover only what the
+	// transactions cannot explain
+	accounts, cards, goals, _, err := auditBalances(tx, userID)
+	if err != nil {
+		return err
+	}
+
+	ledgers := []struct {
+		ledgerType string
+		model      interface{}
+		column     string
+		balances   []auditedBalance
+	}{
+		{models.LedgerAccount, &models.Account{}, "balance", accounts},
+		{models.LedgerCreditCard, &models.CreditCard{}, "current_balance", cards},
+	}
+	for _, ledger := range ledgers {
+		for _, balance := range ledger.balances {
+			if balance.Journal != balance.Expected {
+				description := "Balance audit correction: " + balance.Name
+				if err := models.RecordLedgerAdjustment(tx, balance.UserID, ledger.ledgerType, balance.ID, balance.Expected-balance.Journal, balance.Currency, description); err != nil {
+					return fmt.Errorf("%s %s: %w", ledger.ledgerType, balance.ID, err)
+				}
+			}
+			if balance.Stored != balance.Expected {
+				if err := tx.Model(ledger.model).Where("id = ?", balance.ID).UpdateColumn(ledger.column, reportedAmount(ledger.ledgerType, balance.Expected)).Error; err != nil {
+					return fmt.Errorf("%s %s: %w", ledger.ledgerType, balance.ID, err)
+				}
+			}
+		}
+	}
+
+	for _, goal := range goals {
+		if goal.Stored == goal.Expected {
+			continue
+		}
+		if err := tx.Model(&models.Goal{}).Where("id = ?", goal.ID).UpdateColumn("current_amount", goal.Expected).Error; err != nil {
+			return fmt.Errorf("goal %s: %w", goal.ID, err)
+		}
+	}
+	return nil
+}
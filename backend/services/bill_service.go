@@ -0,0 +1,423 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"time"
+
+	"daybook-backend/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Bill payment errors caused by the paying account or card rather than the
+// database
+var (
+	ErrInsufficientFunds  = errors.New("insufficient funds in the paying account")
+	ErrInsufficientCredit = errors.New("insufficient available credit on the card")
+	ErrNoPaymentSource    = errors.New("no account or credit card to pay the bill from")
+)
+
+// BillOccurrence is one due date of a bill with its payment status
+type BillOccurrence struct {
+	BillID       uuid.UUID    `json:"billId"`
+	Name         string       `json:"name"`
+	Category     string       `json:"category"`
+	Amount       models.Money `json:"amount"`
+	DueDate      time.Time    `json:"dueDate"`
+	Status       string       `json:"status"`       // paid, unpaid, overdue
+	PaymentID    *uuid.UUID   `json:"paymentId"`    // Latest payment of the due date
+	PaidDate     *time.Time   `json:"paidDate"`     // Date of the latest payment
+	PaidAmount   models.Money `json:"paidAmount"`   // Total of every payment of the due date
+	DaysUntilDue int          `json:"daysUntilDue"` // Negative once past due
+	ReminderDate time.Time    `json:"reminderDate"` // ReminderDays before the due date
+	AutoPay      bool         `json:"autoPay"`
+}
+
+// BillSchedule returns the due dates of the bills from the day each was added
+// up to but not including the day of to, with their status as of now. A due
+// date is paid once a payment settles it; see BillPaymentDueDate. Today is
+// the day of now in each bill's user's time zone. Occurrences are sorted by
+// due date.
+func BillSchedule(db *gorm.DB, bills []models.Bill, to, now time.Time) ([]BillOccurrence, error) {
+	occurrences := []BillOccurrence{}
+	if len(bills) == 0 {
+		return occurrences, nil
+	}
+
+	billIDs := make([]uuid.UUID, len(bills))
+	for i := range bills {
+		billIDs[i] = bills[i].ID
+	}
+	var payments []models.BillPayment
+	if err := db.Where("bill_id IN ?", billIDs).Order("payment_date ASC, created_at ASC").Find(&payments).Error; err != nil {
+		return nil, err
+	}
+	paymentsByBill := make(map[uuid.UUID][]models.BillPayment)
+	for _, payment := range payments {
+		paymentsByBill[payment.BillID] = append(paymentsByBill[payment.BillID], payment)
+	}
+
+	calendars := make(map[uuid.UUID]BudgetCalendar)
+	for i := range bills {
+		bill := &bills[i]
+
+		cal, ok := calendars[bill.UserID]
+		if !ok {
+			cal = UserBudgetCalendar(db, bill.UserID)
+			calendars[bill.UserID] = cal
+		}
+		today := cal.Today(now)
+
+		// Payments by the due date they settle, oldest first
+		paymentsByDueDate := make(map[int64][]models.BillPayment)
+		for _, payment := range paymentsByBill[bill.ID] {
+			if dueDate, ok := BillPaymentDueDate(bill, &payment, cal); ok {
+				paymentsByDueDate[dueDate.Unix()] = append(paymentsByDueDate[dueDate.Unix()], payment)
+			}
+		}
+
+		for _, dueDate := range bill.DueDatesBetween(bill.CreatedAt, to) {
+			occurrence := BillOccurrence{
+				BillID:       bill.ID,
+				Name:         bill.Name,
+				Category:     bill.Category,
+				Amount:       bill.Amount,
+				DueDate:      dueDate,
+				Status:       models.BillUnpaid,
+				DaysUntilDue: int(dueDate.Sub(today).Hours() / 24),
+				ReminderDate: dueDate.AddDate(0, 0, -bill.ReminderDays),
+				AutoPay:      bill.AutoPay,
+			}
+			if duePayments := paymentsByDueDate[dueDate.Unix()]; len(duePayments) > 0 {
+				latest := duePayments[len(duePayments)-1]
+				occurrence.Status = models.BillPaid
+				occurrence.PaymentID = &latest.ID
+				occurrence.PaidDate = &latest.PaymentDate
+				for _, payment := range duePayments {
+					occurrence.PaidAmount += payment.Amount
+				}
+			} else if dueDate.Before(today) {
+				occurrence.Status = models.BillOverdue
+			}
+			occurrences = append(occurrences, occurrence)
+		}
+	}
+
+	sort.SliceStable(occurrences, func(i, j int) bool {
+		return occurrences[i].DueDate.Before(occurrences[j].DueDate)
+	})
+	return occurrences, nil
+}
+
+// BillPaymentDueDate returns the due date a payment settles: the one stored
+// on it, or else the first due date on or after the day it was paid in the
+// calendar's time zone, so that a second payment in a period settles the
+// same due date and a payment after a missed one settles its own
+func BillPaymentDueDate(bill *models.Bill, payment *models.BillPayment, cal BudgetCalendar) (time.Time, bool) {
+	if payment.DueDate != nil {
+		return payment.DueDate.UTC(), true
+	}
+	return bill.NextDueDate(cal.Today(payment.PaymentDate))
+}
+
+// UpcomingBills returns the user's active bills falling due from the day of
+// from up to and including the day of to, together with every earlier due
+// date still unpaid
+func UpcomingBills(db *gorm.DB, userID uuid.UUID, from, to, now time.Time) ([]BillOccurrence, error) {
+	var bills []models.Bill
+	if err := db.Where("user_id = ? AND active = ?", userID, true).Find(&bills).Error; err != nil {
+		return nil, err
+	}
+
+	schedule, err := BillSchedule(db, bills, to.AddDate(0, 0, 1), now)
+	if err != nil {
+		return nil, err
+	}
+
+	start := from.UTC().Truncate(24 * time.Hour)
+	upcoming := []BillOccurrence{}
+	for _, occurrence := range schedule {
+		if !occurrence.DueDate.Before(start) || occurrence.Status == models.BillOverdue {
+			upcoming = append(upcoming, occurrence)
+		}
+	}
+	return upcoming, nil
+}
+
+// BillPaymentRequest describes a payment of a bill
+type BillPaymentRequest struct {
+	Amount     models.Money
+	Date       time.Time
+	DueDate    *time.Time         // Due date the payment settles; by default the first on or after Date
+	Account    *models.Account    // Pays the bill from the account
+	CreditCard *models.CreditCard // Charges the bill to the card
+	Notes      string
+	AutoPay    bool
+}
+
+// PayBill records a payment of the bill and updates its last payment. Paid
+// from an account or charged to a card, the payment is also posted as an
+// expense in the bill's category so that it counts toward spending; with
+// neither it is only recorded. The account or card must have the funds or
+// credit for it.
+func PayBill(tx *gorm.DB, bill *models.Bill, req BillPaymentRequest) (*models.BillPayment, error) {
+	payment := models.BillPayment{
+		UserID:      bill.UserID,
+		BillID:      bill.ID,
+		Amount:      req.Amount,
+		PaymentDate: req.Date,
+		Notes:       req.Notes,
+		AutoPay:     req.AutoPay,
+		DueDate:     req.DueDate,
+	}
+	if payment.DueDate == nil {
+		if dueDate, ok := BillPaymentDueDate(bill, &payment, UserBudgetCalendar(tx, bill.UserID)); ok {
+			payment.DueDate = &dueDate
+		}
+	}
+
+	if req.Account != nil || req.CreditCard != nil {
+		category := "other_expense"
+		if found, err := models.FindCategory(tx, bill.UserID, bill.Category); err == nil {
+			category = found.Key
+		}
+
+		transaction := models.Transaction{
+			UserID:      bill.UserID,
+			Type:        "expense",
+			Amount:      req.Amount,
+			Date:        req.Date,
+			Description: "Bill payment: " + bill.Name,
+			CategoryID:  category,
+		}
+
+		if req.Account != nil {
+			account := req.Account
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(account, "id = ?", account.ID).Error; err != nil {
+				return nil, err
+			}
+			if req.Amount > account.Balance {
+				return nil, ErrInsufficientFunds
+			}
+			transaction.AccountID = account.ID
+			payment.AccountID = &account.ID
+		} else {
+			card := req.CreditCard
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(card, "id = ?", card.ID).Error; err != nil {
+				return nil, err
+			}
+			if req.Amount > card.CreditLimit-card.CurrentBalance {
+				return nil, ErrInsufficientCredit
+			}
+			transaction.AccountID = card.ID
+			transaction.CreditCardID = &card.ID
+			payment.CreditCardID = &card.ID
+		}
+
+		if err := tx.Create(&transaction).Error; err != nil {
+			return nil, err
+		}
+
+		// A bill charged to a card is a purchase on it and earns rewards
+		if req.CreditCard != nil {
+			ccTransaction := models.CreditCardTransaction{
+				UserID:        bill.UserID,
+				CardID:        req.CreditCard.ID,
+				TransactionID: transaction.ID,
+				CategoryID:    category,
+				Amount:        req.Amount,
+				Description:   transaction.Description,
+				Merchant:      bill.Name,
+				Date:          req.Date,
+				Type:          "purchase",
+			}
+			if err := tx.Create(&ccTransaction).Error; err != nil {
+				return nil, err
+			}
+			if _, err := EarnRewards(tx, req.CreditCard, &ccTransaction); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := transaction.ApplyBalance(tx); err != nil {
+			return nil, err
+		}
+		payment.TransactionID = &transaction.ID
+	}
+
+	if err := tx.Create(&payment).Error; err != nil {
+		return nil, err
+	}
+
+	bill.LastPaidDate = &payment.PaymentDate
+	bill.LastPaidAmount = payment.Amount
+	bill.AutoPayError = ""
+	bill.AutoPayFailedAt = nil
+	err := tx.Model(bill).UpdateColumns(map[string]interface{}{
+		"last_paid_date":     bill.LastPaidDate,
+		"last_paid_amount":   bill.LastPaidAmount,
+		"auto_pay_error":     "",
+		"auto_pay_failed_at": nil,
+	}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &payment, nil
+}
+
+// BillPaymentSource loads the bill's default account or credit card into a
+// payment request
+func BillPaymentSource(tx *gorm.DB, bill *models.Bill, req *BillPaymentRequest) error {
+	switch {
+	case bill.AccountID != nil:
+		var account models.Account
+		if err := tx.Where("id = ? AND user_id = ?", *bill.AccountID, bill.UserID).First(&account).Error; err != nil {
+			return err
+		}
+		req.Account = &account
+	case bill.CreditCardID != nil:
+		var card models.CreditCard
+		if err := tx.Where("id = ? AND user_id = ?", *bill.CreditCardID, bill.UserID).First(&card).Error; err != nil {
+			return err
+		}
+		req.CreditCard = &card
+	}
+	return nil
+}
+
+// RemoveTransactionBillPayments deletes the bill payments posted as the
+// transaction, so the due dates they settled are unpaid again, and moves
+// each bill's last payment back to its latest remaining one
+func RemoveTransactionBillPayments(tx *gorm.DB, transactionID uuid.UUID) error {
+	var payments []models.BillPayment
+	if err := tx.Where("transaction_id = ?", transactionID).Find(&payments).Error; err != nil {
+		return err
+	}
+
+	for i := range payments {
+		if err := tx.Delete(&payments[i]).Error; err != nil {
+			return err
+		}
+
+		var latest models.BillPayment
+		if err := tx.Where("bill_id = ?", payments[i].BillID).Order("payment_date DESC").Limit(1).Find(&latest).Error; err != nil {
+			return err
+		}
+		updates := map[string]interface{}{"last_paid_date": nil, "last_paid_amount": models.Money(0)}
+		if latest.ID != uuid.Nil {
+			updates = map[string]interface{}{"last_paid_date": latest.PaymentDate, "last_paid_amount": latest.Amount}
+		}
+		if err := tx.Model(&models.Bill{}).Where("id = ?", payments[i].BillID).UpdateColumns(updates).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// errBillDueDateHandled signals that another worker already handled a due date
+var errBillDueDateHandled = errors.New("bill due date already handled")
+
+// ProcessBillAutoPays pays the due dates of all active autopay bills that
+// have arrived, including those missed while the server was down
+func ProcessBillAutoPays(db *gorm.DB, now time.Time) error {
+	var bills []models.Bill
+	if err := db.Where("auto_pay = ? AND active = ?", true, true).Find(&bills).Error; err != nil {
+		return err
+	}
+
+	for i := range bills {
+		paid, err := ProcessBillAutoPay(db, &bills[i], now)
+		if err != nil {
+			log.Printf("Failed to autopay bill %s: %v", bills[i].ID, err)
+		}
+		if paid > 0 {
+			log.Printf("Bill %s: autopaid %d due date(s)", bills[i].ID, paid)
+		}
+	}
+
+	return nil
+}
+
+// ProcessBillAutoPay pays each due date of the bill from after AutoPayThrough
+// through today in the user's time zone, using the bill's default account or
+// card, and advances AutoPayThrough. Due dates already paid by hand are passed over. When the
+// account lacks the funds, the card the credit or the bill has neither, the
+// due date is skipped and the reason recorded on the bill. Each due date is
+// committed in its own database transaction.
+func ProcessBillAutoPay(db *gorm.DB, bill *models.Bill, now time.Time) (int, error) {
+	today := UserBudgetCalendar(db, bill.UserID).Today(now)
+	from := today
+	if bill.AutoPayThrough != nil {
+		from = bill.AutoPayThrough.AddDate(0, 0, 1)
+	}
+
+	paid := 0
+	for _, dueDate := range bill.DueDatesBetween(from, today.AddDate(0, 0, 1)) {
+		var payment *models.BillPayment
+		err := db.Transaction(func(tx *gorm.DB) error {
+			// Claim the due date first so concurrent runs cannot pay it twice
+			claim := tx.Model(&models.Bill{}).
+				Where("id = ? AND (auto_pay_through IS NULL OR auto_pay_through < ?)", bill.ID, dueDate).
+				Update("auto_pay_through", dueDate)
+			if claim.Error != nil {
+				return claim.Error
+			}
+			if claim.RowsAffected == 0 {
+				return errBillDueDateHandled
+			}
+
+			schedule, err := BillSchedule(tx, []models.Bill{*bill}, dueDate.AddDate(0, 0, 1), now)
+			if err != nil {
+				return err
+			}
+			for _, occurrence := range schedule {
+				if occurrence.DueDate.Equal(dueDate) && occurrence.Status == models.BillPaid {
+					return nil
+				}
+			}
+
+			req := BillPaymentRequest{Amount: bill.Amount, Date: dueDate, DueDate: &dueDate, Notes: "Autopay", AutoPay: true}
+			if err := BillPaymentSource(tx, bill, &req); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			if req.Account == nil && req.CreditCard == nil {
+				return flagAutoPay(tx, bill, ErrNoPaymentSource, now)
+			}
+
+			payment, err = PayBill(tx, bill, req)
+			if errors.Is(err, ErrInsufficientFunds) || errors.Is(err, ErrInsufficientCredit) {
+				return flagAutoPay(tx, bill, err, now)
+			}
+			return err
+		})
+		if errors.Is(err, errBillDueDateHandled) {
+			break
+		}
+		if err != nil {
+			return paid, err
+		}
+
+		bill.AutoPayThrough = &dueDate
+		if payment != nil {
+			paid++
+		}
+	}
+
+	return paid, nil
+}
+
+// flagAutoPay records on the bill why autopay skipped a due date
+func flagAutoPay(tx *gorm.DB, bill *models.Bill, reason error, now time.Time) error {
+	log.Printf("Bill %s: autopay skipped: %v", bill.ID, reason)
+	bill.AutoPayError = reason.Error()
+	bill.AutoPayFailedAt = &now
+	return tx.Model(bill).UpdateColumns(map[string]interface{}{
+		"auto_pay_error":     bill.AutoPayError,
+		"auto_pay_failed_at": now,
+	}).Error
+}
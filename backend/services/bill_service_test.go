@@ -0,0 +1,89 @@
+package services
+
+import (
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"daybook-backend/models"
+
+	"github.com/google/uuid"
+)
+
+func TestBillSchedule(t *testing.T) {
+	bill := models.Bill{
+		ID:        uuid.New(),
+		UserID:    uuid.New(),
+		Name:      "Rent",
+		Amount:    10000000,
+		Frequency: models.FrequencyMonthly,
+		DueDay:    31,
+		StartDate: utcDate(2024, 1, 1),
+		CreatedAt: utcDate(2024, 1, 1),
+	}
+
+	// The settings query gets the same rows; they have no time zone, so the
+	// user's calendar is UTC
+	payment := func(id uuid.UUID, amount string, paid time.Time, due *time.Time) []driver.Value {
+		var dueDate driver.Value
+		if due != nil {
+			dueDate = *due
+		}
+		return []driver.Value{id.String(), bill.ID.String(), amount, paid, dueDate}
+	}
+	earlyID, janID, janTopUpID, aprID, storedID := uuid.New(), uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	db := stubDB(t, []string{"id", "bill_id", "amount", "payment_date", "due_date"},
+		payment(earlyID, "100.0000", utcDate(2023, 12, 20), nil),   // Before the start; counts toward the first due date
+		payment(janID, "600.0000", utcDate(2024, 1, 25), nil),      // Settles Jan 31
+		payment(janTopUpID, "400.0000", utcDate(2024, 1, 31), nil), // Second payment of the same period
+		payment(aprID, "1000.0000", utcDate(2024, 4, 2), nil),      // Feb 29 and Mar 31 are missed; settles Apr 30
+		payment(storedID, "1000.0000", utcDate(2024, 5, 3), utcDatePtr(2024, 3, 31)),
+	)
+
+	occurrences, err := BillSchedule(db, []models.Bill{bill}, utcDate(2024, 6, 1), utcDate(2024, 5, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		dueDate    time.Time
+		status     string
+		paymentID  *uuid.UUID
+		paidAmount models.Money
+	}{
+		{utcDate(2024, 1, 31), models.BillPaid, &janTopUpID, 11000000},
+		{utcDate(2024, 2, 29), models.BillOverdue, nil, 0},
+		{utcDate(2024, 3, 31), models.BillPaid, &storedID, 10000000},
+		{utcDate(2024, 4, 30), models.BillPaid, &aprID, 10000000},
+		{utcDate(2024, 5, 31), models.BillUnpaid, nil, 0},
+	}
+	if len(occurrences) != len(want) {
+		t.Fatalf("got %d occurrences, want %d: %+v", len(occurrences), len(want), occurrences)
+	}
+	for i, w := range want {
+		got := occurrences[i]
+		if !got.DueDate.Equal(w.dueDate) || got.Status != w.status || got.PaidAmount != w.paidAmount {
+			t.Errorf("occurrence %d = %v %s %v, want %v %s %v", i, got.DueDate, got.Status, got.PaidAmount, w.dueDate, w.status, w.paidAmount)
+		}
+		if (got.PaymentID == nil) != (w.paymentID == nil) || (w.paymentID != nil && *got.PaymentID != *w.paymentID) {
+			t.Errorf("occurrence %d payment = %v, want %v", i, got.PaymentID, w.paymentID)
+		}
+	}
+	if got := occurrences[4].DaysUntilDue; got != 21 {
+		t.Errorf("DaysUntilDue = %d, want 21", got)
+	}
+}
+
+func TestBillPaymentDueDateInTimeZone(t *testing.T) {
+	bill := models.Bill{Frequency: models.FrequencyMonthly, DueDay: 31, StartDate: utcDate(2024, 1, 1)}
+	dhaka := BudgetCalendar{Location: time.FixedZone("Asia/Dhaka", 6*60*60)}
+
+	// Late on Feb 29 UTC is already Mar 1 in Dhaka
+	payment := models.BillPayment{PaymentDate: time.Date(2024, 2, 29, 20, 0, 0, 0, time.UTC)}
+	if got, ok := BillPaymentDueDate(&bill, &payment, dhaka); !ok || !got.Equal(utcDate(2024, 3, 31)) {
+		t.Errorf("BillPaymentDueDate = %v, %v, want 2024-03-31", got, ok)
+	}
+	if got, ok := BillPaymentDueDate(&bill, &payment, BudgetCalendar{Location: time.UTC}); !ok || !got.Equal(utcDate(2024, 2, 29)) {
+		t.Errorf("BillPaymentDueDate in UTC = %v, %v, want 2024-02-29", got, ok)
+	}
+}
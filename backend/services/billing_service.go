@@ -0,0 +1,299 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"daybook-backend/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxCatchUpStatements bounds how many billing cycles a single card can close
+// in one run
+const maxCatchUpStatements = 120
+
+// errCycleOpen signals that the card has no completed cycle left to close
+var errCycleOpen = errors.New("billing cycle still open")
+
+// cycleActivitySQL totals a card's transactions in a billing cycle. Purchases,
+// fees and interest are told apart by their credit card transaction; card
+// transactions entered elsewhere count as purchases. Payments are booked on
+// the paying account with the card amount in to_amount when the currencies
+// differ, as in the journal.
+const cycleActivitySQL = `
+SELECT
+	COALESCE(SUM(CASE WHEN t.account_id = t.credit_card_id AND t.type = 'expense'
+		AND COALESCE(cct.type, 'purchase') NOT IN ('fee', 'interest') THEN t.amount END), 0) AS charges,
+	COALESCE(SUM(CASE WHEN t.account_id = t.credit_card_id AND t.type = 'expense'
+		AND cct.type = 'fee' THEN t.amount END), 0) AS fees,
+	COALESCE(SUM(CASE WHEN t.account_id = t.credit_card_id AND t.type = 'expense'
+		AND cct.type = 'interest' THEN t.amount END), 0) AS interest,
+	COALESCE(SUM(CASE WHEN t.account_id = t.credit_card_id AND t.type = 'income'
+		THEN t.amount END), 0) AS refunds,
+	COALESCE(SUM(CASE WHEN t.account_id <> t.credit_card_id
+		THEN COALESCE(t.to_amount, t.amount) END), 0) AS payments
+FROM transactions t
+LEFT JOIN credit_card_transactions cct ON cct.transaction_id = t.id AND cct.deleted_at IS NULL
+WHERE t.credit_card_id = @card AND t.deleted_at IS NULL AND t.type <> 'tracking'
+	AND t.date >= @start AND t.date < @end`
+
+// cycleActivity is the money that moved on a card during one billing cycle
+type cycleActivity struct {
+	Charges  models.Money
+	Fees     models.Money
+	Interest models.Money
+	Refunds  models.Money
+	Payments models.Money
+}
+
+// CloseBillingCycles generates a statement for every completed billing cycle
+// of the cards that have one configured, including cycles that ended while
+// the server was down, refreshes which statements have been paid and charges
+// late fees on those past due
+func CloseBillingCycles(db *gorm.DB, now time.Time) error {
+	var cards []models.CreditCard
+	if err := db.Where("statement_day > 0").Find(&cards).Error; err != nil {
+		return err
+	}
+
+	for i := range cards {
+		created, err := CloseBillingCycle(db, &cards[i], now)
+		if err != nil {
+			log.Printf("Failed to close billing cycle of credit card %s: %v", cards[i].ID, err)
+		}
+		if created > 0 {
+			log.Printf("Credit card %s: generated %d statement(s)", cards[i].ID, created)
+		}
+
+		if err := UpdateStatementPayments(db, cards[i].ID); err != nil {
+			log.Printf("Failed to update statement payments of credit card %s: %v", cards[i].ID, err)
+		}
+
+		if err := AssessLateFees(db, &cards[i], now); err != nil {
+			log.Printf("Failed to assess late fees of credit card %s: %v", cards[i].ID, err)
+		}
+	}
+
+	return nil
+}
+
+// CloseBillingCycle generates the statements of card's cycles that ended on
+// or before now, charging the late fees and interest due in each cycle, and
+// rolls the card's statement date, due date and minimum payment forward.
+// Each cycle is committed in its own database transaction.
+//
+// The first statement of a card covers its most recently completed cycle;
+// later ones follow on from the card's latest statement, whether generated
+// or entered by hand.
+func CloseBillingCycle(db *gorm.DB, card *models.CreditCard, now time.Time) (int, error) {
+	created := 0
+
+	for created < maxCatchUpStatements {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			// Lock the card so concurrent runs cannot close the same cycle twice
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(card, "id = ?", card.ID).Error; err != nil {
+				return err
+			}
+			if !card.HasBillingCycle() {
+				return errCycleOpen
+			}
+
+			// Payments since the last run decide whether earlier statements
+			// were paid on time
+			if err := UpdateStatementPayments(tx, card.ID); err != nil {
+				return err
+			}
+
+			var previous models.Statement
+			if err := tx.Where("card_id = ?", card.ID).Order("statement_date DESC").Limit(1).Find(&previous).Error; err != nil {
+				return err
+			}
+
+			var statementDate, periodStart time.Time
+			if previous.ID != uuid.Nil {
+				statementDate = card.NextStatementDate(previous.StatementDate)
+				periodStart = previous.StatementDate.AddDate(0, 0, 1)
+			} else {
+				statementDate = card.LastClosedStatementDate(now)
+				// Skip cycles that ended before the card was added
+				if !statementDate.AddDate(0, 0, 1).After(card.CreatedAt) {
+					statementDate = card.NextStatementDate(card.CreatedAt)
+				}
+				periodStart = card.PreviousStatementDate(statementDate).AddDate(0, 0, 1)
+			}
+			if statementDate.AddDate(0, 0, 1).After(now) {
+				return errCycleOpen
+			}
+
+			// Late fees and interest are charged within the cycle so that they
+			// appear on its statement
+			if err := assessLateFees(tx, card, statementDate.AddDate(0, 0, 1), periodStart); err != nil {
+				return err
+			}
+			if err := accrueInterest(tx, card, &previous, periodStart, statementDate); err != nil {
+				return err
+			}
+
+			statement, err := buildStatement(tx, card, &previous, periodStart, statementDate)
+			if err != nil {
+				return err
+			}
+			if err := tx.Create(statement).Error; err != nil {
+				return err
+			}
+
+			return tx.Model(card).UpdateColumns(map[string]interface{}{
+				"statement_date":  card.NextStatementDate(statementDate),
+				"due_date":        statement.DueDate,
+				"minimum_payment": statement.MinimumPayment,
+			}).Error
+		})
+		if errors.Is(err, errCycleOpen) {
+			break
+		}
+		if err != nil {
+			return created, err
+		}
+
+		created++
+	}
+
+	return created, nil
+}
+
+// buildStatement computes the statement of the cycle from periodStart through
+// the end of statementDate. The opening balance carries over the previous
+// statement's closing balance; a card's first statement opens with the
+// balance its journal held when the cycle began.
+func buildStatement(tx *gorm.DB, card *models.CreditCard, previous *models.Statement, periodStart, statementDate time.Time) (*models.Statement, error) {
+	var activity cycleActivity
+	err := tx.Raw(cycleActivitySQL, map[string]interface{}{
+		"card":  card.ID,
+		"start": periodStart,
+		"end":   statementDate.AddDate(0, 0, 1),
+	}).Scan(&activity).Error
+	if err != nil {
+		return nil, err
+	}
+
+	opening := previous.ClosingBalance
+	if previous.ID == uuid.Nil {
+		opening, err = cardBalanceBefore(tx, card.ID, periodStart)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	closing := opening + activity.Charges + activity.Fees + activity.Interest - activity.Refunds - activity.Payments
+
+	return &models.Statement{
+		UserID:          card.UserID,
+		CardID:          card.ID,
+		StatementDate:   statementDate,
+		DueDate:         card.PaymentDueDate(statementDate),
+		PeriodStart:     &periodStart,
+		OpeningBalance:  opening,
+		ClosingBalance:  closing,
+		MinimumPayment:  card.MinimumPaymentFor(closing, activity.Interest, activity.Fees),
+		TotalCharges:    activity.Charges,
+		TotalFees:       activity.Fees,
+		TotalRefunds:    activity.Refunds,
+		TotalPayments:   activity.Payments,
+		InterestCharged: activity.Interest,
+		Generated:       true,
+	}, nil
+}
+
+// cardBalanceBefore returns what was owed on the card before the given time
+// according to its journal postings
+func cardBalanceBefore(tx *gorm.DB, cardID uuid.UUID, before time.Time) (models.Money, error) {
+	var row struct {
+		Total models.Money
+	}
+	err := tx.Model(&models.Posting{}).
+		Select("COALESCE(SUM(postings.amount), 0) AS total").
+		Joins("JOIN journal_entries ON journal_entries.id = postings.entry_id").
+		Where("postings.ledger_type = ? AND postings.ledger_id = ? AND journal_entries.date < ?", models.LedgerCreditCard, cardID, before).
+		Scan(&row).Error
+	if err != nil {
+		return 0, err
+	}
+	return -row.Total, nil
+}
+
+// UpdateStatementPayments marks each generated statement of the card paid
+// once the payments made after its statement date add up to its closing
+// balance, and unpaid again when those payments are removed. A statement
+// with nothing owed is paid on its statement date. Statements entered by
+// hand keep the status they were given.
+func UpdateStatementPayments(db *gorm.DB, cardID uuid.UUID) error {
+	var statements []models.Statement
+	if err := db.Where("card_id = ? AND generated = ?", cardID, true).Order("statement_date").Find(&statements).Error; err != nil {
+		return err
+	}
+	if len(statements) == 0 {
+		return nil
+	}
+
+	var payments []struct {
+		Date   time.Time
+		Amount models.Money
+	}
+	err := db.Model(&models.Transaction{}).
+		Select("date, COALESCE(to_amount, amount) AS amount").
+		Where("credit_card_id = ? AND account_id <> credit_card_id AND type <> ?", cardID, "tracking").
+		Where("date >= ?", statements[0].StatementDate.AddDate(0, 0, 1)).
+		Order("date").
+		Scan(&payments).Error
+	if err != nil {
+		return err
+	}
+
+	for i := range statements {
+		statement := &statements[i]
+		paid := false
+		var paidDate *time.Time
+
+		if statement.ClosingBalance <= 0 {
+			paid = true
+			paidDate = &statement.StatementDate
+		} else {
+			periodEnd := statement.StatementDate.AddDate(0, 0, 1)
+			var total models.Money
+			for j := range payments {
+				if payments[j].Date.Before(periodEnd) {
+					continue
+				}
+				total += payments[j].Amount
+				if total >= statement.ClosingBalance {
+					paid = true
+					paidDate = &payments[j].Date
+					break
+				}
+			}
+		}
+
+		if paid == statement.Paid && sameTime(paidDate, statement.PaidDate) {
+			continue
+		}
+		if err := db.Model(statement).UpdateColumns(map[string]interface{}{
+			"paid":      paid,
+			"paid_date": paidDate,
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
@@ -0,0 +1,519 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"daybook-backend/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Budget errors caused by the budget's settings rather than the database
+var (
+	ErrCustomBudgetDates   = errors.New("custom budget dates not set")
+	ErrInvalidBudgetPeriod = errors.New("invalid budget period")
+)
+
+// BudgetProgress is how much of a budget has been spent in one period
+type BudgetProgress struct {
+	Budget         *models.Budget `json:"budget,omitempty"`
+	Currency       string         `json:"currency"`
+	Amount         models.Money   `json:"amount"`         // The budget amount for the period
+	RolloverAmount models.Money   `json:"rolloverAmount"` // Unused (positive) or overspent (negative) budget carried over from earlier periods
+	Available      models.Money   `json:"available"`      // Amount plus RolloverAmount
+	TotalSpent     models.Money   `json:"totalSpent"`
+	Remaining      models.Money   `json:"remaining"`
+	PercentageUsed float64        `json:"percentageUsed"` // Of Available
+	StartDate      time.Time      `json:"startDate"`
+	EndDate        time.Time      `json:"endDate"`
+	IsOverBudget   bool           `json:"isOverBudget"`
+	AlertTriggered bool           `json:"alertTriggered"` // PercentageUsed has reached the budget's AlertThreshold
+}
+
+// BudgetPeriod is one period of a budget, from Start up to but not
+// including End
+type BudgetPeriod struct {
+	Start time.Time
+	End   time.Time
+}
+
+// BudgetCalendar lays out a user's budget periods: days start at midnight in
+// Location and weeks start on WeekStart
+type BudgetCalendar struct {
+	Location  *time.Location
+	WeekStart time.Weekday
+}
+
+// UserBudgetCalendar returns the budget calendar from the user's time zone
+// and first day of week settings
+func UserBudgetCalendar(db *gorm.DB, userID uuid.UUID) BudgetCalendar {
+	settings := models.UserSettings(db, userID)
+	return BudgetCalendar{
+		Location:  settings.Location(),
+		WeekStart: time.Weekday(settings.FirstDayOfWeek % 7),
+	}
+}
+
+// Today returns the day containing now in the calendar's time zone, at
+// midnight UTC like bill and statement due dates
+func (cal BudgetCalendar) Today(now time.Time) time.Time {
+	local := now.In(cal.Location)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// BudgetPeriodBounds returns the start and end of the budget's period that
+// contains date
+func BudgetPeriodBounds(budget *models.Budget, date time.Time, cal BudgetCalendar) (time.Time, time.Time, error) {
+	var startDate, endDate time.Time
+	now := date.In(cal.Location)
+
+	switch budget.Period {
+	case "weekly":
+		// Start of current week
+		offset := (int(now.Weekday()) - int(cal.WeekStart) + 7) % 7
+		startDate = time.Date(now.Year(), now.Month(), now.Day()-offset, 0, 0, 0, 0, cal.Location)
+		endDate = startDate.AddDate(0, 0, 7)
+
+	case "monthly":
+		// Start of current month
+		startDate = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, cal.Location)
+		endDate = startDate.AddDate(0, 1, 0)
+
+	case "quarterly":
+		// Start of current quarter
+		currentMonth := int(now.Month())
+		quarterStartMonth := ((currentMonth-1)/3)*3 + 1
+		startDate = time.Date(now.Year(), time.Month(quarterStartMonth), 1, 0, 0, 0, 0, cal.Location)
+		endDate = startDate.AddDate(0, 3, 0)
+
+	case "yearly":
+		// Start of current year
+		startDate = time.Date(now.Year(), 1, 1, 0, 0, 0, 0, cal.Location)
+		endDate = startDate.AddDate(1, 0, 0)
+
+	case "custom":
+		if budget.CustomStartDate == nil || budget.CustomEndDate == nil {
+			return time.Time{}, time.Time{}, ErrCustomBudgetDates
+		}
+		startDate = *budget.CustomStartDate
+		endDate = *budget.CustomEndDate
+
+	default:
+		return time.Time{}, time.Time{}, ErrInvalidBudgetPeriod
+	}
+
+	return startDate, endDate, nil
+}
+
+// BudgetPeriodsBetween returns the budget's periods from the one
+// containing from through the one containing to. A custom budget has a
+// single period.
+func BudgetPeriodsBetween(budget *models.Budget, from, to time.Time, cal BudgetCalendar) ([]BudgetPeriod, error) {
+	start, end, err := BudgetPeriodBounds(budget, from, cal)
+	if err != nil {
+		return nil, err
+	}
+	periods := []BudgetPeriod{{Start: start, End: end}}
+	if budget.Period == "custom" {
+		return periods, nil
+	}
+
+	for !to.Before(end) {
+		if start, end, err = BudgetPeriodBounds(budget, end, cal); err != nil {
+			return nil, err
+		}
+		periods = append(periods, BudgetPeriod{Start: start, End: end})
+	}
+	return periods, nil
+}
+
+// CalculateBudgetProgress totals the expenses in the budget's category and
+// its subcategories during the period containing date, including what
+// rolled over from earlier periods
+func CalculateBudgetProgress(db *gorm.DB, budget *models.Budget, date time.Time) (*BudgetProgress, error) {
+	history, err := CalculateBudgetHistory(db, budget, date, 1)
+	if err != nil {
+		return nil, err
+	}
+	progress := history[0]
+	progress.Budget = budget
+	return &progress, nil
+}
+
+// CalculateBudgetHistory returns the progress of the last count periods of
+// the budget, ending with the period containing date, oldest first.
+//
+// Spending counts the expenses in the budget's category and its
+// subcategories. Budgets are in the user's currency, so spending in other
+// currencies is converted as of each transaction's date.
+//
+// A rollover budget carries what is left of each period, or the amount it
+// was overspent by, into the next one, starting with the period the budget
+// was created in.
+func CalculateBudgetHistory(db *gorm.DB, budget *models.Budget, date time.Time, count int) ([]BudgetProgress, error) {
+	cal := UserBudgetCalendar(db, budget.UserID)
+	periods, first, err := budgetHistoryPeriods(budget, date, count, cal)
+	if err != nil {
+		return nil, err
+	}
+
+	// Spending in subcategories counts towards the parent category's budget
+	categoryKeys, err := models.CategoryKeysWithDescendants(db, budget.UserID, budget.CategoryID)
+	if err != nil {
+		return nil, err
+	}
+	spending, currency, err := loadCategoryTotals(db, budget.UserID, []string{"expense"}, categoryKeys, periods[0].Start, periods[len(periods)-1].End, cal)
+	if err != nil {
+		return nil, err
+	}
+
+	spent := spendingByPeriod(spending, categoryKeys, periods)
+	return budgetHistory(budget, periods, spent, first, currency, cal), nil
+}
+
+// budgetHistoryPeriods returns the periods needed to report the last count
+// periods ending with the one containing date, which for a rollover budget
+// go back to the period it was created in, and the start of the first
+// period reported
+func budgetHistoryPeriods(budget *models.Budget, date time.Time, count int, cal BudgetCalendar) ([]BudgetPeriod, time.Time, error) {
+	// Step back from the period containing date to the first one reported
+	first, _, err := BudgetPeriodBounds(budget, date, cal)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if budget.Period != "custom" {
+		for i := 1; i < count; i++ {
+			if first, _, err = BudgetPeriodBounds(budget, first.AddDate(0, 0, -1), cal); err != nil {
+				return nil, time.Time{}, err
+			}
+		}
+	}
+
+	from := first
+	if budget.Rollover {
+		rolloverStart, _, err := BudgetPeriodBounds(budget, budget.CreatedAt, cal)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		if rolloverStart.Before(from) {
+			from = rolloverStart
+		}
+	}
+
+	periods, err := BudgetPeriodsBetween(budget, from, date, cal)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return periods, first, nil
+}
+
+// budgetHistory works out the progress of each period from what was spent
+// in it, carrying the rollover along, and returns the periods from first on
+func budgetHistory(budget *models.Budget, periods []BudgetPeriod, spent []models.Money, first time.Time, currency string, cal BudgetCalendar) []BudgetProgress {
+	// The periods were laid out without error, so the creation period is too
+	rolloverStart, _, _ := BudgetPeriodBounds(budget, budget.CreatedAt, cal)
+
+	var history []BudgetProgress
+	var rollover models.Money
+	for i, period := range periods {
+		available := budget.Amount + rollover
+		// Nothing is left to use when earlier periods overspent it all
+		var percentageUsed float64
+		if available > 0 {
+			percentageUsed = spent[i].Ratio(available) * 100
+		}
+		progress := BudgetProgress{
+			Currency:       currency,
+			Amount:         budget.Amount,
+			RolloverAmount: rollover,
+			Available:      available,
+			TotalSpent:     spent[i],
+			Remaining:      available - spent[i],
+			PercentageUsed: percentageUsed,
+			StartDate:      period.Start,
+			EndDate:        period.End,
+			IsOverBudget:   spent[i] > available,
+			AlertTriggered: percentageUsed >= budget.AlertThreshold || spent[i] > available,
+		}
+		if budget.Rollover && !period.Start.Before(rolloverStart) {
+			rollover = progress.Remaining
+		}
+		if !period.Start.Before(first) {
+			history = append(history, progress)
+		}
+	}
+	return history
+}
+
+// categoryTotal is the total of the user's transactions of one type in one
+// category on one day, in the user's currency
+type categoryTotal struct {
+	Type       string
+	CategoryID string // Lowercased
+	Day        time.Time
+	Amount     models.Money
+}
+
+// loadCategoryTotals totals the user's transactions of the types from from
+// up to to by type, category and day in the calendar's time zone with one
+// grouped query, attributing split transactions to the category of each
+// split, and converts them to the user's currency as of each day.
+// Without category keys it covers every category.
+func loadCategoryTotals(db *gorm.DB, userID uuid.UUID, types []string, categoryKeys []string, from, to time.Time, cal BudgetCalendar) ([]categoryTotal, string, error) {
+	query := db.Model(&models.Transaction{}).
+		Select("transactions.type, LOWER("+splitCategory+") AS category_id, DATE(transactions.date AT TIME ZONE ?) AS day, COALESCE(credit_cards.currency, accounts.currency, '') AS currency, SUM("+splitAmount+") AS amount", cal.Location.String()).
+		Joins(splitsJoin).
+		Joins("LEFT JOIN accounts ON accounts.id = transactions.account_id").
+		Joins("LEFT JOIN credit_cards ON credit_cards.id = transactions.credit_card_id").
+		Where("transactions.user_id = ? AND transactions.type IN ? AND transactions.date >= ? AND transactions.date < ?", userID, types, from, to)
+	if categoryKeys != nil {
+		query = query.Where("LOWER("+splitCategory+") IN ?", categoryKeys)
+	}
+
+	var rows []struct {
+		Type       string
+		CategoryID string
+		Day        time.Time
+		Currency   string
+		Amount     models.Money
+	}
+	if err := query.Group("1, 2, 3, 4").Scan(&rows).Error; err != nil {
+		return nil, "", err
+	}
+
+	currency := models.UserCurrency(db, userID)
+	converter, err := models.NewCurrencyConverter(db, userID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	totals := make([]categoryTotal, 0, len(rows))
+	for _, row := range rows {
+		// Dates come back as midnight UTC; the day is in the user's time zone
+		day := time.Date(row.Day.Year(), row.Day.Month(), row.Day.Day(), 0, 0, 0, 0, cal.Location)
+		if row.Currency == "" {
+			row.Currency = currency
+		}
+		amount, err := converter.Convert(row.Amount, row.Currency, currency, day)
+		if err != nil {
+			return nil, "", err
+		}
+		totals = append(totals, categoryTotal{Type: row.Type, CategoryID: row.CategoryID, Day: day, Amount: amount})
+	}
+	return totals, currency, nil
+}
+
+// spendingByPeriod totals the spending in the categories for each of the
+// periods, which must be consecutive
+func spendingByPeriod(spending []categoryTotal, categoryKeys []string, periods []BudgetPeriod) []models.Money {
+	keys := make(map[string]bool, len(categoryKeys))
+	for _, key := range categoryKeys {
+		keys[key] = true
+	}
+
+	spent := make([]models.Money, len(periods))
+	for _, row := range spending {
+		if !keys[row.CategoryID] || row.Day.Before(periods[0].Start) {
+			continue
+		}
+		i := sort.Search(len(periods), func(i int) bool { return row.Day.Before(periods[i].End) })
+		if i < len(periods) {
+			spent[i] += row.Amount
+		}
+	}
+	return spent
+}
+
+// BudgetSummaryItem is a budget's progress in its current period together
+// with where spending is heading by the end of it
+type BudgetSummaryItem struct {
+	BudgetProgress
+	CategoryName        string       `json:"categoryName"`
+	DaysElapsed         int          `json:"daysElapsed"`
+	DaysInPeriod        int          `json:"daysInPeriod"`
+	ProjectedSpend      models.Money `json:"projectedSpend"` // TotalSpent extended to the whole period at the run rate so far
+	ProjectedOverBudget bool         `json:"projectedOverBudget"`
+}
+
+// CategorySpending is the spending in one category
+type CategorySpending struct {
+	CategoryID   string       `json:"categoryId"`
+	CategoryName string       `json:"categoryName"`
+	Amount       models.Money `json:"amount"`
+}
+
+// BudgetSummary is the progress of all of a user's enabled budgets
+type BudgetSummary struct {
+	Currency        string              `json:"currency"`
+	Budgets         []BudgetSummaryItem `json:"budgets"`
+	TotalBudgeted   models.Money        `json:"totalBudgeted"`
+	TotalAvailable  models.Money        `json:"totalAvailable"`
+	TotalSpent      models.Money        `json:"totalSpent"`
+	TotalRemaining  models.Money        `json:"totalRemaining"`
+	TotalProjected  models.Money        `json:"totalProjected"`
+	OverBudgetCount int                 `json:"overBudgetCount"`
+	AlertCount      int                 `json:"alertCount"`
+
+	// Expenses in the month containing the date in categories no enabled
+	// budget covers, largest first
+	UnbudgetedStartDate time.Time          `json:"unbudgetedStartDate"`
+	UnbudgetedEndDate   time.Time          `json:"unbudgetedEndDate"`
+	UnbudgetedSpending  []CategorySpending `json:"unbudgetedSpending"` // Leaves out models.NonSpendingCategories
+	TotalUnbudgeted     models.Money       `json:"totalUnbudgeted"`
+}
+
+// CalculateBudgetSummary works out the progress of every enabled budget in
+// the period containing date, loading all the spending it needs with one
+// grouped query. Budgets whose period cannot be worked out are left out.
+func CalculateBudgetSummary(db *gorm.DB, userID uuid.UUID, date time.Time) (*BudgetSummary, error) {
+	var budgets []models.Budget
+	if err := db.Where("user_id = ? AND enabled = ?", userID, true).Order("created_at ASC").Find(&budgets).Error; err != nil {
+		return nil, err
+	}
+	var categories []models.Category
+	if err := db.Where("user_id = ?", userID).Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	names := make(map[string]string, len(categories))
+	for _, category := range categories {
+		names[strings.ToLower(category.Key)] = category.Name
+	}
+
+	cal := UserBudgetCalendar(db, userID)
+	local := date.In(cal.Location)
+	monthStart := time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, cal.Location)
+	monthEnd := monthStart.AddDate(0, 1, 0)
+
+	// Lay out every budget's periods first so one query covers them all
+	type budgetPeriods struct {
+		budget  *models.Budget
+		periods []BudgetPeriod
+		first   time.Time
+		keys    []string
+	}
+	var laidOut []budgetPeriods
+	from, to := monthStart, monthEnd
+	budgeted := make(map[string]bool)
+	for i := range budgets {
+		budget := &budgets[i]
+		periods, first, err := budgetHistoryPeriods(budget, date, 1, cal)
+		if errors.Is(err, ErrCustomBudgetDates) || errors.Is(err, ErrInvalidBudgetPeriod) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		keys := models.CategoryDescendantKeys(categories, budget.CategoryID)
+		for _, key := range keys {
+			budgeted[key] = true
+		}
+		if periods[0].Start.Before(from) {
+			from = periods[0].Start
+		}
+		if periods[len(periods)-1].End.After(to) {
+			to = periods[len(periods)-1].End
+		}
+		laidOut = append(laidOut, budgetPeriods{budget: budget, periods: periods, first: first, keys: keys})
+	}
+
+	spending, currency, err := loadCategoryTotals(db, userID, []string{"expense"}, nil, from, to, cal)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &BudgetSummary{
+		Currency:            currency,
+		Budgets:             []BudgetSummaryItem{},
+		UnbudgetedStartDate: monthStart,
+		UnbudgetedEndDate:   monthEnd,
+		UnbudgetedSpending:  []CategorySpending{},
+	}
+	for _, entry := range laidOut {
+		spent := spendingByPeriod(spending, entry.keys, entry.periods)
+		progress := budgetHistory(entry.budget, entry.periods, spent, entry.first, currency, cal)[0]
+		progress.Budget = entry.budget
+
+		item := BudgetSummaryItem{BudgetProgress: progress, CategoryName: names[strings.ToLower(entry.budget.CategoryID)]}
+		item.DaysElapsed, item.DaysInPeriod, item.ProjectedSpend = projectBudgetSpend(&progress, date)
+		item.ProjectedOverBudget = item.ProjectedSpend > progress.Available
+
+		summary.Budgets = append(summary.Budgets, item)
+		summary.TotalBudgeted += progress.Amount
+		summary.TotalAvailable += progress.Available
+		summary.TotalSpent += progress.TotalSpent
+		summary.TotalRemaining += progress.Remaining
+		summary.TotalProjected += item.ProjectedSpend
+		if progress.IsOverBudget {
+			summary.OverBudgetCount++
+		}
+		if progress.AlertTriggered {
+			summary.AlertCount++
+		}
+	}
+
+	unbudgeted := make(map[string]models.Money)
+	for _, row := range spending {
+		if budgeted[row.CategoryID] || models.IsNonSpendingCategory(row.CategoryID) || row.Day.Before(monthStart) || !row.Day.Before(monthEnd) {
+			continue
+		}
+		unbudgeted[row.CategoryID] += row.Amount
+	}
+	for categoryID, amount := range unbudgeted {
+		name := names[categoryID]
+		if name == "" {
+			name = categoryID
+		}
+		summary.UnbudgetedSpending = append(summary.UnbudgetedSpending, CategorySpending{CategoryID: categoryID, CategoryName: name, Amount: amount})
+		summary.TotalUnbudgeted += amount
+	}
+	sort.Slice(summary.UnbudgetedSpending, func(i, j int) bool {
+		if summary.UnbudgetedSpending[i].Amount != summary.UnbudgetedSpending[j].Amount {
+			return summary.UnbudgetedSpending[i].Amount > summary.UnbudgetedSpending[j].Amount
+		}
+		return summary.UnbudgetedSpending[i].CategoryID < summary.UnbudgetedSpending[j].CategoryID
+	})
+
+	return summary, nil
+}
+
+// projectBudgetSpend returns how many days of the period have passed as of
+// date, counting date itself, how many days it has and the spending
+// projected for the whole period at the daily run rate so far
+func projectBudgetSpend(progress *BudgetProgress, date time.Time) (int, int, models.Money) {
+	daysInPeriod := calendarDaysBetween(progress.StartDate, progress.EndDate)
+	if daysInPeriod < 1 {
+		daysInPeriod = 1
+	}
+
+	var daysElapsed int
+	switch {
+	case date.Before(progress.StartDate):
+		daysElapsed = 0
+	case !date.Before(progress.EndDate):
+		daysElapsed = daysInPeriod
+	default:
+		daysElapsed = calendarDaysBetween(progress.StartDate, date) + 1
+		if daysElapsed > daysInPeriod {
+			daysElapsed = daysInPeriod
+		}
+	}
+
+	if daysElapsed == 0 || daysElapsed == daysInPeriod {
+		return daysElapsed, daysInPeriod, progress.TotalSpent
+	}
+	return daysElapsed, daysInPeriod, (progress.TotalSpent * models.Money(daysInPeriod)).Div(int64(daysElapsed))
+}
+
+// calendarDaysBetween counts the midnights from from to to in from's time
+// zone, so days shortened or lengthened by daylight saving count as one
+func calendarDaysBetween(from, to time.Time) int {
+	to = to.In(from.Location())
+	start := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	end := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	return int(end.Sub(start).Hours() / 24)
+}
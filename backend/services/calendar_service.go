@@ -0,0 +1,168 @@
+package services
+
+import (
+	"sort"
+	"time"
+
+	"daybook-backend/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Calendar event types
+const (
+	CalendarBill                 = "bill"
+	CalendarCreditCardDue        = "credit_card_due"
+	CalendarRecurringTransaction = "recurring_transaction"
+)
+
+// Statuses of recurring transaction occurrences on the calendar
+const (
+	RecurringProcessed = "processed" // The transaction has been created
+	RecurringScheduled = "scheduled"
+)
+
+// CalendarEvent is a dated money event shown on the calendar
+type CalendarEvent struct {
+	Date           time.Time     `json:"date"`
+	Type           string        `json:"type"`     // bill, credit_card_due, recurring_transaction
+	SourceID       uuid.UUID     `json:"sourceId"` // The bill, credit card or recurring transaction
+	Title          string        `json:"title"`
+	Amount         models.Money  `json:"amount"`
+	MinimumPayment *models.Money `json:"minimumPayment,omitempty"` // Credit card due dates only
+	Currency       string        `json:"currency,omitempty"`
+	Status         string        `json:"status"` // paid, unpaid, overdue; processed or scheduled for recurring transactions
+}
+
+// Calendar returns the user's bill due dates, credit card payment due dates
+// and recurring transactions from the day of from up to and including the
+// day of to, in date order
+func Calendar(db *gorm.DB, userID uuid.UUID, from, to, now time.Time) ([]CalendarEvent, error) {
+	start := from.UTC().Truncate(24 * time.Hour)
+	end := to.UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)
+	today := UserBudgetCalendar(db, userID).Today(now)
+	events := []CalendarEvent{}
+
+	// Bills
+	var bills []models.Bill
+	if err := db.Where("user_id = ? AND active = ?", userID, true).Find(&bills).Error; err != nil {
+		return nil, err
+	}
+	schedule, err := BillSchedule(db, bills, end, now)
+	if err != nil {
+		return nil, err
+	}
+	for _, occurrence := range schedule {
+		if occurrence.DueDate.Before(start) {
+			continue
+		}
+		events = append(events, CalendarEvent{
+			Date:     occurrence.DueDate,
+			Type:     CalendarBill,
+			SourceID: occurrence.BillID,
+			Title:    occurrence.Name,
+			Amount:   occurrence.Amount,
+			Status:   occurrence.Status,
+		})
+	}
+
+	// Credit card due dates, from statements and from the due date kept on
+	// cards whose statements are entered by hand
+	var cards []models.CreditCard
+	if err := db.Where("user_id = ? AND active = ?", userID, true).Find(&cards).Error; err != nil {
+		return nil, err
+	}
+	for i := range cards {
+		card := &cards[i]
+
+		var statements []models.Statement
+		if err := db.Where("card_id = ? AND due_date >= ? AND due_date < ?", card.ID, start, end).
+			Order("due_date ASC").Find(&statements).Error; err != nil {
+			return nil, err
+		}
+
+		coversCardDueDate := false
+		for j := range statements {
+			statement := &statements[j]
+			if card.DueDate != nil && statement.DueDate.Equal(*card.DueDate) {
+				coversCardDueDate = true
+			}
+			events = append(events, CalendarEvent{
+				Date:           statement.DueDate,
+				Type:           CalendarCreditCardDue,
+				SourceID:       card.ID,
+				Title:          card.Name,
+				Amount:         statement.ClosingBalance,
+				MinimumPayment: &statement.MinimumPayment,
+				Currency:       card.Currency,
+				Status:         dueStatus(statement.Paid, statement.DueDate, today),
+			})
+		}
+
+		if card.DueDate != nil && !coversCardDueDate && !card.DueDate.Before(start) && card.DueDate.Before(end) {
+			events = append(events, CalendarEvent{
+				Date:           *card.DueDate,
+				Type:           CalendarCreditCardDue,
+				SourceID:       card.ID,
+				Title:          card.Name,
+				Amount:         card.CurrentBalance,
+				MinimumPayment: &card.MinimumPayment,
+				Currency:       card.Currency,
+				Status:         dueStatus(card.CurrentBalance <= 0, *card.DueDate, today),
+			})
+		}
+	}
+
+	// Recurring transactions
+	var recurring []models.RecurringTransaction
+	if err := db.Where("user_id = ? AND enabled = ?", userID, true).Find(&recurring).Error; err != nil {
+		return nil, err
+	}
+	for i := range recurring {
+		rt := &recurring[i]
+		for n := 0; ; n++ {
+			occurrence, ok := models.AddFrequency(rt.StartDate, rt.Frequency, n)
+			if !ok || !occurrence.Before(end) || (rt.EndDate != nil && occurrence.After(*rt.EndDate)) {
+				break
+			}
+			if occurrence.Before(start) {
+				continue
+			}
+
+			status := RecurringScheduled
+			if rt.LastProcessed != nil && !occurrence.After(*rt.LastProcessed) {
+				status = RecurringProcessed
+			}
+			title := rt.TransactionTemplate.Description
+			if title == "" {
+				title = rt.TransactionTemplate.CategoryID
+			}
+			events = append(events, CalendarEvent{
+				Date:     occurrence,
+				Type:     CalendarRecurringTransaction,
+				SourceID: rt.ID,
+				Title:    title,
+				Amount:   rt.TransactionTemplate.Amount,
+				Status:   status,
+			})
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Date.Before(events[j].Date)
+	})
+	return events, nil
+}
+
+// dueStatus returns whether a payment due on dueDate is paid, overdue or
+// still to be paid as of today
+func dueStatus(paid bool, dueDate, today time.Time) string {
+	if paid {
+		return models.BillPaid
+	}
+	if dueDate.Before(today) {
+		return models.BillOverdue
+	}
+	return models.BillUnpaid
+}
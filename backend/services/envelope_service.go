@@ -0,0 +1,346 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"daybook-backend/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Envelope errors caused by the request rather than the database
+var (
+	ErrEnvelopeInsufficientFunds = errors.New("not enough available in the envelope")
+	ErrEnvelopeSameCategory      = errors.New("cannot move money to the same envelope")
+)
+
+// Envelope is one expense category's envelope in a month
+type Envelope struct {
+	CategoryID   string       `json:"categoryId"`
+	CategoryName string       `json:"categoryName"`
+	CarriedOver  models.Money `json:"carriedOver"` // Left in the envelope at the end of the previous month
+	Assigned     models.Money `json:"assigned"`
+	Spent        models.Money `json:"spent"` // Expenses in the category and subcategories without an envelope of their own
+	Available    models.Money `json:"available"`
+	Overspent    bool         `json:"overspent"` // Available is negative and needs covering from another envelope
+}
+
+// EnvelopeMonth is the envelope budget of one month
+type EnvelopeMonth struct {
+	Month     time.Time  `json:"month"`
+	StartDate time.Time  `json:"startDate"`
+	EndDate   time.Time  `json:"endDate"`
+	Currency  string     `json:"currency"`
+	Envelopes []Envelope `json:"envelopes"`
+
+	Income    models.Money `json:"income"`
+	Assigned  models.Money `json:"assigned"`
+	Spent     models.Money `json:"spent"`
+	Available models.Money `json:"available"` // Across all envelopes
+
+	// Money received but not yet given to an envelope. It is reduced by
+	// spending outside any envelope and by overspending left uncovered at
+	// the end of earlier months.
+	ReadyToAssign        models.Money `json:"readyToAssign"`
+	UnenvelopedSpending  models.Money `json:"unenvelopedSpending"`  // Expenses this month in categories without an envelope
+	OverspentLastMonth   models.Money `json:"overspentLastMonth"`   // Uncovered overspending taken out of ReadyToAssign this month
+	NeedsCoverage        models.Money `json:"needsCoverage"`        // Total overspending in this month's envelopes
+	OverspentEnvelopeIDs []string     `json:"overspentEnvelopeIds"` // Category IDs of the overspent envelopes
+}
+
+// EnvelopeMonthStart returns the first day of the month containing date in
+// the user's calendar, as stored on assignments and moves
+func EnvelopeMonthStart(date time.Time, cal BudgetCalendar) time.Time {
+	local := date.In(cal.Location)
+	return time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// CalculateEnvelopeMonth works out every envelope of the month starting on
+// month.
+//
+// Envelope budgeting starts with the first month money was assigned. What
+// was earned and spent before then is the opening money to assign. From
+// then on, income adds to the money to assign and each envelope keeps what
+// is left at the end of a month. An envelope overspent at the end of a month
+// starts the next month empty and the overspending comes out of the money
+// to assign instead.
+func CalculateEnvelopeMonth(db *gorm.DB, userID uuid.UUID, month time.Time) (*EnvelopeMonth, error) {
+	cal := UserBudgetCalendar(db, userID)
+	month = time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	var assignments []models.EnvelopeAssignment
+	if err := db.Where("user_id = ? AND month <= ?", userID, month).Order("month ASC").Find(&assignments).Error; err != nil {
+		return nil, err
+	}
+	var categories []models.Category
+	if err := db.Where("user_id = ?", userID).Find(&categories).Error; err != nil {
+		return nil, err
+	}
+
+	// Every category assigned money so far is an envelope
+	start := month
+	assigned := make(map[string]map[string]models.Money) // month -> category -> amount
+	envelopeKeys := make(map[string]bool)
+	for _, assignment := range assignments {
+		key := strings.ToLower(assignment.CategoryID)
+		monthKey := assignment.Month.Format(models.EnvelopeMonthFormat)
+		if assigned[monthKey] == nil {
+			assigned[monthKey] = make(map[string]models.Money)
+		}
+		assigned[monthKey][key] += assignment.Amount
+		envelopeKeys[key] = true
+		if assignment.Month.Before(start) {
+			start = time.Date(assignment.Month.Year(), assignment.Month.Month(), 1, 0, 0, 0, 0, time.UTC)
+		}
+	}
+
+	localMonth := func(m time.Time) time.Time {
+		return time.Date(m.Year(), m.Month(), 1, 0, 0, 0, 0, cal.Location)
+	}
+	budgetStart := localMonth(start)
+	monthEnd := localMonth(month).AddDate(0, 1, 0)
+
+	totals, currency, err := loadCategoryTotals(db, userID, []string{"income", "expense"}, nil, time.Time{}, monthEnd, cal)
+	if err != nil {
+		return nil, err
+	}
+
+	// Spending goes to the envelope of its category or of its nearest
+	// ancestor with one
+	envelopeOf := envelopeResolver(categories, envelopeKeys)
+	income := make(map[string]models.Money)
+	unenveloped := make(map[string]models.Money)
+	spent := make(map[string]map[string]models.Money)
+	var opening models.Money
+	for _, total := range totals {
+		// Card payments and goal holdings are neither income nor spending.
+		// Opening balances are money the user has to budget, so they count
+		// as income.
+		if models.IsNonSpendingCategory(total.CategoryID) && total.CategoryID != models.CategoryOpeningBalance {
+			continue
+		}
+		if total.Day.Before(budgetStart) {
+			if total.Type == "income" {
+				opening += total.Amount
+			} else {
+				opening -= total.Amount
+			}
+			continue
+		}
+
+		monthKey := total.Day.Format(models.EnvelopeMonthFormat)
+		if total.Type == "income" {
+			income[monthKey] += total.Amount
+			continue
+		}
+		envelope := envelopeOf(total.CategoryID)
+		if envelope == "" {
+			unenveloped[monthKey] += total.Amount
+			continue
+		}
+		if spent[monthKey] == nil {
+			spent[monthKey] = make(map[string]models.Money)
+		}
+		spent[monthKey][envelope] += total.Amount
+	}
+
+	keys := make([]string, 0, len(envelopeKeys))
+	for key := range envelopeKeys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// Walk the months from the start, carrying envelopes and the money to
+	// assign forward
+	available := make(map[string]models.Money)
+	readyToAssign := opening
+	var result *EnvelopeMonth
+	for m := start; !m.After(month); m = m.AddDate(0, 1, 0) {
+		monthKey := m.Format(models.EnvelopeMonthFormat)
+		result = &EnvelopeMonth{
+			Month:                m,
+			StartDate:            localMonth(m),
+			EndDate:              localMonth(m).AddDate(0, 1, 0),
+			Currency:             currency,
+			Envelopes:            []Envelope{},
+			OverspentEnvelopeIDs: []string{},
+		}
+
+		for _, key := range keys {
+			carried := available[key]
+			if carried < 0 {
+				result.OverspentLastMonth -= carried
+				carried = 0
+			}
+			envelope := Envelope{
+				CategoryID:  key,
+				CarriedOver: carried,
+				Assigned:    assigned[monthKey][key],
+				Spent:       spent[monthKey][key],
+			}
+			envelope.Available = envelope.CarriedOver + envelope.Assigned - envelope.Spent
+			envelope.Overspent = envelope.Available < 0
+			available[key] = envelope.Available
+
+			result.Envelopes = append(result.Envelopes, envelope)
+			result.Assigned += envelope.Assigned
+			result.Spent += envelope.Spent
+			result.Available += envelope.Available
+			if envelope.Overspent {
+				result.NeedsCoverage -= envelope.Available
+				result.OverspentEnvelopeIDs = append(result.OverspentEnvelopeIDs, key)
+			}
+		}
+
+		result.Income = income[monthKey]
+		result.UnenvelopedSpending = unenveloped[monthKey]
+		readyToAssign += result.Income - result.Assigned - result.UnenvelopedSpending - result.OverspentLastMonth
+		result.ReadyToAssign = readyToAssign
+	}
+
+	names := make(map[string]string, len(categories))
+	for _, category := range categories {
+		names[strings.ToLower(category.Key)] = category.Name
+	}
+	for i := range result.Envelopes {
+		result.Envelopes[i].CategoryName = names[result.Envelopes[i].CategoryID]
+	}
+	return result, nil
+}
+
+// envelopeResolver returns a function finding the envelope that spending in
+// a category goes to: the category's own or its nearest ancestor's, or ""
+// when there is none
+func envelopeResolver(categories []models.Category, envelopeKeys map[string]bool) func(string) string {
+	byKey := make(map[string]*models.Category, len(categories))
+	byID := make(map[uuid.UUID]*models.Category, len(categories))
+	for i := range categories {
+		byKey[strings.ToLower(categories[i].Key)] = &categories[i]
+		byID[categories[i].ID] = &categories[i]
+	}
+
+	return func(key string) string {
+		seen := make(map[string]bool)
+		for key != "" && !seen[key] {
+			if envelopeKeys[key] {
+				return key
+			}
+			seen[key] = true
+			category := byKey[key]
+			if category == nil || category.ParentID == nil || byID[*category.ParentID] == nil {
+				return ""
+			}
+			key = strings.ToLower(byID[*category.ParentID].Key)
+		}
+		return ""
+	}
+}
+
+// AssignEnvelope sets the money assigned to the category's envelope in the
+// month starting on month
+func AssignEnvelope(tx *gorm.DB, userID uuid.UUID, categoryID string, month time.Time, amount models.Money) error {
+	assignment := models.EnvelopeAssignment{
+		UserID:     userID,
+		CategoryID: strings.ToLower(categoryID),
+		Month:      month,
+		Amount:     amount,
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "category_id"}, {Name: "month"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"amount": amount, "updated_at": time.Now(), "deleted_at": nil}),
+	}).Create(&assignment).Error
+}
+
+// MergeEnvelopeAssignments moves the money assigned to the source
+// categories' envelopes into the target's, adding up assignments in the
+// same month, and returns how many assignments moved. Source keys must be
+// lowercased.
+func MergeEnvelopeAssignments(tx *gorm.DB, userID uuid.UUID, sourceKeys []string, targetKey string) (int64, error) {
+	var assignments []models.EnvelopeAssignment
+	if err := tx.Where("user_id = ? AND LOWER(category_id) IN ?", userID, sourceKeys).Find(&assignments).Error; err != nil {
+		return 0, err
+	}
+
+	for _, assignment := range assignments {
+		merged := models.EnvelopeAssignment{
+			UserID:     userID,
+			CategoryID: strings.ToLower(targetKey),
+			Month:      assignment.Month,
+			Amount:     assignment.Amount,
+		}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "category_id"}, {Name: "month"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{"amount": gorm.Expr("envelope_assignments.amount + ?", assignment.Amount), "updated_at": time.Now()}),
+		}).Create(&merged).Error; err != nil {
+			return 0, err
+		}
+		// Removed for good so the unique month index stays free for the key
+		if err := tx.Unscoped().Delete(&assignment).Error; err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(assignments)), nil
+}
+
+// MoveEnvelopeMoney moves money between two envelopes in a month by
+// assigning less to one and more to the other, and records the move. The
+// envelope it comes from must have that much available.
+func MoveEnvelopeMoney(tx *gorm.DB, move *models.EnvelopeMove) error {
+	move.FromCategoryID = strings.ToLower(move.FromCategoryID)
+	move.ToCategoryID = strings.ToLower(move.ToCategoryID)
+	if move.FromCategoryID == move.ToCategoryID {
+		return ErrEnvelopeSameCategory
+	}
+
+	if err := shiftEnvelopeMoney(tx, move.UserID, move.Month, move.FromCategoryID, move.ToCategoryID, move.Amount); err != nil {
+		return err
+	}
+	return tx.Create(move).Error
+}
+
+// UndoEnvelopeMove moves the money back and deletes the move. The envelope
+// it went to must still have that much available.
+func UndoEnvelopeMove(tx *gorm.DB, move *models.EnvelopeMove) error {
+	if err := shiftEnvelopeMoney(tx, move.UserID, move.Month, move.ToCategoryID, move.FromCategoryID, move.Amount); err != nil {
+		return err
+	}
+	return tx.Delete(move).Error
+}
+
+// shiftEnvelopeMoney assigns amount less to one envelope and amount more to
+// another in the month, provided the first has that much available
+func shiftEnvelopeMoney(tx *gorm.DB, userID uuid.UUID, month time.Time, fromCategoryID, toCategoryID string, amount models.Money) error {
+	// Serialize envelope changes for the user so two moves cannot both
+	// spend the same money
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, "id = ?", userID).Error; err != nil {
+		return err
+	}
+
+	view, err := CalculateEnvelopeMonth(tx, userID, month)
+	if err != nil {
+		return err
+	}
+	var fromAvailable, fromAssigned, toAssigned models.Money
+	for _, envelope := range view.Envelopes {
+		switch envelope.CategoryID {
+		case fromCategoryID:
+			fromAvailable, fromAssigned = envelope.Available, envelope.Assigned
+		case toCategoryID:
+			toAssigned = envelope.Assigned
+		}
+	}
+	if fromAvailable < amount {
+		return ErrEnvelopeInsufficientFunds
+	}
+
+	if err := AssignEnvelope(tx, userID, fromCategoryID, month, fromAssigned-amount); err != nil {
+		return err
+	}
+	return AssignEnvelope(tx, userID, toCategoryID, month, toAssigned+amount)
+}
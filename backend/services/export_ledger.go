@@ -0,0 +1,420 @@
+package services
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"daybook-backend/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Plain-text accounting formats supported by WriteLedgerExport
+const (
+	LedgerFormatLedger    = "ledger"
+	LedgerFormatHledger   = "hledger"
+	LedgerFormatBeancount = "beancount"
+)
+
+// IsValidLedgerFormat reports whether format is a supported plain-text accounting format
+func IsValidLedgerFormat(format string) bool {
+	switch format {
+	case LedgerFormatLedger, LedgerFormatHledger, LedgerFormatBeancount:
+		return true
+	}
+	return false
+}
+
+// ledgerPosting is one line of a double-entry transaction
+type ledgerPosting struct {
+	account  string
+	amount   models.Money
+	currency string
+	// Total price of the posting in another currency, for transfers between
+	// accounts in different currencies
+	cost         *models.Money
+	costCurrency string
+	comment      string // Memo of a split
+}
+
+// ledgerWriter renders transactions as ledger, hledger or beancount journal entries
+type ledgerWriter struct {
+	w          *bufio.Writer
+	format     string
+	ctx        *exportContext
+	converter  *models.CurrencyConverter
+	currency   string                                  // User's reporting currency
+	categories map[string]models.Category              // By lowercased key
+	paths      map[uuid.UUID][]string                  // Category names from the root down
+	splits     map[uuid.UUID][]models.TransactionSplit // By transaction ID
+}
+
+// WriteLedgerExport writes the user's transactions as a double-entry journal.
+// Accounts become Assets:<name>, credit cards Liabilities:Credit Cards:<name>
+// and categories Income:<path> or Expenses:<path>; opening balances are
+// booked against Equity:Opening Balances.
+func WriteLedgerExport(w io.Writer, db *gorm.DB, userID uuid.UUID, format string, filter ExportFilter) error {
+	filter.IncludeTracking = false
+	ctx, err := newExportContext(db, userID, filter)
+	if err != nil {
+		return err
+	}
+	converter, err := models.NewCurrencyConverter(db, userID)
+	if err != nil {
+		return err
+	}
+
+	lw := &ledgerWriter{
+		w:          bufio.NewWriter(w),
+		format:     format,
+		ctx:        ctx,
+		converter:  converter,
+		currency:   models.UserCurrency(db, userID),
+		categories: make(map[string]models.Category),
+		paths:      make(map[uuid.UUID][]string),
+		splits:     make(map[uuid.UUID][]models.TransactionSplit),
+	}
+
+	var categories []models.Category
+	if err := db.Unscoped().Where("user_id = ?", userID).Find(&categories).Error; err != nil {
+		return err
+	}
+	byID := make(map[uuid.UUID]models.Category, len(categories))
+	for _, category := range categories {
+		byID[category.ID] = category
+		lw.categories[strings.ToLower(category.Key)] = category
+	}
+	for _, category := range categories {
+		var path []string
+		current := category
+		// The depth limit guards against a parent cycle in bad data
+		for depth := 0; depth < 10; depth++ {
+			path = append([]string{current.Name}, path...)
+			if current.ParentID == nil {
+				break
+			}
+			parent, ok := byID[*current.ParentID]
+			if !ok {
+				break
+			}
+			current = parent
+		}
+		lw.paths[category.ID] = path
+	}
+
+	var firstDate time.Time
+	var earliest struct{ Date *time.Time }
+	if err := filter.apply(db.Model(&models.Transaction{}).Where("user_id = ?", userID)).
+		Select("MIN(date) AS date").Scan(&earliest).Error; err != nil {
+		return err
+	}
+	if earliest.Date != nil {
+		firstDate = *earliest.Date
+	} else {
+		firstDate = time.Now()
+	}
+
+	var splits []models.TransactionSplit
+	transactions := filter.apply(db.Model(&models.Transaction{}).Select("id").Where("user_id = ?", userID))
+	if err := db.Where("user_id = ? AND transaction_id IN (?)", userID, transactions).Order("position ASC").Find(&splits).Error; err != nil {
+		return err
+	}
+	for _, split := range splits {
+		lw.splits[split.TransactionID] = append(lw.splits[split.TransactionID], split)
+	}
+
+	lw.writeHeader(firstDate, categories)
+
+	err = eachTransaction(db, userID, filter, func(t *models.Transaction) error {
+		lw.writeTransaction(t)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return lw.w.Flush()
+}
+
+// writeHeader declares every account the journal may use so that strict
+// modes of hledger and beancount accept the file
+func (lw *ledgerWriter) writeHeader(firstDate time.Time, categories []models.Category) {
+	fmt.Fprintf(lw.w, "; Exported from daybook on %s\n", time.Now().Format("2006-01-02"))
+	if lw.format == LedgerFormatBeancount {
+		fmt.Fprintf(lw.w, "option \"operating_currency\" \"%s\"\n", lw.currency)
+	}
+	lw.w.WriteString("\n")
+
+	names := []string{
+		lw.accountName("Equity", "Opening Balances"),
+		lw.accountName("Equity", "Transfers"),
+		lw.accountName("Income", "Uncategorized"),
+		lw.accountName("Expenses", "Uncategorized"),
+	}
+	// Deleted accounts are declared too, since their transactions may remain
+	for _, account := range lw.ctx.accounts {
+		names = append(names, lw.accountName("Assets", account.Name))
+	}
+	for _, card := range lw.ctx.cards {
+		names = append(names, lw.accountName("Liabilities", "Credit Cards", card.Name))
+	}
+	for _, category := range categories {
+		names = append(names, lw.categoryAccount(category.Key, ""))
+	}
+
+	sort.Strings(names)
+	previous := ""
+	for _, name := range names {
+		if name == previous {
+			continue
+		}
+		previous = name
+		if lw.format == LedgerFormatBeancount {
+			fmt.Fprintf(lw.w, "%s open %s\n", firstDate.Format("2006-01-02"), name)
+		} else {
+			fmt.Fprintf(lw.w, "account %s\n", name)
+		}
+	}
+	lw.w.WriteString("\n")
+}
+
+// writeTransaction writes one journal entry. Transactions that cannot be
+// expressed as balanced postings are skipped.
+func (lw *ledgerWriter) writeTransaction(t *models.Transaction) {
+	postings := lw.postings(t)
+	if len(postings) == 0 {
+		return
+	}
+
+	description := strings.Join(strings.Fields(t.Description), " ")
+	date := t.Date.Format("2006-01-02")
+	indent := "    "
+
+	switch lw.format {
+	case LedgerFormatBeancount:
+		indent = "  "
+		line := fmt.Sprintf("%s * %q", date, description)
+		for _, tag := range t.Tags {
+			if tag = ledgerTag(tag); tag != "" {
+				line += " #" + tag
+			}
+		}
+		fmt.Fprintln(lw.w, line)
+		fmt.Fprintf(lw.w, "%sid: %q\n", indent, t.ID.String())
+	default:
+		status := ""
+		if t.Reconciled {
+			status = "* "
+		}
+		fmt.Fprintln(lw.w, strings.TrimSpace(date+" "+status+description))
+		fmt.Fprintf(lw.w, "%s; id: %s\n", indent, t.ID)
+		if tags := lw.tagComment(t.Tags); tags != "" {
+			fmt.Fprintf(lw.w, "%s; %s\n", indent, tags)
+		}
+	}
+
+	for _, p := range postings {
+		amount := p.amount.StringFixed(p.currency) + " " + p.currency
+		if p.cost != nil {
+			amount += " @@ " + p.cost.Abs().StringFixed(p.costCurrency) + " " + p.costCurrency
+		}
+		if comment := strings.Join(strings.Fields(p.comment), " "); comment != "" {
+			amount += " ; " + comment
+		}
+		fmt.Fprintf(lw.w, "%s%-50s  %s\n", indent, p.account, amount)
+	}
+	lw.w.WriteString("\n")
+}
+
+// postings turns a transaction into balanced postings
+func (lw *ledgerWriter) postings(t *models.Transaction) []ledgerPosting {
+	source, currency := lw.sourceAccount(t.AccountID)
+
+	switch {
+	case t.Type == "transfer" && t.ToAccountID != nil:
+		destination, toCurrency := lw.sourceAccount(*t.ToAccountID)
+		toAmount := t.Amount
+		if t.ToAmount != nil {
+			toAmount = *t.ToAmount
+		}
+		return lw.exchange(source, t.Amount, currency, destination, toAmount, toCurrency)
+
+	case t.Type == "transfer":
+		return []ledgerPosting{
+			{account: lw.accountName("Equity", "Transfers"), amount: t.Amount, currency: currency},
+			{account: source, amount: -t.Amount, currency: currency},
+		}
+
+	case t.CreditCardID != nil && *t.CreditCardID != t.AccountID:
+		// Card payment from a bank account: the amount is in the account's
+		// currency and reduces what is owed on the card
+		card, cardCurrency := lw.sourceAccount(*t.CreditCardID)
+		cardAmount := t.Amount
+		if cardCurrency != currency {
+			converted, err := lw.converter.Convert(t.Amount, currency, cardCurrency, t.Date)
+			if err != nil {
+				cardAmount, cardCurrency = t.Amount, currency
+			} else {
+				cardAmount = converted.Round(cardCurrency)
+			}
+		}
+		return lw.exchange(source, t.Amount, currency, card, cardAmount, cardCurrency)
+
+	case t.Type == "income" || t.Type == "expense":
+		// A split transaction has one category posting per split
+		shares := lw.splits[t.ID]
+		if len(shares) == 0 {
+			shares = []models.TransactionSplit{{CategoryID: t.CategoryID, Amount: t.Amount}}
+		}
+		postings := make([]ledgerPosting, 0, len(shares)+1)
+		for _, share := range shares {
+			amount := share.Amount
+			if t.Type == "income" {
+				amount = -amount
+			}
+			postings = append(postings, ledgerPosting{account: lw.categoryAccount(share.CategoryID, t.Type), amount: amount, currency: currency, comment: share.Memo})
+		}
+		total := t.Amount
+		if t.Type == "income" {
+			total = -total
+		}
+		return append(postings, ledgerPosting{account: source, amount: -total, currency: currency})
+	}
+
+	return nil
+}
+
+// exchange moves an amount from one account to another, recording the
+// destination amount as the price when the currencies differ
+func (lw *ledgerWriter) exchange(from string, amount models.Money, currency, to string, toAmount models.Money, toCurrency string) []ledgerPosting {
+	out := ledgerPosting{account: from, amount: -amount, currency: currency}
+	if toCurrency != currency {
+		out.cost = &toAmount
+		out.costCurrency = toCurrency
+	} else {
+		toAmount = amount
+	}
+	return []ledgerPosting{
+		{account: to, amount: toAmount, currency: toCurrency},
+		out,
+	}
+}
+
+// sourceAccount returns the journal account and currency for an account or
+// credit card ID
+func (lw *ledgerWriter) sourceAccount(id uuid.UUID) (string, string) {
+	if account, ok := lw.ctx.accounts[id]; ok {
+		return lw.accountName("Assets", account.Name), lw.currencyOr(account.Currency)
+	}
+	if card, ok := lw.ctx.cards[id]; ok {
+		return lw.accountName("Liabilities", "Credit Cards", card.Name), lw.currencyOr(card.Currency)
+	}
+	return lw.accountName("Assets", "Unknown"), lw.currency
+}
+
+// categoryAccount maps a category key to Income:<path> or Expenses:<path>.
+// The category's own kind wins over the transaction type, so a refund booked
+// as income in an expense category reduces that expense.
+func (lw *ledgerWriter) categoryAccount(key, transactionType string) string {
+	if strings.EqualFold(key, models.CategoryOpeningBalance) {
+		return lw.accountName("Equity", "Opening Balances")
+	}
+
+	category, ok := lw.categories[strings.ToLower(key)]
+	if !ok {
+		if transactionType == "income" {
+			return lw.accountName("Income", "Uncategorized")
+		}
+		return lw.accountName("Expenses", "Uncategorized")
+	}
+
+	root := "Expenses"
+	switch category.Kind {
+	case models.CategoryKindIncome:
+		root = "Income"
+	case models.CategoryKindTransfer:
+		root = "Equity"
+	}
+	return lw.accountName(root, lw.paths[category.ID]...)
+}
+
+// accountName joins account name components, cleaning each one for the
+// target format
+func (lw *ledgerWriter) accountName(root string, components ...string) string {
+	parts := []string{root}
+	for _, component := range components {
+		parts = append(parts, lw.cleanComponent(component))
+	}
+	return strings.Join(parts, ":")
+}
+
+// cleanComponent makes a name safe to use in an account name. Ledger and
+// hledger end an account name at two spaces, and beancount only allows
+// letters, digits and dashes, starting with a capital letter or digit.
+func (lw *ledgerWriter) cleanComponent(name string) string {
+	if lw.format != LedgerFormatBeancount {
+		name = strings.NewReplacer(":", "-", ";", "-").Replace(name)
+		name = strings.Join(strings.Fields(name), " ")
+		if name == "" {
+			return "Unnamed"
+		}
+		return name
+	}
+
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, word := range words {
+		runes := []rune(word)
+		runes[0] = unicode.ToUpper(runes[0])
+		words[i] = string(runes)
+	}
+	component := strings.Join(words, "-")
+	if component == "" {
+		return "Unnamed"
+	}
+	if first := []rune(component)[0]; !unicode.IsUpper(first) && !unicode.IsDigit(first) {
+		component = "X-" + component
+	}
+	return component
+}
+
+// tagComment formats tags as a ledger or hledger comment
+func (lw *ledgerWriter) tagComment(tags []string) string {
+	var cleaned []string
+	for _, tag := range tags {
+		if tag = ledgerTag(tag); tag != "" {
+			cleaned = append(cleaned, tag)
+		}
+	}
+	if len(cleaned) == 0 {
+		return ""
+	}
+	if lw.format == LedgerFormatHledger {
+		return strings.Join(cleaned, ":, ") + ":"
+	}
+	return ":" + strings.Join(cleaned, ":") + ":"
+}
+
+func (lw *ledgerWriter) currencyOr(currency string) string {
+	if currency == "" {
+		return lw.currency
+	}
+	return currency
+}
+
+// ledgerTag reduces a tag to characters every format accepts in tag names
+func ledgerTag(tag string) string {
+	return strings.Trim(strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' {
+			return r
+		}
+		return '-'
+	}, strings.TrimSpace(tag)), "-")
+}
@@ -0,0 +1,542 @@
+package services
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"daybook-backend/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ExportVersion is bumped whenever the layout of the JSON archive changes
+const ExportVersion = 1
+
+// ErrUnknownExport is returned for a resource name that cannot be exported
+var ErrUnknownExport = errors.New("unknown export resource")
+
+// ErrExportJSONOnly is returned when CSV is requested for a resource that is
+// only part of the JSON archive
+var ErrExportJSONOnly = errors.New("resource is only available as JSON")
+
+// ExportFilter narrows the transactions included in an export. Other
+// resources are always exported in full.
+type ExportFilter struct {
+	StartDate       *time.Time
+	EndDate         *time.Time
+	AccountID       *uuid.UUID // Matches the source or destination account, or the credit card
+	IncludeTracking bool       // Include goal tracking entries, which never move a balance
+}
+
+// apply adds the filter conditions to a transactions query
+func (f ExportFilter) apply(query *gorm.DB) *gorm.DB {
+	if f.StartDate != nil {
+		query = query.Where("date >= ?", *f.StartDate)
+	}
+	if f.EndDate != nil {
+		query = query.Where("date <= ?", *f.EndDate)
+	}
+	if f.AccountID != nil {
+		query = query.Where("account_id = ? OR to_account_id = ? OR credit_card_id = ?", *f.AccountID, *f.AccountID, *f.AccountID)
+	}
+	if !f.IncludeTracking {
+		query = query.Where("type != ?", "tracking")
+	}
+	return query
+}
+
+// exportEmitter receives every exported record along with its CSV row
+type exportEmitter func(record interface{}, row []string) error
+
+// exportResource is one kind of record that can be exported. Resources
+// without a header are only included in the JSON archive.
+type exportResource struct {
+	name   string // URL name, e.g. credit-cards
+	key    string // JSON archive key, e.g. creditCards
+	header []string
+	each   func(ctx *exportContext, emit exportEmitter) error
+}
+
+// exportContext holds what resources need to resolve IDs into readable names
+type exportContext struct {
+	db       *gorm.DB
+	userID   uuid.UUID
+	filter   ExportFilter
+	accounts map[uuid.UUID]models.Account
+	cards    map[uuid.UUID]models.CreditCard
+	goals    map[uuid.UUID]models.Goal
+	bills    map[uuid.UUID]models.Bill
+}
+
+func newExportContext(db *gorm.DB, userID uuid.UUID, filter ExportFilter) (*exportContext, error) {
+	ctx := &exportContext{
+		db:       db,
+		userID:   userID,
+		filter:   filter,
+		accounts: make(map[uuid.UUID]models.Account),
+		cards:    make(map[uuid.UUID]models.CreditCard),
+		goals:    make(map[uuid.UUID]models.Goal),
+		bills:    make(map[uuid.UUID]models.Bill),
+	}
+
+	var accounts []models.Account
+	if err := db.Unscoped().Where("user_id = ?", userID).Find(&accounts).Error; err != nil {
+		return nil, err
+	}
+	for _, account := range accounts {
+		ctx.accounts[account.ID] = account
+	}
+
+	var cards []models.CreditCard
+	if err := db.Unscoped().Where("user_id = ?", userID).Find(&cards).Error; err != nil {
+		return nil, err
+	}
+	for _, card := range cards {
+		ctx.cards[card.ID] = card
+	}
+
+	var goals []models.Goal
+	if err := db.Unscoped().Where("user_id = ?", userID).Find(&goals).Error; err != nil {
+		return nil, err
+	}
+	for _, goal := range goals {
+		ctx.goals[goal.ID] = goal
+	}
+
+	var bills []models.Bill
+	if err := db.Unscoped().Where("user_id = ?", userID).Find(&bills).Error; err != nil {
+		return nil, err
+	}
+	for _, bill := range bills {
+		ctx.bills[bill.ID] = bill
+	}
+
+	return ctx, nil
+}
+
+// accountName returns the name and currency of an account or credit card.
+// Credit card transactions store the card ID as their account ID.
+func (ctx *exportContext) accountName(id uuid.UUID) (string, string) {
+	if account, ok := ctx.accounts[id]; ok {
+		return account.Name, account.Currency
+	}
+	if card, ok := ctx.cards[id]; ok {
+		return card.Name, card.Currency
+	}
+	return "", ""
+}
+
+// eachTransaction streams the user's transactions in date order without
+// loading them all into memory
+func eachTransaction(db *gorm.DB, userID uuid.UUID, filter ExportFilter, fn func(*models.Transaction) error) error {
+	query := filter.apply(db.Model(&models.Transaction{}).Where("user_id = ?", userID))
+	rows, err := query.Order("date ASC, created_at ASC").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var transaction models.Transaction
+		if err := db.ScanRows(rows, &transaction); err != nil {
+			return err
+		}
+		if err := fn(&transaction); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// userRecords returns a resource that exports every row of T owned by the user
+func userRecords[T any](order string, row func(ctx *exportContext, record *T) []string) func(*exportContext, exportEmitter) error {
+	return func(ctx *exportContext, emit exportEmitter) error {
+		var records []T
+		if err := ctx.db.Where("user_id = ?", ctx.userID).Order(order).Find(&records).Error; err != nil {
+			return err
+		}
+		for i := range records {
+			var values []string
+			if row != nil {
+				values = row(ctx, &records[i])
+			}
+			if err := emit(&records[i], values); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// exportResources lists every exportable resource in archive order
+var exportResources = []exportResource{
+	{
+		name:   "accounts",
+		key:    "accounts",
+		header: []string{"id", "name", "type", "currency", "initialBalance", "balance", "institution", "accountNumber", "active", "lastReconciled", "createdAt"},
+		each: userRecords("name ASC", func(ctx *exportContext, a *models.Account) []string {
+			return []string{a.ID.String(), a.Name, a.Type, a.Currency, a.InitialBalance.StringFixed(a.Currency), a.Balance.StringFixed(a.Currency),
+				a.Institution, a.AccountNumber, formatBool(a.Active), formatDate(a.LastReconciled), a.CreatedAt.Format(time.RFC3339)}
+		}),
+	},
+	{
+		name:   "credit-cards",
+		key:    "creditCards",
+		header: []string{"id", "name", "lastFourDigits", "cardNetwork", "currency", "creditLimit", "currentBalance", "apr", "minimumPayment", "dueDate", "statementDate", "active"},
+		each: userRecords("name ASC", func(ctx *exportContext, cc *models.CreditCard) []string {
+			return []string{cc.ID.String(), cc.Name, cc.LastFourDigits, cc.CardNetwork, cc.Currency, cc.CreditLimit.StringFixed(cc.Currency),
+				cc.CurrentBalance.StringFixed(cc.Currency), formatFloat(cc.APR), cc.MinimumPayment.StringFixed(cc.Currency),
+				formatDate(cc.DueDate), formatDate(cc.StatementDate), formatBool(cc.Active)}
+		}),
+	},
+	{
+		name:   "categories",
+		key:    "categories",
+		header: []string{"id", "key", "name", "kind", "parentId", "isSystem", "active"},
+		each: userRecords("kind ASC, sort_order ASC, name ASC", func(ctx *exportContext, c *models.Category) []string {
+			return []string{c.ID.String(), c.Key, c.Name, c.Kind, formatID(c.ParentID), formatBool(c.IsSystem), formatBool(c.Active)}
+		}),
+	},
+	{
+		name:   "transactions",
+		key:    "transactions",
+		header: []string{"id", "date", "type", "amount", "currency", "account", "toAccount", "toAmount", "toCurrency", "exchangeRate", "category", "description", "tags", "reconciled", "externalId"},
+		each: func(ctx *exportContext, emit exportEmitter) error {
+			return eachTransaction(ctx.db, ctx.userID, ctx.filter, func(t *models.Transaction) error {
+				account, currency := ctx.accountName(t.AccountID)
+				toAccount, toCurrency := "", ""
+				toAmount := ""
+				if t.ToAccountID != nil {
+					toAccount, toCurrency = ctx.accountName(*t.ToAccountID)
+					amount := t.Amount
+					if t.ToAmount != nil {
+						amount = *t.ToAmount
+					}
+					toAmount = amount.StringFixed(toCurrency)
+				}
+				exchangeRate := ""
+				if t.ExchangeRate != nil {
+					exchangeRate = formatFloat(*t.ExchangeRate)
+				}
+				return emit(t, []string{t.ID.String(), t.Date.Format("2006-01-02"), t.Type, t.Amount.StringFixed(currency), currency,
+					account, toAccount, toAmount, toCurrency, exchangeRate, t.CategoryID, t.Description,
+					strings.Join(t.Tags, ";"), formatBool(t.Reconciled), t.ExternalID})
+			})
+		},
+	},
+	{
+		name:   "transaction-splits",
+		key:    "transactionSplits",
+		header: []string{"id", "transactionId", "date", "type", "amount", "currency", "category", "memo", "tags"},
+		each: func(ctx *exportContext, emit exportEmitter) error {
+			transactions := ctx.filter.apply(ctx.db.Model(&models.Transaction{}).Select("id").Where("user_id = ?", ctx.userID))
+			var rows []struct {
+				models.TransactionSplit
+				Date      time.Time
+				Type      string
+				AccountID uuid.UUID
+			}
+			err := ctx.db.Model(&models.TransactionSplit{}).
+				Select("transaction_splits.*, transactions.date, transactions.type, transactions.account_id").
+				Joins("JOIN transactions ON transactions.id = transaction_splits.transaction_id").
+				Where("transaction_splits.user_id = ? AND transaction_splits.transaction_id IN (?)", ctx.userID, transactions).
+				Order("transactions.date ASC, transactions.created_at ASC, transaction_splits.position ASC").
+				Scan(&rows).Error
+			if err != nil {
+				return err
+			}
+			for i := range rows {
+				split := &rows[i].TransactionSplit
+				_, currency := ctx.accountName(rows[i].AccountID)
+				if err := emit(split, []string{split.ID.String(), split.TransactionID.String(), rows[i].Date.Format("2006-01-02"), rows[i].Type,
+					split.Amount.StringFixed(currency), currency, split.CategoryID, split.Memo, strings.Join(split.Tags, ";")}); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		name: "recurring-transactions",
+		key:  "recurringTransactions",
+		each: userRecords[models.RecurringTransaction]("start_date ASC", nil),
+	},
+	{
+		name: "tags",
+		key:  "tags",
+		each: userRecords[models.Tag]("name ASC", nil),
+	},
+	{
+		name: "exchange-rates",
+		key:  "exchangeRates",
+		each: userRecords[models.ExchangeRate]("date ASC", nil),
+	},
+	{
+		name: "credit-card-transactions",
+		key:  "creditCardTransactions",
+		each: userRecords[models.CreditCardTransaction]("date ASC", nil),
+	},
+	{
+		name: "credit-card-payments",
+		key:  "creditCardPayments",
+		each: userRecords[models.CreditCardPayment]("payment_date ASC", nil),
+	},
+	{
+		name: "statements",
+		key:  "statements",
+		each: userRecords[models.Statement]("statement_date ASC", nil),
+	},
+	{
+		name: "rewards",
+		key:  "rewards",
+		each: userRecords[models.Reward]("earned_date ASC", nil),
+	},
+	{
+		name: "reward-rules",
+		key:  "rewardRules",
+		each: userRecords[models.RewardRule]("created_at ASC", nil),
+	},
+	{
+		name: "reward-redemptions",
+		key:  "rewardRedemptions",
+		each: userRecords[models.RewardRedemption]("redeemed_at ASC", nil),
+	},
+	{
+		name:   "bills",
+		key:    "bills",
+		header: []string{"id", "name", "category", "amount", "frequency", "startDate", "dueDay", "lastPaidDate", "lastPaidAmount", "autoPay", "active", "notes"},
+		each: userRecords("name ASC", func(ctx *exportContext, b *models.Bill) []string {
+			return []string{b.ID.String(), b.Name, b.Category, b.Amount.String(), b.Frequency, b.StartDate.Format("2006-01-02"),
+				strconv.Itoa(b.DueDay), formatDate(b.LastPaidDate), b.LastPaidAmount.String(), formatBool(b.AutoPay), formatBool(b.Active), b.Notes}
+		}),
+	},
+	{
+		name:   "bill-payments",
+		key:    "billPayments",
+		header: []string{"id", "bill", "paymentDate", "amount", "account", "notes"},
+		each: userRecords("payment_date ASC", func(ctx *exportContext, p *models.BillPayment) []string {
+			account := ""
+			if p.AccountID != nil {
+				account, _ = ctx.accountName(*p.AccountID)
+			}
+			return []string{p.ID.String(), ctx.bills[p.BillID].Name, p.PaymentDate.Format("2006-01-02"), p.Amount.String(), account, p.Notes}
+		}),
+	},
+	{
+		name:   "budgets",
+		key:    "budgets",
+		header: []string{"id", "category", "amount", "period", "customStartDate", "customEndDate", "rollover", "alertThreshold", "enabled", "notes"},
+		each: userRecords("category_id ASC", func(ctx *exportContext, b *models.Budget) []string {
+			return []string{b.ID.String(), b.CategoryID, b.Amount.String(), b.Period, formatDate(b.CustomStartDate), formatDate(b.CustomEndDate),
+				formatBool(b.Rollover), formatFloat(b.AlertThreshold), formatBool(b.Enabled), b.Notes}
+		}),
+	},
+	{
+		name:   "goals",
+		key:    "goals",
+		header: []string{"id", "name", "category", "priority", "status", "targetAmount", "currentAmount", "targetDate", "monthlyContribution", "achievedDate"},
+		each: userRecords("name ASC", func(ctx *exportContext, g *models.Goal) []string {
+			return []string{g.ID.String(), g.Name, g.Category, g.Priority, g.Status, g.TargetAmount.String(), g.CurrentAmount.String(),
+				formatDate(g.TargetDate), g.MonthlyContribution.String(), formatDate(g.AchievedDate)}
+		}),
+	},
+	{
+		name:   "goal-holdings",
+		key:    "goalHoldings",
+		header: []string{"id", "goal", "name", "type", "status", "purchaseDate", "amount", "currentValue", "symbol", "quantity", "maturityDate", "maturityAmount"},
+		each: userRecords("purchase_date ASC", func(ctx *exportContext, h *models.GoalHolding) []string {
+			symbol, quantity, maturityAmount := "", "", ""
+			if h.Symbol != nil {
+				symbol = *h.Symbol
+			}
+			if h.Quantity != nil {
+				quantity = formatFloat(*h.Quantity)
+			}
+			if h.MaturityAmount != nil {
+				maturityAmount = h.MaturityAmount.String()
+			}
+			return []string{h.ID.String(), ctx.goals[h.GoalID].Name, h.Name, h.Type, h.Status, h.PurchaseDate.Format("2006-01-02"),
+				h.Amount.String(), h.CurrentValue.String(), symbol, quantity, formatDate(h.MaturityDate), maturityAmount}
+		}),
+	},
+	{
+		name:   "goal-contributions",
+		key:    "goalContributions",
+		header: []string{"id", "goal", "holdingId", "type", "date", "amount", "notes"},
+		each: userRecords("date ASC", func(ctx *exportContext, gc *models.GoalContribution) []string {
+			return []string{gc.ID.String(), ctx.goals[gc.GoalID].Name, formatID(gc.HoldingID), gc.Type, gc.Date.Format("2006-01-02"), gc.Amount.String(), gc.Notes}
+		}),
+	},
+	{
+		name:   "reconciliations",
+		key:    "reconciliations",
+		header: []string{"id", "account", "reconciliationDate", "statementBalance", "bookBalance", "difference", "status", "notes"},
+		each: userRecords("reconciliation_date ASC", func(ctx *exportContext, r *models.Reconciliation) []string {
+			account, currency := ctx.accountName(r.AccountID)
+			return []string{r.ID.String(), account, r.ReconciliationDate.Format("2006-01-02"), r.StatementBalance.StringFixed(currency),
+				r.BookBalance.StringFixed(currency), r.Difference.StringFixed(currency), string(r.Status), r.Notes}
+		}),
+	},
+}
+
+// findExportResource looks up a resource by its URL name
+func findExportResource(name string) (*exportResource, error) {
+	for i := range exportResources {
+		if exportResources[i].name == name {
+			return &exportResources[i], nil
+		}
+	}
+	return nil, ErrUnknownExport
+}
+
+// ValidateExport checks that a resource can be exported in the given format
+// before any output is written
+func ValidateExport(resource, format string) error {
+	r, err := findExportResource(resource)
+	if err != nil {
+		return err
+	}
+	if format == "csv" && r.header == nil {
+		return ErrExportJSONOnly
+	}
+	return nil
+}
+
+// WriteCSVExport writes one resource as CSV with a header row
+func WriteCSVExport(w io.Writer, db *gorm.DB, userID uuid.UUID, resource string, filter ExportFilter) error {
+	r, err := findExportResource(resource)
+	if err != nil {
+		return err
+	}
+	if r.header == nil {
+		return ErrExportJSONOnly
+	}
+
+	ctx, err := newExportContext(db, userID, filter)
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(r.header); err != nil {
+		return err
+	}
+	err = r.each(ctx, func(record interface{}, row []string) error {
+		return writer.Write(row)
+	})
+	if err != nil {
+		return err
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteJSONExport writes one resource as a JSON array
+func WriteJSONExport(w io.Writer, db *gorm.DB, userID uuid.UUID, resource string, filter ExportFilter) error {
+	r, err := findExportResource(resource)
+	if err != nil {
+		return err
+	}
+
+	ctx, err := newExportContext(db, userID, filter)
+	if err != nil {
+		return err
+	}
+
+	return writeJSONArray(w, ctx, r)
+}
+
+// WriteJSONArchive writes every resource into a single JSON document keyed
+// by resource, together with the user's settings. Transactions are streamed,
+// so the archive is never held in memory as a whole.
+func WriteJSONArchive(w io.Writer, db *gorm.DB, userID uuid.UUID, filter ExportFilter) error {
+	ctx, err := newExportContext(db, userID, filter)
+	if err != nil {
+		return err
+	}
+
+	var settings models.Settings
+	if err := db.Where("user_id = ?", userID).Limit(1).Find(&settings).Error; err != nil {
+		return err
+	}
+	settingsJSON, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+
+	header := fmt.Sprintf(`{"version":%d,"exportedAt":%q,"currency":%q,"settings":%s`,
+		ExportVersion, time.Now().UTC().Format(time.RFC3339), models.UserCurrency(db, userID), settingsJSON)
+	if _, err := io.WriteString(w, header); err != nil {
+		return err
+	}
+
+	for i := range exportResources {
+		if _, err := fmt.Fprintf(w, ",%q:", exportResources[i].key); err != nil {
+			return err
+		}
+		if err := writeJSONArray(w, ctx, &exportResources[i]); err != nil {
+			return err
+		}
+	}
+
+	_, err = io.WriteString(w, "}\n")
+	return err
+}
+
+// writeJSONArray writes a resource's records as a JSON array, one record at a time
+func writeJSONArray(w io.Writer, ctx *exportContext, r *exportResource) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+
+	first := true
+	err := r.each(ctx, func(record interface{}, row []string) error {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		if !first {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		first = false
+		_, err = w.Write(data)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "]")
+	return err
+}
+
+func formatDate(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
+
+func formatID(id *uuid.UUID) string {
+	if id == nil {
+		return ""
+	}
+	return id.String()
+}
+
+func formatBool(b bool) string {
+	return strconv.FormatBool(b)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
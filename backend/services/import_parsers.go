@@ -0,0 +1,474 @@
+package services
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"daybook-backend/models"
+)
+
+// ParsedRow is one statement line before validation against the account.
+// Amount is signed: positive for money in, negative for money out.
+type ParsedRow struct {
+	RowNumber   int
+	Date        *time.Time
+	Amount      models.Money
+	Description string
+	Category    string
+	ExternalID  string
+	Errors      []string
+}
+
+// defaultDateLayouts are tried in order when no date format is configured
+var defaultDateLayouts = []string{
+	"2006-01-02", "2006/01/02", "01/02/2006", "1/2/2006", "02.01.2006", "20060102",
+	"2006-01-02T15:04:05Z07:00", "2006-01-02 15:04:05", "02-Jan-2006", "Jan 2, 2006", "2 Jan 2006",
+}
+
+// dateFormatTokens translates user-facing date formats to Go layouts
+var dateFormatTokens = strings.NewReplacer("YYYY", "2006", "YY", "06", "MMM", "Jan", "MM", "01", "DD", "02", "M", "1", "D", "2")
+
+// ParseCSVStatement reads a CSV export using the account's column mapping
+func ParseCSVStatement(r io.Reader, mapping models.ImportMapping) ([]ParsedRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.LazyQuotes = true
+	if mapping.Delimiter != "" {
+		if mapping.Delimiter == `\t` {
+			reader.Comma = '\t'
+		} else {
+			reader.Comma = []rune(mapping.Delimiter)[0]
+		}
+	}
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if mapping.SkipRows > 0 {
+		if mapping.SkipRows >= len(records) {
+			return nil, nil
+		}
+		records = records[mapping.SkipRows:]
+	}
+
+	var header []string
+	firstDataLine := mapping.SkipRows + 1
+	if mapping.HasHeader {
+		if len(records) == 0 {
+			return nil, fmt.Errorf("file has no header row")
+		}
+		header = records[0]
+		records = records[1:]
+		firstDataLine++
+	}
+
+	columnIndex := func(name string) (int, error) {
+		name = strings.TrimSpace(name)
+		if n, err := strconv.Atoi(name); err == nil && n > 0 {
+			return n - 1, nil
+		}
+		for i, column := range header {
+			if strings.EqualFold(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")), name) {
+				return i, nil
+			}
+		}
+		return 0, fmt.Errorf("column %q not found", name)
+	}
+	columnList := func(spec string) ([]int, error) {
+		if spec == "" {
+			return nil, nil
+		}
+		var indexes []int
+		for _, name := range strings.Split(spec, "+") {
+			index, err := columnIndex(name)
+			if err != nil {
+				return nil, err
+			}
+			indexes = append(indexes, index)
+		}
+		return indexes, nil
+	}
+
+	if mapping.DateColumn == "" {
+		return nil, fmt.Errorf("date column is required")
+	}
+	if mapping.AmountColumn == "" && mapping.DebitColumn == "" && mapping.CreditColumn == "" {
+		return nil, fmt.Errorf("amount column or debit/credit columns are required")
+	}
+
+	columns := map[string][]int{}
+	for key, spec := range map[string]string{
+		"date": mapping.DateColumn, "amount": mapping.AmountColumn, "debit": mapping.DebitColumn,
+		"credit": mapping.CreditColumn, "description": mapping.DescriptionColumn,
+		"category": mapping.CategoryColumn, "reference": mapping.ReferenceColumn,
+	} {
+		indexes, err := columnList(spec)
+		if err != nil {
+			return nil, err
+		}
+		columns[key] = indexes
+	}
+
+	value := func(record []string, key string) string {
+		var parts []string
+		for _, index := range columns[key] {
+			if index < len(record) {
+				if part := strings.TrimSpace(record[index]); part != "" {
+					parts = append(parts, part)
+				}
+			}
+		}
+		return strings.Join(parts, " ")
+	}
+
+	var rows []ParsedRow
+	for i, record := range records {
+		if isBlankRecord(record) {
+			continue
+		}
+
+		row := ParsedRow{
+			RowNumber:   firstDataLine + i,
+			Description: value(record, "description"),
+			Category:    value(record, "category"),
+			ExternalID:  value(record, "reference"),
+		}
+
+		if date, err := ParseStatementDate(value(record, "date"), mapping.DateFormat); err != nil {
+			row.Errors = append(row.Errors, err.Error())
+		} else {
+			row.Date = &date
+		}
+
+		if len(columns["amount"]) > 0 {
+			amount, err := ParseStatementAmount(value(record, "amount"), mapping.DecimalSeparator)
+			if err != nil {
+				row.Errors = append(row.Errors, err.Error())
+			}
+			row.Amount = amount
+		} else {
+			var debit, credit models.Money
+			var err error
+			if raw := value(record, "debit"); raw != "" {
+				if debit, err = ParseStatementAmount(raw, mapping.DecimalSeparator); err != nil {
+					row.Errors = append(row.Errors, err.Error())
+				}
+			}
+			if raw := value(record, "credit"); raw != "" {
+				if credit, err = ParseStatementAmount(raw, mapping.DecimalSeparator); err != nil {
+					row.Errors = append(row.Errors, err.Error())
+				}
+			}
+			row.Amount = credit.Abs() - debit.Abs()
+		}
+
+		if mapping.NegateAmounts {
+			row.Amount = -row.Amount
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// ofxTagPattern matches an SGML or XML OFX element and its value
+var ofxTagPattern = regexp.MustCompile(`<([A-Za-z0-9.]+)>([^<\r\n]*)`)
+
+// ParseOFXStatement reads the STMTTRN records of an OFX or QFX file. Both
+// SGML (OFX 1.x, unclosed tags) and XML (OFX 2.x) files are supported.
+func ParseOFXStatement(r io.Reader) ([]ParsedRow, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	content := string(data)
+	// Upper-case ASCII bytes only so offsets stay aligned with content,
+	// which is often Latin-1 rather than UTF-8
+	upperBytes := []byte(content)
+	for i, c := range upperBytes {
+		if c >= 'a' && c <= 'z' {
+			upperBytes[i] = c - 'a' + 'A'
+		}
+	}
+	upper := string(upperBytes)
+	if !strings.Contains(upper, "<OFX>") {
+		return nil, fmt.Errorf("not an OFX file")
+	}
+
+	var rows []ParsedRow
+	for offset := 0; ; {
+		start := strings.Index(upper[offset:], "<STMTTRN>")
+		if start < 0 {
+			break
+		}
+		start += offset + len("<STMTTRN>")
+		end := strings.Index(upper[start:], "</STMTTRN>")
+		next := strings.Index(upper[start:], "<STMTTRN>")
+		switch {
+		case end < 0 && next < 0:
+			end = len(content)
+		case end < 0 || (next >= 0 && next < end):
+			end = start + next
+		default:
+			end += start
+		}
+		offset = end
+
+		fields := map[string]string{}
+		for _, match := range ofxTagPattern.FindAllStringSubmatch(content[start:end], -1) {
+			fields[strings.ToUpper(match[1])] = strings.TrimSpace(unescapeOFX(match[2]))
+		}
+
+		row := ParsedRow{
+			RowNumber:  len(rows) + 1,
+			ExternalID: fields["FITID"],
+		}
+
+		if date, err := parseOFXDate(fields["DTPOSTED"]); err != nil {
+			row.Errors = append(row.Errors, err.Error())
+		} else {
+			row.Date = &date
+		}
+
+		amount, err := ParseStatementAmount(fields["TRNAMT"], ".")
+		if err != nil {
+			row.Errors = append(row.Errors, err.Error())
+		}
+		row.Amount = amount
+
+		description := fields["NAME"]
+		if description == "" {
+			description = fields["PAYEE"]
+		}
+		if memo := fields["MEMO"]; memo != "" && !strings.EqualFold(memo, description) {
+			description = strings.TrimSpace(description + " " + memo)
+		}
+		row.Description = description
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// ParseQIFStatement reads a QIF bank or credit card export. QIF dates are
+// usually US style (MM/DD/YY or MM/DD'YY); pass dateFormat for other layouts.
+func ParseQIFStatement(r io.Reader, dateFormat string) ([]ParsedRow, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var rows []ParsedRow
+	var current map[byte]string
+	var splitCategories []string
+	recordNumber := 0
+
+	flush := func() {
+		if current == nil {
+			return
+		}
+		recordNumber++
+		row := ParsedRow{
+			RowNumber:   recordNumber,
+			Description: current['P'],
+			Category:    current['L'],
+			ExternalID:  current['N'],
+		}
+		if memo := current['M']; memo != "" && !strings.EqualFold(memo, row.Description) {
+			row.Description = strings.TrimSpace(row.Description + " " + memo)
+		}
+		if row.Category == "" && len(splitCategories) > 0 {
+			row.Category = splitCategories[0]
+		}
+
+		if date, err := parseQIFDate(current['D'], dateFormat); err != nil {
+			row.Errors = append(row.Errors, err.Error())
+		} else {
+			row.Date = &date
+		}
+
+		rawAmount := current['T']
+		if rawAmount == "" {
+			rawAmount = current['U']
+		}
+		amount, err := ParseStatementAmount(rawAmount, ".")
+		if err != nil {
+			row.Errors = append(row.Errors, err.Error())
+		}
+		row.Amount = amount
+
+		rows = append(rows, row)
+		current = nil
+		splitCategories = nil
+	}
+
+	sawHeader := false
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "!") {
+			sawHeader = true
+			continue
+		}
+		if line == "^" {
+			flush()
+			continue
+		}
+
+		if current == nil {
+			current = map[byte]string{}
+		}
+		code, value := line[0], strings.TrimSpace(line[1:])
+		switch code {
+		case 'S':
+			splitCategories = append(splitCategories, value)
+		default:
+			if _, exists := current[code]; !exists {
+				current[code] = value
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+
+	if !sawHeader && len(rows) == 0 {
+		return nil, fmt.Errorf("not a QIF file")
+	}
+
+	return rows, nil
+}
+
+// ParseStatementDate parses a date using a format such as DD/MM/YYYY, or a
+// list of common layouts when format is empty
+func ParseStatementDate(value, format string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, fmt.Errorf("missing date")
+	}
+
+	layouts := defaultDateLayouts
+	if format != "" {
+		layouts = []string{dateFormatTokens.Replace(format)}
+	}
+	for _, layout := range layouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
+
+// ParseStatementAmount parses bank amounts such as "1,234.56", "(12.00)",
+// "12.00-", "-$5", "12.00 DR" and, with a comma decimal separator, "1.234,56"
+func ParseStatementAmount(value, decimalSeparator string) (models.Money, error) {
+	original := value
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, fmt.Errorf("missing amount")
+	}
+
+	negative := false
+	upper := strings.ToUpper(value)
+	switch {
+	case strings.HasSuffix(upper, "DR"):
+		negative = true
+		value = value[:len(value)-2]
+	case strings.HasSuffix(upper, "CR"):
+		value = value[:len(value)-2]
+	}
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")") {
+		negative = !negative
+		value = value[1 : len(value)-1]
+	}
+	if strings.HasSuffix(value, "-") {
+		negative = !negative
+		value = strings.TrimSuffix(value, "-")
+	}
+
+	thousands := ","
+	if decimalSeparator == "," {
+		thousands = "."
+	}
+
+	var cleaned strings.Builder
+	for _, r := range value {
+		switch {
+		case r >= '0' && r <= '9':
+			cleaned.WriteRune(r)
+		case r == '-':
+			negative = !negative
+		case string(r) == thousands:
+		case string(r) == decimalSeparator || (decimalSeparator == "" && r == '.'):
+			cleaned.WriteByte('.')
+		}
+	}
+
+	amount, err := models.ParseMoney(cleaned.String())
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", strings.TrimSpace(original))
+	}
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+// parseOFXDate parses OFX dates such as 20240115, 20240115120000 or
+// 20240115120000.000[-5:EST]
+func parseOFXDate(value string) (time.Time, error) {
+	if len(value) < 8 {
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+	date, err := time.Parse("20060102", value[:8])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+	return date, nil
+}
+
+// parseQIFDate parses QIF dates, which use an apostrophe before two-digit
+// years from 2000 (1/15'24) and sometimes spaces instead of zero padding
+func parseQIFDate(value, format string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if format != "" {
+		return ParseStatementDate(value, format)
+	}
+
+	normalized := strings.ReplaceAll(strings.ReplaceAll(value, "'", "/"), " ", "")
+	normalized = strings.ReplaceAll(normalized, "-", "/")
+	for _, layout := range []string{"1/2/2006", "1/2/06", "2006/1/2"} {
+		if date, err := time.Parse(layout, normalized); err == nil {
+			return date, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
+
+func unescapeOFX(value string) string {
+	return strings.NewReplacer("&amp;", "&", "&lt;", "<", "&gt;", ">", "&quot;", `"`, "&apos;", "'").Replace(value)
+}
+
+func isBlankRecord(record []string) bool {
+	for _, field := range record {
+		if strings.TrimSpace(field) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,245 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"daybook-backend/models"
+)
+
+func utcDate(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func utcDatePtr(year int, month time.Month, day int) *time.Time {
+	d := utcDate(year, month, day)
+	return &d
+}
+
+// checkRows compares parsed rows on the fields the parsers fill in
+func checkRows(t *testing.T, got, want []ParsedRow) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d rows, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		g, w := got[i], want[i]
+		if g.RowNumber != w.RowNumber || g.Amount != w.Amount || g.Description != w.Description ||
+			g.Category != w.Category || g.ExternalID != w.ExternalID || len(g.Errors) != len(w.Errors) {
+			t.Errorf("row %d = %+v, want %+v", i, g, w)
+			continue
+		}
+		if (g.Date == nil) != (w.Date == nil) || (g.Date != nil && !g.Date.Equal(*w.Date)) {
+			t.Errorf("row %d date = %v, want %v", i, g.Date, w.Date)
+		}
+	}
+}
+
+func TestParseCSVStatement(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		mapping models.ImportMapping
+		want    []ParsedRow
+	}{
+		{
+			name: "header with debit and credit columns",
+			input: "Date,Description,Memo,Debit,Credit,Ref\n" +
+				"15/01/2024,Coffee,Shop,4.50,,R1\n" +
+				"16/01/2024,Salary,,,\"1,000.00\",R2\n" +
+				",,,,,\n" +
+				"bad,Rent,,x,,R3\n",
+			mapping: models.ImportMapping{
+				DateColumn: "Date", DescriptionColumn: "Description+Memo", DebitColumn: "Debit",
+				CreditColumn: "Credit", ReferenceColumn: "Ref", DateFormat: "DD/MM/YYYY", HasHeader: true,
+			},
+			want: []ParsedRow{
+				{RowNumber: 2, Date: utcDatePtr(2024, 1, 15), Amount: -45000, Description: "Coffee Shop", ExternalID: "R1"},
+				{RowNumber: 3, Date: utcDatePtr(2024, 1, 16), Amount: 10000000, Description: "Salary", ExternalID: "R2"},
+				{RowNumber: 5, Description: "Rent", ExternalID: "R3", Errors: []string{"invalid date", "invalid amount"}},
+			},
+		},
+		{
+			name:  "column numbers, comma decimals and negated amounts",
+			input: "Statement export\n2024-02-01;Grocer;12,50\n2024-02-02;Refund;-3,00\n",
+			mapping: models.ImportMapping{
+				DateColumn: "1", DescriptionColumn: "2", AmountColumn: "3", DecimalSeparator: ",",
+				Delimiter: ";", SkipRows: 1, NegateAmounts: true,
+			},
+			want: []ParsedRow{
+				{RowNumber: 2, Date: utcDatePtr(2024, 2, 1), Amount: -125000, Description: "Grocer"},
+				{RowNumber: 3, Date: utcDatePtr(2024, 2, 2), Amount: 30000, Description: "Refund"},
+			},
+		},
+		{
+			name:    "tab delimiter and byte order mark",
+			input:   "\ufeffDate\tAmount\tPayee\n2024-03-05\t-7.25\tBakery\n",
+			mapping: models.ImportMapping{DateColumn: "date", AmountColumn: "amount", DescriptionColumn: "payee", Delimiter: `\t`, HasHeader: true},
+			want: []ParsedRow{
+				{RowNumber: 2, Date: utcDatePtr(2024, 3, 5), Amount: -72500, Description: "Bakery"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := ParseCSVStatement(strings.NewReader(tt.input), tt.mapping)
+			if err != nil {
+				t.Fatalf("ParseCSVStatement error: %v", err)
+			}
+			checkRows(t, rows, tt.want)
+		})
+	}
+}
+
+func TestParseCSVStatementMappingErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapping models.ImportMapping
+	}{
+		{"no date column", models.ImportMapping{AmountColumn: "Amount", HasHeader: true}},
+		{"no amount column", models.ImportMapping{DateColumn: "Date", HasHeader: true}},
+		{"unknown column", models.ImportMapping{DateColumn: "Posted", AmountColumn: "Amount", HasHeader: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseCSVStatement(strings.NewReader("Date,Amount\n2024-01-01,5\n"), tt.mapping); err == nil {
+				t.Error("ParseCSVStatement succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestParseOFXStatement(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []ParsedRow
+	}{
+		{
+			name: "SGML without closing tags",
+			input: "OFXHEADER:100\r\nDATA:OFXSGML\r\n\r\n<OFX><BANKMSGSRSV1><STMTTRNRS><STMTRS><BANKTRANLIST>\r\n" +
+				"<STMTTRN>\r\n<TRNTYPE>DEBIT\r\n<DTPOSTED>20240115120000.000[-5:EST]\r\n<TRNAMT>-42.10\r\n" +
+				"<FITID>2024011501\r\n<NAME>ACME &amp; Sons\r\n<MEMO>Invoice 7\r\n" +
+				"<STMTTRN>\r\n<TRNTYPE>CREDIT\r\n<DTPOSTED>20240116\r\n<TRNAMT>1500.00\r\n" +
+				"<FITID>2024011602\r\n<NAME>Payroll\r\n<MEMO>PAYROLL\r\n" +
+				"</BANKTRANLIST></STMTRS></STMTTRNRS></BANKMSGSRSV1></OFX>\r\n",
+			want: []ParsedRow{
+				{RowNumber: 1, Date: utcDatePtr(2024, 1, 15), Amount: -421000, Description: "ACME & Sons Invoice 7", ExternalID: "2024011501"},
+				{RowNumber: 2, Date: utcDatePtr(2024, 1, 16), Amount: 15000000, Description: "Payroll", ExternalID: "2024011602"},
+			},
+		},
+		{
+			name: "XML with lower-case tags",
+			input: `<?xml version="1.0"?><ofx><banktranlist>` +
+				`<stmttrn><dtposted>20240201</dtposted><trnamt>-5</trnamt><fitid>X1</fitid><payee>Cafe</payee></stmttrn>` +
+				`<stmttrn><dtposted>2024</dtposted><trnamt>ten</trnamt><fitid>X2</fitid></stmttrn>` +
+				`</banktranlist></ofx>`,
+			want: []ParsedRow{
+				{RowNumber: 1, Date: utcDatePtr(2024, 2, 1), Amount: -50000, Description: "Cafe", ExternalID: "X1"},
+				{RowNumber: 2, ExternalID: "X2", Errors: []string{"invalid date", "invalid amount"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := ParseOFXStatement(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("ParseOFXStatement error: %v", err)
+			}
+			checkRows(t, rows, tt.want)
+		})
+	}
+
+	if _, err := ParseOFXStatement(strings.NewReader("Date,Amount\n")); err == nil {
+		t.Error("ParseOFXStatement of a CSV file succeeded, want an error")
+	}
+}
+
+func TestParseQIFStatement(t *testing.T) {
+	input := "!Type:Bank\nD1/15'24\nT-12.50\nPCoffee\nMLatte\nN101\n^\nD02/01/2024\nT100\nPDeposit\nSIncome:Salary\n$100\n^\n"
+	rows, err := ParseQIFStatement(strings.NewReader(input), "")
+	if err != nil {
+		t.Fatalf("ParseQIFStatement error: %v", err)
+	}
+	checkRows(t, rows, []ParsedRow{
+		{RowNumber: 1, Date: utcDatePtr(2024, 1, 15), Amount: -125000, Description: "Coffee Latte", ExternalID: "101"},
+		{RowNumber: 2, Date: utcDatePtr(2024, 2, 1), Amount: 1000000, Description: "Deposit", Category: "Income:Salary"},
+	})
+}
+
+func TestParseStatementAmount(t *testing.T) {
+	tests := []struct {
+		value            string
+		decimalSeparator string
+		want             models.Money
+		wantErr          bool
+	}{
+		{"1,234.56", ".", 12345600, false},
+		{"(12.00)", ".", -120000, false},
+		{"12.00-", ".", -120000, false},
+		{"-$5", ".", -50000, false},
+		{"12.00 DR", ".", -120000, false},
+		{"12.00 cr", ".", 120000, false},
+		{"1.234,56", ",", 12345600, false},
+		{"-0,5", ",", -5000, false},
+		{"7.5", "", 75000, false},
+		{"", ".", 0, true},
+		{"abc", ".", 0, true},
+		{"1.2.3", ".", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseStatementAmount(tt.value, tt.decimalSeparator)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseStatementAmount(%q) = %v, want an error", tt.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseStatementAmount(%q) error: %v", tt.value, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseStatementAmount(%q) = %d, want %d", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseStatementDate(t *testing.T) {
+	tests := []struct {
+		value   string
+		format  string
+		want    time.Time
+		wantErr bool
+	}{
+		{"2024-01-15", "", utcDate(2024, 1, 15), false},
+		{"01/15/2024", "", utcDate(2024, 1, 15), false},
+		{"15 Jan 2024", "", utcDate(2024, 1, 15), false},
+		{"20240115", "", utcDate(2024, 1, 15), false},
+		{"15/01/2024", "DD/MM/YYYY", utcDate(2024, 1, 15), false},
+		{"15-Jan-24", "DD-MMM-YY", utcDate(2024, 1, 15), false},
+		{"1/5/2024", "D/M/YYYY", utcDate(2024, 5, 1), false},
+		{"01/15/2024", "DD/MM/YYYY", time.Time{}, true},
+		{"2024-13-01", "", time.Time{}, true},
+		{" ", "", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value+" "+tt.format, func(t *testing.T) {
+			got, err := ParseStatementDate(tt.value, tt.format)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseStatementDate(%q, %q) = %v, want an error", tt.value, tt.format, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseStatementDate(%q, %q) error: %v", tt.value, tt.format, err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("ParseStatementDate(%q, %q) = %v, want %v", tt.value, tt.format, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,415 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"daybook-backend/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// duplicateDateWindow is how far apart the dates of a fuzzy duplicate may be,
+// since banks often post a few days after the transaction was entered
+const duplicateDateWindow = 3 * 24 * time.Hour
+
+// duplicateSimilarity is the minimum word overlap between two descriptions
+// for a fuzzy duplicate
+const duplicateSimilarity = 0.5
+
+// Duplicate reasons
+const (
+	DuplicateExternalID = "external_id"
+	DuplicateFuzzy      = "fuzzy"
+	DuplicateFile       = "file"
+)
+
+// ErrImportNotPreview is returned when committing a batch that was already committed
+var ErrImportNotPreview = errors.New("import has already been committed")
+
+// ImportRowError describes a row that blocks a commit
+type ImportRowError struct {
+	RowNumber int      `json:"rowNumber"`
+	Errors    []string `json:"errors"`
+}
+
+// ImportValidationError is returned by CommitImportBatch when rows selected
+// for import still have errors
+type ImportValidationError struct {
+	Rows []ImportRowError
+}
+
+func (e *ImportValidationError) Error() string {
+	return fmt.Sprintf("%d row(s) have errors; fix or skip them before committing", len(e.Rows))
+}
+
+// BuildImportBatch validates parsed rows for the account, assigns categories,
+// runs the user's rules, flags duplicates and stores the batch as a preview
+func BuildImportBatch(db *gorm.DB, account models.Account, fileName, format string, parsed []ParsedRow) (*models.ImportBatch, error) {
+	if err := models.EnsureDefaultCategories(db, account.UserID); err != nil {
+		return nil, err
+	}
+	engine, err := LoadRuleEngine(db, account.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	batch := &models.ImportBatch{
+		UserID:    account.UserID,
+		AccountID: account.ID,
+		FileName:  fileName,
+		Format:    format,
+		Status:    models.ImportStatusPreview,
+		TotalRows: len(parsed),
+	}
+
+	for _, p := range parsed {
+		row := models.ImportRow{
+			RowNumber:   p.RowNumber,
+			Date:        p.Date,
+			Description: strings.TrimSpace(p.Description),
+			ExternalID:  strings.TrimSpace(p.ExternalID),
+			Errors:      p.Errors,
+		}
+
+		switch {
+		case p.Amount > 0:
+			row.Type = "income"
+		case p.Amount < 0:
+			row.Type = "expense"
+		default:
+			if len(p.Errors) == 0 {
+				row.Errors = append(row.Errors, "amount is zero")
+			}
+		}
+		row.Amount = p.Amount.Abs().Round(account.Currency)
+		row.CategoryID = importCategory(db, account.UserID, p.Category, row.Type)
+		applyImportRules(engine, account, &row)
+
+		batch.Rows = append(batch.Rows, row)
+	}
+
+	if err := flagDuplicates(db, account, batch.Rows); err != nil {
+		return nil, err
+	}
+
+	for i := range batch.Rows {
+		if len(batch.Rows[i].Errors) > 0 {
+			batch.ErrorRows++
+		}
+		if batch.Rows[i].Duplicate != "" {
+			batch.DuplicateRows++
+		}
+	}
+
+	if err := db.Create(batch).Error; err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// CommitImportBatch creates a transaction for every row that is not skipped
+// and not a duplicate, unless the row is listed in includeDuplicates. All
+// transactions and balance updates are created in one database transaction,
+// so either every row is imported or none is.
+func CommitImportBatch(db *gorm.DB, batch *models.ImportBatch, includeDuplicates []uuid.UUID) (int, error) {
+	included := make(map[uuid.UUID]bool, len(includeDuplicates))
+	for _, id := range includeDuplicates {
+		included[id] = true
+	}
+
+	imported := 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Claim the batch so a double submit cannot import it twice
+		now := time.Now()
+		claim := tx.Model(&models.ImportBatch{}).
+			Where("id = ? AND status = ?", batch.ID, models.ImportStatusPreview).
+			Updates(map[string]interface{}{"status": models.ImportStatusCommitted, "committed_at": now})
+		if claim.Error != nil {
+			return claim.Error
+		}
+		if claim.RowsAffected == 0 {
+			return ErrImportNotPreview
+		}
+
+		var rows []models.ImportRow
+		if err := tx.Where("batch_id = ?", batch.ID).Order("row_number ASC").Find(&rows).Error; err != nil {
+			return err
+		}
+
+		var selected []*models.ImportRow
+		var rowErrors []ImportRowError
+		for i := range rows {
+			row := &rows[i]
+			if row.Skip || (row.Duplicate != "" && !included[row.ID]) {
+				continue
+			}
+			if len(row.Errors) > 0 {
+				rowErrors = append(rowErrors, ImportRowError{RowNumber: row.RowNumber, Errors: row.Errors})
+				continue
+			}
+			selected = append(selected, row)
+		}
+		if len(rowErrors) > 0 {
+			return &ImportValidationError{Rows: rowErrors}
+		}
+
+		for _, row := range selected {
+			transaction := models.Transaction{
+				UserID:        batch.UserID,
+				AccountID:     batch.AccountID,
+				Type:          row.Type,
+				Amount:        row.Amount,
+				CategoryID:    row.CategoryID,
+				Date:          *row.Date,
+				Description:   row.Description,
+				Tags:          row.Tags,
+				ExternalID:    row.ExternalID,
+				ImportBatchID: &batch.ID,
+			}
+			if row.TransferAccountID != nil {
+				transaction.MarkAsTransfer(*row.TransferAccountID)
+				if err := resolveRuleTransfer(tx, &transaction); err != nil {
+					return err
+				}
+			}
+			if err := tx.Create(&transaction).Error; err != nil {
+				return err
+			}
+			if err := transaction.ApplyBalance(tx); err != nil {
+				return err
+			}
+			if err := tx.Model(row).Update("transaction_id", transaction.ID).Error; err != nil {
+				return err
+			}
+		}
+
+		imported = len(selected)
+		batch.Status = models.ImportStatusCommitted
+		batch.CommittedAt = &now
+		batch.ImportedRows = imported
+		return tx.Model(batch).Update("imported_rows", imported).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return imported, nil
+}
+
+// applyImportRules runs the rules on a row as the transaction it would
+// create, keeping the category, description and tags they set. A rule that
+// turns the row into a transfer records the other account, and the transfer
+// is made on commit.
+func applyImportRules(engine *RuleEngine, account models.Account, row *models.ImportRow) {
+	if row.Type == "" || len(row.Errors) > 0 {
+		return
+	}
+
+	transaction := models.Transaction{
+		UserID:      account.UserID,
+		AccountID:   account.ID,
+		Type:        row.Type,
+		Amount:      row.Amount,
+		CategoryID:  row.CategoryID,
+		Description: row.Description,
+	}
+	if row.Date != nil {
+		transaction.Date = *row.Date
+	}
+	if len(engine.Apply(&transaction, "")) == 0 {
+		return
+	}
+
+	row.Description = transaction.Description
+	row.Tags = transaction.Tags
+	switch {
+	case transaction.Type != "transfer":
+		row.CategoryID = transaction.CategoryID
+	case row.Type == "income":
+		row.TransferAccountID = &transaction.AccountID
+	default:
+		row.TransferAccountID = transaction.ToAccountID
+	}
+}
+
+// importCategory maps a category from the file to one of the user's
+// categories of the matching kind, falling back to Other Income/Expense
+func importCategory(db *gorm.DB, userID uuid.UUID, name, transactionType string) string {
+	if name != "" {
+		for _, candidate := range []string{name, strings.ToLower(strings.Join(strings.Fields(name), "_"))} {
+			if category, err := models.FindCategory(db, userID, candidate); err == nil && category.Kind == transactionType {
+				return category.Key
+			}
+		}
+	}
+
+	if transactionType == "income" {
+		return "other_income"
+	}
+	return "other_expense"
+}
+
+// flagDuplicates marks rows that match an existing transaction on the account
+// by external ID, or by amount, type, a date within duplicateDateWindow and a
+// similar description. Rows repeated within the file are flagged as well.
+// Each existing transaction matches at most one row.
+func flagDuplicates(db *gorm.DB, account models.Account, rows []models.ImportRow) error {
+	var earliest, latest time.Time
+	var externalIDs []string
+	for _, row := range rows {
+		if row.ExternalID != "" {
+			externalIDs = append(externalIDs, row.ExternalID)
+		}
+		if row.Date == nil {
+			continue
+		}
+		if earliest.IsZero() || row.Date.Before(earliest) {
+			earliest = *row.Date
+		}
+		if row.Date.After(latest) {
+			latest = *row.Date
+		}
+	}
+
+	var existing []models.Transaction
+	if !earliest.IsZero() || len(externalIDs) > 0 {
+		query := db.Where("account_id = ? AND user_id = ? AND credit_card_id IS NULL", account.ID, account.UserID)
+		switch {
+		case !earliest.IsZero() && len(externalIDs) > 0:
+			query = query.Where("(date BETWEEN ? AND ?) OR external_id IN ?",
+				earliest.Add(-duplicateDateWindow), latest.Add(duplicateDateWindow), externalIDs)
+		case !earliest.IsZero():
+			query = query.Where("date BETWEEN ? AND ?", earliest.Add(-duplicateDateWindow), latest.Add(duplicateDateWindow))
+		default:
+			query = query.Where("external_id IN ?", externalIDs)
+		}
+		if err := query.Find(&existing).Error; err != nil {
+			return err
+		}
+	}
+
+	matched := make(map[uuid.UUID]bool)
+	byExternalID := make(map[string]*models.Transaction)
+	for i := range existing {
+		if existing[i].ExternalID != "" {
+			byExternalID[existing[i].ExternalID] = &existing[i]
+		}
+	}
+
+	seenExternalIDs := make(map[string]bool)
+	seenRows := make(map[string]bool)
+	for i := range rows {
+		row := &rows[i]
+
+		if row.ExternalID != "" {
+			if transaction, ok := byExternalID[row.ExternalID]; ok {
+				row.Duplicate = DuplicateExternalID
+				row.DuplicateOfID = &transaction.ID
+				matched[transaction.ID] = true
+				continue
+			}
+			if seenExternalIDs[row.ExternalID] {
+				row.Duplicate = DuplicateFile
+				continue
+			}
+			seenExternalIDs[row.ExternalID] = true
+		}
+
+		if row.Date == nil || row.Type == "" {
+			continue
+		}
+
+		for j := range existing {
+			transaction := &existing[j]
+			if matched[transaction.ID] || transaction.Type != row.Type || transaction.Amount != row.Amount {
+				continue
+			}
+			// Both sides carry bank references that differ, so they are different transactions
+			if row.ExternalID != "" && transaction.ExternalID != "" {
+				continue
+			}
+			gap := transaction.Date.Sub(*row.Date)
+			if gap < -duplicateDateWindow || gap > duplicateDateWindow {
+				continue
+			}
+			if descriptionSimilarity(transaction.Description, row.Description) < duplicateSimilarity {
+				continue
+			}
+			row.Duplicate = DuplicateFuzzy
+			row.DuplicateOfID = &transaction.ID
+			matched[transaction.ID] = true
+			break
+		}
+		if row.Duplicate != "" || row.ExternalID != "" {
+			continue
+		}
+
+		key := fmt.Sprintf("%s|%s|%s|%s", row.Date.Format("2006-01-02"), row.Type, row.Amount, strings.Join(descriptionWords(row.Description), " "))
+		if seenRows[key] {
+			row.Duplicate = DuplicateFile
+		}
+		seenRows[key] = true
+	}
+
+	return nil
+}
+
+// descriptionSimilarity returns the share of words two descriptions have in
+// common (Jaccard index). Empty descriptions on both sides count as equal,
+// and a description wholly contained in the other counts as a match.
+func descriptionSimilarity(a, b string) float64 {
+	wordsA, wordsB := descriptionWords(a), descriptionWords(b)
+	if len(wordsA) == 0 && len(wordsB) == 0 {
+		return 1
+	}
+	if len(wordsA) == 0 || len(wordsB) == 0 {
+		return 0
+	}
+
+	joinedA, joinedB := strings.Join(wordsA, " "), strings.Join(wordsB, " ")
+	if strings.Contains(joinedA, joinedB) || strings.Contains(joinedB, joinedA) {
+		return 1
+	}
+
+	set := make(map[string]bool, len(wordsA))
+	for _, word := range wordsA {
+		set[word] = true
+	}
+	common := 0
+	union := len(set)
+	seen := make(map[string]bool, len(wordsB))
+	for _, word := range wordsB {
+		if seen[word] {
+			continue
+		}
+		seen[word] = true
+		if set[word] {
+			common++
+		} else {
+			union++
+		}
+	}
+
+	return float64(common) / float64(union)
+}
+
+// descriptionWords lower-cases a description and splits it into words,
+// dropping punctuation and pure numbers such as card or reference numbers
+func descriptionWords(description string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(description), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	words := fields[:0]
+	for _, field := range fields {
+		if strings.IndexFunc(field, unicode.IsLetter) >= 0 {
+			words = append(words, field)
+		}
+	}
+	return words
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"math"
+	"testing"
+
+	"daybook-backend/models"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// stubConnector is a database connection that answers every query with the
+// same rows, so that code reading from the database can be tested without
+// Postgres
+type stubConnector struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (c *stubConnector) Connect(context.Context) (driver.Conn, error) { return stubConn{c}, nil }
+func (c *stubConnector) Driver() driver.Driver                        { return nil }
+
+type stubConn struct{ connector *stubConnector }
+
+func (c stubConn) Prepare(string) (driver.Stmt, error) { return stubStmt(c), nil }
+func (c stubConn) Close() error                        { return nil }
+func (c stubConn) Begin() (driver.Tx, error)           { return nil, driver.ErrSkip }
+
+type stubStmt struct{ connector *stubConnector }
+
+func (s stubStmt) Close() error                               { return nil }
+func (s stubStmt) NumInput() int                              { return -1 }
+func (s stubStmt) Exec([]driver.Value) (driver.Result, error) { return driver.RowsAffected(0), nil }
+func (s stubStmt) Query([]driver.Value) (driver.Rows, error) {
+	return &stubRows{columns: s.connector.columns, rows: s.connector.rows}, nil
+}
+
+type stubRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *stubRows) Columns() []string { return r.columns }
+func (r *stubRows) Close() error      { return nil }
+func (r *stubRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// stubDB returns a database whose queries all return rows
+func stubDB(t *testing.T, columns []string, rows ...[]driver.Value) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sql.OpenDB(&stubConnector{columns: columns, rows: rows})}),
+		&gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestFlagDuplicates(t *testing.T) {
+	coffeeID, salaryID, rentID := uuid.New(), uuid.New(), uuid.New()
+	db := stubDB(t, []string{"id", "type", "amount", "date", "description", "external_id"},
+		[]driver.Value{coffeeID.String(), "expense", "50.0000", utcDate(2024, 3, 10), "STARBUCKS COFFEE #1234", ""},
+		[]driver.Value{salaryID.String(), "income", "1000.0000", utcDate(2024, 3, 1), "Salary ACME", "FIT-1"},
+		[]driver.Value{rentID.String(), "expense", "800.0000", utcDate(2024, 3, 3), "Rent", "FIT-3"},
+	)
+
+	row := func(day int, transactionType string, amount models.Money, description, externalID string) models.ImportRow {
+		return models.ImportRow{Date: utcDatePtr(2024, 3, day), Type: transactionType, Amount: amount, Description: description, ExternalID: externalID}
+	}
+	rows := []models.ImportRow{
+		row(1, "income", 10000000, "Payroll", "FIT-1"),       // Same bank reference as the salary
+		row(12, "expense", 500000, "Starbucks Coffee", ""),   // Within the date window of the coffee
+		row(12, "expense", 500000, "Starbucks Coffee", ""),   // The coffee is already matched
+		row(12, "expense", 500000, "Starbucks Coffee", ""),   // Repeats the row above
+		row(20, "expense", 500000, "Starbucks Coffee", ""),   // Outside the date window
+		row(10, "expense", 500000, "Amazon Marketplace", ""), // Different description
+		row(10, "income", 500000, "Starbucks Coffee", ""),    // Different type
+		row(5, "expense", 120000, "Bus fare", "FIT-9"),
+		row(6, "expense", 130000, "Train fare", "FIT-9"), // Repeats a bank reference in the file
+		row(3, "expense", 8000000, "Rent", "FIT-4"),      // Bank references differ
+		row(4, "expense", 8000000, "Rent", ""),
+	}
+	rows = append(rows, models.ImportRow{Type: "expense", Amount: 500000, Description: "Starbucks Coffee"}) // No date
+
+	if err := flagDuplicates(db, models.Account{ID: uuid.New(), UserID: uuid.New()}, rows); err != nil {
+		t.Fatalf("flagDuplicates error: %v", err)
+	}
+
+	want := []struct {
+		duplicate     string
+		duplicateOfID *uuid.UUID
+	}{
+		{DuplicateExternalID, &salaryID},
+		{DuplicateFuzzy, &coffeeID},
+		{"", nil},
+		{DuplicateFile, nil},
+		{"", nil},
+		{"", nil},
+		{"", nil},
+		{"", nil},
+		{DuplicateFile, nil},
+		{"", nil},
+		{DuplicateFuzzy, &rentID},
+		{"", nil},
+	}
+	for i, w := range want {
+		got := rows[i]
+		if got.Duplicate != w.duplicate {
+			t.Errorf("row %d duplicate = %q, want %q", i, got.Duplicate, w.duplicate)
+		}
+		if (got.DuplicateOfID == nil) != (w.duplicateOfID == nil) || (w.duplicateOfID != nil && *got.DuplicateOfID != *w.duplicateOfID) {
+			t.Errorf("row %d duplicate of %v, want %v", i, got.DuplicateOfID, w.duplicateOfID)
+		}
+	}
+}
+
+func TestFlagDuplicatesWithinFile(t *testing.T) {
+	db := stubDB(t, []string{"id"})
+	rows := []models.ImportRow{
+		{Date: utcDatePtr(2024, 3, 1), Type: "expense", Amount: 10000, Description: "Card 4411 ACME"},
+		{Date: utcDatePtr(2024, 3, 1), Type: "expense", Amount: 10000, Description: "card, ACME #9932"},
+		{Date: utcDatePtr(2024, 3, 1), Type: "expense", Amount: 20000, Description: "ACME"},
+	}
+	if err := flagDuplicates(db, models.Account{ID: uuid.New(), UserID: uuid.New()}, rows); err != nil {
+		t.Fatalf("flagDuplicates error: %v", err)
+	}
+	for i, want := range []string{"", DuplicateFile, ""} {
+		if rows[i].Duplicate != want {
+			t.Errorf("row %d duplicate = %q, want %q", i, rows[i].Duplicate, want)
+		}
+	}
+}
+
+func TestDescriptionSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"", "", 1},
+		{"#1234", "", 1}, // Numbers and punctuation are dropped
+		{"Coffee", "", 0},
+		{"STARBUCKS COFFEE #1234", "Starbucks Coffee", 1},
+		{"Starbucks Coffee", "coffee", 1}, // Contained in the other
+		{"Uber trip", "Uber eats", 1.0 / 3},
+		{"rent march flat", "rent april flat flat", 2.0 / 4},
+		{"Amazon Marketplace", "Netflix", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+"|"+tt.b, func(t *testing.T) {
+			for _, pair := range [][2]string{{tt.a, tt.b}, {tt.b, tt.a}} {
+				if got := descriptionSimilarity(pair[0], pair[1]); math.Abs(got-tt.want) > 1e-9 {
+					t.Errorf("descriptionSimilarity(%q, %q) = %v, want %v", pair[0], pair[1], got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"time"
+
+	"daybook-backend/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Credit card charge kinds posted by the backend, matching
+// CreditCardTransaction.Type
+const (
+	CardChargeInterest = "interest"
+	CardChargeFee      = "fee"
+)
+
+// dailyCardChangesSQL sums the change to what is owed on a card per day
+const dailyCardChangesSQL = `
+SELECT (e.date AT TIME ZONE 'UTC')::date AS day, -SUM(p.amount) AS change
+FROM postings p
+JOIN journal_entries e ON e.id = p.entry_id
+WHERE p.ledger_type = @ledger AND p.ledger_id = @card
+	AND e.date >= @start AND e.date < @end
+GROUP BY 1`
+
+// gracePeriodLost reports whether the previous statement was left unpaid
+// past its due date as of the end of the cycle closing on statementDate.
+// Until then purchases are interest free.
+func gracePeriodLost(previous *models.Statement, statementDate time.Time) bool {
+	if previous.ID == uuid.Nil || previous.ClosingBalance <= 0 {
+		return false
+	}
+
+	// Not due yet
+	dueEnd := previous.DueDate.AddDate(0, 0, 1)
+	if dueEnd.After(statementDate.AddDate(0, 0, 1)) {
+		return false
+	}
+
+	if !previous.Paid {
+		return true
+	}
+	return previous.PaidDate != nil && !previous.PaidDate.Before(dueEnd)
+}
+
+// accrueInterest charges interest for the cycle from periodStart through
+// statementDate on the average daily balance, at the card's APR over a
+// 365-day year, when the previous statement was not paid in full by its due
+// date. The charge is dated on the statement date so that it appears on the
+// statement being closed.
+func accrueInterest(tx *gorm.DB, card *models.CreditCard, previous *models.Statement, periodStart, statementDate time.Time) error {
+	if card.APR <= 0 || !gracePeriodLost(previous, statementDate) {
+		return nil
+	}
+
+	periodEnd := statementDate.AddDate(0, 0, 1)
+	balance, err := cardBalanceBefore(tx, card.ID, periodStart)
+	if err != nil {
+		return err
+	}
+
+	var rows []struct {
+		Day    time.Time
+		Change models.Money
+	}
+	err = tx.Raw(dailyCardChangesSQL, map[string]interface{}{
+		"ledger": models.LedgerCreditCard,
+		"card":   card.ID,
+		"start":  periodStart,
+		"end":    periodEnd,
+	}).Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+	changes := make(map[string]models.Money, len(rows))
+	for _, row := range rows {
+		changes[row.Day.Format("2006-01-02")] += row.Change
+	}
+
+	// Sum of the balances owed at the end of each day; the average daily
+	// balance times the days in the cycle
+	var balanceDays models.Money
+	for day := periodStart; day.Before(periodEnd); day = day.AddDate(0, 0, 1) {
+		balance += changes[day.Format("2006-01-02")]
+		if balance > 0 {
+			balanceDays += balance
+		}
+	}
+
+	interest := balanceDays.Mul(card.APR / 100 / 365).Round(card.Currency)
+	if interest <= 0 {
+		return nil
+	}
+	return postCardCharge(tx, card, CardChargeInterest, interest, statementDate, "Interest charge: "+card.Name)
+}
+
+// assessLateFees charges the card's late fee for each generated statement
+// whose due date ended before until without its minimum payment being met.
+// Fees are dated the day after the due date, or on notBefore when that falls
+// in a cycle that has already closed.
+func assessLateFees(tx *gorm.DB, card *models.CreditCard, until, notBefore time.Time) error {
+	var statements []models.Statement
+	err := tx.Where("card_id = ? AND generated = ? AND late_fee_assessed = ? AND due_date <= ?", card.ID, true, false, until.AddDate(0, 0, -1)).
+		Order("statement_date").
+		Find(&statements).Error
+	if err != nil {
+		return err
+	}
+
+	for i := range statements {
+		statement := &statements[i]
+		dueEnd := statement.DueDate.AddDate(0, 0, 1)
+
+		paid, err := cardPaymentsBetween(tx, card.ID, statement.StatementDate.AddDate(0, 0, 1), dueEnd)
+		if err != nil {
+			return err
+		}
+
+		var fee models.Money
+		if card.LateFee > 0 && statement.MinimumPayment > 0 && paid < statement.MinimumPayment {
+			fee = card.LateFee
+			date := dueEnd
+			if date.Before(notBefore) {
+				date = notBefore
+			}
+			if err := postCardCharge(tx, card, CardChargeFee, fee, date, "Late payment fee: "+card.Name); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Model(statement).UpdateColumns(map[string]interface{}{
+			"late_fee_assessed": true,
+			"late_fee_charged":  fee,
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AssessLateFees charges late fees for the card's statements that went past
+// due since its last statement closed, so they show up before the next one
+func AssessLateFees(db *gorm.DB, card *models.CreditCard, now time.Time) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(card, "id = ?", card.ID).Error; err != nil {
+			return err
+		}
+
+		var latest models.Statement
+		if err := tx.Where("card_id = ?", card.ID).Order("statement_date DESC").Limit(1).Find(&latest).Error; err != nil {
+			return err
+		}
+		if latest.ID == uuid.Nil {
+			return nil
+		}
+		return assessLateFees(tx, card, now, latest.StatementDate.AddDate(0, 0, 1))
+	})
+}
+
+// cardPaymentsBetween returns the payments credited to the card in [from, to),
+// in the card's currency
+func cardPaymentsBetween(tx *gorm.DB, cardID uuid.UUID, from, to time.Time) (models.Money, error) {
+	var row struct {
+		Total models.Money
+	}
+	err := tx.Model(&models.Transaction{}).
+		Select("COALESCE(SUM(COALESCE(to_amount, amount)), 0) AS total").
+		Where("credit_card_id = ? AND account_id <> credit_card_id AND type <> ?", cardID, "tracking").
+		Where("date >= ? AND date < ?", from, to).
+		Scan(&row).Error
+	return row.Total, err
+}
+
+// postCardCharge books an interest or fee charge on the card as a credit
+// card transaction with its linked transaction, like one entered by hand
+func postCardCharge(tx *gorm.DB, card *models.CreditCard, kind string, amount models.Money, date time.Time, description string) error {
+	category := models.CategoryCreditCardFee
+	if kind == CardChargeInterest {
+		category = models.CategoryCreditCardInterest
+	}
+
+	transaction := models.Transaction{
+		UserID:       card.UserID,
+		AccountID:    card.ID,
+		Type:         "expense",
+		Amount:       amount,
+		Date:         date,
+		Description:  description,
+		CategoryID:   category,
+		CreditCardID: &card.ID,
+	}
+	if err := tx.Create(&transaction).Error; err != nil {
+		return err
+	}
+
+	ccTransaction := models.CreditCardTransaction{
+		UserID:        card.UserID,
+		CardID:        card.ID,
+		TransactionID: transaction.ID,
+		CategoryID:    category,
+		Amount:        amount,
+		Description:   description,
+		Date:          date,
+		Type:          kind,
+	}
+	if err := tx.Create(&ccTransaction).Error; err != nil {
+		return err
+	}
+
+	return transaction.ApplyBalance(tx)
+}
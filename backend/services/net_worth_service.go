@@ -0,0 +1,428 @@
+package services
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"daybook-backend/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// netWorthBackfillDays is how many days of snapshots are reconstructed for
+// a user without any, or when they are rebuilt
+const netWorthBackfillDays = 365
+
+// netWorthEffectsSQL totals by day in the user's time zone the change every
+// transaction dated from @since made to account and credit card ledgers
+const netWorthEffectsSQL = `WITH ` + transactionEffectsSQL + `
+SELECT e.ledger_type, e.ledger_id, DATE(t.date AT TIME ZONE @zone) AS day, SUM(e.amount) AS amount
+FROM effects e JOIN transactions t ON t.id = e.transaction_id
+WHERE t.date >= @since
+GROUP BY 1, 2, 3`
+
+// ledgerFirstActivitySQL finds the date of the first transaction on every
+// account and credit card ledger
+const ledgerFirstActivitySQL = `WITH ` + transactionEffectsSQL + `
+SELECT e.ledger_type, e.ledger_id, MIN(t.date) AS first_date
+FROM effects e JOIN transactions t ON t.id = e.transaction_id
+GROUP BY 1, 2`
+
+// NetWorthItem is one account, credit card or goal holding in a net worth
+type NetWorthItem struct {
+	Kind     string       `json:"kind"`   // asset, liability
+	Source   string       `json:"source"` // account, credit_card, holding
+	ID       uuid.UUID    `json:"id"`
+	Name     string       `json:"name"`
+	Type     string       `json:"type"`
+	Balance  models.Money `json:"balance"`  // In Currency; what is owed for liabilities
+	Currency string       `json:"currency"` // Of the account or card
+	Amount   models.Money `json:"amount"`   // Balance in the user's currency
+}
+
+// NetWorth is the user's assets, liabilities and net worth at the end of a
+// day in their currency
+type NetWorth struct {
+	Date        time.Time             `json:"date"`
+	Currency    string                `json:"currency"`
+	Assets      models.Money          `json:"assets"`
+	Liabilities models.Money          `json:"liabilities"`
+	NetWorth    models.Money          `json:"netWorth"`
+	Breakdown   []models.NetWorthLine `json:"breakdown"` // Assets first, largest first
+	Items       []NetWorthItem        `json:"items"`
+}
+
+// netWorthLedger is an account, credit card or holding with its value today
+// in posting signs: positive for money held, negative for money owed
+type netWorthLedger struct {
+	source   string
+	id       uuid.UUID
+	name     string
+	kind     string // Account or holding type
+	currency string
+	value    models.Money
+	since    time.Time // Counted from this moment
+}
+
+// ledgerEffect is the change transactions made to a ledger on a day
+type ledgerEffect struct {
+	LedgerType string
+	LedgerID   uuid.UUID
+	Day        time.Time
+	Amount     models.Money
+}
+
+// netWorthBook holds what is needed to value the user's ledgers on the days
+// from the one it was loaded for
+type netWorthBook struct {
+	location  *time.Location
+	currency  string
+	converter *models.CurrencyConverter
+	ledgers   []netWorthLedger
+	effects   []ledgerEffect // Latest day first
+}
+
+// loadNetWorthBook loads the user's accounts, credit cards and active goal
+// holdings, with the effects of transactions dated after the day from
+func loadNetWorthBook(db *gorm.DB, userID uuid.UUID, from time.Time) (*netWorthBook, error) {
+	settings := models.UserSettings(db, userID)
+	book := &netWorthBook{location: settings.Location(), currency: models.UserCurrency(db, userID)}
+
+	var err error
+	if book.converter, err = models.NewCurrencyConverter(db, userID); err != nil {
+		return nil, err
+	}
+
+	args := map[string]interface{}{
+		"all":   false,
+		"user":  userID,
+		"zone":  book.location.String(),
+		"since": from.AddDate(0, 0, 1),
+	}
+	var firsts []struct {
+		LedgerType string
+		LedgerID   uuid.UUID
+		FirstDate  time.Time
+	}
+	if err := db.Raw(ledgerFirstActivitySQL, args).Scan(&firsts).Error; err != nil {
+		return nil, err
+	}
+	firstActivity := make(map[uuid.UUID]time.Time, len(firsts))
+	for _, first := range firsts {
+		firstActivity[first.LedgerID] = first.FirstDate
+	}
+	// A ledger counts from its creation, or its first transaction when that
+	// is earlier, such as for imported history
+	since := func(id uuid.UUID, created time.Time) time.Time {
+		if first, ok := firstActivity[id]; ok && first.Before(created) {
+			return first
+		}
+		return created
+	}
+
+	var accounts []models.Account
+	if err := db.Where("user_id = ?", userID).Find(&accounts).Error; err != nil {
+		return nil, err
+	}
+	for _, account := range accounts {
+		book.ledgers = append(book.ledgers, netWorthLedger{
+			source:   models.NetWorthSourceAccount,
+			id:       account.ID,
+			name:     account.Name,
+			kind:     account.Type,
+			currency: account.Currency,
+			value:    account.Balance,
+			since:    since(account.ID, account.CreatedAt),
+		})
+	}
+
+	var cards []models.CreditCard
+	if err := db.Where("user_id = ?", userID).Find(&cards).Error; err != nil {
+		return nil, err
+	}
+	for _, card := range cards {
+		book.ledgers = append(book.ledgers, netWorthLedger{
+			source:   models.NetWorthSourceCreditCard,
+			id:       card.ID,
+			name:     card.Name,
+			kind:     models.NetWorthSourceCreditCard,
+			currency: card.Currency,
+			value:    -card.CurrentBalance,
+			since:    since(card.ID, card.CreatedAt),
+		})
+	}
+
+	// Holdings count at their current value, as goals do
+	var holdings []models.GoalHolding
+	err = db.Joins("JOIN goals ON goals.id = goal_holdings.goal_id AND goals.deleted_at IS NULL").
+		Where("goal_holdings.user_id = ? AND goal_holdings.status IN ?", userID, []string{"active", "matured", "achieved"}).
+		Find(&holdings).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, holding := range holdings {
+		book.ledgers = append(book.ledgers, netWorthLedger{
+			source:   models.NetWorthSourceHolding,
+			id:       holding.ID,
+			name:     holding.Name,
+			kind:     holding.Type,
+			currency: book.currency,
+			value:    holding.CurrentValue,
+			since:    holding.PurchaseDate,
+		})
+	}
+
+	if err := db.Raw(netWorthEffectsSQL, args).Scan(&book.effects).Error; err != nil {
+		return nil, err
+	}
+	for i := range book.effects {
+		// Dates come back as midnight UTC; the day is in the user's time zone
+		day := book.effects[i].Day
+		book.effects[i].Day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, book.location)
+	}
+	sort.Slice(book.effects, func(i, j int) bool { return book.effects[i].Day.After(book.effects[j].Day) })
+
+	return book, nil
+}
+
+// earliest returns when the first ledger started to count, or the zero time
+// without ledgers
+func (b *netWorthBook) earliest() time.Time {
+	var earliest time.Time
+	for _, ledger := range b.ledgers {
+		if earliest.IsZero() || ledger.since.Before(earliest) {
+			earliest = ledger.since
+		}
+	}
+	return earliest
+}
+
+// series values the ledgers at the end of each day, which must be midnights
+// in the user's time zone no earlier than the day the book was loaded for.
+// Balances are today's with the effects of later transactions undone.
+func (b *netWorthBook) series(days []time.Time) ([]NetWorth, error) {
+	sorted := append([]time.Time(nil), days...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].After(sorted[j]) })
+
+	later := make(map[uuid.UUID]models.Money)
+	next := 0
+	byDay := make(map[time.Time]NetWorth, len(sorted))
+	for _, day := range sorted {
+		for ; next < len(b.effects) && b.effects[next].Day.After(day); next++ {
+			later[b.effects[next].LedgerID] += b.effects[next].Amount
+		}
+		netWorth, err := b.valueAt(day, later)
+		if err != nil {
+			return nil, err
+		}
+		byDay[day] = netWorth
+	}
+
+	result := make([]NetWorth, len(days))
+	for i, day := range days {
+		result[i] = byDay[day]
+	}
+	return result, nil
+}
+
+// valueAt values the ledgers at the end of day, given the effects of the
+// transactions after it
+func (b *netWorthBook) valueAt(day time.Time, later map[uuid.UUID]models.Money) (NetWorth, error) {
+	end := day.AddDate(0, 0, 1)
+	netWorth := NetWorth{Date: day, Currency: b.currency, Breakdown: []models.NetWorthLine{}, Items: []NetWorthItem{}}
+
+	type lineKey struct{ kind, source, kindOf string }
+	lines := make(map[lineKey]*models.NetWorthLine)
+	for _, ledger := range b.ledgers {
+		if !ledger.since.Before(end) {
+			continue
+		}
+
+		value := ledger.value - later[ledger.id]
+		currency := ledger.currency
+		if currency == "" {
+			currency = b.currency
+		}
+		amount, err := b.converter.Convert(value, currency, b.currency, day)
+		if err != nil {
+			return NetWorth{}, err
+		}
+
+		// Overdrawn accounts are owed and cards in credit are held
+		kind := models.NetWorthAsset
+		if value < 0 {
+			kind = models.NetWorthLiability
+			value, amount = -value, -amount
+			netWorth.Liabilities += amount
+		} else {
+			netWorth.Assets += amount
+		}
+
+		netWorth.Items = append(netWorth.Items, NetWorthItem{
+			Kind:     kind,
+			Source:   ledger.source,
+			ID:       ledger.id,
+			Name:     ledger.name,
+			Type:     ledger.kind,
+			Balance:  value,
+			Currency: currency,
+			Amount:   amount,
+		})
+
+		key := lineKey{kind, ledger.source, ledger.kind}
+		if lines[key] == nil {
+			lines[key] = &models.NetWorthLine{Kind: kind, Source: ledger.source, Type: ledger.kind}
+		}
+		lines[key].Amount += amount
+		lines[key].Count++
+	}
+	netWorth.NetWorth = netWorth.Assets - netWorth.Liabilities
+
+	for _, line := range lines {
+		netWorth.Breakdown = append(netWorth.Breakdown, *line)
+	}
+	sort.Slice(netWorth.Breakdown, func(i, j int) bool {
+		a, b := netWorth.Breakdown[i], netWorth.Breakdown[j]
+		if a.Kind != b.Kind {
+			return a.Kind == models.NetWorthAsset
+		}
+		if a.Amount != b.Amount {
+			return a.Amount > b.Amount
+		}
+		return a.Source+a.Type < b.Source+b.Type
+	})
+	sort.Slice(netWorth.Items, func(i, j int) bool {
+		a, b := netWorth.Items[i], netWorth.Items[j]
+		if a.Kind != b.Kind {
+			return a.Kind == models.NetWorthAsset
+		}
+		return a.Amount > b.Amount
+	})
+
+	return netWorth, nil
+}
+
+// CalculateNetWorth reconstructs the user's net worth at the end of the day
+// that contains date in their time zone. Account and card balances are
+// today's with the transactions dated after the day undone; goal holdings
+// held on the day count at their current value.
+func CalculateNetWorth(db *gorm.DB, userID uuid.UUID, date time.Time) (*NetWorth, error) {
+	settings := models.UserSettings(db, userID)
+	local := date.In(settings.Location())
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, local.Location())
+
+	book, err := loadNetWorthBook(db, userID, day)
+	if err != nil {
+		return nil, err
+	}
+	series, err := book.series([]time.Time{day})
+	if err != nil {
+		return nil, err
+	}
+	return &series[0], nil
+}
+
+// SnapshotNetWorth takes today's net worth snapshot of every user, filling
+// in the days missed since their last one
+func SnapshotNetWorth(db *gorm.DB, now time.Time) error {
+	var users []models.User
+	if err := db.Find(&users).Error; err != nil {
+		return err
+	}
+
+	for i := range users {
+		if _, err := TakeNetWorthSnapshots(db, users[i].ID, now, false); err != nil {
+			log.Printf("Failed to snapshot net worth of user %s: %v", users[i].ID, err)
+		}
+	}
+
+	return nil
+}
+
+// TakeNetWorthSnapshots stores the user's net worth for every day from
+// their latest snapshot through today, retaking today's, and returns how
+// many it stored. A user without snapshots, or with rebuild, gets the last
+// year of days reconstructed, from the day their first account, card or
+// holding counts.
+func TakeNetWorthSnapshots(db *gorm.DB, userID uuid.UUID, now time.Time, rebuild bool) (int, error) {
+	settings := models.UserSettings(db, userID)
+	local := now.In(settings.Location())
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, local.Location())
+
+	from := today.AddDate(0, 0, 1-netWorthBackfillDays)
+	if !rebuild {
+		var latest models.NetWorthSnapshot
+		err := db.Where("user_id = ?", userID).Order("date DESC").Limit(1).Find(&latest).Error
+		if err != nil {
+			return 0, err
+		}
+		if latest.ID != uuid.Nil {
+			// Snapshot dates come back as midnight UTC
+			from = time.Date(latest.Date.Year(), latest.Date.Month(), latest.Date.Day(), 0, 0, 0, 0, today.Location())
+			if from.After(today) {
+				from = today
+			}
+		}
+	}
+
+	rebuildFrom := from.Format("2006-01-02")
+
+	book, err := loadNetWorthBook(db, userID, from)
+	if err != nil {
+		return 0, err
+	}
+	earliest := book.earliest()
+	if earliest.IsZero() {
+		return 0, nil
+	}
+	earliest = earliest.In(today.Location())
+	if first := time.Date(earliest.Year(), earliest.Month(), earliest.Day(), 0, 0, 0, 0, today.Location()); first.After(from) {
+		from = first
+	}
+	if from.After(today) {
+		return 0, nil
+	}
+
+	var days []time.Time
+	for day := from; !day.After(today); day = day.AddDate(0, 0, 1) {
+		days = append(days, day)
+	}
+	series, err := book.series(days)
+	if err != nil {
+		return 0, err
+	}
+
+	snapshots := make([]models.NetWorthSnapshot, len(series))
+	for i, netWorth := range series {
+		snapshots[i] = models.NetWorthSnapshot{
+			UserID: userID,
+			// Stored as a date, so keep the day's calendar date
+			Date:        time.Date(netWorth.Date.Year(), netWorth.Date.Month(), netWorth.Date.Day(), 0, 0, 0, 0, time.UTC),
+			Currency:    netWorth.Currency,
+			Assets:      netWorth.Assets,
+			Liabilities: netWorth.Liabilities,
+			NetWorth:    netWorth.NetWorth,
+			Breakdown:   netWorth.Breakdown,
+		}
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Days rebuilt before the first ledger now counts no longer have one
+		if rebuild {
+			if err := tx.Where("user_id = ? AND date >= ?", userID, rebuildFrom).Delete(&models.NetWorthSnapshot{}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "date"}},
+			DoUpdates: clause.AssignmentColumns([]string{"currency", "assets", "liabilities", "net_worth", "breakdown", "updated_at"}),
+		}).CreateInBatches(&snapshots, 100).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(snapshots), nil
+}
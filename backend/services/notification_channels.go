@@ -0,0 +1,199 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"syscall"
+	"time"
+
+	"daybook-backend/config"
+	"daybook-backend/models"
+)
+
+// ErrChannelNotConfigured signals that a channel cannot deliver to the user,
+// such as email without an SMTP server or a webhook without a URL
+var ErrChannelNotConfigured = errors.New("channel not configured")
+
+// Webhook delivery errors. They are shown to the user in deliveries, so they
+// say nothing about what answered at the address.
+var (
+	ErrWebhookAddressNotAllowed = errors.New("webhook address is not allowed")
+	ErrWebhookFailed            = errors.New("webhook delivery failed")
+)
+
+// NotificationChannel delivers notifications outside the in-app inbox
+type NotificationChannel interface {
+	Name() string
+	// Wants reports whether the user's settings turn the channel on
+	Wants(prefs *models.Notifications) bool
+	Send(user *models.User, prefs *models.Notifications, notification *models.Notification) error
+}
+
+// NotificationChannels lists the channels every notification is offered to,
+// in order. ConfigureNotifications sets them up from the configuration.
+var NotificationChannels = []NotificationChannel{}
+
+// WebhookAllowPrivate lets webhooks reach loopback, private and link-local
+// addresses, for delivery to local stand-ins. It is off unless configured.
+var WebhookAllowPrivate = false
+
+// ConfigureNotifications sets up the email and webhook channels
+func ConfigureNotifications(cfg config.NotifyConfig) {
+	timeout := time.Duration(cfg.WebhookTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	WebhookAllowPrivate = cfg.WebhookAllowPrivate
+
+	NotificationChannels = []NotificationChannel{
+		&EmailChannel{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.SMTPFrom,
+		},
+		&WebhookChannel{Client: NewWebhookClient(timeout, cfg.WebhookAllowPrivate)},
+	}
+}
+
+// EmailChannel sends notifications to the user's email address over SMTP
+type EmailChannel struct {
+	Host     string
+	Port     string
+	Username string // Authenticates with PLAIN auth when set
+	Password string
+	From     string
+}
+
+func (ch *EmailChannel) Name() string { return "email" }
+
+func (ch *EmailChannel) Wants(prefs *models.Notifications) bool { return prefs.Email }
+
+func (ch *EmailChannel) Send(user *models.User, prefs *models.Notifications, notification *models.Notification) error {
+	if ch.Host == "" || user.Email == "" {
+		return ErrChannelNotConfigured
+	}
+
+	var auth smtp.Auth
+	if ch.Username != "" {
+		auth = smtp.PlainAuth("", ch.Username, ch.Password, ch.Host)
+	}
+
+	var message strings.Builder
+	fmt.Fprintf(&message, "From: %s\r\n", ch.From)
+	fmt.Fprintf(&message, "To: %s\r\n", user.Email)
+	fmt.Fprintf(&message, "Subject: %s\r\n", headerSafe(notification.Title))
+	fmt.Fprintf(&message, "Date: %s\r\n", notification.CreatedAt.Format(time.RFC1123Z))
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	message.WriteString(notification.Message)
+	message.WriteString("\r\n")
+
+	return smtp.SendMail(net.JoinHostPort(ch.Host, ch.Port), auth, ch.From, []string{user.Email}, []byte(message.String()))
+}
+
+// headerSafe keeps a value on a single header line
+func headerSafe(value string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+}
+
+// WebhookChannel posts notifications as JSON to the user's webhook URL
+type WebhookChannel struct {
+	Client *http.Client
+}
+
+// webhookPayload is the JSON body posted to a webhook
+type webhookPayload struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	Title     string    `json:"title"`
+	Message   string    `json:"message"`
+	SourceID  *string   `json:"sourceId"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// NewWebhookClient returns a client that only connects to public
+// addresses, so that webhooks cannot reach the server's own network or
+// cloud metadata. The address is checked after DNS resolution, and
+// redirects are not followed since they could lead anywhere. With
+// allowPrivate every address may be reached.
+func NewWebhookClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || (!allowPrivate && !IsPublicAddress(ip)) {
+				return ErrWebhookAddressNotAllowed
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: timeout,
+		// No proxy: it would make the connection the dialer checks
+		Transport: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: timeout},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// IsPublicAddress reports whether ip may receive webhooks: it is not a
+// loopback, private, link-local, multicast or unspecified address
+func IsPublicAddress(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast() && !ip.IsUnspecified()
+}
+
+func (ch *WebhookChannel) Name() string { return "webhook" }
+
+func (ch *WebhookChannel) Wants(prefs *models.Notifications) bool { return prefs.Push }
+
+func (ch *WebhookChannel) Send(user *models.User, prefs *models.Notifications, notification *models.Notification) error {
+	if prefs.WebhookURL == "" {
+		return ErrChannelNotConfigured
+	}
+
+	payload := webhookPayload{
+		ID:        notification.ID.String(),
+		Type:      notification.Type,
+		Title:     notification.Title,
+		Message:   notification.Message,
+		CreatedAt: notification.CreatedAt,
+	}
+	if notification.SourceID != nil {
+		sourceID := notification.SourceID.String()
+		payload.SourceID = &sourceID
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	response, err := ch.Client.Post(prefs.WebhookURL, "application/json", bytes.NewReader(body))
+	if errors.Is(err, ErrWebhookAddressNotAllowed) {
+		return ErrWebhookAddressNotAllowed
+	}
+	if err != nil {
+		log.Printf("Webhook delivery of notification %s failed: %v", notification.ID, err)
+		return ErrWebhookFailed
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		log.Printf("Webhook delivery of notification %s failed: status %d", notification.ID, response.StatusCode)
+		return ErrWebhookFailed
+	}
+	return nil
+}
@@ -0,0 +1,244 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"daybook-backend/models"
+
+	"github.com/google/uuid"
+)
+
+func testNotification() *models.Notification {
+	sourceID := uuid.New()
+	return &models.Notification{
+		ID:        uuid.New(),
+		Type:      models.NotificationBillReminder,
+		SourceID:  &sourceID,
+		Title:     "Bill due soon: Rent",
+		Message:   "Rent for 800.00 is due today.",
+		CreatedAt: time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestWebhookChannelSend(t *testing.T) {
+	notification := testNotification()
+
+	var received webhookPayload
+	var contentType string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("decoding webhook body: %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	channel := &WebhookChannel{Client: NewWebhookClient(5*time.Second, true)}
+	if err := channel.Send(&models.User{}, &models.Notifications{WebhookURL: server.URL}, notification); err != nil {
+		t.Fatalf("Send error: %v", err)
+	}
+	if contentType != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", contentType)
+	}
+	if received.ID != notification.ID.String() || received.Type != notification.Type || received.Title != notification.Title ||
+		received.Message != notification.Message || received.SourceID == nil || *received.SourceID != notification.SourceID.String() ||
+		!received.CreatedAt.Equal(notification.CreatedAt) {
+		t.Errorf("webhook received %+v, want notification %+v", received, notification)
+	}
+}
+
+func TestWebhookChannelSendFailures(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "database password is hunter2", http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	redirecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+	}))
+	defer redirecting.Close()
+
+	tests := []struct {
+		name         string
+		url          string
+		allowPrivate bool
+		want         error
+	}{
+		{"no URL", "", true, ErrChannelNotConfigured},
+		{"error status", failing.URL, true, ErrWebhookFailed},
+		{"redirect", redirecting.URL, true, ErrWebhookFailed},
+		{"private address refused", failing.URL, false, ErrWebhookAddressNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			channel := &WebhookChannel{Client: NewWebhookClient(5*time.Second, tt.allowPrivate)}
+			err := channel.Send(&models.User{}, &models.Notifications{WebhookURL: tt.url}, testNotification())
+			if !errors.Is(err, tt.want) || err.Error() != tt.want.Error() {
+				t.Errorf("Send error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsPublicAddress(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"224.0.0.1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+	}
+	for _, tt := range tests {
+		if got := IsPublicAddress(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("IsPublicAddress(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+// fakeSMTPServer accepts one SMTP session on a local port and returns the
+// envelope and message it was given
+func fakeSMTPServer(t *testing.T) (string, <-chan smtpMessage) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	messages := make(chan smtpMessage, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+		var message smtpMessage
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+		reply("220 localhost ESMTP")
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			command := strings.TrimRight(line, "\r\n")
+			switch upper := strings.ToUpper(command); {
+			case strings.HasPrefix(upper, "EHLO"), strings.HasPrefix(upper, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(upper, "MAIL FROM:"):
+				message.From = command[len("MAIL FROM:"):]
+				reply("250 OK")
+			case strings.HasPrefix(upper, "RCPT TO:"):
+				message.To = append(message.To, command[len("RCPT TO:"):])
+				reply("250 OK")
+			case upper == "DATA":
+				reply("354 End data with <CR><LF>.<CR><LF>")
+				var data strings.Builder
+				for {
+					dataLine, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if dataLine == ".\r\n" {
+						break
+					}
+					data.WriteString(dataLine)
+				}
+				message.Data = data.String()
+				reply("250 OK")
+			case upper == "QUIT":
+				reply("221 Bye")
+				messages <- message
+				return
+			default:
+				reply("502 Command not implemented")
+			}
+		}
+	}()
+	return listener.Addr().String(), messages
+}
+
+type smtpMessage struct {
+	From string
+	To   []string
+	Data string
+}
+
+func TestEmailChannelSend(t *testing.T) {
+	address, messages := fakeSMTPServer(t)
+	host, port, _ := net.SplitHostPort(address)
+
+	notification := testNotification()
+	notification.Title = "Bill due soon: Rent\r\nBcc: victim@example.com"
+	channel := &EmailChannel{Host: host, Port: port, From: "daybook@localhost"}
+	if err := channel.Send(&models.User{Email: "user@example.com"}, &models.Notifications{Email: true}, notification); err != nil {
+		t.Fatalf("Send error: %v", err)
+	}
+
+	var message smtpMessage
+	select {
+	case message = <-messages:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the SMTP server received no message")
+	}
+	if message.From != "<daybook@localhost>" || len(message.To) != 1 || message.To[0] != "<user@example.com>" {
+		t.Errorf("envelope from %s to %v, want daybook@localhost to user@example.com", message.From, message.To)
+	}
+	headers, body, _ := strings.Cut(message.Data, "\r\n\r\n")
+	for _, header := range []string{
+		"From: daybook@localhost",
+		"To: user@example.com",
+		"Subject: Bill due soon: Rent  Bcc: victim@example.com", // Kept on one line
+		"Date: Fri, 01 Mar 2024 09:00:00 +0000",
+	} {
+		if !strings.Contains(headers, header+"\r\n") {
+			t.Errorf("headers %q lack %q", headers, header)
+		}
+	}
+	if strings.Contains(headers, "\r\nBcc:") {
+		t.Errorf("the title added a header: %q", headers)
+	}
+	if body != notification.Message+"\r\n" {
+		t.Errorf("body = %q, want %q", body, notification.Message+"\r\n")
+	}
+}
+
+func TestEmailChannelNotConfigured(t *testing.T) {
+	tests := []struct {
+		name    string
+		channel *EmailChannel
+		user    *models.User
+	}{
+		{"no SMTP host", &EmailChannel{Port: "25"}, &models.User{Email: "user@example.com"}},
+		{"no email address", &EmailChannel{Host: "127.0.0.1", Port: "25"}, &models.User{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.channel.Send(tt.user, &models.Notifications{Email: true}, testNotification()); !errors.Is(err, ErrChannelNotConfigured) {
+				t.Errorf("Send error = %v, want %v", err, ErrChannelNotConfigured)
+			}
+		})
+	}
+}
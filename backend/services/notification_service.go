@@ -0,0 +1,355 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"daybook-backend/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// NotificationRecipient is a user together with the notification settings
+// that apply to them
+type NotificationRecipient struct {
+	User  models.User
+	Prefs *models.Notifications
+}
+
+// LoadNotificationRecipient loads the user and their notification settings,
+// falling back to the defaults for users who have not saved any
+func LoadNotificationRecipient(db *gorm.DB, userID uuid.UUID) (*NotificationRecipient, error) {
+	var user models.User
+	if err := db.First(&user, "id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+	recipient := &NotificationRecipient{User: user, Prefs: models.DefaultNotificationSettings()}
+
+	var settings models.Settings
+	if err := db.Where("user_id = ?", userID).Limit(1).Find(&settings).Error; err != nil {
+		return nil, err
+	}
+	if settings.ID != uuid.Nil && settings.Notifications != nil {
+		recipient.Prefs = settings.Notifications
+	}
+	return recipient, nil
+}
+
+// Notify stores the notification in the recipient's inbox and delivers it
+// through every channel their settings turn on. A notification whose Key the
+// user has already been sent is dropped, so each condition is notified only
+// once. It returns the deliveries made, or nil when the notification was
+// dropped; a failed delivery is recorded rather than returned.
+func Notify(db *gorm.DB, recipient *NotificationRecipient, notification *models.Notification) ([]models.NotificationDelivery, error) {
+	notification.UserID = recipient.User.ID
+	result := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "key"}},
+		DoNothing: true,
+	}).Create(notification)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	deliveries := []models.NotificationDelivery{}
+	for _, channel := range NotificationChannels {
+		if !channel.Wants(recipient.Prefs) {
+			continue
+		}
+
+		delivery := models.NotificationDelivery{
+			NotificationID: notification.ID,
+			Channel:        channel.Name(),
+			Status:         models.DeliverySent,
+		}
+		err := channel.Send(&recipient.User, recipient.Prefs, notification)
+		if errors.Is(err, ErrChannelNotConfigured) {
+			delivery.Status = models.DeliverySkipped
+		} else if err != nil {
+			log.Printf("Failed to deliver notification %s by %s: %v", notification.ID, channel.Name(), err)
+			delivery.Status = models.DeliveryFailed
+			delivery.Error = err.Error()
+		}
+
+		if err := db.Create(&delivery).Error; err != nil {
+			return deliveries, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, nil
+}
+
+// ProcessNotifications checks every user's budgets, bills, accounts and
+// holdings and notifies them of what needs their attention
+func ProcessNotifications(db *gorm.DB, now time.Time) error {
+	var users []models.User
+	if err := db.Find(&users).Error; err != nil {
+		return err
+	}
+
+	for i := range users {
+		if err := CheckNotifications(db, users[i].ID, now); err != nil {
+			log.Printf("Failed to check notifications of user %s: %v", users[i].ID, err)
+		}
+	}
+
+	return nil
+}
+
+// CheckNotifications notifies the user of each condition their settings ask
+// about: budgets reaching their alert threshold, bills coming due, overdue or
+// skipped by autopay, accounts below their minimum balance and holdings about
+// to mature
+func CheckNotifications(db *gorm.DB, userID uuid.UUID, now time.Time) error {
+	recipient, err := LoadNotificationRecipient(db, userID)
+	if err != nil {
+		return err
+	}
+
+	checks := []struct {
+		enabled bool
+		check   func(*gorm.DB, *NotificationRecipient, time.Time) error
+	}{
+		{recipient.Prefs.BudgetAlerts, checkBudgetAlerts},
+		{recipient.Prefs.BillReminders, checkBillReminders},
+		{recipient.Prefs.LowBalanceAlerts, checkLowBalances},
+		{recipient.Prefs.MaturityAlerts, checkMaturities},
+	}
+	for _, c := range checks {
+		if !c.enabled {
+			continue
+		}
+		if err := c.check(db, recipient, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkBudgetAlerts notifies once per budget period when spending reaches
+// the budget's alert threshold and again when it exceeds the budget
+func checkBudgetAlerts(db *gorm.DB, recipient *NotificationRecipient, now time.Time) error {
+	var budgets []models.Budget
+	if err := db.Where("user_id = ? AND enabled = ?", recipient.User.ID, true).Find(&budgets).Error; err != nil {
+		return err
+	}
+
+	for i := range budgets {
+		budget := &budgets[i]
+		progress, err := CalculateBudgetProgress(db, budget, now)
+		if errors.Is(err, ErrCustomBudgetDates) || errors.Is(err, ErrInvalidBudgetPeriod) || errors.Is(err, models.ErrExchangeRateNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if now.Before(progress.StartDate) || !now.Before(progress.EndDate) || progress.TotalSpent <= 0 || !progress.AlertTriggered {
+			continue
+		}
+
+		name := budget.CategoryID
+		if category, err := models.FindCategory(db, recipient.User.ID, budget.CategoryID); err == nil {
+			name = category.Name
+		}
+		key := fmt.Sprintf("budget:%s:%s", budget.ID, progress.StartDate.Format("2006-01-02"))
+
+		notification := models.Notification{
+			Type:     models.NotificationBudgetAlert,
+			SourceID: &budget.ID,
+			Title:    fmt.Sprintf("%s budget %.0f%% used", name, progress.PercentageUsed),
+			Message: fmt.Sprintf("You have spent %s of your %s %s budget for %s.",
+				progress.TotalSpent.StringFixed(progress.Currency), progress.Available.StringFixed(progress.Currency), progress.Currency, name),
+			Key: key + ":threshold",
+		}
+		if progress.IsOverBudget {
+			notification.Title = fmt.Sprintf("%s budget exceeded", name)
+			notification.Message = fmt.Sprintf("You have spent %s of your %s %s budget for %s, %s over.",
+				progress.TotalSpent.StringFixed(progress.Currency), progress.Available.StringFixed(progress.Currency), progress.Currency, name,
+				(-progress.Remaining).StringFixed(progress.Currency))
+			notification.Key = key + ":exceeded"
+		}
+
+		if _, err := Notify(db, recipient, &notification); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkBillReminders notifies of each bill due date once it is within the
+// bill's reminder days, of the latest due date gone overdue and of due dates
+// autopay skipped
+func checkBillReminders(db *gorm.DB, recipient *NotificationRecipient, now time.Time) error {
+	var bills []models.Bill
+	if err := db.Where("user_id = ? AND active = ?", recipient.User.ID, true).Find(&bills).Error; err != nil {
+		return err
+	}
+
+	maxReminderDays := 0
+	for i := range bills {
+		if bills[i].ReminderDays > maxReminderDays {
+			maxReminderDays = bills[i].ReminderDays
+		}
+	}
+
+	today := UserBudgetCalendar(db, recipient.User.ID).Today(now)
+	schedule, err := BillSchedule(db, bills, today.AddDate(0, 0, maxReminderDays+1), now)
+	if err != nil {
+		return err
+	}
+
+	currency := models.UserCurrency(db, recipient.User.ID)
+	latestOverdue := make(map[uuid.UUID]BillOccurrence)
+	for _, occurrence := range schedule {
+		switch {
+		case occurrence.Status == models.BillOverdue:
+			latestOverdue[occurrence.BillID] = occurrence
+
+		case occurrence.Status == models.BillUnpaid && !occurrence.ReminderDate.After(today):
+			when := "on " + occurrence.DueDate.Format("2006-01-02")
+			if occurrence.DaysUntilDue == 0 {
+				when = "today"
+			}
+			message := fmt.Sprintf("%s for %s is due %s.", occurrence.Name, occurrence.Amount.StringFixed(currency), when)
+			if occurrence.AutoPay {
+				message += " It will be paid by autopay."
+			}
+			notification := models.Notification{
+				Type:     models.NotificationBillReminder,
+				SourceID: &occurrence.BillID,
+				Title:    "Bill due soon: " + occurrence.Name,
+				Message:  message,
+				Key:      fmt.Sprintf("bill:%s:%s:due", occurrence.BillID, occurrence.DueDate.Format("2006-01-02")),
+			}
+			if _, err := Notify(db, recipient, &notification); err != nil {
+				return err
+			}
+		}
+	}
+
+	for billID, occurrence := range latestOverdue {
+		notification := models.Notification{
+			Type:     models.NotificationBillReminder,
+			SourceID: &billID,
+			Title:    "Bill overdue: " + occurrence.Name,
+			Message: fmt.Sprintf("%s for %s was due on %s and has not been paid.",
+				occurrence.Name, occurrence.Amount.StringFixed(currency), occurrence.DueDate.Format("2006-01-02")),
+			Key: fmt.Sprintf("bill:%s:%s:overdue", billID, occurrence.DueDate.Format("2006-01-02")),
+		}
+		if _, err := Notify(db, recipient, &notification); err != nil {
+			return err
+		}
+	}
+
+	for i := range bills {
+		bill := &bills[i]
+		if bill.AutoPayFailedAt == nil || bill.AutoPayError == "" {
+			continue
+		}
+		notification := models.Notification{
+			Type:     models.NotificationBillReminder,
+			SourceID: &bill.ID,
+			Title:    "Autopay skipped: " + bill.Name,
+			Message:  fmt.Sprintf("Autopay could not pay %s: %s.", bill.Name, bill.AutoPayError),
+			Key:      fmt.Sprintf("bill:%s:autopay:%d", bill.ID, bill.AutoPayFailedAt.Unix()),
+		}
+		if _, err := Notify(db, recipient, &notification); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkLowBalances notifies, at most once a day in the user's time zone, of each active account
+// whose balance is below its minimum balance
+func checkLowBalances(db *gorm.DB, recipient *NotificationRecipient, now time.Time) error {
+	var accounts []models.Account
+	err := db.Where("user_id = ? AND active = ? AND minimum_balance > 0 AND balance < minimum_balance", recipient.User.ID, true).
+		Find(&accounts).Error
+	if err != nil {
+		return err
+	}
+
+	today := UserBudgetCalendar(db, recipient.User.ID).Today(now)
+	for i := range accounts {
+		account := &accounts[i]
+		notification := models.Notification{
+			Type:     models.NotificationLowBalance,
+			SourceID: &account.ID,
+			Title:    "Low balance: " + account.Name,
+			Message: fmt.Sprintf("%s has %s %s, below its minimum of %s %s.",
+				account.Name, account.Balance.StringFixed(account.Currency), account.Currency,
+				account.MinimumBalance.StringFixed(account.Currency), account.Currency),
+			Key: fmt.Sprintf("low_balance:%s:%s", account.ID, today.Format("2006-01-02")),
+		}
+		if _, err := Notify(db, recipient, &notification); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkMaturities notifies once of each active holding, such as a fixed
+// deposit, maturing within the user's maturity reminder days
+func checkMaturities(db *gorm.DB, recipient *NotificationRecipient, now time.Time) error {
+	days := recipient.Prefs.MaturityReminderDays
+	if days <= 0 {
+		days = models.DefaultMaturityReminderDays
+	}
+	today := UserBudgetCalendar(db, recipient.User.ID).Today(now)
+
+	var holdings []models.GoalHolding
+	err := db.Where("user_id = ? AND status = ? AND maturity_date >= ? AND maturity_date < ?",
+		recipient.User.ID, "active", today, today.AddDate(0, 0, days+1)).
+		Find(&holdings).Error
+	if err != nil {
+		return err
+	}
+
+	currency := models.UserCurrency(db, recipient.User.ID)
+	for i := range holdings {
+		holding := &holdings[i]
+		message := fmt.Sprintf("%s matures on %s.", holding.Name, holding.MaturityDate.Format("2006-01-02"))
+		if holding.MaturityAmount != nil {
+			message = fmt.Sprintf("%s matures on %s for %s.", holding.Name, holding.MaturityDate.Format("2006-01-02"), holding.MaturityAmount.StringFixed(currency))
+		}
+		notification := models.Notification{
+			Type:     models.NotificationMaturity,
+			SourceID: &holding.ID,
+			Title:    "Maturing soon: " + holding.Name,
+			Message:  message,
+			Key:      fmt.Sprintf("maturity:%s:%s", holding.ID, holding.MaturityDate.Format("2006-01-02")),
+		}
+		if _, err := Notify(db, recipient, &notification); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SendTestNotification sends the user a notification through every channel
+// their settings turn on, to check that delivery works
+func SendTestNotification(db *gorm.DB, userID uuid.UUID, now time.Time) (*models.Notification, []models.NotificationDelivery, error) {
+	recipient, err := LoadNotificationRecipient(db, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	notification := models.Notification{
+		Type:    models.NotificationTest,
+		Title:   "Test notification",
+		Message: "Notifications from Daybook reach you here.",
+		Key:     fmt.Sprintf("test:%d", now.UnixNano()),
+	}
+	deliveries, err := Notify(db, recipient, &notification)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &notification, deliveries, nil
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"time"
+
+	"daybook-backend/models"
+)
+
+// Payoff strategies compared by SimulatePayoff
+const (
+	PayoffMinimum   = "minimum"     // Pay the card's minimum payment each month
+	PayoffFixed     = "fixed"       // Pay the same amount each month
+	PayoffPayInFull = "pay_in_full" // Pay the whole balance at the next due date
+)
+
+// maxPayoffMonths bounds a simulation; a plan that has not paid off the card
+// by then is reported as never paying it off
+const maxPayoffMonths = 600
+
+// PayoffMonth is one month of a payoff plan
+type PayoffMonth struct {
+	Month    int          `json:"month"`
+	Date     time.Time    `json:"date"`
+	Payment  models.Money `json:"payment"`
+	Interest models.Money `json:"interest"`
+	Balance  models.Money `json:"balance"` // Owed after the payment
+}
+
+// PayoffPlan is the outcome of paying a card down with one strategy
+type PayoffPlan struct {
+	Strategy       string        `json:"strategy"`
+	MonthlyPayment *models.Money `json:"monthlyPayment,omitempty"` // Fixed strategy only
+	PaidOff        bool          `json:"paidOff"`                  // False when the payments never clear the balance
+	Months         int           `json:"months"`
+	PayoffDate     *time.Time    `json:"payoffDate"`
+	TotalPaid      models.Money  `json:"totalPaid"`
+	TotalInterest  models.Money  `json:"totalInterest"`
+	Schedule       []PayoffMonth `json:"schedule"`
+}
+
+// SimulatePayoff projects paying off balance on the card with no new
+// purchases. Interest accrues monthly at a twelfth of the card's APR and is
+// added before each payment; the minimum strategy uses the card's minimum
+// payment formula. Payments fall on firstPayment and then monthly. payment is
+// the monthly amount of the fixed strategy and ignored by the others.
+func SimulatePayoff(card *models.CreditCard, balance models.Money, strategy string, payment models.Money, firstPayment time.Time) PayoffPlan {
+	plan := PayoffPlan{Strategy: strategy, Schedule: []PayoffMonth{}}
+	if strategy == PayoffFixed {
+		plan.MonthlyPayment = &payment
+	}
+	if balance <= 0 {
+		plan.PaidOff = true
+		return plan
+	}
+
+	if strategy == PayoffPayInFull {
+		plan.PaidOff = true
+		plan.Months = 1
+		plan.PayoffDate = &firstPayment
+		plan.TotalPaid = balance
+		plan.Schedule = append(plan.Schedule, PayoffMonth{Month: 1, Date: firstPayment, Payment: balance})
+		return plan
+	}
+
+	monthlyRate := card.APR / 100 / 12
+	for month := 1; month <= maxPayoffMonths; month++ {
+		date, _ := models.AddFrequency(firstPayment, models.FrequencyMonthly, month-1)
+		interest := balance.Mul(monthlyRate).Round(card.Currency)
+		balance += interest
+
+		amount := payment
+		if strategy == PayoffMinimum {
+			amount = card.MinimumPaymentFor(balance, interest, 0)
+		}
+		if amount > balance {
+			amount = balance
+		}
+
+		balance -= amount
+		plan.Months = month
+		plan.TotalPaid += amount
+		plan.TotalInterest += interest
+		plan.Schedule = append(plan.Schedule, PayoffMonth{Month: month, Date: date, Payment: amount, Interest: interest, Balance: balance})
+		if balance <= 0 {
+			plan.PaidOff = true
+			plan.PayoffDate = &date
+			break
+		}
+
+		// A payment that does not cover the interest never clears the balance
+		if amount <= interest {
+			break
+		}
+	}
+
+	return plan
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"daybook-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// maxCatchUpOccurrences bounds how many occurrences a single recurring
+// transaction can materialize in one run, protecting against runaway loops
+// on schedules with a very old start date
+const maxCatchUpOccurrences = 1000
+
+// errOccurrenceProcessed signals that another worker already handled an occurrence
+var errOccurrenceProcessed = errors.New("occurrence already processed")
+
+// ProcessRecurringTransactions materializes every due occurrence of all enabled
+// recurring transactions, including periods missed while the server was down
+func ProcessRecurringTransactions(db *gorm.DB, now time.Time) error {
+	var recurring []models.RecurringTransaction
+	if err := db.Where("enabled = ? AND start_date <= ?", true, now).Find(&recurring).Error; err != nil {
+		return err
+	}
+
+	for i := range recurring {
+		created, err := ProcessRecurringTransaction(db, &recurring[i], now)
+		if err != nil {
+			log.Printf("Failed to process recurring transaction %s: %v", recurring[i].ID, err)
+		}
+		if created > 0 {
+			log.Printf("Recurring transaction %s: created %d transaction(s)", recurring[i].ID, created)
+		}
+	}
+
+	return nil
+}
+
+// ProcessRecurringTransaction creates a transaction for each occurrence of rt
+// that is due on or before now and advances LastProcessed. Every occurrence is
+// committed in its own database transaction together with its balance update,
+// so a failure part way through leaves earlier occurrences in place.
+func ProcessRecurringTransaction(db *gorm.DB, rt *models.RecurringTransaction, now time.Time) (int, error) {
+	created := 0
+
+	for created < maxCatchUpOccurrences {
+		occurrence, ok := rt.NextOccurrence()
+		if !ok || occurrence.After(now) {
+			break
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			// Claim the occurrence first so concurrent runs cannot create it twice
+			claim := tx.Model(&models.RecurringTransaction{}).
+				Where("id = ? AND (last_processed IS NULL OR last_processed < ?)", rt.ID, occurrence).
+				Update("last_processed", occurrence)
+			if claim.Error != nil {
+				return claim.Error
+			}
+			if claim.RowsAffected == 0 {
+				return errOccurrenceProcessed
+			}
+
+			transaction := rt.BuildTransaction(occurrence)
+			if err := resolveTransferCurrencies(tx, &transaction); err != nil {
+				return err
+			}
+			if err := tx.Create(&transaction).Error; err != nil {
+				return err
+			}
+
+			return transaction.ApplyBalance(tx)
+		})
+		if errors.Is(err, errOccurrenceProcessed) {
+			break
+		}
+		if err != nil {
+			return created, err
+		}
+
+		rt.LastProcessed = &occurrence
+		created++
+	}
+
+	return created, nil
+}
+
+// resolveTransferCurrencies converts a transfer between accounts in different
+// currencies using the exchange rate on the occurrence date, unless the
+// template fixes the rate or destination amount
+func resolveTransferCurrencies(tx *gorm.DB, transaction *models.Transaction) error {
+	if transaction.Type != "transfer" || transaction.ToAccountID == nil {
+		return nil
+	}
+
+	var from, to models.Account
+	if err := tx.Where("id = ?", transaction.AccountID).First(&from).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("id = ?", *transaction.ToAccountID).First(&to).Error; err != nil {
+		return err
+	}
+
+	return transaction.ResolveTransferAmounts(tx, from.Currency, to.Currency)
+}
@@ -0,0 +1,628 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"daybook-backend/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Report intervals
+const (
+	ReportIntervalMonth   = "month"
+	ReportIntervalQuarter = "quarter"
+	ReportIntervalYear    = "year"
+)
+
+// IsValidReportInterval reports whether interval is a supported report interval
+func IsValidReportInterval(interval string) bool {
+	return interval == ReportIntervalMonth || interval == ReportIntervalQuarter || interval == ReportIntervalYear
+}
+
+// ReportPeriod is one period of a report, from Start up to but not
+// including End. Only the first and last periods can be partial.
+type ReportPeriod struct {
+	Label string    `json:"label"` // 2025-03, 2025-Q1 or 2025
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// ReportPeriods divides from up to to into calendar months, quarters or
+// years in from's time zone
+func ReportPeriods(from, to time.Time, interval string) []ReportPeriod {
+	var periods []ReportPeriod
+	for start := from; start.Before(to); {
+		intervalStart := startOfInterval(start, interval)
+		end := nextInterval(intervalStart, interval)
+		if end.After(to) {
+			end = to
+		}
+		periods = append(periods, ReportPeriod{Label: intervalLabel(intervalStart, interval), Start: start, End: end})
+		start = end
+	}
+	return periods
+}
+
+// StartOfReportMonth returns midnight on the first day of date's month
+func StartOfReportMonth(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, date.Location())
+}
+
+func startOfInterval(date time.Time, interval string) time.Time {
+	switch interval {
+	case ReportIntervalQuarter:
+		return time.Date(date.Year(), (date.Month()-1)/3*3+1, 1, 0, 0, 0, 0, date.Location())
+	case ReportIntervalYear:
+		return time.Date(date.Year(), time.January, 1, 0, 0, 0, 0, date.Location())
+	default:
+		return StartOfReportMonth(date)
+	}
+}
+
+func nextInterval(start time.Time, interval string) time.Time {
+	switch interval {
+	case ReportIntervalQuarter:
+		return start.AddDate(0, 3, 0)
+	case ReportIntervalYear:
+		return start.AddDate(1, 0, 0)
+	default:
+		return start.AddDate(0, 1, 0)
+	}
+}
+
+func intervalLabel(start time.Time, interval string) string {
+	switch interval {
+	case ReportIntervalQuarter:
+		return fmt.Sprintf("%d-Q%d", start.Year(), (start.Month()-1)/3+1)
+	case ReportIntervalYear:
+		return fmt.Sprintf("%d", start.Year())
+	default:
+		return start.Format("2006-01")
+	}
+}
+
+// periodIndex returns the index of the period containing day, or -1
+func periodIndex(periods []ReportPeriod, day time.Time) int {
+	if len(periods) == 0 || day.Before(periods[0].Start) {
+		return -1
+	}
+	i := sort.Search(len(periods), func(i int) bool { return day.Before(periods[i].End) })
+	if i == len(periods) {
+		return -1
+	}
+	return i
+}
+
+// loadReportTotals totals the user's income and expenses from from up to to
+// by category and day in the user's currency, leaving out tracking entries
+// and the models.NonSpendingCategories
+func loadReportTotals(db *gorm.DB, userID uuid.UUID, from, to time.Time) ([]categoryTotal, string, error) {
+	cal := UserBudgetCalendar(db, userID)
+	totals, currency, err := loadCategoryTotals(db, userID, []string{"income", "expense"}, nil, from, to, cal)
+	if err != nil {
+		return nil, "", err
+	}
+
+	kept := totals[:0]
+	for _, total := range totals {
+		if !models.IsNonSpendingCategory(total.CategoryID) {
+			kept = append(kept, total)
+		}
+	}
+	return kept, currency, nil
+}
+
+// savingsRate is the percentage of income not spent, or nil without income
+func savingsRate(income, expense models.Money) *float64 {
+	if income <= 0 {
+		return nil
+	}
+	rate := (income - expense).Ratio(income) * 100
+	return &rate
+}
+
+// percentChange is the change from previous to current as a percentage of
+// previous, or nil when previous is zero
+func percentChange(current, previous models.Money) *float64 {
+	if previous == 0 {
+		return nil
+	}
+	change := (current - previous).Ratio(previous.Abs()) * 100
+	return &change
+}
+
+// CashFlowPeriod is the income and expenses of one report period
+type CashFlowPeriod struct {
+	ReportPeriod
+	Income      models.Money `json:"income"`
+	Expense     models.Money `json:"expense"`
+	Net         models.Money `json:"net"`
+	SavingsRate *float64     `json:"savingsRate"` // Percentage of income not spent; null without income
+}
+
+// CashFlowReport is income against expenses over time
+type CashFlowReport struct {
+	Currency          string           `json:"currency"`
+	Interval          string           `json:"interval"`
+	StartDate         time.Time        `json:"startDate"`
+	EndDate           time.Time        `json:"endDate"`
+	Periods           []CashFlowPeriod `json:"periods"`
+	TotalIncome       models.Money     `json:"totalIncome"`
+	TotalExpense      models.Money     `json:"totalExpense"`
+	Net               models.Money     `json:"net"`
+	SavingsRate       *float64         `json:"savingsRate"`
+	Days              int              `json:"days"`              // Days of the range up to today
+	AverageDailySpend models.Money     `json:"averageDailySpend"` // TotalExpense over Days
+}
+
+// CalculateCashFlow totals the user's income and expenses in each period
+// from from up to to. Days after today do not count towards the average
+// daily spend.
+func CalculateCashFlow(db *gorm.DB, userID uuid.UUID, from, to time.Time, interval string) (*CashFlowReport, error) {
+	totals, currency, err := loadReportTotals(db, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	periods := ReportPeriods(from, to, interval)
+	report := &CashFlowReport{
+		Currency:  currency,
+		Interval:  interval,
+		StartDate: from,
+		EndDate:   to,
+		Periods:   make([]CashFlowPeriod, len(periods)),
+	}
+	for i, period := range periods {
+		report.Periods[i].ReportPeriod = period
+	}
+
+	for _, total := range totals {
+		i := periodIndex(periods, total.Day)
+		if i < 0 {
+			continue
+		}
+		if total.Type == "income" {
+			report.Periods[i].Income += total.Amount
+			report.TotalIncome += total.Amount
+		} else {
+			report.Periods[i].Expense += total.Amount
+			report.TotalExpense += total.Amount
+		}
+	}
+
+	for i := range report.Periods {
+		period := &report.Periods[i]
+		period.Net = period.Income - period.Expense
+		period.SavingsRate = savingsRate(period.Income, period.Expense)
+	}
+	report.Net = report.TotalIncome - report.TotalExpense
+	report.SavingsRate = savingsRate(report.TotalIncome, report.TotalExpense)
+
+	today := time.Now().In(from.Location())
+	elapsedEnd := time.Date(today.Year(), today.Month(), today.Day()+1, 0, 0, 0, 0, today.Location())
+	if to.Before(elapsedEnd) {
+		elapsedEnd = to
+	}
+	report.Days = max(calendarDaysBetween(from, elapsedEnd), 1)
+	report.AverageDailySpend = report.TotalExpense.Div(int64(report.Days))
+
+	return report, nil
+}
+
+// reportCategories looks up the user's categories by lowercased key and
+// finds the top-level ancestor of each
+type reportCategories struct {
+	byKey map[string]*models.Category
+	byID  map[uuid.UUID]*models.Category
+}
+
+func loadReportCategories(db *gorm.DB, userID uuid.UUID) (*reportCategories, error) {
+	var categories []models.Category
+	if err := db.Where("user_id = ?", userID).Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	rc := &reportCategories{
+		byKey: make(map[string]*models.Category, len(categories)),
+		byID:  make(map[uuid.UUID]*models.Category, len(categories)),
+	}
+	for i := range categories {
+		rc.byKey[strings.ToLower(categories[i].Key)] = &categories[i]
+		rc.byID[categories[i].ID] = &categories[i]
+	}
+	return rc, nil
+}
+
+// name returns the category's name, or its key once it has been deleted
+func (rc *reportCategories) name(key string) string {
+	if category := rc.byKey[key]; category != nil {
+		return category.Name
+	}
+	return key
+}
+
+// parent returns the lowercased key of the category's parent, or ""
+func (rc *reportCategories) parent(key string) string {
+	category := rc.byKey[key]
+	if category == nil || category.ParentID == nil || rc.byID[*category.ParentID] == nil {
+		return ""
+	}
+	return strings.ToLower(rc.byID[*category.ParentID].Key)
+}
+
+// ancestors returns the key and the keys of every ancestor of the category,
+// nearest first
+func (rc *reportCategories) ancestors(key string) []string {
+	keys := []string{key}
+	seen := map[string]bool{key: true}
+	for parent := rc.parent(key); parent != "" && !seen[parent]; parent = rc.parent(parent) {
+		keys = append(keys, parent)
+		seen[parent] = true
+	}
+	return keys
+}
+
+// StatementLine is the income or expense in a category, including its
+// subcategories, which are listed under it
+type StatementLine struct {
+	CategoryID string          `json:"categoryId"`
+	Name       string          `json:"name"`
+	Amount     models.Money    `json:"amount"`
+	Share      float64         `json:"share"` // Percentage of the total income or expense
+	Children   []StatementLine `json:"children,omitempty"`
+}
+
+// IncomeStatement is the user's income and expenses over a range by
+// category, with subcategories rolled up into their parents
+type IncomeStatement struct {
+	Currency     string          `json:"currency"`
+	StartDate    time.Time       `json:"startDate"`
+	EndDate      time.Time       `json:"endDate"`
+	Income       []StatementLine `json:"income"`
+	Expense      []StatementLine `json:"expense"`
+	TotalIncome  models.Money    `json:"totalIncome"`
+	TotalExpense models.Money    `json:"totalExpense"`
+	Net          models.Money    `json:"net"`
+	SavingsRate  *float64        `json:"savingsRate"`
+}
+
+// CalculateIncomeStatement totals the user's income and expenses from from
+// up to to by category
+func CalculateIncomeStatement(db *gorm.DB, userID uuid.UUID, from, to time.Time) (*IncomeStatement, error) {
+	totals, currency, err := loadReportTotals(db, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	categories, err := loadReportCategories(db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each category's amount includes its descendants'
+	amounts := map[string]map[string]models.Money{"income": {}, "expense": {}}
+	for _, total := range totals {
+		for _, key := range categories.ancestors(total.CategoryID) {
+			amounts[total.Type][key] += total.Amount
+		}
+	}
+
+	statement := &IncomeStatement{Currency: currency, StartDate: from, EndDate: to}
+	statement.Income, statement.TotalIncome = statementLines(categories, amounts["income"], "")
+	statement.Expense, statement.TotalExpense = statementLines(categories, amounts["expense"], "")
+	setStatementShares(statement.Income, statement.TotalIncome)
+	setStatementShares(statement.Expense, statement.TotalExpense)
+	statement.Net = statement.TotalIncome - statement.TotalExpense
+	statement.SavingsRate = savingsRate(statement.TotalIncome, statement.TotalExpense)
+
+	return statement, nil
+}
+
+// statementLines builds the lines of the categories under parent, largest
+// first, and returns them with their total
+func statementLines(categories *reportCategories, amounts map[string]models.Money, parent string) ([]StatementLine, models.Money) {
+	lines := []StatementLine{}
+	var total models.Money
+	for key, amount := range amounts {
+		if categories.parent(key) != parent || amount == 0 {
+			continue
+		}
+		line := StatementLine{CategoryID: key, Name: categories.name(key), Amount: amount}
+		if children, _ := statementLines(categories, amounts, key); len(children) > 0 {
+			line.Children = children
+		}
+		lines = append(lines, line)
+		total += amount
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		if lines[i].Amount != lines[j].Amount {
+			return lines[i].Amount > lines[j].Amount
+		}
+		return lines[i].CategoryID < lines[j].CategoryID
+	})
+	return lines, total
+}
+
+func setStatementShares(lines []StatementLine, total models.Money) {
+	for i := range lines {
+		if total > 0 {
+			lines[i].Share = lines[i].Amount.Ratio(total) * 100
+		}
+		setStatementShares(lines[i].Children, total)
+	}
+}
+
+// CategoryTrend is the income or expense in one category in each period
+type CategoryTrend struct {
+	CategoryID string         `json:"categoryId"`
+	Name       string         `json:"name"`
+	ParentID   string         `json:"parentId,omitempty"` // Key of the parent category when not rolled up
+	Amounts    []models.Money `json:"amounts"`            // One per period
+	Total      models.Money   `json:"total"`
+	Average    models.Money   `json:"average"` // Per period
+}
+
+// CategoryTrendsReport is income or expense by category over time
+type CategoryTrendsReport struct {
+	Currency   string          `json:"currency"`
+	Interval   string          `json:"interval"`
+	Type       string          `json:"type"`
+	Rollup     bool            `json:"rollup"`
+	Periods    []ReportPeriod  `json:"periods"`
+	Categories []CategoryTrend `json:"categories"` // Largest total first
+	Totals     []models.Money  `json:"totals"`     // All categories, one per period
+}
+
+// CalculateCategoryTrends totals the user's transactions of the type in
+// each category and period from from up to to. With rollup every amount
+// counts towards its top-level category; otherwise each category keeps its
+// own amounts and names its parent.
+func CalculateCategoryTrends(db *gorm.DB, userID uuid.UUID, from, to time.Time, interval, transactionType string, rollup bool) (*CategoryTrendsReport, error) {
+	totals, currency, err := loadReportTotals(db, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	categories, err := loadReportCategories(db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	periods := ReportPeriods(from, to, interval)
+	report := &CategoryTrendsReport{
+		Currency:   currency,
+		Interval:   interval,
+		Type:       transactionType,
+		Rollup:     rollup,
+		Periods:    periods,
+		Categories: []CategoryTrend{},
+		Totals:     make([]models.Money, len(periods)),
+	}
+
+	index := make(map[string]int)
+	for _, total := range totals {
+		i := periodIndex(periods, total.Day)
+		if total.Type != transactionType || i < 0 {
+			continue
+		}
+
+		key := total.CategoryID
+		if rollup {
+			ancestors := categories.ancestors(key)
+			key = ancestors[len(ancestors)-1]
+		}
+		c, ok := index[key]
+		if !ok {
+			c = len(report.Categories)
+			index[key] = c
+			trend := CategoryTrend{CategoryID: key, Name: categories.name(key), Amounts: make([]models.Money, len(periods))}
+			if !rollup {
+				trend.ParentID = categories.parent(key)
+			}
+			report.Categories = append(report.Categories, trend)
+		}
+		report.Categories[c].Amounts[i] += total.Amount
+		report.Categories[c].Total += total.Amount
+		report.Totals[i] += total.Amount
+	}
+
+	for i := range report.Categories {
+		if len(periods) > 0 {
+			report.Categories[i].Average = report.Categories[i].Total.Div(int64(len(periods)))
+		}
+	}
+	sort.Slice(report.Categories, func(i, j int) bool {
+		if report.Categories[i].Total != report.Categories[j].Total {
+			return report.Categories[i].Total > report.Categories[j].Total
+		}
+		return report.Categories[i].CategoryID < report.Categories[j].CategoryID
+	})
+
+	return report, nil
+}
+
+// PayeeTotal is what was paid to or received from one merchant or payee
+type PayeeTotal struct {
+	Payee     string       `json:"payee"`
+	Count     int          `json:"count"`
+	Total     models.Money `json:"total"`
+	Average   models.Money `json:"average"`
+	FirstDate time.Time    `json:"firstDate"`
+	LastDate  time.Time    `json:"lastDate"`
+}
+
+// TopPayeesReport ranks merchants and payees by amount
+type TopPayeesReport struct {
+	Currency  string       `json:"currency"`
+	Type      string       `json:"type"`
+	StartDate time.Time    `json:"startDate"`
+	EndDate   time.Time    `json:"endDate"`
+	Payees    []PayeeTotal `json:"payees"`
+}
+
+// CalculateTopPayees totals the user's transactions of the type from from
+// up to to by payee: the card merchant for card purchases and the
+// description otherwise. Payees differing only in case or spacing are the
+// same. It returns the limit largest.
+func CalculateTopPayees(db *gorm.DB, userID uuid.UUID, from, to time.Time, transactionType string, limit int) (*TopPayeesReport, error) {
+	cal := UserBudgetCalendar(db, userID)
+
+	const payee = "REGEXP_REPLACE(TRIM(COALESCE(NULLIF(TRIM(credit_card_transactions.merchant), ''), transactions.description, '')), '\\s+', ' ', 'g')"
+	var rows []struct {
+		PayeeKey string
+		Payee    string
+		Day      time.Time
+		Currency string
+		Count    int
+		Amount   models.Money
+	}
+	err := db.Model(&models.Transaction{}).
+		Select("LOWER("+payee+") AS payee_key, MAX("+payee+") AS payee, DATE(transactions.date AT TIME ZONE ?) AS day, COALESCE(credit_cards.currency, accounts.currency, '') AS currency, COUNT(*) AS count, SUM(transactions.amount) AS amount", cal.Location.String()).
+		Joins("LEFT JOIN credit_card_transactions ON credit_card_transactions.transaction_id = transactions.id AND credit_card_transactions.deleted_at IS NULL").
+		Joins("LEFT JOIN accounts ON accounts.id = transactions.account_id").
+		Joins("LEFT JOIN credit_cards ON credit_cards.id = transactions.credit_card_id").
+		Where("transactions.user_id = ? AND transactions.type = ? AND transactions.date >= ? AND transactions.date < ?", userID, transactionType, from, to).
+		Where("LOWER(transactions.category_id) NOT IN ?", models.NonSpendingCategories).
+		Group("1, 3, 4").
+		Having("LOWER(" + payee + ") <> ''").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	currency := models.UserCurrency(db, userID)
+	converter, err := models.NewCurrencyConverter(db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]int)
+	payees := []PayeeTotal{}
+	for _, row := range rows {
+		// Dates come back as midnight UTC; the day is in the user's time zone
+		day := time.Date(row.Day.Year(), row.Day.Month(), row.Day.Day(), 0, 0, 0, 0, cal.Location)
+		if row.Currency == "" {
+			row.Currency = currency
+		}
+		amount, err := converter.Convert(row.Amount, row.Currency, currency, day)
+		if err != nil {
+			return nil, err
+		}
+
+		i, ok := index[row.PayeeKey]
+		if !ok {
+			i = len(payees)
+			index[row.PayeeKey] = i
+			payees = append(payees, PayeeTotal{Payee: row.Payee, FirstDate: day, LastDate: day})
+		}
+		payee := &payees[i]
+		payee.Count += row.Count
+		payee.Total += amount
+		if day.Before(payee.FirstDate) {
+			payee.FirstDate = day
+		}
+		if day.After(payee.LastDate) {
+			payee.LastDate = day
+			payee.Payee = row.Payee // Show the most recent spelling
+		}
+	}
+
+	for i := range payees {
+		payees[i].Average = payees[i].Total.Div(int64(payees[i].Count))
+	}
+	sort.Slice(payees, func(i, j int) bool {
+		if payees[i].Total != payees[j].Total {
+			return payees[i].Total > payees[j].Total
+		}
+		return strings.ToLower(payees[i].Payee) < strings.ToLower(payees[j].Payee)
+	})
+	if len(payees) > limit {
+		payees = payees[:limit]
+	}
+
+	return &TopPayeesReport{Currency: currency, Type: transactionType, StartDate: from, EndDate: to, Payees: payees}, nil
+}
+
+// YearOverYearMonth compares a month's income and expenses with the same
+// month a year earlier
+type YearOverYearMonth struct {
+	Month           int          `json:"month"` // 1 to 12
+	Income          models.Money `json:"income"`
+	Expense         models.Money `json:"expense"`
+	PreviousIncome  models.Money `json:"previousIncome"`
+	PreviousExpense models.Money `json:"previousExpense"`
+	IncomeChange    *float64     `json:"incomeChange"`  // Percentage; null when there was none the year before
+	ExpenseChange   *float64     `json:"expenseChange"` // Percentage; null when there was none the year before
+}
+
+// YearOverYearReport compares a year's income and expenses with the year
+// before, month by month
+type YearOverYearReport struct {
+	Currency        string              `json:"currency"`
+	Year            int                 `json:"year"`
+	ThroughMonth    int                 `json:"throughMonth"` // Last month in the totals; earlier than 12 while the year is in progress
+	Months          []YearOverYearMonth `json:"months"`
+	TotalIncome     models.Money        `json:"totalIncome"`
+	TotalExpense    models.Money        `json:"totalExpense"`
+	PreviousIncome  models.Money        `json:"previousIncome"`
+	PreviousExpense models.Money        `json:"previousExpense"`
+	IncomeChange    *float64            `json:"incomeChange"`
+	ExpenseChange   *float64            `json:"expenseChange"`
+}
+
+// CalculateYearOverYear compares each month of the year in the user's time
+// zone with the same month of the year before. While the year is in
+// progress the totals of both years only run through the current month, so
+// that they compare like with like.
+func CalculateYearOverYear(db *gorm.DB, userID uuid.UUID, year int) (*YearOverYearReport, error) {
+	cal := UserBudgetCalendar(db, userID)
+	start := time.Date(year, time.January, 1, 0, 0, 0, 0, cal.Location)
+	previousStart := start.AddDate(-1, 0, 0)
+
+	totals, currency, err := loadReportTotals(db, userID, previousStart, start.AddDate(1, 0, 0))
+	if err != nil {
+		return nil, err
+	}
+
+	report := &YearOverYearReport{Currency: currency, Year: year, ThroughMonth: 12, Months: make([]YearOverYearMonth, 12)}
+	now := time.Now().In(cal.Location)
+	if now.Year() == year {
+		report.ThroughMonth = int(now.Month())
+	}
+	for i := range report.Months {
+		report.Months[i].Month = i + 1
+	}
+
+	for _, total := range totals {
+		month := &report.Months[total.Day.Month()-1]
+		current := total.Day.Year() == year
+		switch {
+		case total.Type == "income" && current:
+			month.Income += total.Amount
+		case total.Type == "income":
+			month.PreviousIncome += total.Amount
+		case current:
+			month.Expense += total.Amount
+		default:
+			month.PreviousExpense += total.Amount
+		}
+	}
+
+	for i := range report.Months {
+		month := &report.Months[i]
+		month.IncomeChange = percentChange(month.Income, month.PreviousIncome)
+		month.ExpenseChange = percentChange(month.Expense, month.PreviousExpense)
+		if month.Month <= report.ThroughMonth {
+			report.TotalIncome += month.Income
+			report.TotalExpense += month.Expense
+			report.PreviousIncome += month.PreviousIncome
+			report.PreviousExpense += month.PreviousExpense
+		}
+	}
+	report.IncomeChange = percentChange(report.TotalIncome, report.PreviousIncome)
+	report.ExpenseChange = percentChange(report.TotalExpense, report.PreviousExpense)
+
+	return report, nil
+}
@@ -0,0 +1,349 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"daybook-backend/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Reward redemption errors caused by the request rather than the database
+var (
+	ErrInsufficientRewards   = errors.New("not enough rewards available to redeem")
+	ErrRedemptionValueNeeded = errors.New("value is required when redeeming points or miles")
+)
+
+// EarnRewards records the reward a card purchase earns under the card's
+// rewards program: RewardRate per unit spent, times the multiplier of the
+// best active rule for the purchase's category. Once a rule's cap for the
+// period is reached, the rest of the purchase earns the base rate. It
+// returns nil when the card earns nothing on the purchase.
+func EarnRewards(tx *gorm.DB, card *models.CreditCard, purchase *models.CreditCardTransaction) (*models.Reward, error) {
+	if !models.IsValidRewardType(card.RewardType) || card.RewardRate <= 0 || purchase.Amount <= 0 {
+		return nil, nil
+	}
+	if purchase.Type != "" && purchase.Type != "purchase" {
+		return nil, nil
+	}
+
+	rule, err := matchRewardRule(tx, card, purchase.CategoryID)
+	if err != nil {
+		return nil, err
+	}
+
+	earned := purchase.Amount.Mul(card.RewardRate)
+	if rule != nil {
+		earned = earned.Mul(rule.Multiplier)
+		if rule.Cap > 0 {
+			start, end := rule.CapPeriodBounds(purchase.Date)
+			var row struct {
+				Total models.Money
+			}
+			err := tx.Model(&models.Reward{}).
+				Select("COALESCE(SUM(amount), 0) AS total").
+				Where("rule_id = ? AND earned_date >= ? AND earned_date < ?", rule.ID, start, end).
+				Scan(&row).Error
+			if err != nil {
+				return nil, err
+			}
+
+			remaining := rule.Cap - row.Total
+			if remaining < 0 {
+				remaining = 0
+			}
+			if earned > remaining {
+				earned = remaining + (earned - remaining).Mul(1/rule.Multiplier)
+			}
+		}
+	}
+
+	earned = models.RoundReward(earned, card.RewardType, card.Currency)
+	if earned <= 0 {
+		return nil, nil
+	}
+
+	description := purchase.Merchant
+	if description == "" {
+		description = purchase.Description
+	}
+	reward := models.Reward{
+		UserID:        card.UserID,
+		CardID:        card.ID,
+		Type:          card.RewardType,
+		Amount:        earned,
+		Description:   "Earned on " + description,
+		EarnedDate:    purchase.Date,
+		TransactionID: &purchase.ID,
+	}
+	if rule != nil {
+		reward.RuleID = &rule.ID
+	}
+	if card.RewardExpiryMonths > 0 {
+		expiresAt := purchase.Date.AddDate(0, card.RewardExpiryMonths, 0)
+		reward.ExpiresAt = &expiresAt
+	}
+
+	if err := tx.Create(&reward).Error; err != nil {
+		return nil, err
+	}
+	return &reward, nil
+}
+
+// matchRewardRule returns the card's active rule with the highest
+// multiplier covering the category, if any
+func matchRewardRule(tx *gorm.DB, card *models.CreditCard, categoryID string) (*models.RewardRule, error) {
+	if categoryID == "" {
+		return nil, nil
+	}
+
+	var rules []models.RewardRule
+	if err := tx.Where("card_id = ? AND active = ?", card.ID, true).Find(&rules).Error; err != nil {
+		return nil, err
+	}
+
+	var best *models.RewardRule
+	for i := range rules {
+		keys, err := models.CategoryKeysWithDescendants(tx, card.UserID, rules[i].CategoryID)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			if key == strings.ToLower(categoryID) && (best == nil || rules[i].Multiplier > best.Multiplier) {
+				best = &rules[i]
+			}
+		}
+	}
+	return best, nil
+}
+
+// RewardRedemptionRequest describes rewards to turn into money
+type RewardRedemptionRequest struct {
+	Type        string
+	Amount      models.Money // Rewards to redeem; zero redeems everything available
+	Value       models.Money // Money credited for points and miles; cashback is worth its amount
+	Method      string
+	Account     *models.Account // Receives the value with RedeemAccount
+	Description string
+}
+
+// RedeemRewards redeems the card's available rewards of one type, oldest
+// first, and credits their value to the card as a statement credit or to
+// an account as income. Cashback paid into an account in another currency
+// is converted at the rate on the day.
+func RedeemRewards(tx *gorm.DB, card *models.CreditCard, req RewardRedemptionRequest, now time.Time) (*models.RewardRedemption, error) {
+	var rewards []models.Reward
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("card_id = ? AND type = ? AND redeemed = ?", card.ID, req.Type, false).
+		Where("expires_at IS NULL OR expires_at > ?", now).
+		Order("earned_date ASC, created_at ASC").
+		Find(&rewards).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var available models.Money
+	for _, reward := range rewards {
+		available += reward.Amount - reward.RedeemedAmount
+	}
+	amount := req.Amount
+	if amount == 0 {
+		amount = available
+	}
+	if amount <= 0 || amount > available {
+		return nil, ErrInsufficientRewards
+	}
+
+	// The value is in the card's currency unless paid into an account
+	value := req.Value
+	currency := card.Currency
+	if req.Method == models.RedeemAccount {
+		currency = req.Account.Currency
+	}
+	if req.Type == models.RewardCashback {
+		value = amount
+		if currency != card.Currency {
+			converter, err := models.NewCurrencyConverter(tx, card.UserID)
+			if err != nil {
+				return nil, err
+			}
+			value, err = converter.Convert(amount, card.Currency, currency, now)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if value <= 0 {
+		return nil, ErrRedemptionValueNeeded
+	}
+
+	// Use up the oldest rewards first
+	remaining := amount
+	for i := range rewards {
+		if remaining == 0 {
+			break
+		}
+		reward := &rewards[i]
+		take := reward.Amount - reward.RedeemedAmount
+		if take > remaining {
+			take = remaining
+		}
+		remaining -= take
+
+		updates := map[string]interface{}{"redeemed_amount": reward.RedeemedAmount + take}
+		if reward.RedeemedAmount+take == reward.Amount {
+			updates["redeemed"] = true
+			updates["redeemed_at"] = now
+		}
+		if err := tx.Model(reward).UpdateColumns(updates).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	description := req.Description
+	if description == "" {
+		description = "Rewards redemption: " + card.Name
+	}
+
+	transaction := models.Transaction{
+		UserID:      card.UserID,
+		Type:        "income",
+		Amount:      value,
+		Date:        now,
+		Description: description,
+		CategoryID:  models.CategoryCreditCardRewards,
+	}
+	redemption := models.RewardRedemption{
+		UserID:      card.UserID,
+		CardID:      card.ID,
+		Type:        req.Type,
+		Amount:      amount,
+		Value:       value,
+		Method:      req.Method,
+		Description: description,
+		RedeemedAt:  now,
+	}
+
+	if req.Method == models.RedeemAccount {
+		transaction.AccountID = req.Account.ID
+		redemption.AccountID = &req.Account.ID
+	} else {
+		// A statement credit lowers what is owed like a refund
+		transaction.AccountID = card.ID
+		transaction.CreditCardID = &card.ID
+	}
+	if err := tx.Create(&transaction).Error; err != nil {
+		return nil, err
+	}
+	if err := transaction.ApplyBalance(tx); err != nil {
+		return nil, err
+	}
+	redemption.TransactionID = &transaction.ID
+
+	if req.Method == models.RedeemStatementCredit {
+		ccTransaction := models.CreditCardTransaction{
+			UserID:        card.UserID,
+			CardID:        card.ID,
+			TransactionID: transaction.ID,
+			CategoryID:    models.CategoryCreditCardRewards,
+			Amount:        value,
+			Description:   description,
+			Date:          now,
+			Type:          "reward",
+		}
+		if err := tx.Create(&ccTransaction).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Create(&redemption).Error; err != nil {
+		return nil, err
+	}
+	return &redemption, nil
+}
+
+// RewardBalance summarizes a card's rewards of one type
+type RewardBalance struct {
+	Type      string           `json:"type"`
+	Earned    models.Money     `json:"earned"`
+	Redeemed  models.Money     `json:"redeemed"`
+	Expired   models.Money     `json:"expired"`
+	Available models.Money     `json:"available"`
+	Expiring  []RewardExpiring `json:"expiring"` // Available rewards expiring within the report window
+}
+
+// RewardExpiring is the amount of rewards expiring on one day
+type RewardExpiring struct {
+	Date   time.Time    `json:"date"`
+	Amount models.Money `json:"amount"`
+}
+
+// rewardBalancesSQL totals a card's rewards per type as of @now
+const rewardBalancesSQL = `
+SELECT type,
+	COALESCE(SUM(amount), 0) AS earned,
+	COALESCE(SUM(redeemed_amount), 0) AS redeemed,
+	COALESCE(SUM(CASE WHEN expires_at <= @now THEN amount - redeemed_amount ELSE 0 END), 0) AS expired,
+	COALESCE(SUM(CASE WHEN expires_at IS NULL OR expires_at > @now THEN amount - redeemed_amount ELSE 0 END), 0) AS available
+FROM rewards
+WHERE card_id = @card AND deleted_at IS NULL
+GROUP BY type
+ORDER BY type`
+
+// rewardExpiringSQL lists the unredeemed rewards expiring in (@now, @until]
+// per type and day
+const rewardExpiringSQL = `
+SELECT type, (expires_at AT TIME ZONE 'UTC')::date AS date, SUM(amount - redeemed_amount) AS amount
+FROM rewards
+WHERE card_id = @card AND deleted_at IS NULL AND redeemed = false
+	AND expires_at > @now AND expires_at <= @until
+GROUP BY 1, 2
+HAVING SUM(amount - redeemed_amount) > 0
+ORDER BY 2`
+
+// RewardBalances reports the card's earned, redeemed, expired and available
+// rewards per type, with what expires in the next days days
+func RewardBalances(db *gorm.DB, cardID uuid.UUID, now time.Time, days int) ([]RewardBalance, error) {
+	args := map[string]interface{}{"card": cardID, "now": now, "until": now.AddDate(0, 0, days)}
+	var totals []struct {
+		Type      string
+		Earned    models.Money
+		Redeemed  models.Money
+		Expired   models.Money
+		Available models.Money
+	}
+	if err := db.Raw(rewardBalancesSQL, args).Scan(&totals).Error; err != nil {
+		return nil, err
+	}
+
+	var expiring []struct {
+		Type   string
+		Date   time.Time
+		Amount models.Money
+	}
+	if err := db.Raw(rewardExpiringSQL, args).Scan(&expiring).Error; err != nil {
+		return nil, err
+	}
+
+	balances := make([]RewardBalance, len(totals))
+	for i, total := range totals {
+		balances[i] = RewardBalance{
+			Type:      total.Type,
+			Earned:    total.Earned,
+			Redeemed:  total.Redeemed,
+			Expired:   total.Expired,
+			Available: total.Available,
+			Expiring:  []RewardExpiring{},
+		}
+		for _, row := range expiring {
+			if row.Type == total.Type {
+				balances[i].Expiring = append(balances[i].Expiring, RewardExpiring{Date: row.Date, Amount: row.Amount})
+			}
+		}
+	}
+	return balances, nil
+}
@@ -0,0 +1,361 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"daybook-backend/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RuleEngine applies a user's transaction rules
+type RuleEngine struct {
+	rules      []compiledRule
+	categories map[string]models.Category // By lowercased key
+}
+
+type compiledRule struct {
+	rule    models.TransactionRule
+	pattern *regexp.Regexp
+}
+
+// CompileRulePattern compiles a rule's description pattern, which matches
+// case-insensitively
+func CompileRulePattern(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("(?i)" + pattern)
+}
+
+// LoadRuleEngine loads the user's active rules in the order they run
+func LoadRuleEngine(db *gorm.DB, userID uuid.UUID) (*RuleEngine, error) {
+	var rules []models.TransactionRule
+	if err := db.Where("user_id = ? AND active = ?", userID, true).
+		Order("priority ASC, created_at ASC").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	return NewRuleEngine(db, userID, rules)
+}
+
+// NewRuleEngine builds an engine running the given rules in order, such as
+// a single rule being tried out
+func NewRuleEngine(db *gorm.DB, userID uuid.UUID, rules []models.TransactionRule) (*RuleEngine, error) {
+	engine := &RuleEngine{categories: make(map[string]models.Category)}
+	for _, rule := range rules {
+		compiled := compiledRule{rule: rule}
+		if rule.DescriptionPattern != "" {
+			pattern, err := CompileRulePattern(rule.DescriptionPattern)
+			if err != nil {
+				return nil, fmt.Errorf("rule %s: %w", rule.Name, err)
+			}
+			compiled.pattern = pattern
+		}
+		engine.rules = append(engine.rules, compiled)
+	}
+	if len(rules) == 0 {
+		return engine, nil
+	}
+
+	var categories []models.Category
+	if err := db.Where("user_id = ?", userID).Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	for _, category := range categories {
+		engine.categories[strings.ToLower(category.Key)] = category
+	}
+	return engine, nil
+}
+
+// Apply runs the rules on a transaction that is about to be saved and
+// returns the IDs of the rules that matched. Merchant is the card merchant
+// of a card purchase, if any. Transactions booked in system categories,
+// such as opening balances or goal movements, are left alone, and split
+// transactions keep their category. A nil engine runs no rules.
+func (e *RuleEngine) Apply(t *models.Transaction, merchant string) []uuid.UUID {
+	if e == nil || len(e.rules) == 0 || t.Type == "tracking" {
+		return nil
+	}
+	if category, ok := e.categories[strings.ToLower(t.CategoryID)]; ok && category.IsSystem && t.Type != "transfer" {
+		return nil
+	}
+
+	var matched []uuid.UUID
+	for _, compiled := range e.rules {
+		source, ok := compiled.matches(t, merchant)
+		if !ok {
+			continue
+		}
+		matched = append(matched, compiled.rule.ID)
+		e.applyActions(&compiled, t, source)
+		if compiled.rule.StopProcessing {
+			break
+		}
+	}
+	return matched
+}
+
+// matches checks the rule's conditions and returns the text its pattern
+// matched, for expanding the new description
+func (r *compiledRule) matches(t *models.Transaction, merchant string) (string, bool) {
+	rule := &r.rule
+	if rule.Type != "" && rule.Type != t.Type {
+		return "", false
+	}
+	if rule.AccountID != nil && *rule.AccountID != t.AccountID &&
+		(t.CreditCardID == nil || *rule.AccountID != *t.CreditCardID) {
+		return "", false
+	}
+	if rule.MinAmount != nil && t.Amount < *rule.MinAmount {
+		return "", false
+	}
+	if rule.MaxAmount != nil && t.Amount > *rule.MaxAmount {
+		return "", false
+	}
+	if r.pattern == nil {
+		return "", true
+	}
+	for _, text := range []string{t.Description, merchant} {
+		if text != "" && r.pattern.MatchString(text) {
+			return text, true
+		}
+	}
+	return "", false
+}
+
+func (e *RuleEngine) applyActions(r *compiledRule, t *models.Transaction, source string) {
+	rule := &r.rule
+	categorizable := (t.Type == "income" || t.Type == "expense") && len(t.Splits) == 0
+
+	if rule.SetCategoryID != "" && categorizable {
+		if category, ok := e.categories[strings.ToLower(rule.SetCategoryID)]; ok && category.Kind == t.Type {
+			t.CategoryID = category.Key
+		}
+	}
+
+	for _, tag := range rule.Tags {
+		if !slices.Contains(t.Tags, tag) {
+			t.Tags = append(t.Tags, tag)
+		}
+	}
+
+	if rule.SetDescription != "" {
+		if r.pattern != nil && source != "" {
+			var expanded []byte
+			for _, submatches := range r.pattern.FindAllStringSubmatchIndex(source, 1) {
+				expanded = r.pattern.ExpandString(expanded, rule.SetDescription, source, submatches)
+			}
+			t.Description = string(expanded)
+		} else {
+			t.Description = rule.SetDescription
+		}
+	}
+
+	// Card purchases and reconciled transactions keep moving the same
+	// balance, so they cannot become transfers
+	if rule.TransferAccountID != nil && categorizable && t.CreditCardID == nil && !t.Reconciled &&
+		*rule.TransferAccountID != t.AccountID {
+		t.MarkAsTransfer(*rule.TransferAccountID)
+	}
+}
+
+// resolveRuleTransfer checks that both accounts of a transaction a rule
+// turned into a transfer belong to the user, and converts the amount
+// between their currencies
+func resolveRuleTransfer(tx *gorm.DB, t *models.Transaction) error {
+	var from, to models.Account
+	if err := tx.Where("id = ? AND user_id = ?", t.AccountID, t.UserID).First(&from).Error; err != nil {
+		return fmt.Errorf("transfer account %s: %w", t.AccountID, err)
+	}
+	if err := tx.Where("id = ? AND user_id = ?", *t.ToAccountID, t.UserID).First(&to).Error; err != nil {
+		return fmt.Errorf("transfer account %s: %w", *t.ToAccountID, err)
+	}
+	return t.ResolveMarkedTransferAmounts(tx, from.Currency, to.Currency)
+}
+
+// RuleHistoryFilter narrows the existing transactions rules are run on
+type RuleHistoryFilter struct {
+	StartDate *time.Time
+	EndDate   *time.Time
+	AccountID *uuid.UUID // Matches the account or credit card
+}
+
+// RuleMatch is an existing transaction the rules would change
+type RuleMatch struct {
+	TransactionID uuid.UUID              `json:"transactionId"`
+	Date          time.Time              `json:"date"`
+	Amount        models.Money           `json:"amount"`
+	Description   string                 `json:"description"` // Before the rules ran
+	RuleIDs       []uuid.UUID            `json:"ruleIds"`
+	Changes       map[string]interface{} `json:"changes"` // New value of every field the rules change
+}
+
+// RuleRun summarizes running rules over existing transactions
+type RuleRun struct {
+	Scanned int         `json:"scanned"`
+	Matched int         `json:"matched"` // Transactions matched by at least one rule
+	Changed int         `json:"changed"` // Matched transactions the rules change
+	Matches []RuleMatch `json:"matches"` // Changed transactions, up to the requested limit
+}
+
+// TestRules runs the rules over the user's existing transactions without
+// saving anything and reports what they would change, listing at most limit
+// of the changed transactions
+func TestRules(db *gorm.DB, userID uuid.UUID, engine *RuleEngine, filter RuleHistoryFilter, limit int) (*RuleRun, error) {
+	return runRulesOnHistory(db, userID, engine, filter, limit, nil)
+}
+
+// ApplyRulesToHistory runs the rules over the user's existing transactions
+// and saves every change, rebooking the journal of each changed transaction.
+// Card purchases keep their card record in step. Call it in a database
+// transaction so that a failure leaves everything unchanged.
+func ApplyRulesToHistory(tx *gorm.DB, userID uuid.UUID, engine *RuleEngine, filter RuleHistoryFilter, limit int) (*RuleRun, error) {
+	return runRulesOnHistory(tx, userID, engine, filter, limit, func(before, after *models.Transaction) error {
+		if after.Type == "transfer" && before.Type != "transfer" {
+			if err := resolveRuleTransfer(tx, after); err != nil {
+				return err
+			}
+		}
+
+		if err := before.RevertBalance(tx); err != nil {
+			return err
+		}
+		if err := tx.Save(after).Error; err != nil {
+			return err
+		}
+		if err := after.ApplyBalance(tx); err != nil {
+			return err
+		}
+
+		if after.CreditCardID != nil {
+			return tx.Model(&models.CreditCardTransaction{}).Where("transaction_id = ?", after.ID).
+				Select("category_id", "description", "tags").
+				Updates(&models.CreditCardTransaction{CategoryID: after.CategoryID, Description: after.Description, Tags: after.Tags}).Error
+		}
+		return nil
+	})
+}
+
+// runRulesOnHistory runs the rules over the user's transactions in batches
+// and calls save, if given, with every transaction they change
+func runRulesOnHistory(db *gorm.DB, userID uuid.UUID, engine *RuleEngine, filter RuleHistoryFilter, limit int, save func(before, after *models.Transaction) error) (*RuleRun, error) {
+	run := &RuleRun{Matches: []RuleMatch{}}
+
+	// Movements booked by goals, deposits, investments and card payments are
+	// managed by their own features
+	query := db.Where("user_id = ? AND type != ?", userID, "tracking").
+		Where("savings_goal_id IS NULL AND fixed_deposit_id IS NULL AND investment_id IS NULL").
+		Where("credit_card_id IS NULL OR credit_card_id = account_id")
+	if filter.StartDate != nil {
+		query = query.Where("date >= ?", *filter.StartDate)
+	}
+	if filter.EndDate != nil {
+		query = query.Where("date <= ?", *filter.EndDate)
+	}
+	if filter.AccountID != nil {
+		query = query.Where("account_id = ? OR credit_card_id = ?", *filter.AccountID, *filter.AccountID)
+	}
+
+	var transactions []models.Transaction
+	result := query.FindInBatches(&transactions, 500, func(batch *gorm.DB, _ int) error {
+		if err := models.LoadTransactionSplits(db, transactions); err != nil {
+			return err
+		}
+		merchants, err := cardMerchants(db, transactions)
+		if err != nil {
+			return err
+		}
+
+		for i := range transactions {
+			run.Scanned++
+			before := transactions[i]
+			after := transactions[i]
+			after.Tags = append([]string(nil), before.Tags...)
+
+			ruleIDs := engine.Apply(&after, merchants[before.ID])
+			if len(ruleIDs) == 0 {
+				continue
+			}
+			run.Matched++
+
+			changes := transactionChanges(&before, &after)
+			if len(changes) == 0 {
+				continue
+			}
+			run.Changed++
+			if len(run.Matches) < limit {
+				run.Matches = append(run.Matches, RuleMatch{
+					TransactionID: before.ID,
+					Date:          before.Date,
+					Amount:        before.Amount,
+					Description:   before.Description,
+					RuleIDs:       ruleIDs,
+					Changes:       changes,
+				})
+			}
+
+			if save != nil {
+				if err := save(&before, &after); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return run, nil
+}
+
+// cardMerchants returns the merchant of every card purchase among the
+// transactions, by transaction ID
+func cardMerchants(db *gorm.DB, transactions []models.Transaction) (map[uuid.UUID]string, error) {
+	var ids []uuid.UUID
+	for _, t := range transactions {
+		if t.CreditCardID != nil {
+			ids = append(ids, t.ID)
+		}
+	}
+	merchants := make(map[uuid.UUID]string)
+	if len(ids) == 0 {
+		return merchants, nil
+	}
+
+	var records []models.CreditCardTransaction
+	if err := db.Select("transaction_id, merchant").Where("transaction_id IN ?", ids).Find(&records).Error; err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		merchants[record.TransactionID] = record.Merchant
+	}
+	return merchants, nil
+}
+
+// transactionChanges lists the fields rules can change that differ between
+// two versions of a transaction, keyed by their JSON names
+func transactionChanges(before, after *models.Transaction) map[string]interface{} {
+	changes := make(map[string]interface{})
+	if before.Type != after.Type {
+		changes["type"] = after.Type
+	}
+	if before.AccountID != after.AccountID {
+		changes["accountId"] = after.AccountID
+	}
+	if (before.ToAccountID == nil) != (after.ToAccountID == nil) ||
+		(before.ToAccountID != nil && *before.ToAccountID != *after.ToAccountID) {
+		changes["toAccountId"] = after.ToAccountID
+	}
+	if before.CategoryID != after.CategoryID {
+		changes["categoryId"] = after.CategoryID
+	}
+	if before.Description != after.Description {
+		changes["description"] = after.Description
+	}
+	if len(before.Tags) != len(after.Tags) {
+		changes["tags"] = after.Tags
+	}
+	return changes
+}
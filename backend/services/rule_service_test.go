@@ -0,0 +1,150 @@
+package services
+
+import (
+	"database/sql/driver"
+	"slices"
+	"testing"
+
+	"daybook-backend/models"
+
+	"github.com/google/uuid"
+)
+
+func TestRuleEngineApply(t *testing.T) {
+	userID, checkingID, savingsID := uuid.New(), uuid.New(), uuid.New()
+	db := stubDB(t, []string{"id", "user_id", "key", "kind", "is_system"},
+		[]driver.Value{uuid.New().String(), userID.String(), "groceries", models.CategoryKindExpense, false},
+		[]driver.Value{uuid.New().String(), userID.String(), "salary", models.CategoryKindIncome, false},
+		[]driver.Value{uuid.New().String(), userID.String(), models.CategoryOpeningBalance, models.CategoryKindIncome, true},
+	)
+
+	minSalary := models.Money(10000000)
+	rules := []models.TransactionRule{
+		{ID: uuid.New(), Name: "Card shops", DescriptionPattern: `^sq \*(?P<shop>.+?)\s*#\d+$`, SetDescription: "${shop}", SetCategoryID: "Groceries", Tags: []string{"card"}},
+		{ID: uuid.New(), Name: "Payroll", Type: "income", MinAmount: &minSalary, SetCategoryID: "salary"},
+		{ID: uuid.New(), Name: "Wrong kind", DescriptionPattern: "payroll", SetCategoryID: "groceries", StopProcessing: true},
+		{ID: uuid.New(), Name: "Savings", DescriptionPattern: "savings", TransferAccountID: &savingsID, Tags: []string{"moved"}},
+	}
+	engine, err := NewRuleEngine(db, userID, rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		transaction models.Transaction
+		matched     []uuid.UUID
+		want        func(t *testing.T, got *models.Transaction)
+	}{
+		{
+			name:        "pattern groups expand into the description",
+			transaction: models.Transaction{Type: "expense", AccountID: checkingID, Amount: 250000, CategoryID: "other_expense", Description: "SQ *Corner Shop #1234"},
+			matched:     []uuid.UUID{rules[0].ID},
+			want: func(t *testing.T, got *models.Transaction) {
+				if got.Description != "Corner Shop" || got.CategoryID != "groceries" || !slices.Equal(got.Tags, []string{"card"}) {
+					t.Errorf("got %q %q %v", got.Description, got.CategoryID, got.Tags)
+				}
+			},
+		},
+		{
+			name:        "category of another kind is not set and stop processing ends the run",
+			transaction: models.Transaction{Type: "income", AccountID: checkingID, Amount: 50000000, CategoryID: "other_income", Description: "ACME payroll savings plan"},
+			matched:     []uuid.UUID{rules[1].ID, rules[2].ID},
+			want: func(t *testing.T, got *models.Transaction) {
+				if got.CategoryID != "salary" || got.Type != "income" {
+					t.Errorf("got %s %s, want an income in salary", got.Type, got.CategoryID)
+				}
+			},
+		},
+		{
+			name:        "below the minimum amount",
+			transaction: models.Transaction{Type: "income", AccountID: checkingID, Amount: 5000000, CategoryID: "other_income", Description: "Refund"},
+			want: func(t *testing.T, got *models.Transaction) {
+				if got.CategoryID != "other_income" {
+					t.Errorf("category = %s, want other_income", got.CategoryID)
+				}
+			},
+		},
+		{
+			name:        "system categories are left alone",
+			transaction: models.Transaction{Type: "income", AccountID: checkingID, Amount: 50000000, CategoryID: "Opening_Balance", Description: "Opening savings"},
+			want: func(t *testing.T, got *models.Transaction) {
+				if got.Type != "income" || got.Tags != nil {
+					t.Errorf("got %s %v, want an untouched income", got.Type, got.Tags)
+				}
+			},
+		},
+		{
+			name:        "money spent goes to the transfer account",
+			transaction: models.Transaction{Type: "expense", AccountID: checkingID, Amount: 1000000, CategoryID: "other_expense", Description: "To savings"},
+			matched:     []uuid.UUID{rules[3].ID},
+			want: func(t *testing.T, got *models.Transaction) {
+				if got.Type != "transfer" || got.CategoryID != models.CategoryTransfer || got.AccountID != checkingID ||
+					got.ToAccountID == nil || *got.ToAccountID != savingsID || got.ToAmount != nil {
+					t.Errorf("got %s from %s to %v, toAmount %v", got.Type, got.AccountID, got.ToAccountID, got.ToAmount)
+				}
+			},
+		},
+		{
+			name:        "money received comes from the transfer account",
+			transaction: models.Transaction{Type: "income", AccountID: checkingID, Amount: 1000000, CategoryID: "other_income", Description: "From savings"},
+			matched:     []uuid.UUID{rules[3].ID},
+			want: func(t *testing.T, got *models.Transaction) {
+				if got.Type != "transfer" || got.AccountID != savingsID || got.ToAccountID == nil || *got.ToAccountID != checkingID {
+					t.Errorf("got %s from %s to %v", got.Type, got.AccountID, got.ToAccountID)
+				}
+				if got.ToAmount == nil || *got.ToAmount != 1000000 {
+					t.Errorf("toAmount = %v, want the amount received", got.ToAmount)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.transaction
+			if matched := engine.Apply(&got, ""); !slices.Equal(matched, tt.matched) {
+				t.Errorf("matched %v, want %v", matched, tt.matched)
+			}
+			tt.want(t, &got)
+		})
+	}
+}
+
+func TestResolveMarkedTransferAmounts(t *testing.T) {
+	userID, usdID, bdtID := uuid.New(), uuid.New(), uuid.New()
+	db := stubDB(t, []string{"base_currency", "quote_currency", "rate", "date"},
+		[]driver.Value{"USD", "BDT", "120.0000000000", utcDate(2024, 1, 1)},
+	)
+
+	// 12,000 BDT received in the BDT account from the USD account
+	received := models.Transaction{UserID: userID, Type: "income", AccountID: bdtID, Amount: 120000000, Date: utcDate(2024, 3, 1)}
+	received.MarkAsTransfer(usdID)
+	if err := received.ResolveMarkedTransferAmounts(db, "USD", "BDT"); err != nil {
+		t.Fatal(err)
+	}
+	if received.AccountID != usdID || received.Amount != 1000000 || *received.ToAmount != 120000000 || *received.ExchangeRate != 120 {
+		t.Errorf("received: from %s amount %v, toAmount %v, rate %v; want 100.00 USD sent for 12000.00 BDT at 120",
+			received.AccountID, received.Amount, *received.ToAmount, *received.ExchangeRate)
+	}
+
+	// 100 USD spent from the USD account into the BDT account
+	spent := models.Transaction{UserID: userID, Type: "expense", AccountID: usdID, Amount: 1000000, Date: utcDate(2024, 3, 1)}
+	spent.MarkAsTransfer(bdtID)
+	if err := spent.ResolveMarkedTransferAmounts(db, "USD", "BDT"); err != nil {
+		t.Fatal(err)
+	}
+	if spent.Amount != 1000000 || spent.ToAmount == nil || *spent.ToAmount != 120000000 {
+		t.Errorf("spent: amount %v, toAmount %v; want 100.00 USD sent for 12000.00 BDT", spent.Amount, spent.ToAmount)
+	}
+
+	// Between accounts of the same currency the amount is kept as is
+	same := models.Transaction{UserID: userID, Type: "income", AccountID: bdtID, Amount: 120000000, Date: utcDate(2024, 3, 1)}
+	same.MarkAsTransfer(uuid.New())
+	if err := same.ResolveMarkedTransferAmounts(db, "BDT", "BDT"); err != nil {
+		t.Fatal(err)
+	}
+	if same.Amount != 120000000 || same.ToAmount != nil || same.ExchangeRate != nil {
+		t.Errorf("same currency: amount %v, toAmount %v, rate %v", same.Amount, same.ToAmount, same.ExchangeRate)
+	}
+}
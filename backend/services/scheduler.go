@@ -0,0 +1,80 @@
+package services
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Job is a unit of background work executed on every scheduler tick
+type Job struct {
+	Name string
+	Run  func(db *gorm.DB, now time.Time) error
+}
+
+// Jobs lists the background jobs run by the scheduler, in order
+var Jobs = []Job{
+	{Name: "recurring_transactions", Run: ProcessRecurringTransactions},
+	{Name: "credit_card_statements", Run: CloseBillingCycles},
+	{Name: "bill_autopay", Run: ProcessBillAutoPays},
+	{Name: "notifications", Run: ProcessNotifications},
+	{Name: "net_worth_snapshots", Run: SnapshotNetWorth},
+}
+
+var (
+	stopScheduler chan struct{}
+	schedulerWG   sync.WaitGroup
+)
+
+// StartScheduler runs all jobs immediately and then once every interval until
+// StopScheduler is called. Running on start lets the jobs catch up on
+// anything that became due while the server was down.
+func StartScheduler(db *gorm.DB, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	stopScheduler = make(chan struct{})
+	schedulerWG.Add(1)
+
+	go func() {
+		defer schedulerWG.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		RunJobs(db, time.Now())
+		for {
+			select {
+			case <-ticker.C:
+				RunJobs(db, time.Now())
+			case <-stopScheduler:
+				return
+			}
+		}
+	}()
+
+	log.Printf("Scheduler started (interval: %s)", interval)
+}
+
+// StopScheduler stops the scheduler and waits for a running tick to finish
+func StopScheduler() {
+	if stopScheduler == nil {
+		return
+	}
+	close(stopScheduler)
+	schedulerWG.Wait()
+	stopScheduler = nil
+}
+
+// RunJobs executes every job once. A failing job is logged and does not
+// prevent the remaining jobs from running.
+func RunJobs(db *gorm.DB, now time.Time) {
+	for _, job := range Jobs {
+		if err := job.Run(db, now); err != nil {
+			log.Printf("Scheduler job %s failed: %v", job.Name, err)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+	"unicode"
+
+	"daybook-backend/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// The text search documents of descriptions, card merchants and split memos.
+// They match the expressions of the GIN indexes on those columns, so keep
+// the two in step.
+const (
+	descriptionDocument = "to_tsvector('simple', COALESCE(transactions.description, ''))"
+	merchantDocument    = "to_tsvector('simple', COALESCE(credit_card_transactions.merchant, ''))"
+	memoDocument        = "to_tsvector('simple', COALESCE(transaction_splits.memo, ''))"
+)
+
+// SearchTransactions finds the user's transactions matching the search and
+// returns one page of them in the search's order, with the total number of
+// matches
+func SearchTransactions(db *gorm.DB, userID uuid.UUID, search models.TransactionSearch, page, limit int) ([]models.Transaction, int64, error) {
+	query, err := transactionSearchQuery(db, userID, search)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Model(&models.Transaction{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var transactions []models.Transaction
+	err = query.Order(transactionSearchOrder(search)).
+		Limit(limit).Offset((page - 1) * limit).
+		Find(&transactions).Error
+	return transactions, total, err
+}
+
+// transactionSearchQuery builds the conditions of a search on the user's
+// transactions. Dates are days in the user's time zone.
+func transactionSearchQuery(db *gorm.DB, userID uuid.UUID, search models.TransactionSearch) (*gorm.DB, error) {
+	query := db.Model(&models.Transaction{}).Where("transactions.user_id = ?", userID)
+
+	if !search.IncludeTracking {
+		query = query.Where("transactions.type != ?", "tracking")
+	}
+	if len(search.Types) > 0 {
+		query = query.Where("transactions.type IN ?", search.Types)
+	}
+
+	if terms := textSearchQuery(search.Text); terms != "" {
+		query = query.Where(
+			descriptionDocument+" @@ to_tsquery('simple', ?)"+
+				" OR EXISTS (SELECT 1 FROM credit_card_transactions WHERE credit_card_transactions.transaction_id = transactions.id AND credit_card_transactions.deleted_at IS NULL AND "+merchantDocument+" @@ to_tsquery('simple', ?))"+
+				" OR EXISTS (SELECT 1 FROM transaction_splits WHERE transaction_splits.transaction_id = transactions.id AND transaction_splits.deleted_at IS NULL AND "+memoDocument+" @@ to_tsquery('simple', ?))",
+			terms, terms, terms)
+	}
+
+	if len(search.CategoryIDs) > 0 {
+		var categories []models.Category
+		if err := db.Where("user_id = ?", userID).Find(&categories).Error; err != nil {
+			return nil, err
+		}
+		var keys []string
+		for _, categoryID := range search.CategoryIDs {
+			keys = append(keys, models.CategoryDescendantKeys(categories, categoryID)...)
+		}
+		query = query.Where("LOWER(transactions.category_id) IN ? OR EXISTS (SELECT 1 FROM transaction_splits WHERE transaction_splits.transaction_id = transactions.id AND LOWER(transaction_splits.category_id) IN ? AND transaction_splits.deleted_at IS NULL)", keys, keys)
+	}
+
+	if len(search.AccountIDs) > 0 {
+		query = query.Where("transactions.account_id IN ? OR transactions.to_account_id IN ?", search.AccountIDs, search.AccountIDs)
+	}
+
+	if search.MinAmount != nil {
+		query = query.Where("transactions.amount >= ?", *search.MinAmount)
+	}
+	if search.MaxAmount != nil {
+		query = query.Where("transactions.amount <= ?", *search.MaxAmount)
+	}
+
+	if search.StartDate != "" || search.EndDate != "" {
+		settings := models.UserSettings(db, userID)
+		location := settings.Location()
+		if search.StartDate != "" {
+			startDate, err := time.ParseInLocation("2006-01-02", search.StartDate, location)
+			if err != nil {
+				return nil, err
+			}
+			query = query.Where("transactions.date >= ?", startDate)
+		}
+		if search.EndDate != "" {
+			endDate, err := time.ParseInLocation("2006-01-02", search.EndDate, location)
+			if err != nil {
+				return nil, err
+			}
+			query = query.Where("transactions.date < ?", endDate.AddDate(0, 0, 1))
+		}
+	}
+
+	if len(search.AllTags) > 0 {
+		encoded, _ := json.Marshal(search.AllTags)
+		query = query.Where("transactions.tags @> ?::jsonb", string(encoded))
+	}
+	if len(search.AnyTags) > 0 {
+		query = query.Where("jsonb_typeof(transactions.tags) = 'array' AND EXISTS (SELECT 1 FROM jsonb_array_elements_text(transactions.tags) AS e(tag) WHERE e.tag IN ?)", search.AnyTags)
+	}
+	if len(search.NoTags) > 0 {
+		query = query.Where("NOT (jsonb_typeof(transactions.tags) = 'array' AND EXISTS (SELECT 1 FROM jsonb_array_elements_text(transactions.tags) AS e(tag) WHERE e.tag IN ?))", search.NoTags)
+	}
+
+	if search.Reconciled != nil {
+		query = query.Where("transactions.reconciled = ?", *search.Reconciled)
+	}
+	if search.HasAttachments != nil {
+		hasAttachments := "CASE WHEN jsonb_typeof(transactions.attachments) = 'array' THEN jsonb_array_length(transactions.attachments) > 0 ELSE false END"
+		if *search.HasAttachments {
+			query = query.Where(hasAttachments)
+		} else {
+			query = query.Where("NOT " + hasAttachments)
+		}
+	}
+
+	switch search.Source {
+	case "account":
+		query = query.Where("transactions.credit_card_id IS NULL")
+	case "credit_card":
+		query = query.Where("transactions.credit_card_id IS NOT NULL")
+	}
+
+	return query, nil
+}
+
+// transactionSearchOrder orders search results by the search's sort field,
+// breaking ties by the newest first so that pages are stable
+func transactionSearchOrder(search models.TransactionSearch) clause.OrderBy {
+	order := search.Order
+	if order == "" {
+		order = "DESC"
+		if search.Sort == "description" {
+			order = "ASC"
+		}
+	}
+	order = strings.ToUpper(order)
+
+	var expression clause.Expr
+	switch search.Sort {
+	case "amount":
+		expression = clause.Expr{SQL: "transactions.amount " + order}
+	case "description":
+		expression = clause.Expr{SQL: "LOWER(transactions.description) " + order}
+	case "date":
+		expression = clause.Expr{SQL: "transactions.date " + order + ", transactions.created_at " + order}
+	default:
+		// Relevance, the default for text searches
+		if terms := textSearchQuery(search.Text); terms != "" {
+			expression = clause.Expr{SQL: "ts_rank(" + descriptionDocument + ", to_tsquery('simple', ?)) " + order, Vars: []interface{}{terms}}
+		} else {
+			expression = clause.Expr{SQL: "transactions.date " + order + ", transactions.created_at " + order}
+		}
+	}
+	expression.SQL += ", transactions.date DESC, transactions.created_at DESC, transactions.id DESC"
+	return clause.OrderBy{Expression: expression}
+}
+
+// textSearchQuery turns free text into a tsquery matching every word, each
+// also as a prefix, so "plumb" finds "Plumber". Punctuation separates words
+// and is dropped, leaving nothing the tsquery syntax would interpret.
+func textSearchQuery(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, word := range words {
+		words[i] = word + ":*"
+	}
+	return strings.Join(words, " & ")
+}
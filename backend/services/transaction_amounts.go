@@ -0,0 +1,64 @@
+package services
+
+import (
+	"time"
+
+	"daybook-backend/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// A transactions query joined with splitsJoin has one row per split, or one
+// row for a transaction without splits. splitCategory and splitAmount select
+// the category and amount of each row.
+const (
+	splitsJoin    = "LEFT JOIN transaction_splits ON transaction_splits.transaction_id = transactions.id AND transaction_splits.deleted_at IS NULL"
+	splitCategory = "COALESCE(transaction_splits.category_id, transactions.category_id)"
+	splitAmount   = "COALESCE(transaction_splits.amount, transactions.amount)"
+)
+
+// TransactionAmount is a transaction amount attributed to one category,
+// together with the currency of its account or credit card. A split
+// transaction has one amount per split.
+type TransactionAmount struct {
+	TransactionID uuid.UUID
+	Type          string
+	CategoryID    string
+	Amount        models.Money
+	Date          time.Time
+	Currency      string
+}
+
+// LoadTransactionAmounts runs a query on transactions and returns the amount
+// of each split, or of the whole transaction when it has none, with its
+// account or credit card currency. Conditions on the query must be
+// qualified with the transactions table.
+func LoadTransactionAmounts(query *gorm.DB) ([]TransactionAmount, error) {
+	var rows []TransactionAmount
+	err := query.
+		Select("transactions.id AS transaction_id, transactions.type, " + splitCategory + " AS category_id, " + splitAmount + " AS amount, transactions.date, COALESCE(credit_cards.currency, accounts.currency, '') AS currency").
+		Joins(splitsJoin).
+		Joins("LEFT JOIN accounts ON accounts.id = transactions.account_id").
+		Joins("LEFT JOIN credit_cards ON credit_cards.id = transactions.credit_card_id").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// ConvertTransactionAmounts converts every amount to the target currency as
+// of its transaction date. Amounts without a known currency are taken to be
+// in the target currency already.
+func ConvertTransactionAmounts(converter *models.CurrencyConverter, rows []TransactionAmount, currency string) error {
+	for i := range rows {
+		if rows[i].Currency == "" {
+			rows[i].Currency = currency
+		}
+		converted, err := converter.Convert(rows[i].Amount, rows[i].Currency, currency, rows[i].Date)
+		if err != nil {
+			return err
+		}
+		rows[i].Amount = converted
+		rows[i].Currency = currency
+	}
+	return nil
+}